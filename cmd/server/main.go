@@ -47,6 +47,9 @@ import (
 // @tag.name Traces
 // @tag.description Trace lookup endpoints
 
+// @tag.name Admin
+// @tag.description Baseline snapshot export and import
+
 func applySwaggerEnvOverrides() {
 	if docs.SwaggerInfo == nil {
 		return
@@ -89,6 +92,18 @@ func main() {
 		log.Fatalf("load config: %v", err)
 	}
 
+	if args := flag.Args(); len(args) > 0 {
+		if args[0] != "snapshot" {
+			log.Fatalf("unknown command %q", args[0])
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		if err := runSnapshot(ctx, cfg, args[1:]); err != nil {
+			log.Fatalf("snapshot: %v", err)
+		}
+		return
+	}
+
 	a, err := app.New(cfg)
 	if err != nil {
 		log.Fatalf("init app: %v", err)
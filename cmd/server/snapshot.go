@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/app"
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/config"
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/service"
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+const snapshotUsage = `usage:
+  server [-config file] snapshot export [-tenant name] [-samples] [-out file]
+  server [-config file] snapshot import [-tenant name] [-mode merge|overwrite] [-in file]`
+
+// runSnapshot implements the "snapshot" subcommand, which exports or imports
+// baselines directly against the configured store.
+func runSnapshot(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing snapshot command\n%s", snapshotUsage)
+	}
+	if cfg.Store.Backend == config.StoreBackendMemory {
+		return fmt.Errorf("the memory store only lives inside the server process; use the /v1/admin/snapshot endpoints instead")
+	}
+
+	switch args[0] {
+	case "export":
+		fs := flag.NewFlagSet("snapshot export", flag.ContinueOnError)
+		includeSamples := fs.Bool("samples", false, "include raw duration windows")
+		out := fs.String("out", "", "output file (default stdout)")
+		tenant := fs.String("tenant", cfg.Tenancy.DefaultTenant, "tenant to export")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		ctx, err := tenantContext(ctx, cfg, *tenant)
+		if err != nil {
+			return err
+		}
+		return withSnapshot(cfg, func(svc *service.Snapshot) error {
+			var w io.Writer = os.Stdout
+			if *out != "" {
+				f, err := os.Create(*out)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+			st, err := svc.Export(ctx, w, *includeSamples)
+			if err != nil {
+				return err
+			}
+			log.Printf("exported %d baselines, %d windows (%d samples)", st.Baselines, st.Windows, st.Samples)
+			return nil
+		})
+	case "import":
+		fs := flag.NewFlagSet("snapshot import", flag.ContinueOnError)
+		modeStr := fs.String("mode", string(service.SnapshotMerge), "merge or overwrite")
+		in := fs.String("in", "", "input file (default stdin)")
+		tenant := fs.String("tenant", cfg.Tenancy.DefaultTenant, "tenant to import into")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		ctx, err := tenantContext(ctx, cfg, *tenant)
+		if err != nil {
+			return err
+		}
+		mode, err := service.ParseSnapshotMode(*modeStr)
+		if err != nil {
+			return err
+		}
+		return withSnapshot(cfg, func(svc *service.Snapshot) error {
+			var r io.Reader = os.Stdin
+			if *in != "" {
+				f, err := os.Open(*in)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				r = f
+			}
+			st, err := svc.Import(ctx, r, mode)
+			log.Printf("imported %d baselines (%d skipped), %d windows (%d samples); %d keys marked dirty",
+				st.Baselines, st.Skipped, st.Windows, st.Samples, st.Dirty)
+			return err
+		})
+	default:
+		return fmt.Errorf("unknown snapshot command %q\n%s", args[0], snapshotUsage)
+	}
+}
+
+func tenantContext(ctx context.Context, cfg *config.Config, tenant string) (context.Context, error) {
+	if !cfg.HasTenant(tenant) {
+		return nil, fmt.Errorf("unknown tenant %q", tenant)
+	}
+	return store.WithTenant(ctx, tenant), nil
+}
+
+func withSnapshot(cfg *config.Config, fn func(svc *service.Snapshot) error) error {
+	st, err := app.NewStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	return fn(service.NewSnapshot(st, cfg))
+}
@@ -19,7 +19,8 @@ import (
 // @Param service query string true "Service name" example("twdiw-customer-service-prod")
 // @Param endpoint query string true "Endpoint name" example("GET /actuator/health")
 // @Param hour query int true "Hour of day (0-23)" example(16)
-// @Param dayType query string true "Day type (weekday or weekend)" example("weekday")
+// @Param minute query int false "Slot start within the hour, for sub-hour bucketing (0-59)" example(30)
+// @Param dayType query string true "Day type (weekday or weekend, or the day type of the bucketing scheme)" example("weekday")
 // @Success 200 {object} store.Baseline
 // @Failure 400 {object} map[string]string "Invalid parameters"
 // @Failure 404 {object} map[string]string "Baseline not found"
@@ -46,7 +47,15 @@ func Baseline(st store.Store) http.Handler {
             http.Error(w, "invalid hour", http.StatusBadRequest)
             return
         }
-        key := domain.MakeBaselineKey(service, endpoint, domain.TimeBucket{Hour: hour, DayType: dayType})
+        minute := 0
+        if minuteStr := q.Get("minute"); minuteStr != "" {
+            minute, err = strconv.Atoi(minuteStr)
+            if err != nil || minute < 0 || minute > 59 {
+                http.Error(w, "invalid minute", http.StatusBadRequest)
+                return
+            }
+        }
+        key := domain.MakeBaselineKey(service, endpoint, domain.TimeBucket{Hour: hour, Minute: minute, DayType: dayType})
         b, err := st.GetBaseline(r.Context(), key)
         if err != nil {
             http.Error(w, err.Error(), http.StatusInternalServerError)
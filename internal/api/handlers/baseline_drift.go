@@ -0,0 +1,51 @@
+package handlers
+
+import (
+    "encoding/json"
+    "net/http"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/service"
+)
+
+// BaselineDrift godoc
+// @Summary Detect baseline drift
+// @Description Run CUSUM change-point detection over the daily baseline history (p50 and p95) of every time bucket of an endpoint
+// @Description Span baselines keep no history and are not covered
+// @Description Returns the detected shifts with their magnitude, start and detection day, most recently detected first
+// @Tags Baseline
+// @Accept json
+// @Produce json
+// @Param service query string true "Service name" example("twdiw-customer-service-prod")
+// @Param endpoint query string true "Endpoint name" example("GET /actuator/health")
+// @Success 200 {object} domain.BaselineDriftResponse
+// @Failure 400 {object} map[string]string "Invalid parameters"
+// @Failure 404 {object} map[string]string "No baseline history"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 503 {object} map[string]string "Service not available"
+// @Router /v1/baseline/drift [get]
+func BaselineDrift(svc *service.Drift) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if svc == nil {
+            http.Error(w, "service not available", http.StatusServiceUnavailable)
+            return
+        }
+        q := r.URL.Query()
+        service := q.Get("service")
+        endpoint := q.Get("endpoint")
+        if service == "" || endpoint == "" {
+            http.Error(w, "missing query params: service, endpoint", http.StatusBadRequest)
+            return
+        }
+        resp, err := svc.Detect(r.Context(), service, endpoint)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+        if resp.Buckets == 0 {
+            w.WriteHeader(http.StatusNotFound)
+            json.NewEncoder(w).Encode(map[string]string{"error": "no baseline history"})
+            return
+        }
+        json.NewEncoder(w).Encode(resp)
+    })
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+    "encoding/json"
+    "net/http"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/service"
+)
+
+// CheckErrorRate godoc
+// @Summary Check for error-rate anomaly
+// @Description Evaluate if the error ratio of an endpoint (endpoint) or span (spanName) is anomalous in the time bucket of timestampNano
+// @Description The day's ratio (or the given requests/errors) is compared with the bucket's other days within retention:
+// @Description it is anomalous when the lower bound of its Wilson score interval lies above the upper bound of the baseline's
+// @Description Request and error counts are collected at ingest from the span status (OTLP status ERROR or HTTP 5xx)
+// @Tags Anomaly Detection
+// @Accept json
+// @Produce json
+// @Param request body domain.ErrorRateCheckRequest true "Error-rate check request"
+// @Success 200 {object} domain.ErrorRateCheckResponse
+// @Failure 400 {object} map[string]string "Invalid JSON or parameters"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 503 {object} map[string]string "Service not available"
+// @Router /v1/anomaly/check/errors [post]
+func CheckErrorRate(svc *service.ErrorRate) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if svc == nil {
+            http.Error(w, "service not available", http.StatusServiceUnavailable)
+            return
+        }
+        var req domain.ErrorRateCheckRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            http.Error(w, "invalid json", http.StatusBadRequest)
+            return
+        }
+        if req.Service == "" || (req.Endpoint == "") == (req.SpanName == "") {
+            http.Error(w, "need service and exactly one of endpoint, spanName", http.StatusBadRequest)
+            return
+        }
+        if req.Requests < 0 || req.Errors < 0 || req.Errors > req.Requests {
+            http.Error(w, "need 0 <= errors <= requests", http.StatusBadRequest)
+            return
+        }
+        resp, err := svc.Evaluate(r.Context(), req)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(resp)
+    })
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+    "encoding/json"
+    "net/http"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/config"
+)
+
+// HolidaysReload godoc
+// @Summary Reload holiday calendar
+// @Description Re-read the holiday calendar file (holidays.file) without restarting
+// @Description New samples and checks use the reloaded calendar; already bucketed samples keep their bucket.
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} map[string]int "Number of holidays and make-up workdays loaded"
+// @Failure 401 {object} map[string]string "Missing or invalid admin token"
+// @Failure 403 {object} map[string]string "Admin endpoints disabled (no http.admin_token)"
+// @Failure 500 {object} map[string]string "Calendar file could not be read; the previous calendar stays active"
+// @Router /v1/admin/holidays/reload [post]
+func HolidaysReload(cfg *config.Config) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        n, err := cfg.ReloadHolidays()
+        if err != nil {
+            w.WriteHeader(http.StatusInternalServerError)
+            json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+            return
+        }
+        json.NewEncoder(w).Encode(map[string]int{"days": n})
+    })
+}
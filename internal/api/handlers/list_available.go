@@ -11,6 +11,7 @@ import (
 // @Summary List available services and endpoints
 // @Description Retrieve all services and endpoints that have sufficient baseline samples for anomaly detection
 // @Description Returns a list of service/endpoint pairs grouped by service, along with their available time buckets
+// @Description Span baselines are listed under spans in the same form (span name in endpoint)
 // @Description Only includes baselines with sufficient samples (configured via min_samples)
 // @Tags Available Services
 // @Accept json
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+    "encoding/json"
+    "log"
+    "net/http"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/service"
+)
+
+// SnapshotExport godoc
+// @Summary Export baseline snapshot
+// @Description Stream every baseline and span baseline as NDJSON (one record per line, starting with a header record)
+// @Description With samples=true the raw duration and span duration windows are included as well
+// @Tags Admin
+// @Produce application/x-ndjson
+// @Param samples query bool false "Include raw duration windows" example(true)
+// @Success 200 {object} service.SnapshotRecord "NDJSON stream of snapshot records"
+// @Failure 401 {object} map[string]string "Missing or invalid admin token"
+// @Failure 403 {object} map[string]string "Admin endpoints disabled (no http.admin_token)"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 503 {object} map[string]string "Service not available"
+// @Router /v1/admin/snapshot/export [get]
+func SnapshotExport(svc *service.Snapshot) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if svc == nil {
+            http.Error(w, "service not available", http.StatusServiceUnavailable)
+            return
+        }
+        includeSamples := r.URL.Query().Get("samples") == "true"
+        w.Header().Set("Content-Type", "application/x-ndjson")
+        w.Header().Set("Content-Disposition", `attachment; filename="baselines.ndjson"`)
+        st, err := svc.Export(r.Context(), w, includeSamples)
+        if err != nil {
+            // Headers (and possibly records) are already sent; the truncated stream is all we can signal.
+            log.Printf("snapshot export failed after %d baselines: %v", st.Baselines, err)
+            return
+        }
+    })
+}
+
+// SnapshotImport godoc
+// @Summary Import baseline snapshot
+// @Description Import an NDJSON snapshot produced by the export endpoint
+// @Description mode=merge (default) keeps the more recently updated baseline and adds missing samples;
+// @Description mode=overwrite replaces existing baselines and windows.
+// @Description Imported baselines with samples are marked dirty for recomputation.
+// @Tags Admin
+// @Accept application/x-ndjson
+// @Produce json
+// @Param mode query string false "Import mode (merge or overwrite)" example("merge")
+// @Success 200 {object} service.SnapshotStats
+// @Failure 400 {object} map[string]string "Invalid mode or malformed snapshot"
+// @Failure 401 {object} map[string]string "Missing or invalid admin token"
+// @Failure 403 {object} map[string]string "Admin endpoints disabled (no http.admin_token)"
+// @Failure 503 {object} map[string]string "Service not available"
+// @Router /v1/admin/snapshot/import [post]
+func SnapshotImport(svc *service.Snapshot) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if svc == nil {
+            http.Error(w, "service not available", http.StatusServiceUnavailable)
+            return
+        }
+        mode, err := service.ParseSnapshotMode(r.URL.Query().Get("mode"))
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        st, err := svc.Import(r.Context(), r.Body, mode)
+        if err != nil {
+            w.WriteHeader(http.StatusBadRequest)
+            json.NewEncoder(w).Encode(map[string]any{"error": err.Error(), "imported": st})
+            return
+        }
+        json.NewEncoder(w).Encode(st)
+    })
+}
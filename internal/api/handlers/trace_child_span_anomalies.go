@@ -21,6 +21,7 @@ type TraceChildSpanAnomaliesRequest struct {
 // TraceChildSpanAnomalies godoc
 // @Summary Get child span anomalies for a parent span
 // @Description Evaluate anomalies for direct child spans under a parent span in a trace
+// @Description Each child also reports whether it failed (error) and the error-rate decision of its bucket on its day (errorRateAnomaly, errorRatio)
 // @Tags Traces
 // @Accept json
 // @Produce json
@@ -33,7 +34,7 @@ type TraceChildSpanAnomaliesRequest struct {
 // @Failure 504 {object} domain.ErrorResponse "Tempo timeout"
 // @Failure 503 {object} domain.ErrorResponse "Tempo not available"
 // @Router /v1/traces/child-span-anomalies [post]
-func TraceChildSpanAnomalies(client *tempo.Client, checker *service.SpanCheck) http.Handler {
+func TraceChildSpanAnomalies(client *tempo.Client, checker *service.SpanCheck, errorRate *service.ErrorRate) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if client == nil || checker == nil {
 			writeError(w, http.StatusServiceUnavailable, "tempo_unavailable", "tempo client not available", nil)
@@ -127,17 +128,36 @@ func TraceChildSpanAnomalies(client *tempo.Client, checker *service.SpanCheck) h
 				anomalyCount++
 			}
 
-			children = append(children, domain.ChildSpanAnomaly{
+			anomaly := domain.ChildSpanAnomaly{
 				Span:            summary,
 				IsAnomaly:       res.IsAnomaly,
 				CannotDetermine: res.CannotDetermine,
 				Bucket:          res.Bucket,
+				Timezone:        res.Timezone,
 				Baseline:        res.Baseline,
 				BaselineSource:  res.BaselineSource,
 				FallbackLevel:   res.FallbackLevel,
 				SourceDetails:   res.SourceDetails,
+				Detector:        res.Detector,
+				Score:           res.Score,
+				ZScore:          res.ZScore,
+				Severity:        res.Severity,
+				Rule:            res.Rule,
 				Explanation:     res.Explanation,
-			})
+				Error:           child.IsError(),
+			}
+			if errorRate != nil {
+				er, err := errorRate.Evaluate(r.Context(), domain.ErrorRateCheckRequest{
+					Service:       child.ServiceName,
+					SpanName:      child.Name,
+					TimestampNano: startNano,
+				})
+				if err == nil {
+					anomaly.ErrorRateAnomaly = er.IsAnomaly
+					anomaly.ErrorRatio = er.Observed.Ratio
+				}
+			}
+			children = append(children, anomaly)
 		}
 
 		resp := domain.ChildSpanAnomaliesResponse{
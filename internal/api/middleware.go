@@ -2,10 +2,16 @@ package api
 
 import (
     "context"
+    "crypto/subtle"
+    "encoding/json"
     "log"
     "math/rand"
     "net/http"
+    "strings"
     "time"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/config"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/store"
 )
 
 type ctxKey string
@@ -34,6 +40,47 @@ func requestIDMiddleware(next http.Handler) http.Handler {
     })
 }
 
+// adminAuthMiddleware requires "Authorization: Bearer {token}". Without a
+// configured token the admin endpoints are disabled and answer 403.
+func adminAuthMiddleware(token string, next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if token == "" {
+            w.WriteHeader(http.StatusForbidden)
+            json.NewEncoder(w).Encode(map[string]string{"error": "admin endpoints disabled: set http.admin_token"})
+            return
+        }
+        got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+        if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+            w.WriteHeader(http.StatusUnauthorized)
+            json.NewEncoder(w).Encode(map[string]string{"error": "unauthorized"})
+            return
+        }
+        next.ServeHTTP(w, r)
+    })
+}
+
+// tenantMiddleware scopes the request's store operations to the tenant named in the
+// tenancy header, or to the default tenant when the header is absent. Tenants that
+// are not configured are rejected, since no background job would ever serve them.
+func tenantMiddleware(cfg *config.Config, next http.Handler) http.Handler {
+    header := cfg.Tenancy.Header
+    if header == "" {
+        header = config.DefaultTenantHeader
+    }
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        tenant := cfg.Tenancy.DefaultTenant
+        if v := strings.TrimSpace(r.Header.Get(header)); v != "" {
+            tenant = v
+        }
+        if !cfg.HasTenant(tenant) {
+            w.WriteHeader(http.StatusBadRequest)
+            json.NewEncoder(w).Encode(map[string]string{"error": "unknown tenant"})
+            return
+        }
+        next.ServeHTTP(w, r.WithContext(store.WithTenant(r.Context(), tenant)))
+    })
+}
+
 func recoverMiddleware(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
         defer func() {
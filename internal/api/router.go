@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/alexchang/tempo-latency-anomaly-service/internal/api/handlers"
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/config"
 	"github.com/alexchang/tempo-latency-anomaly-service/internal/service"
 	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
 	"github.com/alexchang/tempo-latency-anomaly-service/internal/tempo"
@@ -15,10 +16,13 @@ import (
 )
 
 // NewRouter builds an http.Handler with routes and middleware wired.
-func NewRouter(checkSvc *service.Check, spanCheck *service.SpanCheck, listSvc *service.ListAvailable, st store.Store, tempoClient *tempo.Client) http.Handler {
+// Every request but /healthz is scoped to a tenant (see tenantMiddleware); trace endpoints
+// query the tenant's client from tempoClients.
+func NewRouter(cfg *config.Config, checkSvc *service.Check, spanCheck *service.SpanCheck, errorRateSvc *service.ErrorRate, listSvc *service.ListAvailable, driftSvc *service.Drift, snapshotSvc *service.Snapshot, st store.Store, tempoClients map[string]*tempo.Client) http.Handler {
 	mux := http.NewServeMux()
-
-	mux.HandleFunc("/healthz", handlers.Healthz)
+	tempoFor := func(r *http.Request) *tempo.Client {
+		return tempoClients[store.TenantFromContext(r.Context())]
+	}
 
 	mux.HandleFunc("/v1/anomaly/check", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -28,6 +32,14 @@ func NewRouter(checkSvc *service.Check, spanCheck *service.SpanCheck, listSvc *s
 		handlers.Check(checkSvc).ServeHTTP(w, r)
 	})
 
+	mux.HandleFunc("/v1/anomaly/check/errors", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		handlers.CheckErrorRate(errorRateSvc).ServeHTTP(w, r)
+	})
+
 	mux.HandleFunc("/v1/baseline", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			w.WriteHeader(http.StatusMethodNotAllowed)
@@ -36,6 +48,14 @@ func NewRouter(checkSvc *service.Check, spanCheck *service.SpanCheck, listSvc *s
 		handlers.Baseline(st).ServeHTTP(w, r)
 	})
 
+	mux.HandleFunc("/v1/baseline/drift", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		handlers.BaselineDrift(driftSvc).ServeHTTP(w, r)
+	})
+
 	mux.HandleFunc("/v1/available", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			w.WriteHeader(http.StatusMethodNotAllowed)
@@ -49,7 +69,7 @@ func NewRouter(checkSvc *service.Check, spanCheck *service.SpanCheck, listSvc *s
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
-		handlers.TraceLookup(tempoClient, checkSvc).ServeHTTP(w, r)
+		handlers.TraceLookup(tempoFor(r), checkSvc).ServeHTTP(w, r)
 	})
 
 	mux.HandleFunc("/v1/traces/anomalies", func(w http.ResponseWriter, r *http.Request) {
@@ -57,7 +77,7 @@ func NewRouter(checkSvc *service.Check, spanCheck *service.SpanCheck, listSvc *s
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
-		handlers.TraceLookupAnomalies(tempoClient, checkSvc).ServeHTTP(w, r)
+		handlers.TraceLookupAnomalies(tempoFor(r), checkSvc).ServeHTTP(w, r)
 	})
 
 	mux.HandleFunc("/v1/traces/", func(w http.ResponseWriter, r *http.Request) {
@@ -69,7 +89,7 @@ func NewRouter(checkSvc *service.Check, spanCheck *service.SpanCheck, listSvc *s
 				w.WriteHeader(http.StatusMethodNotAllowed)
 				return
 			}
-			handlers.TraceChildSpans(tempoClient).ServeHTTP(w, r)
+			handlers.TraceChildSpans(tempoFor(r)).ServeHTTP(w, r)
 			return
 		}
 
@@ -79,7 +99,7 @@ func NewRouter(checkSvc *service.Check, spanCheck *service.SpanCheck, listSvc *s
 				w.WriteHeader(http.StatusMethodNotAllowed)
 				return
 			}
-			handlers.TraceChildSpanAnomalies(tempoClient, spanCheck).ServeHTTP(w, r)
+			handlers.TraceChildSpanAnomalies(tempoFor(r), spanCheck, errorRateSvc).ServeHTTP(w, r)
 			return
 		}
 
@@ -91,7 +111,7 @@ func NewRouter(checkSvc *service.Check, spanCheck *service.SpanCheck, listSvc *s
 
 		// Check if it's a longest span request
 		if strings.HasSuffix(path, "/longest-span") {
-			handlers.TraceLongestSpan(tempoClient).ServeHTTP(w, r)
+			handlers.TraceLongestSpan(tempoFor(r)).ServeHTTP(w, r)
 			return
 		}
 
@@ -100,12 +120,41 @@ func NewRouter(checkSvc *service.Check, spanCheck *service.SpanCheck, listSvc *s
 		json.NewEncoder(w).Encode(map[string]string{"error": "endpoint not found"})
 	})
 
+	mux.Handle("/v1/admin/snapshot/export", adminAuthMiddleware(cfg.HTTP.AdminToken, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		handlers.SnapshotExport(snapshotSvc).ServeHTTP(w, r)
+	})))
+
+	mux.Handle("/v1/admin/snapshot/import", adminAuthMiddleware(cfg.HTTP.AdminToken, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		handlers.SnapshotImport(snapshotSvc).ServeHTTP(w, r)
+	})))
+
+	mux.Handle("/v1/admin/holidays/reload", adminAuthMiddleware(cfg.HTTP.AdminToken, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		handlers.HolidaysReload(cfg).ServeHTTP(w, r)
+	})))
+
 	// Swagger UI endpoint
 	mux.HandleFunc("/swagger/", httpSwagger.Handler(
 		httpSwagger.URL("doc.json"),
 	))
 
-	h := recoverMiddleware(requestIDMiddleware(loggingMiddleware(mux)))
+	// Liveness probes carry no tenant header, so /healthz is not tenant-scoped
+	root := http.NewServeMux()
+	root.HandleFunc("/healthz", handlers.Healthz)
+	root.Handle("/", tenantMiddleware(cfg, mux))
+
+	h := recoverMiddleware(requestIDMiddleware(loggingMiddleware(root)))
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
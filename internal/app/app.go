@@ -10,6 +10,8 @@ import (
 	"github.com/alexchang/tempo-latency-anomaly-service/internal/observability"
 	"github.com/alexchang/tempo-latency-anomaly-service/internal/service"
 	storepkg "github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+	filepkg "github.com/alexchang/tempo-latency-anomaly-service/internal/store/file"
+	memorypkg "github.com/alexchang/tempo-latency-anomaly-service/internal/store/memory"
 	redispkg "github.com/alexchang/tempo-latency-anomaly-service/internal/store/redis"
 	"github.com/alexchang/tempo-latency-anomaly-service/internal/tempo"
 )
@@ -26,8 +28,14 @@ type App struct {
 	Check        *service.Check
 	SpanCheck    *service.SpanCheck
 	ListAvail    *service.ListAvailable
-	TempoPoller  *jobs.TempoPoller
+	Snapshot     *service.Snapshot
+	Drift        *service.Drift
+	ErrorRate    *service.ErrorRate
+	// TempoPollers holds one poller per tenant with polling enabled.
+	TempoPollers map[string]*jobs.TempoPoller
 	BaselineJob  *jobs.BaselineRecompute
+	PruneJob     *jobs.RetentionPruner
+	HolidayJob   *jobs.HolidayReloader
 	HTTPServer   *http.Server
 }
 
@@ -41,13 +49,21 @@ func New(cfg *config.Config) (*App, error) {
 	observability.SetupLogger()
 
 	// Storage
-	st, err := redispkg.New(cfg.Redis)
+	st, err := NewStore(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("init redis: %w", err)
+		return nil, err
 	}
 
-	// External clients
+	// External clients: one Tempo client per tenant, shared with the top-level
+	// settings unless the tenant overrides them
 	tempoClient := tempo.NewClient(cfg.Tempo)
+	tempoClients := make(map[string]*tempo.Client)
+	for _, name := range cfg.TenantNames() {
+		tempoClients[name] = tempoClient
+		if tc := cfg.Tenant(name); tc.Tempo != cfg.Tempo {
+			tempoClients[name] = tempo.NewClient(tc.Tempo)
+		}
+	}
 
 	// Services
 	ingestSvc := service.NewIngest(st, cfg)
@@ -58,20 +74,31 @@ func New(cfg *config.Config) (*App, error) {
 	spanBaselineLookup := service.NewSpanBaselineLookup(st, cfg)
 	checkSvc := service.NewCheck(st, cfg, baselineLookup)
 	spanCheck := service.NewSpanCheck(st, cfg, spanBaselineLookup)
-	listAvailSvc := service.NewListAvailable(st, cfg.Stats.MinSamples)
+	listAvailSvc := service.NewListAvailable(st, cfg.Stats.MinSamples, cfg.TimeBuckets())
+	snapshotSvc := service.NewSnapshot(st, cfg)
+	driftSvc := service.NewDrift(st, cfg)
+	errorRateSvc := service.NewErrorRate(st, cfg)
 
 	// Jobs
-	poller := jobs.NewTempoPoller(cfg, tempoClient, ingestSvc, spanIngest)
+	pollers := make(map[string]*jobs.TempoPoller)
+	for _, name := range cfg.TenantNames() {
+		if cfg.Tenant(name).PollingDisabled {
+			continue
+		}
+		pollers[name] = jobs.NewTempoPoller(cfg, tempoClients[name], ingestSvc, spanIngest)
+	}
 	recompute := jobs.NewBaselineRecompute(cfg, baselineSvc, spanBaseline, st, 100)
+	pruner := jobs.NewRetentionPruner(cfg, st)
+	holidays := jobs.NewHolidayReloader(cfg)
 
 	// HTTP router and server
-	apiHandler := api.NewRouter(checkSvc, spanCheck, listAvailSvc, st, tempoClient)
+	apiHandler := api.NewRouter(cfg, checkSvc, spanCheck, errorRateSvc, listAvailSvc, driftSvc, snapshotSvc, st, tempoClients)
 
 	mux := http.NewServeMux()
 	// Mount API under root
 	mux.Handle("/", apiHandler)
 	// Expose metrics endpoint
-	mux.HandleFunc("/metrics", observability.MetricsHandler)
+	mux.HandleFunc("/metrics", observability.MetricsHandler(st, cfg.TenantNames()))
 
 	srv := &http.Server{
 		Addr:              fmt.Sprintf(":%d", cfg.HTTP.Port),
@@ -93,8 +120,35 @@ func New(cfg *config.Config) (*App, error) {
 		Check:        checkSvc,
 		SpanCheck:    spanCheck,
 		ListAvail:    listAvailSvc,
-		TempoPoller:  poller,
+		Snapshot:     snapshotSvc,
+		Drift:        driftSvc,
+		ErrorRate:    errorRateSvc,
+		TempoPollers: pollers,
 		BaselineJob:  recompute,
+		PruneJob:     pruner,
+		HolidayJob:   holidays,
 		HTTPServer:   srv,
 	}, nil
 }
+
+// NewStore builds the storage backend selected by cfg.Store.Backend.
+func NewStore(cfg *config.Config) (storepkg.Store, error) {
+	switch cfg.Store.Backend {
+	case "", config.StoreBackendRedis:
+		st, err := redispkg.New(cfg.Redis, cfg.TenantNames())
+		if err != nil {
+			return nil, fmt.Errorf("init redis: %w", err)
+		}
+		return st, nil
+	case config.StoreBackendMemory:
+		return memorypkg.New(), nil
+	case config.StoreBackendFile:
+		st, err := filepkg.Open(cfg.Store.DataDir)
+		if err != nil {
+			return nil, fmt.Errorf("init file store: %w", err)
+		}
+		return st, nil
+	default:
+		return nil, fmt.Errorf("unknown store backend %q", cfg.Store.Backend)
+	}
+}
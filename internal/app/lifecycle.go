@@ -6,6 +6,8 @@ import (
     "log"
     "net/http"
     "time"
+
+    storepkg "github.com/alexchang/tempo-latency-anomaly-service/internal/store"
 )
 
 // Run starts background jobs and the HTTP server, and blocks until the context
@@ -19,9 +21,31 @@ func (a *App) Run(ctx context.Context) error {
     defer cancel()
     defer a.cleanup()
 
-    // Start background jobs
-    go a.TempoPoller.Run(ctx)
-    go a.BaselineJob.Run(ctx)
+    // Windows written before sketches existed get theirs before ingestion resumes
+    for _, tenant := range a.Cfg.TenantNames() {
+        tctx := storepkg.WithTenant(ctx, tenant)
+        for _, backfill := range []func(context.Context) (int, error){a.Baseline.BackfillSketches, a.SpanBaseline.BackfillSketches} {
+            n, err := backfill(tctx)
+            if err != nil {
+                log.Printf("tenant %q: backfill sketches: %v", tenant, err)
+            }
+            if n > 0 {
+                log.Printf("tenant %q: built %d sketches from existing samples", tenant, n)
+            }
+        }
+    }
+
+    // Start background jobs, scoped to each tenant's keys
+    for tenant, poller := range a.TempoPollers {
+        go poller.Run(storepkg.WithTenant(ctx, tenant))
+    }
+    for _, tenant := range a.Cfg.TenantNames() {
+        tctx := storepkg.WithTenant(ctx, tenant)
+        go a.BaselineJob.Run(tctx)
+        go a.PruneJob.Run(tctx)
+    }
+    // The holiday calendar is shared by all tenants
+    go a.HolidayJob.Run(ctx)
 
     // Start HTTP server
     srvErr := make(chan error, 1)
@@ -0,0 +1,130 @@
+package config
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
+)
+
+// BucketingConfig selects how samples are grouped into time buckets.
+// Scheme is one of "weekday_hour" (default), "dow_hour", "slot" (SlotMinutes
+// long slots, e.g. 15 or 30) or "schedule" (the periods of the schedule named
+// by Schedule, taken from Schedules).
+type BucketingConfig struct {
+    Scheme      string           `mapstructure:"scheme" yaml:"scheme"`
+    SlotMinutes int              `mapstructure:"slot_minutes" yaml:"slot_minutes"`
+    Schedule    string           `mapstructure:"schedule" yaml:"schedule"`
+    Schedules   []ScheduleConfig `mapstructure:"schedules" yaml:"schedules"`
+
+    compiled *domain.Bucketing
+}
+
+// ScheduleConfig is a named set of periods such as business hours. Times
+// outside all periods fall into Default.
+type ScheduleConfig struct {
+    Name    string                 `mapstructure:"name" yaml:"name"`
+    Default string                 `mapstructure:"default" yaml:"default"`
+    Periods []SchedulePeriodConfig `mapstructure:"periods" yaml:"periods"`
+}
+
+// SchedulePeriodConfig covers Start (inclusive) to End (exclusive), both "HH:MM",
+// on Days ("mon".."sun"). End may be "24:00".
+type SchedulePeriodConfig struct {
+    Name  string   `mapstructure:"name" yaml:"name"`
+    Days  []string `mapstructure:"days" yaml:"days"`
+    Start string   `mapstructure:"start" yaml:"start"`
+    End   string   `mapstructure:"end" yaml:"end"`
+}
+
+// TimeBuckets returns the bucketing scheme used for keys, fallback neighbours and
+// the available-baselines listing. Configs not loaded through Load are compiled
+// on every call (with an empty holiday calendar); an invalid hand-built config
+// falls back to the default scheme.
+func (c *Config) TimeBuckets() domain.Bucketing {
+    if c.Bucketing.compiled != nil {
+        return *c.Bucketing.compiled
+    }
+    b, err := compileBucketing(c.Bucketing, c.Holidays, c.bucketTimezones(), false)
+    if err != nil {
+        return domain.Bucketing{}
+    }
+    return b
+}
+
+// compileBucketing resolves the configured schedule, holiday calendar and
+// timezones and validates the scheme. The calendar file is only read when
+// loadCalendar is set.
+func compileBucketing(bc BucketingConfig, h HolidaysConfig, timezones []string, loadCalendar bool) (domain.Bucketing, error) {
+    cal, err := newCalendar(h, loadCalendar)
+    if err != nil {
+        return domain.Bucketing{}, err
+    }
+    b := domain.Bucketing{Scheme: bc.Scheme, SlotMinutes: bc.SlotMinutes, Calendar: cal}
+    for _, tz := range timezones {
+        loc, err := time.LoadLocation(tz)
+        if err != nil {
+            return domain.Bucketing{}, fmt.Errorf("timezone: %w", err)
+        }
+        b.Locations = append(b.Locations, loc)
+    }
+    if bc.Scheme == domain.SchemeSchedule {
+        sc, ok := findSchedule(bc.Schedules, bc.Schedule)
+        if !ok {
+            return domain.Bucketing{}, fmt.Errorf("bucketing.schedule: unknown schedule %q", bc.Schedule)
+        }
+        sched := &domain.Schedule{Name: sc.Name, Default: sc.Default}
+        for i, p := range sc.Periods {
+            field := fmt.Sprintf("bucketing.schedules[%s].periods[%d]", sc.Name, i)
+            period := domain.SchedulePeriod{Name: p.Name}
+            var err error
+            if period.Start, err = parseClock(p.Start); err != nil {
+                return domain.Bucketing{}, fmt.Errorf("%s.start: %w", field, err)
+            }
+            if period.End, err = parseClock(p.End); err != nil {
+                return domain.Bucketing{}, fmt.Errorf("%s.end: %w", field, err)
+            }
+            for _, d := range p.Days {
+                wd, err := domain.ParseWeekday(d)
+                if err != nil {
+                    return domain.Bucketing{}, fmt.Errorf("%s.days: %w", field, err)
+                }
+                period.Days = append(period.Days, wd)
+            }
+            sched.Periods = append(sched.Periods, period)
+        }
+        b.Schedule = sched
+    }
+    if err := b.Validate(); err != nil {
+        return domain.Bucketing{}, fmt.Errorf("bucketing: %w", err)
+    }
+    return b, nil
+}
+
+func findSchedule(schedules []ScheduleConfig, name string) (ScheduleConfig, bool) {
+    for _, s := range schedules {
+        if s.Name == name {
+            return s, true
+        }
+    }
+    return ScheduleConfig{}, false
+}
+
+// parseClock parses "HH:MM" into minutes of the day (0..1440).
+func parseClock(s string) (int, error) {
+    h, m, ok := strings.Cut(strings.TrimSpace(s), ":")
+    if !ok {
+        return 0, fmt.Errorf("invalid time %q, want HH:MM", s)
+    }
+    hour, err := strconv.Atoi(h)
+    if err != nil {
+        return 0, fmt.Errorf("invalid time %q, want HH:MM", s)
+    }
+    minute, err := strconv.Atoi(m)
+    if err != nil || hour < 0 || minute < 0 || minute > 59 || hour*60+minute > 24*60 {
+        return 0, fmt.Errorf("invalid time %q, want HH:MM", s)
+    }
+    return hour*60 + minute, nil
+}
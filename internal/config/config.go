@@ -2,6 +2,8 @@ package config
 
 import (
     "fmt"
+    "reflect"
+    "strconv"
     "strings"
     "time"
 
@@ -12,21 +14,70 @@ import (
 // Config represents the full application configuration.
 type Config struct {
     Timezone     string         `mapstructure:"timezone" yaml:"timezone"`
+    // Timezones override Timezone per service pattern; first match wins.
+    Timezones    []ServiceTimezone `mapstructure:"timezones" yaml:"timezones"`
+    Store        StoreConfig    `mapstructure:"store" yaml:"store"`
     Redis        RedisConfig    `mapstructure:"redis" yaml:"redis"`
     Tempo        TempoConfig    `mapstructure:"tempo" yaml:"tempo"`
     Stats        StatsConfig    `mapstructure:"stats" yaml:"stats"`
+    Detection    DetectionConfig `mapstructure:"detection" yaml:"detection"`
+    Severity     SeverityConfig `mapstructure:"severity" yaml:"severity"`
+    Guard        GuardConfig    `mapstructure:"guard" yaml:"guard"`
+    Drift        DriftConfig    `mapstructure:"drift" yaml:"drift"`
+    ErrorRate    ErrorRateConfig `mapstructure:"error_rate" yaml:"error_rate"`
+    // Rules override detection settings per service/endpoint pattern; first match wins.
+    Rules        []Rule         `mapstructure:"rules" yaml:"rules"`
     Polling      PollingConfig  `mapstructure:"polling" yaml:"polling"`
     WindowSize   int            `mapstructure:"window_size" yaml:"window_size"`
+    // Retention is the maximum age of duration samples (e.g. "14d"). Older samples are
+    // ignored by baseline recomputation and removed by the pruner. 0 (the default)
+    // keeps samples until they are pushed out of the window_size cap.
+    Retention    time.Duration  `mapstructure:"retention" yaml:"retention"`
     Dedup        DedupConfig    `mapstructure:"dedup" yaml:"dedup"`
     HTTP         HTTPConfig     `mapstructure:"http" yaml:"http"`
     Fallback     FallbackConfig `mapstructure:"fallback" yaml:"fallback"`
+    Tenancy      TenancyConfig  `mapstructure:"tenancy" yaml:"tenancy"`
+    Bucketing    BucketingConfig `mapstructure:"bucketing" yaml:"bucketing"`
+    Holidays     HolidaysConfig `mapstructure:"holidays" yaml:"holidays"`
 }
 
+// StoreConfig selects the storage backend.
+// Backend is one of "redis" (default), "memory" or "file".
+// DataDir is where the "file" backend keeps its snapshot and write-ahead log.
+type StoreConfig struct {
+    Backend string `mapstructure:"backend" yaml:"backend"`
+    DataDir string `mapstructure:"data_dir" yaml:"data_dir"`
+}
+
+// RedisConfig describes how to reach Redis. The topology is chosen as follows:
+// - MasterName set: Sentinel (SentinelAddrs lists the sentinels)
+// - ClusterAddrs set: Redis Cluster (ClusterAddrs are seed nodes)
+// - otherwise: a single node at Host:Port
 type RedisConfig struct {
     Host     string `mapstructure:"host" yaml:"host"`
     Port     int    `mapstructure:"port" yaml:"port"`
+    Username string `mapstructure:"username" yaml:"username"`
     Password string `mapstructure:"password" yaml:"password"`
     DB       int    `mapstructure:"db" yaml:"db"`
+
+    MasterName       string   `mapstructure:"master_name" yaml:"master_name"`
+    SentinelAddrs    []string `mapstructure:"sentinel_addrs" yaml:"sentinel_addrs"`
+    SentinelUsername string   `mapstructure:"sentinel_username" yaml:"sentinel_username"`
+    SentinelPassword string   `mapstructure:"sentinel_password" yaml:"sentinel_password"`
+
+    ClusterAddrs []string `mapstructure:"cluster_addrs" yaml:"cluster_addrs"`
+
+    TLS RedisTLSConfig `mapstructure:"tls" yaml:"tls"`
+}
+
+// RedisTLSConfig enables TLS for Redis (and Sentinel) connections.
+type RedisTLSConfig struct {
+    Enabled            bool   `mapstructure:"enabled" yaml:"enabled"`
+    CAFile             string `mapstructure:"ca_file" yaml:"ca_file"`
+    CertFile           string `mapstructure:"cert_file" yaml:"cert_file"`
+    KeyFile            string `mapstructure:"key_file" yaml:"key_file"`
+    ServerName         string `mapstructure:"server_name" yaml:"server_name"`
+    InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify" yaml:"insecure_skip_verify"`
 }
 
 type TempoConfig struct {
@@ -38,16 +89,111 @@ type StatsConfig struct {
     Factor      float64       `mapstructure:"factor" yaml:"factor"`
     K           int           `mapstructure:"k" yaml:"k"`
     MinSamples  int           `mapstructure:"min_samples" yaml:"min_samples"`
+    // MADEpsilon is the smallest MAD used by the detectors, so series with
+    // identical samples (MAD=0) do not flag every small deviation.
     MADEpsilon  time.Duration `mapstructure:"mad_epsilon" yaml:"mad_epsilon"`
+    // MinThresholdMs is an absolute threshold floor: durations at or below it
+    // are never anomalous.
+    MinThresholdMs float64 `mapstructure:"min_threshold_ms" yaml:"min_threshold_ms"`
+    // MinExcessMs is how far above p50 a duration must be to be anomalous.
+    MinExcessMs float64 `mapstructure:"min_excess_ms" yaml:"min_excess_ms"`
+    // Percentiles lists extra quantiles (in percent, e.g. 99.9) computed and stored
+    // with every baseline under the name "p{value}", e.g. "p99.9".
+    Percentiles []float64 `mapstructure:"percentiles" yaml:"percentiles"`
+    // PercentileMethod is "nearest_rank" (default) or "linear" (interpolated).
+    PercentileMethod string `mapstructure:"percentile_method" yaml:"percentile_method"`
+    // ThresholdPercentile names the quantile multiplied by Factor in the threshold:
+    // "p50", "p95" or the name of one of Percentiles.
+    ThresholdPercentile string `mapstructure:"threshold_percentile" yaml:"threshold_percentile"`
+    // Model is "window" (default): order statistics over the last WindowSize
+    // samples, or "decayed": quantiles of the raw window with every sample
+    // weighted by 2^(-age/HalfLife), so a shifted latency level takes over within
+    // a few half-lives instead of a full window.
+    Model    string        `mapstructure:"model" yaml:"model"`
+    HalfLife time.Duration `mapstructure:"half_life" yaml:"half_life"`
+}
+
+// DetectionConfig selects how a duration is judged against its baseline.
+// Detector is one of:
+// - "hybrid" (default): max(threshold_percentile*factor, p50 + k*MAD)
+// - "robust_z": (duration - p50) / (1.4826*MAD) above ZThreshold
+// - "iqr": Tukey's upper fence p75 + IQRMultiplier*(p75 - p25)
+// - "percentile_rank": above the baseline's RankPercentile (in percent)
+// - "slo": above the fixed SLOMs
+// Rules override the detector for single services, endpoints or span names.
+type DetectionConfig struct {
+    Detector       string  `mapstructure:"detector" yaml:"detector"`
+    ZThreshold     float64 `mapstructure:"z_threshold" yaml:"z_threshold"`
+    IQRMultiplier  float64 `mapstructure:"iqr_multiplier" yaml:"iqr_multiplier"`
+    RankPercentile float64 `mapstructure:"rank_percentile" yaml:"rank_percentile"`
+    SLOMs          float64 `mapstructure:"slo_ms" yaml:"slo_ms"`
+}
+
+// SeverityConfig grades evaluations by their score (duration / threshold):
+// above Critical is "critical", above Warning is "warning", anything else "info".
+type SeverityConfig struct {
+    Warning  float64 `mapstructure:"warning" yaml:"warning"`
+    Critical float64 `mapstructure:"critical" yaml:"critical"`
+}
+
+// GuardConfig protects baselines from contamination: at ingest every sample is
+// evaluated against its bucket's current baseline first, and one longer than
+// Multiple times the detection threshold is handled per Mode:
+//   - "off" (default): stored as is
+//   - "exclude": not stored
+//   - "downweight": stored with probability Weight (decided per trace/span ID,
+//     so a replayed trace gets the same decision)
+//   - "winsorize": stored clamped to Multiple times the threshold
+// Buckets without a baseline of at least min_samples are never guarded.
+type GuardConfig struct {
+    Mode     string  `mapstructure:"mode" yaml:"mode"`
+    Multiple float64 `mapstructure:"multiple" yaml:"multiple"`
+    Weight   float64 `mapstructure:"weight" yaml:"weight"`
+}
+
+// Enabled reports whether the guard acts on samples.
+func (g GuardConfig) Enabled() bool {
+    return g.Mode != "" && g.Mode != GuardModeOff
+}
+
+// DriftConfig controls the daily baseline history and the change-point detection
+// of GET /v1/baseline/drift. Each recompute records the day's p50/p95 of the
+// bucket, keeping History of them (0 disables the history). A two-sided CUSUM
+// then follows each metric relative to the median of its first WarmupDays days:
+// changes within Slack per day are tolerated, and a shift is reported once the
+// accumulated excess passes Threshold (both fractions, e.g. 0.05 = 5%).
+type DriftConfig struct {
+    History    time.Duration `mapstructure:"history" yaml:"history"`
+    WarmupDays int           `mapstructure:"warmup_days" yaml:"warmup_days"`
+    Slack      float64       `mapstructure:"slack" yaml:"slack"`
+    Threshold  float64       `mapstructure:"threshold" yaml:"threshold"`
+}
+
+// ErrorRateConfig controls error-rate anomaly detection. Ingest counts the
+// requests and errors of every bucket per day; a day's error ratio is anomalous
+// when the lower bound of its Wilson score interval at Z lies above the upper
+// bound of the ratio of the bucket's other days within retention (0 disables
+// the detection). Days with fewer than MinObserved requests, or baselines with
+// fewer than MinRequests, are not judged.
+type ErrorRateConfig struct {
+    Z           float64 `mapstructure:"z" yaml:"z"`
+    MinRequests int     `mapstructure:"min_requests" yaml:"min_requests"`
+    MinObserved int     `mapstructure:"min_observed" yaml:"min_observed"`
 }
 
 type PollingConfig struct {
     TempoInterval    time.Duration `mapstructure:"tempo_interval" yaml:"tempo_interval"`
     TempoLookback    time.Duration `mapstructure:"tempo_lookback" yaml:"tempo_lookback"`
     BaselineInterval time.Duration `mapstructure:"baseline_interval" yaml:"baseline_interval"`
+    // BaselineLease is how long a claimed dirty key stays leased before it is handed out again.
+    BaselineLease time.Duration `mapstructure:"baseline_lease" yaml:"baseline_lease"`
+    // BaselineMaxRetries is how many failed recomputes a key gets before it is dead-lettered.
+    BaselineMaxRetries int `mapstructure:"baseline_max_retries" yaml:"baseline_max_retries"`
     BackfillEnabled  bool          `mapstructure:"backfill_enabled" yaml:"backfill_enabled"`
     BackfillDuration time.Duration `mapstructure:"backfill_duration" yaml:"backfill_duration"`
     BackfillBatch    time.Duration `mapstructure:"backfill_batch" yaml:"backfill_batch"`
+    // PruneInterval is how often samples older than Retention are removed.
+    PruneInterval time.Duration `mapstructure:"prune_interval" yaml:"prune_interval"`
 }
 
 type DedupConfig struct {
@@ -57,6 +203,9 @@ type DedupConfig struct {
 type HTTPConfig struct {
     Port    int           `mapstructure:"port" yaml:"port"`
     Timeout time.Duration `mapstructure:"timeout" yaml:"timeout"`
+    // AdminToken is required as a bearer token on /v1/admin/* endpoints; when
+    // empty (the default) those endpoints are disabled.
+    AdminToken string `mapstructure:"admin_token" yaml:"admin_token"`
 }
 
 type FallbackConfig struct {
@@ -68,15 +217,256 @@ type FallbackConfig struct {
     DayTypeGlobalMinSamples  int  `mapstructure:"daytype_global_min_samples" yaml:"daytype_global_min_samples"`
     FullGlobalEnabled        bool `mapstructure:"full_global_enabled" yaml:"full_global_enabled"`
     FullGlobalMinSamples     int  `mapstructure:"full_global_min_samples" yaml:"full_global_min_samples"`
+    // CacheTTL is how long a merged fallback baseline (levels 2-4) is reused
+    // before its buckets are read and merged again. 0 disables the cache.
+    CacheTTL time.Duration `mapstructure:"cache_ttl" yaml:"cache_ttl"`
+}
+
+// TenancyConfig namespaces all stored data by tenant so several environments or
+// teams can share one Redis. API requests select their tenant with Header; requests
+// without it (and the top-level Tempo poller) use DefaultTenant. The empty default
+// tenant uses unprefixed keys, i.e. the data written before tenants existed.
+type TenancyConfig struct {
+    Header        string         `mapstructure:"header" yaml:"header"`
+    DefaultTenant string         `mapstructure:"default_tenant" yaml:"default_tenant"`
+    Tenants       []TenantConfig `mapstructure:"tenants" yaml:"tenants"`
+}
+
+// TenantConfig declares a tenant and configures its Tempo poller.
+// Empty Tempo fields are inherited from the top-level tempo section.
+type TenantConfig struct {
+    Name            string      `mapstructure:"name" yaml:"name"`
+    Tempo           TempoConfig `mapstructure:"tempo" yaml:"tempo"`
+    PollingDisabled bool        `mapstructure:"polling_disabled" yaml:"polling_disabled"`
+}
+
+// TenantNames returns the default tenant followed by every configured tenant, without duplicates.
+func (c *Config) TenantNames() []string {
+    names := []string{c.Tenancy.DefaultTenant}
+    seen := map[string]bool{c.Tenancy.DefaultTenant: true}
+    for _, t := range c.Tenancy.Tenants {
+        if !seen[t.Name] {
+            seen[t.Name] = true
+            names = append(names, t.Name)
+        }
+    }
+    return names
+}
+
+// HasTenant reports whether name is the default tenant or a configured one.
+func (c *Config) HasTenant(name string) bool {
+    for _, n := range c.TenantNames() {
+        if n == name {
+            return true
+        }
+    }
+    return false
+}
+
+// Tenant returns the effective settings of tenant name, with Tempo fields
+// inherited from the top-level tempo section.
+func (c *Config) Tenant(name string) TenantConfig {
+    tc := TenantConfig{Name: name}
+    for _, t := range c.Tenancy.Tenants {
+        if t.Name == name {
+            tc = t
+            break
+        }
+    }
+    if tc.Tempo.URL == "" {
+        tc.Tempo.URL = c.Tempo.URL
+    }
+    if tc.Tempo.AuthToken == "" {
+        tc.Tempo.AuthToken = c.Tempo.AuthToken
+    }
+    return tc
+}
+
+// BaselinePercentiles returns stats.percentiles plus the quantiles the configured
+// detectors (including those chosen by rules) read from baselines: p25/p75 for
+// iqr and the percentile_rank cutoff.
+func (c *Config) BaselinePercentiles() []float64 {
+    out := append([]float64(nil), c.Stats.Percentiles...)
+    add := func(p float64) {
+        for _, q := range out {
+            if q == p {
+                return
+            }
+        }
+        out = append(out, p)
+    }
+    detections := []DetectionConfig{c.Detection}
+    for _, r := range c.Rules {
+        d := c.Detection
+        if r.Detector != "" {
+            d.Detector = r.Detector
+        }
+        if r.RankPercentile != 0 {
+            d.RankPercentile = r.RankPercentile
+        }
+        detections = append(detections, d)
+    }
+    for _, d := range detections {
+        switch d.Detector {
+        case "iqr":
+            add(25)
+            add(75)
+        case "percentile_rank":
+            add(d.RankPercentile)
+        }
+    }
+    return out
+}
+
+// validateDetection rejects unknown detectors and parameters they cannot use.
+func validateDetection(d DetectionConfig) error {
+    return validateDetector("detection", d.Detector, d.RankPercentile, d.SLOMs)
+}
+
+// validateDetector checks one effective detector choice; field names it in errors.
+func validateDetector(field, detector string, rank, slo float64) error {
+    switch detector {
+    case "", "hybrid", "robust_z", "iqr":
+    case "percentile_rank":
+        if rank <= 0 || rank >= 100 {
+            return fmt.Errorf("%s: rank_percentile %v is not between 0 and 100", field, rank)
+        }
+    case "slo":
+        if slo <= 0 {
+            return fmt.Errorf("%s: slo detector needs slo_ms > 0", field)
+        }
+    default:
+        return fmt.Errorf("%s: unknown detector %q (want hybrid, robust_z, iqr, percentile_rank or slo)", field, detector)
+    }
+    return nil
+}
+
+// validateSeverity rejects cut points that are not positive and ascending.
+func validateSeverity(s SeverityConfig) error {
+    if s.Warning <= 0 || s.Critical < s.Warning {
+        return fmt.Errorf("severity: need 0 < warning (%v) <= critical (%v)", s.Warning, s.Critical)
+    }
+    return nil
+}
+
+// validateGuard rejects unknown modes and a multiple or weight they cannot use.
+func validateGuard(g GuardConfig) error {
+    switch g.Mode {
+    case "", GuardModeOff:
+        return nil
+    case GuardModeExclude, GuardModeDownweight, GuardModeWinsorize:
+    default:
+        return fmt.Errorf("guard.mode: invalid mode %q (want off, exclude, downweight or winsorize)", g.Mode)
+    }
+    if g.Multiple < 1 {
+        return fmt.Errorf("guard.multiple: %v must be at least 1", g.Multiple)
+    }
+    if g.Mode == GuardModeDownweight && (g.Weight < 0 || g.Weight > 1) {
+        return fmt.Errorf("guard.weight: %v is not between 0 and 1", g.Weight)
+    }
+    return nil
+}
+
+// validateDrift rejects a negative history and CUSUM parameters it cannot use.
+func validateDrift(d DriftConfig) error {
+    if d.History < 0 {
+        return fmt.Errorf("drift.history: must not be negative")
+    }
+    if d.WarmupDays < 1 {
+        return fmt.Errorf("drift.warmup_days: %d must be at least 1", d.WarmupDays)
+    }
+    if d.Slack < 0 || d.Threshold <= 0 {
+        return fmt.Errorf("drift: need slack (%v) >= 0 and threshold (%v) > 0", d.Slack, d.Threshold)
+    }
+    return nil
+}
+
+// validateErrorRate rejects a negative z and negative request minimums.
+func validateErrorRate(e ErrorRateConfig) error {
+    if e.Z < 0 {
+        return fmt.Errorf("error_rate.z: must not be negative")
+    }
+    if e.MinRequests < 0 || e.MinObserved < 0 {
+        return fmt.Errorf("error_rate: need min_requests (%d) and min_observed (%d) >= 0", e.MinRequests, e.MinObserved)
+    }
+    return nil
+}
+
+// validateStats rejects percentiles outside (0, 100), unknown methods, negative
+// floors and a threshold percentile that is not computed.
+func validateStats(s StatsConfig) error {
+    names := map[string]bool{"p50": true, "p95": true}
+    for _, p := range s.Percentiles {
+        if p <= 0 || p >= 100 {
+            return fmt.Errorf("stats.percentiles: %v is not between 0 and 100", p)
+        }
+        names["p"+strconv.FormatFloat(p, 'f', -1, 64)] = true
+    }
+    switch s.PercentileMethod {
+    case "", "nearest_rank", "linear":
+    default:
+        return fmt.Errorf("stats.percentile_method: invalid method %q (want nearest_rank or linear)", s.PercentileMethod)
+    }
+    if s.MADEpsilon < 0 || s.MinThresholdMs < 0 || s.MinExcessMs < 0 {
+        return fmt.Errorf("stats: mad_epsilon, min_threshold_ms and min_excess_ms must not be negative")
+    }
+    if s.ThresholdPercentile != "" && !names[s.ThresholdPercentile] {
+        return fmt.Errorf("stats.threshold_percentile: %q is not p50, p95 or one of stats.percentiles", s.ThresholdPercentile)
+    }
+    return validateModel("stats", s.Model, s.HalfLife)
+}
+
+// validateModel checks one effective baseline model choice; field names it in errors.
+func validateModel(field, model string, halfLife time.Duration) error {
+    switch model {
+    case "", StatsModelWindow:
+    case StatsModelDecayed:
+        if halfLife <= 0 {
+            return fmt.Errorf("%s.half_life: must be positive for the decayed model", field)
+        }
+    default:
+        return fmt.Errorf("%s.model: invalid model %q (want window or decayed)", field, model)
+    }
+    return nil
+}
+
+// validateTenancy rejects tenant names that cannot be used as a key prefix.
+func validateTenancy(t TenancyConfig) error {
+    names := []string{t.DefaultTenant}
+    for _, tc := range t.Tenants {
+        if tc.Name == "" {
+            return fmt.Errorf("tenancy.tenants: tenant name must not be empty")
+        }
+        names = append(names, tc.Name)
+    }
+    for _, n := range names {
+        for _, r := range n {
+            if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+                return fmt.Errorf("invalid tenant name %q: only letters, digits, '-', '_' and '.' are allowed", n)
+            }
+        }
+    }
+    return nil
 }
 
 // Load reads configuration from a YAML file (if provided) and environment variables.
 // - filePath: optional path to a YAML config file. If empty, it will search common locations.
 // Environment variables override file/defaults automatically. Example env vars:
-//   REDIS_HOST, REDIS_PORT, TEMPO_URL, TEMPO_AUTH_TOKEN, TIMEZONE,
+//   STORE_BACKEND, STORE_DATA_DIR, REDIS_HOST, REDIS_PORT, REDIS_USERNAME,
+//   REDIS_MASTER_NAME, REDIS_SENTINEL_ADDRS, REDIS_CLUSTER_ADDRS (comma-separated),
+//   REDIS_TLS_ENABLED, REDIS_TLS_CA_FILE, TEMPO_URL, TEMPO_AUTH_TOKEN, TIMEZONE,
 //   STATS_FACTOR, STATS_K, STATS_MIN_SAMPLES, STATS_MAD_EPSILON,
+//   STATS_MIN_THRESHOLD_MS, STATS_MIN_EXCESS_MS, STATS_MODEL, STATS_HALF_LIFE,
+//   DETECTION_DETECTOR, DETECTION_Z_THRESHOLD, DETECTION_SLO_MS,
+//   SEVERITY_WARNING, SEVERITY_CRITICAL, GUARD_MODE, GUARD_MULTIPLE, GUARD_WEIGHT,
+//   DRIFT_HISTORY, DRIFT_WARMUP_DAYS, DRIFT_SLACK, DRIFT_THRESHOLD,
+//   ERROR_RATE_Z, ERROR_RATE_MIN_REQUESTS, ERROR_RATE_MIN_OBSERVED,
 //   POLLING_TEMPO_INTERVAL, POLLING_TEMPO_LOOKBACK, POLLING_BASELINE_INTERVAL,
-//   WINDOW_SIZE, DEDUP_TTL, HTTP_PORT, HTTP_TIMEOUT
+//   POLLING_BASELINE_LEASE, POLLING_BASELINE_MAX_RETRIES,
+//   POLLING_PRUNE_INTERVAL, WINDOW_SIZE, RETENTION, DEDUP_TTL, HTTP_PORT, HTTP_TIMEOUT,
+//   HTTP_ADMIN_TOKEN, TENANCY_HEADER, TENANCY_DEFAULT_TENANT,
+//   BUCKETING_SCHEME, BUCKETING_SLOT_MINUTES, BUCKETING_SCHEDULE,
+//   HOLIDAYS_FILE, HOLIDAYS_DAY_TYPE, HOLIDAYS_RELOAD_INTERVAL
 func Load(filePath string) (*Config, error) {
     v := viper.New()
 
@@ -108,17 +498,70 @@ func Load(filePath string) (*Config, error) {
     decoder := func(c *mapstructure.DecoderConfig) {
         c.TagName = "mapstructure"
         c.DecodeHook = mapstructure.ComposeDecodeHookFunc(
+            stringToDaysDurationHookFunc(),
             mapstructure.StringToTimeDurationHookFunc(),
+            mapstructure.StringToSliceHookFunc(","),
         )
     }
 
     if err := v.Unmarshal(&cfg, decoder); err != nil {
         return nil, fmt.Errorf("unmarshal config: %w", err)
     }
+    if err := validateStats(cfg.Stats); err != nil {
+        return nil, err
+    }
+    if err := validateDetection(cfg.Detection); err != nil {
+        return nil, err
+    }
+    if err := compileRules(cfg.Rules, cfg.Stats, cfg.Detection); err != nil {
+        return nil, err
+    }
+    if err := compileTimezones(cfg.Timezones); err != nil {
+        return nil, err
+    }
+    if err := validateSeverity(cfg.Severity); err != nil {
+        return nil, err
+    }
+    if err := validateGuard(cfg.Guard); err != nil {
+        return nil, err
+    }
+    if err := validateDrift(cfg.Drift); err != nil {
+        return nil, err
+    }
+    if err := validateErrorRate(cfg.ErrorRate); err != nil {
+        return nil, err
+    }
+    if err := validateTenancy(cfg.Tenancy); err != nil {
+        return nil, err
+    }
+    buckets, err := compileBucketing(cfg.Bucketing, cfg.Holidays, cfg.bucketTimezones(), true)
+    if err != nil {
+        return nil, err
+    }
+    cfg.Bucketing.compiled = &buckets
 
     return &cfg, nil
 }
 
+// stringToDaysDurationHookFunc accepts whole-day durations such as "14d",
+// which time.ParseDuration does not support.
+func stringToDaysDurationHookFunc() mapstructure.DecodeHookFuncType {
+    return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+        if f.Kind() != reflect.String || t != reflect.TypeOf(time.Duration(0)) {
+            return data, nil
+        }
+        s := strings.TrimSpace(data.(string))
+        if !strings.HasSuffix(s, "d") {
+            return data, nil
+        }
+        days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+        if err != nil {
+            return data, nil
+        }
+        return time.Duration(days) * 24 * time.Hour, nil
+    }
+}
+
 // MustLoad is a helper that panics on error.
 func MustLoad(filePath string) *Config {
     cfg, err := Load(filePath)
@@ -3,9 +3,11 @@ package config
 import (
     "os"
     "path/filepath"
+    "strings"
     "testing"
     "time"
 
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
     "github.com/stretchr/testify/assert"
 )
 
@@ -14,6 +16,8 @@ import (
 func setDefaultLikeEnv(t *testing.T) {
     t.Helper()
     t.Setenv("TIMEZONE", DefaultTimezone)
+    t.Setenv("STORE_BACKEND", DefaultStoreBackend)
+    t.Setenv("STORE_DATA_DIR", DefaultStoreDataDir)
     t.Setenv("REDIS_HOST", "127.0.0.1")
     t.Setenv("REDIS_PORT", "6379")
     t.Setenv("REDIS_PASSWORD", "")
@@ -26,20 +30,60 @@ func setDefaultLikeEnv(t *testing.T) {
     t.Setenv("STATS_K", "10")
     t.Setenv("STATS_MIN_SAMPLES", "50")
     t.Setenv("STATS_MAD_EPSILON", DefaultMadepsilon.String())
+    t.Setenv("STATS_MIN_THRESHOLD_MS", "0")
+    t.Setenv("STATS_MIN_EXCESS_MS", "0")
+    t.Setenv("STATS_PERCENTILES", "90,99,99.9")
+    t.Setenv("STATS_PERCENTILE_METHOD", DefaultPercentileMethod)
+    t.Setenv("STATS_THRESHOLD_PERCENTILE", DefaultThresholdPercentile)
+    t.Setenv("STATS_MODEL", DefaultStatsModel)
+    t.Setenv("STATS_HALF_LIFE", DefaultHalfLife.String())
+
+    t.Setenv("DETECTION_DETECTOR", DefaultDetector)
+    t.Setenv("DETECTION_Z_THRESHOLD", "3.5")
+    t.Setenv("DETECTION_IQR_MULTIPLIER", "1.5")
+    t.Setenv("DETECTION_RANK_PERCENTILE", "99")
+    t.Setenv("DETECTION_SLO_MS", "0")
+    t.Setenv("SEVERITY_WARNING", "1")
+    t.Setenv("SEVERITY_CRITICAL", "2")
+    t.Setenv("GUARD_MODE", DefaultGuardMode)
+    t.Setenv("GUARD_MULTIPLE", "3")
+    t.Setenv("GUARD_WEIGHT", "0.1")
+    t.Setenv("DRIFT_HISTORY", DefaultDriftHistory.String())
+    t.Setenv("DRIFT_WARMUP_DAYS", "7")
+    t.Setenv("DRIFT_SLACK", "0.05")
+    t.Setenv("DRIFT_THRESHOLD", "0.5")
+    t.Setenv("ERROR_RATE_Z", "3")
+    t.Setenv("ERROR_RATE_MIN_REQUESTS", "100")
+    t.Setenv("ERROR_RATE_MIN_OBSERVED", "20")
 
     t.Setenv("POLLING_TEMPO_INTERVAL", DefaultTempoInterval.String())
     t.Setenv("POLLING_TEMPO_LOOKBACK", DefaultTempoLookback.String())
     t.Setenv("POLLING_BASELINE_INTERVAL", DefaultBaselineInterval.String())
+    t.Setenv("POLLING_BASELINE_LEASE", DefaultBaselineLease.String())
+    t.Setenv("POLLING_BASELINE_MAX_RETRIES", "5")
     t.Setenv("POLLING_BACKFILL_ENABLED", "true")
     t.Setenv("POLLING_BACKFILL_DURATION", DefaultBackfillDuration.String())
     t.Setenv("POLLING_BACKFILL_BATCH", DefaultBackfillBatch.String())
+    t.Setenv("POLLING_PRUNE_INTERVAL", DefaultPruneInterval.String())
 
     t.Setenv("WINDOW_SIZE", "1000")
+    t.Setenv("RETENTION", DefaultRetention.String())
     t.Setenv("DEDUP_TTL", DefaultDedupTTL.String())
 
     t.Setenv("HTTP_PORT", "8080")
     t.Setenv("HTTP_TIMEOUT", DefaultHTTPTimeout.String())
 
+    t.Setenv("TENANCY_HEADER", DefaultTenantHeader)
+    t.Setenv("TENANCY_DEFAULT_TENANT", "")
+
+    t.Setenv("BUCKETING_SCHEME", DefaultBucketingScheme)
+    t.Setenv("BUCKETING_SLOT_MINUTES", "30")
+    t.Setenv("BUCKETING_SCHEDULE", "")
+
+    t.Setenv("HOLIDAYS_FILE", "")
+    t.Setenv("HOLIDAYS_DAY_TYPE", DefaultHolidaysDayType)
+    t.Setenv("HOLIDAYS_RELOAD_INTERVAL", DefaultHolidaysReloadInterval.String())
+
     t.Setenv("FALLBACK_ENABLED", "true")
     t.Setenv("FALLBACK_NEARBY_HOURS_ENABLED", "true")
     t.Setenv("FALLBACK_NEARBY_HOURS_RANGE", "2")
@@ -48,6 +92,7 @@ func setDefaultLikeEnv(t *testing.T) {
     t.Setenv("FALLBACK_DAYTYPE_GLOBAL_MIN_SAMPLES", "50")
     t.Setenv("FALLBACK_FULL_GLOBAL_ENABLED", "true")
     t.Setenv("FALLBACK_FULL_GLOBAL_MIN_SAMPLES", "30")
+    t.Setenv("FALLBACK_CACHE_TTL", DefaultFallbackCacheTTL.String())
 }
 
 func TestLoad_Defaults(t *testing.T) {
@@ -57,23 +102,59 @@ func TestLoad_Defaults(t *testing.T) {
     assert.NoError(t, err)
 
     assert.Equal(t, DefaultTimezone, cfg.Timezone)
+    assert.Equal(t, DefaultStoreBackend, cfg.Store.Backend)
+    assert.Equal(t, DefaultStoreDataDir, cfg.Store.DataDir)
     assert.Equal(t, 6379, cfg.Redis.Port)
     assert.Equal(t, DefaultFactor, cfg.Stats.Factor)
     assert.Equal(t, DefaultK, cfg.Stats.K)
     assert.Equal(t, DefaultMinSamples, cfg.Stats.MinSamples)
     assert.Equal(t, DefaultMadepsilon, cfg.Stats.MADEpsilon)
+    assert.Equal(t, 0.0, cfg.Stats.MinThresholdMs)
+    assert.Equal(t, 0.0, cfg.Stats.MinExcessMs)
+    assert.Equal(t, DefaultPercentiles, cfg.Stats.Percentiles)
+    assert.Equal(t, DefaultPercentileMethod, cfg.Stats.PercentileMethod)
+    assert.Equal(t, DefaultThresholdPercentile, cfg.Stats.ThresholdPercentile)
+    assert.Equal(t, DefaultStatsModel, cfg.Stats.Model)
+    assert.Equal(t, DefaultHalfLife, cfg.Stats.HalfLife)
+    assert.Equal(t, DefaultDetector, cfg.Detection.Detector)
+    assert.Equal(t, DefaultZThreshold, cfg.Detection.ZThreshold)
+    assert.Equal(t, DefaultIQRMultiplier, cfg.Detection.IQRMultiplier)
+    assert.Equal(t, DefaultRankPercentile, cfg.Detection.RankPercentile)
+    assert.Equal(t, DefaultSeverityWarning, cfg.Severity.Warning)
+    assert.Equal(t, DefaultSeverityCritical, cfg.Severity.Critical)
+    assert.Equal(t, DefaultGuardMode, cfg.Guard.Mode)
+    assert.Equal(t, DefaultGuardMultiple, cfg.Guard.Multiple)
+    assert.Equal(t, DefaultGuardWeight, cfg.Guard.Weight)
+    assert.False(t, cfg.Guard.Enabled())
+    assert.Equal(t, DefaultDriftHistory, cfg.Drift.History)
+    assert.Equal(t, DefaultDriftWarmupDays, cfg.Drift.WarmupDays)
+    assert.Equal(t, DefaultDriftSlack, cfg.Drift.Slack)
+    assert.Equal(t, DefaultDriftThreshold, cfg.Drift.Threshold)
+    assert.Equal(t, DefaultErrorRateZ, cfg.ErrorRate.Z)
+    assert.Equal(t, DefaultErrorRateMinRequests, cfg.ErrorRate.MinRequests)
+    assert.Equal(t, DefaultErrorRateMinObserved, cfg.ErrorRate.MinObserved)
 
     assert.Equal(t, DefaultTempoInterval, cfg.Polling.TempoInterval)
     assert.Equal(t, DefaultTempoLookback, cfg.Polling.TempoLookback)
     assert.Equal(t, DefaultBaselineInterval, cfg.Polling.BaselineInterval)
+    assert.Equal(t, DefaultBaselineLease, cfg.Polling.BaselineLease)
+    assert.Equal(t, DefaultBaselineMaxRetries, cfg.Polling.BaselineMaxRetries)
     assert.Equal(t, DefaultBackfillEnabled, cfg.Polling.BackfillEnabled)
     assert.Equal(t, DefaultBackfillDuration, cfg.Polling.BackfillDuration)
     assert.Equal(t, DefaultBackfillBatch, cfg.Polling.BackfillBatch)
+    assert.Equal(t, DefaultPruneInterval, cfg.Polling.PruneInterval)
 
     assert.Equal(t, DefaultWindowSize, cfg.WindowSize)
+    assert.Equal(t, DefaultRetention, cfg.Retention)
     assert.Equal(t, DefaultDedupTTL, cfg.Dedup.TTL)
     assert.Equal(t, DefaultHTTPPort, cfg.HTTP.Port)
     assert.Equal(t, DefaultHTTPTimeout, cfg.HTTP.Timeout)
+    assert.Equal(t, DefaultBucketingScheme, cfg.Bucketing.Scheme)
+    assert.Equal(t, DefaultBucketingSlotMinutes, cfg.Bucketing.SlotMinutes)
+    assert.Equal(t, domain.SchemeWeekdayHour, cfg.TimeBuckets().Scheme)
+    assert.Equal(t, DefaultHolidaysDayType, cfg.Holidays.DayType)
+    assert.Equal(t, DefaultHolidaysReloadInterval, cfg.Holidays.ReloadInterval)
+    assert.Nil(t, cfg.TimeBuckets().Calendar)
 
     assert.Equal(t, DefaultFallbackEnabled, cfg.Fallback.Enabled)
     assert.Equal(t, DefaultFallbackNearbyHoursEnabled, cfg.Fallback.NearbyHoursEnabled)
@@ -83,6 +164,7 @@ func TestLoad_Defaults(t *testing.T) {
     assert.Equal(t, DefaultFallbackDayTypeGlobalMinSamples, cfg.Fallback.DayTypeGlobalMinSamples)
     assert.Equal(t, DefaultFallbackFullGlobalEnabled, cfg.Fallback.FullGlobalEnabled)
     assert.Equal(t, DefaultFallbackFullGlobalMinSamples, cfg.Fallback.FullGlobalMinSamples)
+    assert.Equal(t, DefaultFallbackCacheTTL, cfg.Fallback.CacheTTL)
 }
 
 func TestLoad_FromFileOverrides(t *testing.T) {
@@ -94,6 +176,8 @@ func TestLoad_FromFileOverrides(t *testing.T) {
     t.Setenv("STATS_K", "7")
     t.Setenv("STATS_MIN_SAMPLES", "5")
     t.Setenv("STATS_MAD_EPSILON", "2ms")
+    t.Setenv("STATS_MIN_THRESHOLD_MS", "20")
+    t.Setenv("STATS_MIN_EXCESS_MS", "5")
     t.Setenv("POLLING_TEMPO_INTERVAL", "10s")
     t.Setenv("POLLING_TEMPO_LOOKBACK", "1m")
     t.Setenv("POLLING_BASELINE_INTERVAL", "2m")
@@ -112,6 +196,8 @@ func TestLoad_FromFileOverrides(t *testing.T) {
     t.Setenv("FALLBACK_DAYTYPE_GLOBAL_MIN_SAMPLES", "40")
     t.Setenv("FALLBACK_FULL_GLOBAL_ENABLED", "false")
     t.Setenv("FALLBACK_FULL_GLOBAL_MIN_SAMPLES", "25")
+    t.Setenv("SEVERITY_WARNING", "1.2")
+    t.Setenv("SEVERITY_CRITICAL", "5")
 
     dir := t.TempDir()
     file := filepath.Join(dir, "config.yaml")
@@ -125,6 +211,8 @@ stats:
   k: 7
   min_samples: 5
   mad_epsilon: 2ms
+  min_threshold_ms: 20
+  min_excess_ms: 5
 polling:
   tempo_interval: 10s
   tempo_lookback: 1m
@@ -147,6 +235,10 @@ fallback:
   daytype_global_min_samples: 40
   full_global_enabled: false
   full_global_min_samples: 25
+  cache_ttl: 1m
+severity:
+  warning: 1.2
+  critical: 5
 `)
     if err := os.WriteFile(file, yaml, 0o600); err != nil {
         t.Fatalf("write temp config: %v", err)
@@ -163,6 +255,8 @@ fallback:
     assert.Equal(t, 7, cfg.Stats.K)
     assert.Equal(t, 5, cfg.Stats.MinSamples)
     assert.Equal(t, 2*time.Millisecond, cfg.Stats.MADEpsilon)
+    assert.Equal(t, 20.0, cfg.Stats.MinThresholdMs)
+    assert.Equal(t, 5.0, cfg.Stats.MinExcessMs)
 
     assert.Equal(t, 10*time.Second, cfg.Polling.TempoInterval)
     assert.Equal(t, 1*time.Minute, cfg.Polling.TempoLookback)
@@ -177,6 +271,9 @@ fallback:
     assert.Equal(t, 9090, cfg.HTTP.Port)
     assert.Equal(t, 20*time.Second, cfg.HTTP.Timeout)
 
+    assert.Equal(t, 1.2, cfg.Severity.Warning)
+    assert.Equal(t, 5.0, cfg.Severity.Critical)
+
     assert.Equal(t, true, cfg.Fallback.Enabled)
     assert.Equal(t, false, cfg.Fallback.NearbyHoursEnabled)
     assert.Equal(t, 1, cfg.Fallback.NearbyHoursRange)
@@ -185,4 +282,454 @@ fallback:
     assert.Equal(t, 40, cfg.Fallback.DayTypeGlobalMinSamples)
     assert.Equal(t, false, cfg.Fallback.FullGlobalEnabled)
     assert.Equal(t, 25, cfg.Fallback.FullGlobalMinSamples)
+    assert.Equal(t, time.Minute, cfg.Fallback.CacheTTL)
+}
+
+func TestLoad_RedisTopologyFromEnv(t *testing.T) {
+    setDefaultLikeEnv(t)
+    t.Setenv("REDIS_USERNAME", "anomaly")
+    t.Setenv("REDIS_CLUSTER_ADDRS", "10.0.0.1:6379,10.0.0.2:6379")
+    t.Setenv("REDIS_TLS_ENABLED", "true")
+    t.Setenv("REDIS_TLS_INSECURE_SKIP_VERIFY", "true")
+
+    cfg, err := Load("")
+    assert.NoError(t, err)
+
+    assert.Equal(t, "anomaly", cfg.Redis.Username)
+    assert.Equal(t, []string{"10.0.0.1:6379", "10.0.0.2:6379"}, cfg.Redis.ClusterAddrs)
+    assert.Empty(t, cfg.Redis.MasterName)
+    assert.True(t, cfg.Redis.TLS.Enabled)
+    assert.True(t, cfg.Redis.TLS.InsecureSkipVerify)
+}
+
+func TestLoad_RetentionInDays(t *testing.T) {
+    setDefaultLikeEnv(t)
+    t.Setenv("RETENTION", "30d")
+
+    cfg, err := Load("")
+    assert.NoError(t, err)
+    assert.Equal(t, 30*24*time.Hour, cfg.Retention)
+}
+
+func TestLoad_Tenants(t *testing.T) {
+    setDefaultLikeEnv(t)
+    t.Setenv("TEMPO_URL", "http://tempo:3200")
+
+    dir := t.TempDir()
+    file := filepath.Join(dir, "config.yaml")
+    yaml := []byte(`
+tenancy:
+  default_tenant: prod
+  tenants:
+    - name: staging
+      tempo:
+        url: http://tempo-staging:3200
+    - name: team-b
+      polling_disabled: true
+`)
+    if err := os.WriteFile(file, yaml, 0o600); err != nil {
+        t.Fatalf("write temp config: %v", err)
+    }
+
+    cfg, err := Load(file)
+    assert.NoError(t, err)
+    assert.Equal(t, DefaultTenantHeader, cfg.Tenancy.Header)
+    assert.Equal(t, []string{"prod", "staging", "team-b"}, cfg.TenantNames())
+    assert.True(t, cfg.HasTenant("team-b"))
+    assert.False(t, cfg.HasTenant("other"))
+    assert.Equal(t, "http://tempo-staging:3200", cfg.Tenant("staging").Tempo.URL)
+    assert.Equal(t, "http://tempo:3200", cfg.Tenant("prod").Tempo.URL, "tempo settings are inherited")
+    assert.True(t, cfg.Tenant("team-b").PollingDisabled)
+
+    t.Setenv("TENANCY_DEFAULT_TENANT", "bad:name")
+    _, err = Load(file)
+    assert.Error(t, err)
+}
+
+func TestLoad_Percentiles(t *testing.T) {
+    dir := t.TempDir()
+    file := filepath.Join(dir, "config.yaml")
+    yaml := []byte(`
+stats:
+  percentiles: [99, 99.9]
+  percentile_method: linear
+  threshold_percentile: p99
+`)
+    if err := os.WriteFile(file, yaml, 0o600); err != nil {
+        t.Fatalf("write temp config: %v", err)
+    }
+
+    cfg, err := Load(file)
+    assert.NoError(t, err)
+    assert.Equal(t, []float64{99, 99.9}, cfg.Stats.Percentiles)
+    assert.Equal(t, "linear", cfg.Stats.PercentileMethod)
+    assert.Equal(t, "p99", cfg.Stats.ThresholdPercentile)
+
+    // The threshold may only reference a computed percentile
+    t.Setenv("STATS_THRESHOLD_PERCENTILE", "p99.99")
+    _, err = Load(file)
+    assert.ErrorContains(t, err, "threshold_percentile")
+}
+
+func TestLoad_DecayedModel(t *testing.T) {
+    dir := t.TempDir()
+    file := filepath.Join(dir, "config.yaml")
+    yaml := []byte(`
+rules:
+  - name: checkout
+    service: checkout
+    model: decayed
+    half_life: 12h
+`)
+    if err := os.WriteFile(file, yaml, 0o600); err != nil {
+        t.Fatalf("write temp config: %v", err)
+    }
+
+    cfg, err := Load(file)
+    if !assert.NoError(t, err) {
+        return
+    }
+    s := cfg.SettingsFor("checkout", "POST /pay")
+    assert.Equal(t, StatsModelDecayed, s.Stats.Model)
+    assert.Equal(t, 12*time.Hour, s.Stats.HalfLife)
+    assert.Equal(t, StatsModelWindow, cfg.SettingsFor("orders", "GET /").Stats.Model)
+
+    t.Setenv("STATS_MODEL", "ewma")
+    _, err = Load(file)
+    assert.ErrorContains(t, err, "stats.model")
+
+    t.Setenv("STATS_MODEL", StatsModelDecayed)
+    t.Setenv("STATS_HALF_LIFE", "0s")
+    _, err = Load(file)
+    assert.ErrorContains(t, err, "stats.half_life")
+}
+
+func TestLoad_Guard(t *testing.T) {
+    t.Setenv("GUARD_MODE", GuardModeWinsorize)
+    t.Setenv("GUARD_MULTIPLE", "4")
+
+    cfg, err := Load("")
+    if !assert.NoError(t, err) {
+        return
+    }
+    assert.True(t, cfg.Guard.Enabled())
+    assert.Equal(t, GuardModeWinsorize, cfg.Guard.Mode)
+    assert.Equal(t, 4.0, cfg.Guard.Multiple)
+
+    t.Setenv("GUARD_MULTIPLE", "0.5")
+    _, err = Load("")
+    assert.ErrorContains(t, err, "guard.multiple")
+
+    t.Setenv("GUARD_MULTIPLE", "3")
+    t.Setenv("GUARD_MODE", GuardModeDownweight)
+    t.Setenv("GUARD_WEIGHT", "1.5")
+    _, err = Load("")
+    assert.ErrorContains(t, err, "guard.weight")
+
+    t.Setenv("GUARD_MODE", "drop")
+    _, err = Load("")
+    assert.ErrorContains(t, err, "guard.mode")
+}
+
+func TestLoad_Drift(t *testing.T) {
+    t.Setenv("DRIFT_HISTORY", "30d")
+    t.Setenv("DRIFT_WARMUP_DAYS", "3")
+
+    cfg, err := Load("")
+    if !assert.NoError(t, err) {
+        return
+    }
+    assert.Equal(t, 30*24*time.Hour, cfg.Drift.History)
+    assert.Equal(t, 3, cfg.Drift.WarmupDays)
+
+    t.Setenv("DRIFT_WARMUP_DAYS", "0")
+    _, err = Load("")
+    assert.ErrorContains(t, err, "drift.warmup_days")
+
+    t.Setenv("DRIFT_WARMUP_DAYS", "7")
+    t.Setenv("DRIFT_THRESHOLD", "0")
+    _, err = Load("")
+    assert.ErrorContains(t, err, "threshold")
+}
+
+func TestLoad_ErrorRate(t *testing.T) {
+    t.Setenv("ERROR_RATE_Z", "2.5")
+    t.Setenv("ERROR_RATE_MIN_REQUESTS", "50")
+
+    cfg, err := Load("")
+    if !assert.NoError(t, err) {
+        return
+    }
+    assert.Equal(t, 2.5, cfg.ErrorRate.Z)
+    assert.Equal(t, 50, cfg.ErrorRate.MinRequests)
+
+    t.Setenv("ERROR_RATE_Z", "-1")
+    _, err = Load("")
+    assert.ErrorContains(t, err, "error_rate.z")
+
+    t.Setenv("ERROR_RATE_Z", "3")
+    t.Setenv("ERROR_RATE_MIN_OBSERVED", "-1")
+    _, err = Load("")
+    assert.ErrorContains(t, err, "min_observed")
+}
+
+func TestLoad_Detection(t *testing.T) {
+    dir := t.TempDir()
+    file := filepath.Join(dir, "config.yaml")
+
+    // slo without a threshold is rejected
+    yaml := []byte(`
+detection:
+  detector: slo
+`)
+    if err := os.WriteFile(file, yaml, 0o600); err != nil {
+        t.Fatalf("write temp config: %v", err)
+    }
+    t.Setenv("DETECTION_DETECTOR", "slo")
+    _, err := Load(file)
+    assert.ErrorContains(t, err, "slo_ms")
+}
+
+func TestLoad_Rules(t *testing.T) {
+    dir := t.TempDir()
+    file := filepath.Join(dir, "config.yaml")
+    yaml := []byte(`
+rules:
+  - name: health-checks
+    endpoint: "GET /actuator/*"
+    factor: 5
+    floor_ms: 50
+    max_fallback_level: 1
+  - name: batch
+    service: "re:^batch-(jobs|etl)$"
+    detector: slo
+    slo_ms: 30000
+    min_samples: 5
+  - service: "orders"
+    endpoint: "db.?uery"
+    k: 20
+  - service: api
+    endpoint: "GET /users"
+    detector: iqr
+`)
+    if err := os.WriteFile(file, yaml, 0o600); err != nil {
+        t.Fatalf("write temp config: %v", err)
+    }
+
+    cfg, err := Load(file)
+    if !assert.NoError(t, err) {
+        return
+    }
+
+    s := cfg.SettingsFor("api", "GET /actuator/health")
+    assert.Equal(t, "health-checks", s.Rule)
+    assert.Equal(t, 5.0, s.Stats.Factor)
+    assert.Equal(t, cfg.Stats.K, s.Stats.K, "unset fields are inherited")
+    assert.Equal(t, 50.0, s.Stats.MinThresholdMs)
+    assert.False(t, s.Fallback.NearbyHoursEnabled)
+    assert.False(t, s.Fallback.FullGlobalEnabled)
+
+    s = cfg.SettingsFor("batch-etl", "nightly")
+    assert.Equal(t, "batch", s.Rule)
+    assert.Equal(t, "slo", s.Detection.Detector)
+    assert.Equal(t, 30000.0, s.Detection.SLOMs)
+    assert.Equal(t, 5, s.Stats.MinSamples)
+    assert.Equal(t, cfg.Fallback.NearbyHoursEnabled, s.Fallback.NearbyHoursEnabled)
+
+    assert.Equal(t, "rules[2]", cfg.SettingsFor("orders", "db.query").Rule)
+    assert.Equal(t, 20, cfg.SettingsFor("orders", "db.query").Stats.K)
+
+    s = cfg.SettingsFor("batch-jobs-v2", "GET /")
+    assert.Equal(t, "", s.Rule)
+    assert.Equal(t, cfg.Stats, s.Stats)
+
+    // A pattern without wildcards matches one endpoint exactly
+    s = cfg.SettingsFor("api", "GET /users")
+    assert.Equal(t, "iqr", s.Detection.Detector)
+    assert.Equal(t, DefaultZThreshold, s.Detection.ZThreshold, "unset parameters are inherited")
+    assert.Equal(t, DefaultDetector, cfg.SettingsFor("api", "GET /users/1").Detection.Detector)
+    // iqr needs p25/p75 stored with the baselines
+    assert.Equal(t, []float64{90, 99, 99.9, 25, 75}, cfg.BaselinePercentiles())
+
+    // Invalid patterns are rejected at load time
+    yaml = []byte(`
+rules:
+  - service: "re:("
+`)
+    if err := os.WriteFile(file, yaml, 0o600); err != nil {
+        t.Fatalf("write temp config: %v", err)
+    }
+    _, err = Load(file)
+    assert.ErrorContains(t, err, "rules[0].service")
+}
+
+func TestLoad_BucketingSchedule(t *testing.T) {
+    dir := t.TempDir()
+    file := filepath.Join(dir, "config.yaml")
+    yaml := []byte(`
+bucketing:
+  scheme: schedule
+  schedule: office
+  schedules:
+    - name: office
+      default: off_hours
+      periods:
+        - name: business_hours
+          days: [mon, tue, wed, thu, fri]
+          start: "09:00"
+          end: "18:00"
+`)
+    if err := os.WriteFile(file, yaml, 0o600); err != nil {
+        t.Fatalf("write temp config: %v", err)
+    }
+    t.Setenv("BUCKETING_SCHEME", "schedule")
+    t.Setenv("BUCKETING_SCHEDULE", "office")
+
+    cfg, err := Load(file)
+    if !assert.NoError(t, err) {
+        return
+    }
+    b := cfg.TimeBuckets()
+    assert.Equal(t, []string{"business_hours", "off_hours"}, b.DayTypes())
+
+    loc, _ := time.LoadLocation("Asia/Taipei")
+    assert.Equal(t, "business_hours", b.BucketAt(time.Date(2024, 1, 8, 9, 0, 0, 0, loc)).DayType)
+    assert.Equal(t, "off_hours", b.BucketAt(time.Date(2024, 1, 8, 18, 0, 0, 0, loc)).DayType)
+    assert.Equal(t, "off_hours", b.BucketAt(time.Date(2024, 1, 6, 10, 0, 0, 0, loc)).DayType)
+
+    // Unknown schedules and slots that do not divide an hour are rejected
+    t.Setenv("BUCKETING_SCHEDULE", "nope")
+    _, err = Load(file)
+    assert.ErrorContains(t, err, "bucketing.schedule")
+
+    t.Setenv("BUCKETING_SCHEME", "slot")
+    t.Setenv("BUCKETING_SLOT_MINUTES", "25")
+    _, err = Load(file)
+    assert.ErrorContains(t, err, "slot_minutes")
+}
+
+func TestLoad_HolidayCalendar(t *testing.T) {
+    setDefaultLikeEnv(t)
+    dir := t.TempDir()
+    cal := filepath.Join(dir, "holidays.yaml")
+    write := func(content string) {
+        if err := os.WriteFile(cal, []byte(content), 0o600); err != nil {
+            t.Fatalf("write calendar: %v", err)
+        }
+    }
+    write(`
+holidays:
+  - 2025-01-01
+  - date: "2025-02-28"
+    name: Peace Memorial Day
+workdays:
+  - date: 2025-02-08
+    name: make-up workday
+`)
+    t.Setenv("HOLIDAYS_FILE", cal)
+
+    cfg, err := Load("")
+    if !assert.NoError(t, err) {
+        return
+    }
+    b := cfg.TimeBuckets()
+    loc, _ := time.LoadLocation("Asia/Taipei")
+    assert.Equal(t, "weekend", b.BucketAt(time.Date(2025, 2, 28, 10, 0, 0, 0, loc)).DayType, "Friday holiday")
+    assert.Equal(t, "weekday", b.BucketAt(time.Date(2025, 2, 8, 10, 0, 0, 0, loc)).DayType, "Saturday make-up workday")
+    assert.Equal(t, "weekday", b.BucketAt(time.Date(2025, 2, 27, 10, 0, 0, 0, loc)).DayType)
+
+    // Reloading swaps the days of the calendar already in use
+    write("holidays:\n  - 2025-02-27\n")
+    n, err := cfg.ReloadHolidays()
+    assert.NoError(t, err)
+    assert.Equal(t, 1, n)
+    assert.Equal(t, "weekend", b.BucketAt(time.Date(2025, 2, 27, 10, 0, 0, 0, loc)).DayType)
+    assert.Equal(t, "weekday", b.BucketAt(time.Date(2025, 2, 28, 10, 0, 0, 0, loc)).DayType)
+
+    // A broken file keeps the previous calendar
+    write("holidays:\n  - not-a-date\n")
+    _, err = cfg.ReloadHolidays()
+    assert.ErrorContains(t, err, "holidays[0]")
+    assert.Equal(t, "weekend", b.BucketAt(time.Date(2025, 2, 27, 10, 0, 0, 0, loc)).DayType)
+
+    // Holidays may get a day type of their own
+    write("holidays:\n  - 2025-01-01\n")
+    t.Setenv("HOLIDAYS_DAY_TYPE", "holiday")
+    cfg, err = Load("")
+    if assert.NoError(t, err) {
+        assert.Equal(t, []string{"weekday", "weekend", "holiday"}, cfg.TimeBuckets().DayTypes())
+    }
+}
+
+func TestParseICS(t *testing.T) {
+    ics := strings.Join([]string{
+        "BEGIN:VCALENDAR",
+        "BEGIN:VEVENT",
+        "DTSTART;VALUE=DATE:20250127",
+        "DTEND;VALUE=DATE:20250130",
+        "SUMMARY:Lunar New Year\\, Eve to Day 2",
+        "END:VEVENT",
+        "BEGIN:VEVENT",
+        "DTSTART;VALUE=DATE:20250208",
+        "SUMMARY:Make-up",
+        "  workday",
+        "CATEGORIES:WORKDAY",
+        "END:VEVENT",
+        "BEGIN:VEVENT",
+        "DTSTART:20250404T000000Z",
+        "SUMMARY:Children's Day",
+        "END:VEVENT",
+        "END:VCALENDAR",
+    }, "\r\n")
+
+    days, err := ParseICS(strings.NewReader(ics))
+    if !assert.NoError(t, err) {
+        return
+    }
+    assert.Equal(t, []domain.CalendarDay{
+        {Date: "2025-01-27", Name: "Lunar New Year, Eve to Day 2"},
+        {Date: "2025-01-28", Name: "Lunar New Year, Eve to Day 2"},
+        {Date: "2025-01-29", Name: "Lunar New Year, Eve to Day 2"},
+        {Date: "2025-02-08", Name: "Make-up workday", Workday: true},
+        {Date: "2025-04-04", Name: "Children's Day"},
+    }, days)
+
+    _, err = ParseICS(strings.NewReader("BEGIN:VEVENT\nDTSTART:2025\nEND:VEVENT\n"))
+    assert.Error(t, err)
+}
+
+func TestLoad_Timezones(t *testing.T) {
+    dir := t.TempDir()
+    file := filepath.Join(dir, "config.yaml")
+    yaml := []byte(`
+timezone: Asia/Taipei
+timezones:
+  - service: "eu-*"
+    timezone: Europe/Berlin
+  - service: "re:^us-(east|checkout)$"
+    timezone: America/New_York
+`)
+    if err := os.WriteFile(file, yaml, 0o600); err != nil {
+        t.Fatalf("write temp config: %v", err)
+    }
+    t.Setenv("TIMEZONE", "Asia/Taipei")
+
+    cfg, err := Load(file)
+    if !assert.NoError(t, err) {
+        return
+    }
+    assert.Equal(t, "Europe/Berlin", cfg.TimezoneFor("eu-orders"))
+    assert.Equal(t, "America/New_York", cfg.TimezoneFor("us-east"))
+    assert.Equal(t, "Asia/Taipei", cfg.TimezoneFor("us-west"))
+
+    yaml = []byte(`
+timezones:
+  - service: "eu-*"
+    timezone: Europe/Nowhere
+`)
+    if err := os.WriteFile(file, yaml, 0o600); err != nil {
+        t.Fatalf("write temp config: %v", err)
+    }
+    _, err = Load(file)
+    assert.ErrorContains(t, err, "timezones[0].timezone")
 }
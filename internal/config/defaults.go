@@ -9,6 +9,24 @@ import (
 const (
     DefaultTimezone = "Asia/Taipei"
     DefaultWindowSize = 1000
+    DefaultRetention  = time.Duration(0) // no time-based pruning unless configured
+
+    // Store backends
+    StoreBackendRedis   = "redis"
+    StoreBackendMemory  = "memory"
+    StoreBackendFile    = "file"
+    DefaultStoreBackend = StoreBackendRedis
+    DefaultStoreDataDir = "./data"
+
+    // Baseline models
+    StatsModelWindow  = "window"
+    StatsModelDecayed = "decayed"
+
+    // Ingest guard modes
+    GuardModeOff        = "off"
+    GuardModeExclude    = "exclude"
+    GuardModeDownweight = "downweight"
+    GuardModeWinsorize  = "winsorize"
 )
 
 var (
@@ -17,17 +35,54 @@ var (
     DefaultK          = 10
     DefaultMinSamples = 50
     DefaultMadepsilon = time.Millisecond
+    // DefaultPercentiles are the extra quantiles (in percent) stored with every baseline.
+    DefaultPercentiles         = []float64{90, 99, 99.9}
+    DefaultPercentileMethod    = "nearest_rank"
+    DefaultThresholdPercentile = "p95"
+    DefaultStatsModel          = StatsModelWindow
+    DefaultHalfLife            = 72 * time.Hour
+
+    // Detection defaults
+    DefaultDetector       = "hybrid"
+    DefaultZThreshold     = 3.5
+    DefaultIQRMultiplier  = 1.5
+    DefaultRankPercentile = 99.0
+
+    // Severity cut points (score = duration / threshold)
+    DefaultSeverityWarning  = 1.0
+    DefaultSeverityCritical = 2.0
+
+    // Ingest guard defaults
+    DefaultGuardMode     = GuardModeOff
+    DefaultGuardMultiple = 3.0
+    DefaultGuardWeight   = 0.1
+
+    // Drift detection defaults
+    DefaultDriftHistory    = 90 * 24 * time.Hour
+    DefaultDriftWarmupDays = 7
+    DefaultDriftSlack      = 0.05
+    DefaultDriftThreshold  = 0.5
+
+    // Error-rate detection defaults
+    DefaultErrorRateZ           = 3.0
+    DefaultErrorRateMinRequests = 100
+    DefaultErrorRateMinObserved = 20
 
     // Polling defaults
-    DefaultTempoInterval    = 15 * time.Second
-    DefaultTempoLookback    = 120 * time.Second
-    DefaultBaselineInterval = 30 * time.Second
+    DefaultTempoInterval      = 15 * time.Second
+    DefaultTempoLookback      = 120 * time.Second
+    DefaultBaselineInterval   = 30 * time.Second
+    DefaultBaselineLease      = 5 * time.Minute
+    DefaultBaselineMaxRetries = 5
     
     // Backfill defaults
     DefaultBackfillEnabled  = true
     DefaultBackfillDuration = 168 * time.Hour  // 7 days
     DefaultBackfillBatch    = 1 * time.Hour
 
+    // Retention pruner default
+    DefaultPruneInterval = 10 * time.Minute
+
     // Dedup TTL default
     DefaultDedupTTL = 6 * time.Hour
 
@@ -35,6 +90,17 @@ var (
     DefaultHTTPPort    = 8080
     DefaultHTTPTimeout = 15 * time.Second
 
+    // Tenancy defaults
+    DefaultTenantHeader = "X-Tenant-ID"
+
+    // Bucketing defaults
+    DefaultBucketingScheme      = "weekday_hour"
+    DefaultBucketingSlotMinutes = 30
+
+    // Holiday calendar defaults
+    DefaultHolidaysDayType        = "weekend"
+    DefaultHolidaysReloadInterval = time.Minute
+
     // Fallback defaults
     DefaultFallbackEnabled                 = true
     DefaultFallbackNearbyHoursEnabled      = true
@@ -44,16 +110,32 @@ var (
     DefaultFallbackDayTypeGlobalMinSamples = 50
     DefaultFallbackFullGlobalEnabled       = true
     DefaultFallbackFullGlobalMinSamples    = 30
+    DefaultFallbackCacheTTL                = 30 * time.Second
 )
 
 // setDefaults registers all default values on the provided viper instance.
 func setDefaults(v *viper.Viper) {
     v.SetDefault("timezone", DefaultTimezone)
 
+    v.SetDefault("store.backend", DefaultStoreBackend)
+    v.SetDefault("store.data_dir", DefaultStoreDataDir)
+
     v.SetDefault("redis.host", "127.0.0.1")
     v.SetDefault("redis.port", 6379)
+    v.SetDefault("redis.username", "")
     v.SetDefault("redis.password", "")
     v.SetDefault("redis.db", 0)
+    v.SetDefault("redis.master_name", "")
+    v.SetDefault("redis.sentinel_addrs", []string{})
+    v.SetDefault("redis.sentinel_username", "")
+    v.SetDefault("redis.sentinel_password", "")
+    v.SetDefault("redis.cluster_addrs", []string{})
+    v.SetDefault("redis.tls.enabled", false)
+    v.SetDefault("redis.tls.ca_file", "")
+    v.SetDefault("redis.tls.cert_file", "")
+    v.SetDefault("redis.tls.key_file", "")
+    v.SetDefault("redis.tls.server_name", "")
+    v.SetDefault("redis.tls.insecure_skip_verify", false)
 
     v.SetDefault("tempo.url", "http://localhost:3200")
     v.SetDefault("tempo.auth_token", "")
@@ -62,20 +144,65 @@ func setDefaults(v *viper.Viper) {
     v.SetDefault("stats.k", DefaultK)
     v.SetDefault("stats.min_samples", DefaultMinSamples)
     v.SetDefault("stats.mad_epsilon", DefaultMadepsilon.String())
+    v.SetDefault("stats.min_threshold_ms", 0)
+    v.SetDefault("stats.min_excess_ms", 0)
+    v.SetDefault("stats.percentiles", DefaultPercentiles)
+    v.SetDefault("stats.percentile_method", DefaultPercentileMethod)
+    v.SetDefault("stats.threshold_percentile", DefaultThresholdPercentile)
+    v.SetDefault("stats.model", DefaultStatsModel)
+    v.SetDefault("stats.half_life", DefaultHalfLife.String())
+
+    v.SetDefault("detection.detector", DefaultDetector)
+    v.SetDefault("detection.z_threshold", DefaultZThreshold)
+    v.SetDefault("detection.iqr_multiplier", DefaultIQRMultiplier)
+    v.SetDefault("detection.rank_percentile", DefaultRankPercentile)
+    v.SetDefault("detection.slo_ms", 0)
+
+    v.SetDefault("severity.warning", DefaultSeverityWarning)
+    v.SetDefault("severity.critical", DefaultSeverityCritical)
+
+    v.SetDefault("guard.mode", DefaultGuardMode)
+    v.SetDefault("guard.multiple", DefaultGuardMultiple)
+    v.SetDefault("guard.weight", DefaultGuardWeight)
+
+    v.SetDefault("drift.history", DefaultDriftHistory.String())
+    v.SetDefault("drift.warmup_days", DefaultDriftWarmupDays)
+    v.SetDefault("drift.slack", DefaultDriftSlack)
+    v.SetDefault("drift.threshold", DefaultDriftThreshold)
+
+    v.SetDefault("error_rate.z", DefaultErrorRateZ)
+    v.SetDefault("error_rate.min_requests", DefaultErrorRateMinRequests)
+    v.SetDefault("error_rate.min_observed", DefaultErrorRateMinObserved)
 
     v.SetDefault("polling.tempo_interval", DefaultTempoInterval.String())
     v.SetDefault("polling.tempo_lookback", DefaultTempoLookback.String())
     v.SetDefault("polling.baseline_interval", DefaultBaselineInterval.String())
+    v.SetDefault("polling.baseline_lease", DefaultBaselineLease.String())
+    v.SetDefault("polling.baseline_max_retries", DefaultBaselineMaxRetries)
     v.SetDefault("polling.backfill_enabled", DefaultBackfillEnabled)
     v.SetDefault("polling.backfill_duration", DefaultBackfillDuration.String())
     v.SetDefault("polling.backfill_batch", DefaultBackfillBatch.String())
+    v.SetDefault("polling.prune_interval", DefaultPruneInterval.String())
 
     v.SetDefault("window_size", DefaultWindowSize)
+    v.SetDefault("retention", DefaultRetention.String())
 
     v.SetDefault("dedup.ttl", DefaultDedupTTL.String())
 
     v.SetDefault("http.port", DefaultHTTPPort)
     v.SetDefault("http.timeout", DefaultHTTPTimeout.String())
+    v.SetDefault("http.admin_token", "")
+
+    v.SetDefault("tenancy.header", DefaultTenantHeader)
+    v.SetDefault("tenancy.default_tenant", "")
+
+    v.SetDefault("bucketing.scheme", DefaultBucketingScheme)
+    v.SetDefault("bucketing.slot_minutes", DefaultBucketingSlotMinutes)
+    v.SetDefault("bucketing.schedule", "")
+
+    v.SetDefault("holidays.file", "")
+    v.SetDefault("holidays.day_type", DefaultHolidaysDayType)
+    v.SetDefault("holidays.reload_interval", DefaultHolidaysReloadInterval.String())
 
     v.SetDefault("fallback.enabled", DefaultFallbackEnabled)
     v.SetDefault("fallback.nearby_hours_enabled", DefaultFallbackNearbyHoursEnabled)
@@ -85,5 +212,6 @@ func setDefaults(v *viper.Viper) {
     v.SetDefault("fallback.daytype_global_min_samples", DefaultFallbackDayTypeGlobalMinSamples)
     v.SetDefault("fallback.full_global_enabled", DefaultFallbackFullGlobalEnabled)
     v.SetDefault("fallback.full_global_min_samples", DefaultFallbackFullGlobalMinSamples)
+    v.SetDefault("fallback.cache_ttl", DefaultFallbackCacheTTL.String())
 }
 
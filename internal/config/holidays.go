@@ -0,0 +1,250 @@
+package config
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
+    "github.com/spf13/viper"
+)
+
+// HolidaysConfig points to a holiday calendar consulted when bucketing: holidays
+// are bucketed as DayType and make-up workdays like a regular Monday. File is an
+// iCalendar (.ics) or YAML (.yaml/.yml) file; it is re-read when it changes,
+// checked every ReloadInterval (0 disables reloading).
+type HolidaysConfig struct {
+    File           string        `mapstructure:"file" yaml:"file"`
+    DayType        string        `mapstructure:"day_type" yaml:"day_type"`
+    ReloadInterval time.Duration `mapstructure:"reload_interval" yaml:"reload_interval"`
+}
+
+// icsWorkdayCategory marks an iCalendar event as a make-up workday.
+const icsWorkdayCategory = "WORKDAY"
+
+// ReloadHolidays re-reads the holiday calendar file and swaps it into the
+// calendar used for bucketing. It returns the number of days loaded.
+func (c *Config) ReloadHolidays() (int, error) {
+    cal := c.TimeBuckets().Calendar
+    if cal == nil {
+        return 0, fmt.Errorf("no holiday calendar configured")
+    }
+    days, err := LoadCalendarFile(c.Holidays.File)
+    if err != nil {
+        return 0, err
+    }
+    cal.Set(days)
+    return len(days), nil
+}
+
+// newCalendar builds the calendar of h, or nil when no file is configured.
+// Configs not loaded through Load get an empty calendar so the file is not read
+// on every bucketing call.
+func newCalendar(h HolidaysConfig, load bool) (*domain.Calendar, error) {
+    if h.File == "" {
+        return nil, nil
+    }
+    if h.DayType == "" {
+        return nil, fmt.Errorf("holidays.day_type must not be empty")
+    }
+    cal := domain.NewCalendar(h.DayType)
+    if !load {
+        return cal, nil
+    }
+    days, err := LoadCalendarFile(h.File)
+    if err != nil {
+        return nil, err
+    }
+    cal.Set(days)
+    return cal, nil
+}
+
+// LoadCalendarFile reads holidays and make-up workdays from an iCalendar or YAML
+// file, chosen by extension.
+func LoadCalendarFile(path string) ([]domain.CalendarDay, error) {
+    switch strings.ToLower(filepath.Ext(path)) {
+    case ".ics":
+        f, err := os.Open(path)
+        if err != nil {
+            return nil, fmt.Errorf("open holiday calendar: %w", err)
+        }
+        defer f.Close()
+        days, err := ParseICS(f)
+        if err != nil {
+            return nil, fmt.Errorf("parse holiday calendar %s: %w", path, err)
+        }
+        return days, nil
+    case ".yaml", ".yml":
+        days, err := parseCalendarYAML(path)
+        if err != nil {
+            return nil, fmt.Errorf("parse holiday calendar %s: %w", path, err)
+        }
+        return days, nil
+    default:
+        return nil, fmt.Errorf("holiday calendar %s: unsupported format, want .ics, .yaml or .yml", path)
+    }
+}
+
+// parseCalendarYAML reads a date list of the form
+//
+//  holidays:
+//    - 2025-01-01
+//    - date: 2025-02-28
+//      name: Peace Memorial Day
+//  workdays:
+//    - date: 2025-02-08
+//      name: make-up workday
+func parseCalendarYAML(path string) ([]domain.CalendarDay, error) {
+    v := viper.New()
+    v.SetConfigFile(path)
+    if err := v.ReadInConfig(); err != nil {
+        return nil, err
+    }
+    var out []domain.CalendarDay
+    for _, section := range []string{"holidays", "workdays"} {
+        raw := v.Get(section)
+        if raw == nil {
+            continue
+        }
+        entries, ok := raw.([]interface{})
+        if !ok {
+            return nil, fmt.Errorf("%s: want a list of dates", section)
+        }
+        for i, e := range entries {
+            day, err := calendarEntry(e)
+            if err != nil {
+                return nil, fmt.Errorf("%s[%d]: %w", section, i, err)
+            }
+            day.Workday = section == "workdays"
+            out = append(out, day)
+        }
+    }
+    return out, nil
+}
+
+// calendarEntry decodes a date, or a map with "date" and an optional "name".
+// Unquoted YAML dates arrive as time.Time.
+func calendarEntry(e interface{}) (domain.CalendarDay, error) {
+    var day domain.CalendarDay
+    date := e
+    if m, ok := e.(map[string]interface{}); ok {
+        date = m["date"]
+        if name, ok := m["name"].(string); ok {
+            day.Name = name
+        }
+    }
+    switch d := date.(type) {
+    case time.Time:
+        day.Date = d.Format(domain.DateLayout)
+    case string:
+        if _, err := time.Parse(domain.DateLayout, strings.TrimSpace(d)); err != nil {
+            return day, fmt.Errorf("invalid date %q, want YYYY-MM-DD", d)
+        }
+        day.Date = strings.TrimSpace(d)
+    default:
+        return day, fmt.Errorf("missing date")
+    }
+    return day, nil
+}
+
+// ParseICS reads the all-day events of an iCalendar file as holidays. Events whose
+// CATEGORIES include WORKDAY are make-up workdays. DTEND is exclusive, so events
+// spanning several days cover every date from DTSTART up to DTEND.
+func ParseICS(r io.Reader) ([]domain.CalendarDay, error) {
+    lines, err := unfoldICS(r)
+    if err != nil {
+        return nil, err
+    }
+
+    var out []domain.CalendarDay
+    var inEvent bool
+    var start, end time.Time
+    var summary string
+    var workday bool
+    for n, line := range lines {
+        name, value, ok := strings.Cut(line, ":")
+        if !ok {
+            continue
+        }
+        prop, _, _ := strings.Cut(name, ";")
+        switch strings.ToUpper(prop) {
+        case "BEGIN":
+            if strings.EqualFold(value, "VEVENT") {
+                inEvent, start, end, summary, workday = true, time.Time{}, time.Time{}, "", false
+            }
+        case "DTSTART", "DTEND":
+            if !inEvent {
+                continue
+            }
+            t, err := parseICSDate(value)
+            if err != nil {
+                return nil, fmt.Errorf("line %d: %w", n+1, err)
+            }
+            if strings.EqualFold(prop, "DTSTART") {
+                start = t
+            } else {
+                end = t
+            }
+        case "SUMMARY":
+            summary = unescapeICSText(value)
+        case "CATEGORIES":
+            for _, c := range strings.Split(value, ",") {
+                if strings.EqualFold(strings.TrimSpace(c), icsWorkdayCategory) {
+                    workday = true
+                }
+            }
+        case "END":
+            if !inEvent || !strings.EqualFold(value, "VEVENT") {
+                continue
+            }
+            inEvent = false
+            if start.IsZero() {
+                return nil, fmt.Errorf("line %d: event without DTSTART", n+1)
+            }
+            if !end.After(start) {
+                end = start.AddDate(0, 0, 1)
+            }
+            for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+                out = append(out, domain.CalendarDay{Date: d.Format(domain.DateLayout), Name: summary, Workday: workday})
+            }
+        }
+    }
+    return out, nil
+}
+
+// unfoldICS joins continuation lines (starting with a space or tab) to the
+// preceding line.
+func unfoldICS(r io.Reader) ([]string, error) {
+    var lines []string
+    sc := bufio.NewScanner(r)
+    for sc.Scan() {
+        line := strings.TrimRight(sc.Text(), "\r")
+        if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+            lines[len(lines)-1] += line[1:]
+            continue
+        }
+        lines = append(lines, line)
+    }
+    return lines, sc.Err()
+}
+
+// parseICSDate parses the date part of a DATE ("20250101") or DATE-TIME
+// ("20250101T000000Z") value.
+func parseICSDate(value string) (time.Time, error) {
+    if len(value) < 8 {
+        return time.Time{}, fmt.Errorf("invalid date %q", value)
+    }
+    t, err := time.Parse("20060102", value[:8])
+    if err != nil {
+        return time.Time{}, fmt.Errorf("invalid date %q", value)
+    }
+    return t, nil
+}
+
+func unescapeICSText(s string) string {
+    return strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ", `\N`, " ", `\\`, `\`).Replace(s)
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+    "time"
+)
+
+// regexPrefix marks a rule pattern as a regular expression instead of a glob.
+const regexPrefix = "re:"
+
+// Rule overrides detection settings for the services and endpoints (or span
+// names) it matches. Service and Endpoint are globs where '*' matches any run of
+// characters (including '/') and '?' a single one, or regular expressions when
+// prefixed with "re:". An empty pattern matches everything and a pattern without
+// wildcards matches exactly one name. Zero values are inherited from the global
+// settings.
+type Rule struct {
+    Name     string `mapstructure:"name" yaml:"name"`
+    Service  string `mapstructure:"service" yaml:"service"`
+    Endpoint string `mapstructure:"endpoint" yaml:"endpoint"`
+
+    Factor     float64 `mapstructure:"factor" yaml:"factor"`
+    K          int     `mapstructure:"k" yaml:"k"`
+    MinSamples int     `mapstructure:"min_samples" yaml:"min_samples"`
+    // FloorMs overrides stats.min_threshold_ms; MinExcessMs overrides stats.min_excess_ms.
+    FloorMs     float64 `mapstructure:"floor_ms" yaml:"floor_ms"`
+    MinExcessMs float64 `mapstructure:"min_excess_ms" yaml:"min_excess_ms"`
+    // Model and HalfLife override stats.model and stats.half_life.
+    Model    string        `mapstructure:"model" yaml:"model"`
+    HalfLife time.Duration `mapstructure:"half_life" yaml:"half_life"`
+
+    Detector       string  `mapstructure:"detector" yaml:"detector"`
+    ZThreshold     float64 `mapstructure:"z_threshold" yaml:"z_threshold"`
+    IQRMultiplier  float64 `mapstructure:"iqr_multiplier" yaml:"iqr_multiplier"`
+    RankPercentile float64 `mapstructure:"rank_percentile" yaml:"rank_percentile"`
+    SLOMs          float64 `mapstructure:"slo_ms" yaml:"slo_ms"`
+
+    // MaxFallbackLevel disables the fallback levels above it (1 = exact bucket only).
+    MaxFallbackLevel int `mapstructure:"max_fallback_level" yaml:"max_fallback_level"`
+
+    service  *regexp.Regexp
+    endpoint *regexp.Regexp
+}
+
+// Settings are the detection settings that apply to one endpoint or span name.
+type Settings struct {
+    Stats     StatsConfig
+    Detection DetectionConfig
+    Fallback  FallbackConfig
+    // Rule is the label of the matched rule, "" if none matched.
+    Rule string
+}
+
+// SettingsFor resolves the settings of service's endpoint (or span name): the
+// global stats, detection and fallback sections, overridden by the first matching
+// rule.
+func (c *Config) SettingsFor(service, endpoint string) Settings {
+    s := Settings{
+        Stats:     c.Stats,
+        Detection: c.Detection,
+        Fallback:  c.Fallback,
+    }
+    for i := range c.Rules {
+        r := &c.Rules[i]
+        if !r.Matches(service, endpoint) {
+            continue
+        }
+        s.Rule = r.Label(i)
+        if r.Factor != 0 {
+            s.Stats.Factor = r.Factor
+        }
+        if r.K != 0 {
+            s.Stats.K = r.K
+        }
+        if r.MinSamples != 0 {
+            s.Stats.MinSamples = r.MinSamples
+        }
+        if r.FloorMs != 0 {
+            s.Stats.MinThresholdMs = r.FloorMs
+        }
+        if r.MinExcessMs != 0 {
+            s.Stats.MinExcessMs = r.MinExcessMs
+        }
+        if r.Model != "" {
+            s.Stats.Model = r.Model
+        }
+        if r.HalfLife != 0 {
+            s.Stats.HalfLife = r.HalfLife
+        }
+        if r.Detector != "" {
+            s.Detection.Detector = r.Detector
+        }
+        if r.ZThreshold != 0 {
+            s.Detection.ZThreshold = r.ZThreshold
+        }
+        if r.IQRMultiplier != 0 {
+            s.Detection.IQRMultiplier = r.IQRMultiplier
+        }
+        if r.RankPercentile != 0 {
+            s.Detection.RankPercentile = r.RankPercentile
+        }
+        if r.SLOMs != 0 {
+            s.Detection.SLOMs = r.SLOMs
+        }
+        if r.MaxFallbackLevel > 0 {
+            s.Fallback.NearbyHoursEnabled = s.Fallback.NearbyHoursEnabled && r.MaxFallbackLevel >= 2
+            s.Fallback.DayTypeGlobalEnabled = s.Fallback.DayTypeGlobalEnabled && r.MaxFallbackLevel >= 3
+            s.Fallback.FullGlobalEnabled = s.Fallback.FullGlobalEnabled && r.MaxFallbackLevel >= 4
+        }
+        break
+    }
+    return s
+}
+
+// Label returns the rule's name, or "rules[i]" for unnamed rules.
+func (r *Rule) Label(i int) string {
+    if r.Name != "" {
+        return r.Name
+    }
+    return fmt.Sprintf("rules[%d]", i)
+}
+
+// Matches reports whether the rule applies to service's endpoint or span name.
+func (r *Rule) Matches(service, endpoint string) bool {
+    return matchPattern(r.service, r.Service, service) && matchPattern(r.endpoint, r.Endpoint, endpoint)
+}
+
+// matchPattern matches s against compiled, or compiles pattern for rules that
+// were not loaded through Load.
+func matchPattern(compiled *regexp.Regexp, pattern, s string) bool {
+    if pattern == "" {
+        return true
+    }
+    if compiled == nil {
+        var err error
+        if compiled, err = compilePattern(pattern); err != nil {
+            return false
+        }
+    }
+    return compiled.MatchString(s)
+}
+
+// compilePattern turns a glob or "re:" pattern into a regular expression.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+    if expr, ok := strings.CutPrefix(pattern, regexPrefix); ok {
+        return regexp.Compile(expr)
+    }
+    var b strings.Builder
+    b.WriteString("^")
+    for _, r := range pattern {
+        switch r {
+        case '*':
+            b.WriteString(".*")
+        case '?':
+            b.WriteString(".")
+        default:
+            b.WriteString(regexp.QuoteMeta(string(r)))
+        }
+    }
+    b.WriteString("$")
+    return regexp.Compile(b.String())
+}
+
+// compileRules compiles the rule patterns and rejects invalid rules.
+func compileRules(rules []Rule, stats StatsConfig, detection DetectionConfig) error {
+    for i := range rules {
+        r := &rules[i]
+        field := fmt.Sprintf("rules[%d]", i)
+        var err error
+        if r.Service != "" {
+            if r.service, err = compilePattern(r.Service); err != nil {
+                return fmt.Errorf("%s.service: %w", field, err)
+            }
+        }
+        if r.Endpoint != "" {
+            if r.endpoint, err = compilePattern(r.Endpoint); err != nil {
+                return fmt.Errorf("%s.endpoint: %w", field, err)
+            }
+        }
+        if r.MaxFallbackLevel < 0 || r.MaxFallbackLevel > 4 {
+            return fmt.Errorf("%s.max_fallback_level: %d is not between 1 and 4", field, r.MaxFallbackLevel)
+        }
+        d := detection
+        if r.Detector != "" {
+            d.Detector = r.Detector
+        }
+        if r.RankPercentile != 0 {
+            d.RankPercentile = r.RankPercentile
+        }
+        if r.SLOMs != 0 {
+            d.SLOMs = r.SLOMs
+        }
+        if err := validateDetector(field, d.Detector, d.RankPercentile, d.SLOMs); err != nil {
+            return err
+        }
+        model, halfLife := stats.Model, stats.HalfLife
+        if r.Model != "" {
+            model = r.Model
+        }
+        if r.HalfLife != 0 {
+            halfLife = r.HalfLife
+        }
+        if err := validateModel(field, model, halfLife); err != nil {
+            return err
+        }
+    }
+    return nil
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+    "fmt"
+    "regexp"
+    "slices"
+    "time"
+)
+
+// ServiceTimezone buckets the services matching Service in Timezone instead of
+// the global timezone. Service is a glob or "re:" pattern as in Rule.
+type ServiceTimezone struct {
+    Service  string `mapstructure:"service" yaml:"service"`
+    Timezone string `mapstructure:"timezone" yaml:"timezone"`
+
+    service *regexp.Regexp
+}
+
+// TimezoneFor returns the timezone service's samples are bucketed in: the first
+// matching entry of timezones, else the global timezone.
+func (c *Config) TimezoneFor(service string) string {
+    for i := range c.Timezones {
+        tz := &c.Timezones[i]
+        if matchPattern(tz.service, tz.Service, service) {
+            return tz.Timezone
+        }
+    }
+    return c.Timezone
+}
+
+// bucketTimezones returns the distinct timezones samples may be bucketed in,
+// the global one first.
+func (c *Config) bucketTimezones() []string {
+    global := c.Timezone
+    if global == "" {
+        global = DefaultTimezone
+    }
+    out := []string{global}
+    for _, tz := range c.Timezones {
+        if !slices.Contains(out, tz.Timezone) {
+            out = append(out, tz.Timezone)
+        }
+    }
+    return out
+}
+
+// compileTimezones compiles the service patterns and rejects unknown timezones.
+func compileTimezones(tzs []ServiceTimezone) error {
+    for i := range tzs {
+        tz := &tzs[i]
+        field := fmt.Sprintf("timezones[%d]", i)
+        if tz.Service == "" {
+            return fmt.Errorf("%s.service must not be empty", field)
+        }
+        var err error
+        if tz.service, err = compilePattern(tz.Service); err != nil {
+            return fmt.Errorf("%s.service: %w", field, err)
+        }
+        if tz.Timezone == "" {
+            return fmt.Errorf("%s.timezone must not be empty", field)
+        }
+        if _, err := time.LoadLocation(tz.Timezone); err != nil {
+            return fmt.Errorf("%s.timezone: %w", field, err)
+        }
+    }
+    return nil
+}
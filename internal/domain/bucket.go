@@ -0,0 +1,388 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bucketing schemes, as configured in bucketing.scheme.
+const (
+	SchemeWeekdayHour   = "weekday_hour" // weekday/weekend × hour (default)
+	SchemeDayOfWeekHour = "dow_hour"     // mon..sun × hour
+	SchemeSlot          = "slot"         // weekday/weekend × SlotMinutes slots
+	SchemeSchedule      = "schedule"     // the named periods of a Schedule
+)
+
+// Bucketing decides how timestamps are grouped into baseline buckets and which
+// buckets are neighbours for the fallback levels. The zero value is the
+// weekday/weekend × hour scheme.
+type Bucketing struct {
+	Scheme string
+	// SlotMinutes is the slot length of SchemeSlot; it divides 60 (e.g. 15 or 30).
+	SlotMinutes int
+	// Schedule is the schedule of SchemeSchedule.
+	Schedule *Schedule
+	// Calendar, if set, buckets holidays as Calendar.DayType and make-up
+	// workdays like a Monday (i.e. as "weekday" in the weekday/weekend schemes).
+	Calendar *Calendar
+	// Locations are the timezones timestamps are bucketed in. DayBuckets and
+	// All include the Fold buckets of the slots their fall-back transitions
+	// repeat.
+	Locations []*time.Location
+}
+
+// Schedule maps times of the week to named periods such as business hours.
+// The first period covering a time wins; uncovered times fall into Default.
+type Schedule struct {
+	Name    string
+	Periods []SchedulePeriod
+	Default string
+}
+
+// SchedulePeriod covers the minutes of day [Start, End) on Days.
+type SchedulePeriod struct {
+	Name  string
+	Days  []time.Weekday
+	Start int
+	End   int
+}
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// dayOfWeekTypes are the day types of SchemeDayOfWeekHour, Monday first.
+var dayOfWeekTypes = []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}
+
+// ParseWeekday accepts "mon" or "monday" (any case).
+func ParseWeekday(s string) (time.Weekday, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for i, name := range weekdayNames {
+		if s == name || s == strings.ToLower(time.Weekday(i).String()) {
+			return time.Weekday(i), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday %q", s)
+}
+
+// Validate rejects unknown schemes and inconsistent slot or schedule settings.
+func (b Bucketing) Validate() error {
+	switch b.Scheme {
+	case "", SchemeWeekdayHour, SchemeDayOfWeekHour:
+	case SchemeSlot:
+		if b.SlotMinutes <= 0 || 60%b.SlotMinutes != 0 {
+			return fmt.Errorf("slot_minutes %d does not divide an hour", b.SlotMinutes)
+		}
+	case SchemeSchedule:
+		if b.Schedule == nil {
+			return fmt.Errorf("schedule scheme needs a schedule")
+		}
+		if b.Schedule.Default == "" {
+			return fmt.Errorf("schedule %q: default period must not be empty", b.Schedule.Name)
+		}
+		for _, p := range b.Schedule.Periods {
+			if p.Name == "" {
+				return fmt.Errorf("schedule %q: period name must not be empty", b.Schedule.Name)
+			}
+			if p.Start < 0 || p.End > 24*60 || p.End <= p.Start {
+				return fmt.Errorf("schedule %q: period %q must end after it starts within one day", b.Schedule.Name, p.Name)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown bucketing scheme %q", b.Scheme)
+	}
+	return nil
+}
+
+// ParseTimeBucket converts a unix nano timestamp string into the bucket of the
+// scheme in the given timezone (Asia/Taipei if empty).
+func (b Bucketing) ParseTimeBucket(unixNano string, timezone string) (TimeBucket, error) {
+	t, err := LocalTime(unixNano, timezone)
+	if err != nil {
+		return TimeBucket{}, err
+	}
+	return b.BucketAt(t), nil
+}
+
+// LocalTime converts a unix nano timestamp string into the time in the given
+// timezone (Asia/Taipei if empty).
+func LocalTime(unixNano string, timezone string) (time.Time, error) {
+	if timezone == "" {
+		timezone = defaultTimezone
+	}
+
+	ns, err := strconv.ParseInt(unixNano, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid unix nano: %w", err)
+	}
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("load location '%s': %w", timezone, err)
+	}
+
+	return time.Unix(0, ns).In(loc), nil
+}
+
+// BucketAt returns the bucket of t in t's location. Wall-clock time that occurs
+// twice when DST ends falls into the slot's Fold bucket on its second pass, so
+// the two occurrences are not merged.
+func (b Bucketing) BucketAt(t time.Time) TimeBucket {
+	wd := t.Weekday()
+	day, special := b.Calendar.Day(t)
+	if special && day.Workday {
+		wd = time.Monday
+	}
+
+	var bucket TimeBucket
+	switch b.Scheme {
+	case SchemeDayOfWeekHour:
+		bucket = TimeBucket{Hour: t.Hour(), DayType: weekdayNames[wd]}
+	case SchemeSlot:
+		minute := t.Minute() / b.SlotMinutes * b.SlotMinutes
+		bucket = TimeBucket{Hour: t.Hour(), Minute: minute, DayType: weekdayType(wd)}
+	case SchemeSchedule:
+		bucket = TimeBucket{DayType: b.Schedule.periodAt(wd, t.Hour()*60+t.Minute())}
+	default:
+		bucket = TimeBucket{Hour: t.Hour(), DayType: weekdayType(wd)}
+	}
+	if special && !day.Workday {
+		bucket.DayType = b.Calendar.DayType
+	}
+	if b.Scheme != SchemeSchedule {
+		bucket.Fold = inFold(t)
+	}
+	return bucket
+}
+
+// inFold reports whether t lies in the wall-clock interval repeated after a
+// transition that set the clocks back, i.e. whether the same local time already
+// occurred shortly before t.
+func inFold(t time.Time) bool {
+	start, _ := t.ZoneBounds()
+	if start.IsZero() {
+		return false
+	}
+	_, offset := t.Zone()
+	_, prevOffset := start.Add(-time.Nanosecond).Zone()
+	return prevOffset > offset && t.Sub(start) < time.Duration(prevOffset-offset)*time.Second
+}
+
+// weekdayType returns "weekend" for Saturday and Sunday, "weekday" otherwise.
+func weekdayType(wd time.Weekday) string {
+	if wd == time.Saturday || wd == time.Sunday {
+		return dayTypeWeekend
+	}
+	return dayTypeWeekday
+}
+
+func (s *Schedule) periodAt(wd time.Weekday, minute int) string {
+	for _, p := range s.Periods {
+		if minute < p.Start || minute >= p.End {
+			continue
+		}
+		for _, d := range p.Days {
+			if d == wd {
+				return p.Name
+			}
+		}
+	}
+	return s.Default
+}
+
+// DayTypes returns the day types of the scheme. For SchemeSchedule these are the
+// period names, in order of first appearance, followed by the default. The
+// holiday day type of the calendar is appended if the scheme lacks it.
+func (b Bucketing) DayTypes() []string {
+	out := b.schemeDayTypes()
+	if b.Calendar != nil && b.Calendar.DayType != "" && !slices.Contains(out, b.Calendar.DayType) {
+		out = append(out, b.Calendar.DayType)
+	}
+	return out
+}
+
+func (b Bucketing) schemeDayTypes() []string {
+	switch b.Scheme {
+	case SchemeDayOfWeekHour:
+		return append([]string(nil), dayOfWeekTypes...)
+	case SchemeSchedule:
+		var out []string
+		seen := make(map[string]bool)
+		for _, name := range append(periodNames(b.Schedule.Periods), b.Schedule.Default) {
+			if !seen[name] {
+				seen[name] = true
+				out = append(out, name)
+			}
+		}
+		return out
+	default:
+		return []string{dayTypeWeekday, dayTypeWeekend}
+	}
+}
+
+func periodNames(periods []SchedulePeriod) []string {
+	out := make([]string, len(periods))
+	for i, p := range periods {
+		out[i] = p.Name
+	}
+	return out
+}
+
+// DayBuckets returns every bucket of dayType in time order. The Fold bucket of
+// a slot repeated when DST ends in one of the Locations follows its first pass.
+func (b Bucketing) DayBuckets(dayType string) []TimeBucket {
+	if b.Scheme == SchemeSchedule {
+		return []TimeBucket{{DayType: dayType}}
+	}
+	slot := b.slotMinutes()
+	folds := b.foldSlots(time.Now())
+	out := make([]TimeBucket, 0, 24*60/slot+len(folds))
+	for m := 0; m < 24*60; m += slot {
+		out = append(out, TimeBucket{Hour: m / 60, Minute: m % 60, DayType: dayType})
+		if folds[m] {
+			out = append(out, TimeBucket{Hour: m / 60, Minute: m % 60, DayType: dayType, Fold: true})
+		}
+	}
+	return out
+}
+
+// foldSlots returns the start minutes of the slots whose wall-clock time is
+// repeated by a fall-back transition of the Locations in the years around now.
+func (b Bucketing) foldSlots(now time.Time) map[int]bool {
+	slot := b.slotMinutes()
+	folds := make(map[int]bool)
+	for _, loc := range b.Locations {
+		t := time.Date(now.Year()-1, time.January, 1, 0, 0, 0, 0, loc)
+		until := time.Date(now.Year()+2, time.January, 1, 0, 0, 0, 0, loc)
+		for t.Before(until) {
+			_, end := t.ZoneBounds()
+			if end.IsZero() {
+				break
+			}
+			t = end
+			_, offset := end.Zone()
+			_, prevOffset := end.Add(-time.Nanosecond).Zone()
+			if prevOffset <= offset {
+				continue
+			}
+			// The wall clock runs through [start, start+repeat) a second time.
+			start := end.Hour()*60 + end.Minute()
+			repeat := (prevOffset - offset) / 60
+			for m := start / slot * slot; m < start+repeat; m += slot {
+				folds[m%(24*60)] = true
+			}
+		}
+	}
+	return folds
+}
+
+// All returns every bucket of the scheme, day type by day type.
+func (b Bucketing) All() []TimeBucket {
+	var out []TimeBucket
+	for _, dt := range b.DayTypes() {
+		out = append(out, b.DayBuckets(dt)...)
+	}
+	return out
+}
+
+// Nearby returns the buckets within n slots of bucket on the same day type, in
+// the order ±1, ±2, ..., wrapping around midnight. The first pass of a Fold
+// bucket's slot comes first. Schedules have no neighbours. Nearby knows nothing
+// of DST transitions; use NearbyAt when the instant is known.
+func (b Bucketing) Nearby(bucket TimeBucket, n int) []TimeBucket {
+	if b.Scheme == SchemeSchedule || n <= 0 {
+		return nil
+	}
+	slot := b.slotMinutes()
+	slots := 24 * 60 / slot
+	center := (bucket.Hour*60 + bucket.Minute) / slot
+	wrap := func(i int) int { return (i%slots + slots) % slots }
+
+	var out []TimeBucket
+	if bucket.Fold {
+		out = append(out, TimeBucket{Hour: bucket.Hour, Minute: bucket.Minute, DayType: bucket.DayType})
+	}
+	seen := map[int]bool{center: true}
+	for d := 1; d <= n; d++ {
+		for _, i := range []int{wrap(center - d), wrap(center + d)} {
+			if seen[i] {
+				continue
+			}
+			seen[i] = true
+			out = append(out, TimeBucket{Hour: i * slot / 60, Minute: i * slot % 60, DayType: bucket.DayType})
+		}
+	}
+	return out
+}
+
+// NearbyAt returns the neighbours of the bucket of t by elapsed time: the buckets
+// of the instants ±1, ±2, ..., n slot lengths away from t, kept on t's day type.
+// On DST transition days this skips the wall-clock hour that does not exist and
+// counts both passes of the repeated hour; on other days it matches Nearby.
+func (b Bucketing) NearbyAt(t time.Time, n int) []TimeBucket {
+	if b.Scheme == SchemeSchedule || n <= 0 {
+		return nil
+	}
+	center := b.BucketAt(t)
+	step := time.Duration(b.slotMinutes()) * time.Minute
+
+	var out []TimeBucket
+	seen := map[TimeBucket]bool{center: true}
+	for d := 1; d <= n; d++ {
+		for _, at := range []time.Time{t.Add(-time.Duration(d) * step), t.Add(time.Duration(d) * step)} {
+			nb := b.BucketAt(at)
+			nb.DayType = center.DayType
+			if seen[nb] {
+				continue
+			}
+			seen[nb] = true
+			out = append(out, nb)
+		}
+	}
+	return out
+}
+
+// slotMinutes is the bucket length within a day of the time-of-day schemes.
+func (b Bucketing) slotMinutes() int {
+	if b.Scheme == SchemeSlot && b.SlotMinutes > 0 {
+		return b.SlotMinutes
+	}
+	return 60
+}
+
+// SlotLabel formats the time of day of the bucket: "9", or "9h30" for a slot
+// starting within the hour, followed by "+" for a Fold bucket.
+func (b TimeBucket) SlotLabel() string {
+	label := strconv.Itoa(b.Hour)
+	if b.Minute != 0 {
+		label = fmt.Sprintf("%dh%02d", b.Hour, b.Minute)
+	}
+	if b.Fold {
+		label += "+"
+	}
+	return label
+}
+
+// Label formats the bucket as "{slot}|{dayType}", e.g. "9|weekday".
+func (b TimeBucket) Label() string {
+	return b.SlotLabel() + "|" + b.DayType
+}
+
+// ParseSlotLabel parses a label produced by TimeBucket.SlotLabel into a bucket
+// without day type.
+func ParseSlotLabel(s string) (TimeBucket, error) {
+	var bucket TimeBucket
+	s, bucket.Fold = strings.CutSuffix(s, "+")
+	h, m, ok := strings.Cut(s, "h")
+	var err error
+	if bucket.Hour, err = strconv.Atoi(h); err != nil {
+		return TimeBucket{}, err
+	}
+	if ok {
+		if bucket.Minute, err = strconv.Atoi(m); err != nil {
+			return TimeBucket{}, err
+		}
+	}
+	return bucket, nil
+}
@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"sync"
+	"time"
+)
+
+// DateLayout is the layout of calendar dates ("2006-01-02").
+const DateLayout = "2006-01-02"
+
+// CalendarDay is a holiday or, with Workday set, a make-up workday falling on a
+// weekend.
+type CalendarDay struct {
+	Date    string `json:"date" example:"2025-01-01"`
+	Name    string `json:"name,omitempty" example:"New Year's Day"`
+	Workday bool   `json:"workday,omitempty" example:"false"`
+}
+
+// Calendar holds the holidays and make-up workdays consulted by Bucketing. Dates
+// are local dates in the timezone of the bucketed timestamp. It is safe for
+// concurrent use, so the days can be replaced while requests are bucketed.
+type Calendar struct {
+	// DayType is the day type holidays are bucketed as, e.g. "weekend".
+	DayType string
+
+	mu   sync.RWMutex
+	days map[string]CalendarDay
+}
+
+// NewCalendar returns an empty calendar that buckets holidays as dayType.
+func NewCalendar(dayType string) *Calendar {
+	return &Calendar{DayType: dayType, days: make(map[string]CalendarDay)}
+}
+
+// Set replaces all days of the calendar. Later entries for the same date win.
+func (c *Calendar) Set(days []CalendarDay) {
+	m := make(map[string]CalendarDay, len(days))
+	for _, d := range days {
+		m[d.Date] = d
+	}
+	c.mu.Lock()
+	c.days = m
+	c.mu.Unlock()
+}
+
+// Day returns the holiday or make-up workday on t's local date.
+func (c *Calendar) Day(t time.Time) (CalendarDay, bool) {
+	if c == nil {
+		return CalendarDay{}, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	d, ok := c.days[t.Format(DateLayout)]
+	return d, ok
+}
+
+// Len returns the number of days in the calendar.
+func (c *Calendar) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.days)
+}
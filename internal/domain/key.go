@@ -13,55 +13,125 @@ const (
 )
 
 // ParseTimeBucket converts a unix nano timestamp string into a TimeBucket using the given timezone.
-// If timezone is empty, it defaults to Asia/Taipei.
+// If timezone is empty, it defaults to Asia/Taipei. Buckets use the default
+// weekday/weekend × hour scheme; see Bucketing for the others.
 func ParseTimeBucket(unixNano string, timezone string) (TimeBucket, error) {
-	if timezone == "" {
-		timezone = defaultTimezone
-	}
+	return Bucketing{}.ParseTimeBucket(unixNano, timezone)
+}
 
+// ParseUnixNano converts a unix nano timestamp string into a time.Time.
+func ParseUnixNano(unixNano string) (time.Time, error) {
 	ns, err := strconv.ParseInt(unixNano, 10, 64)
 	if err != nil {
-		return TimeBucket{}, fmt.Errorf("invalid unix nano: %w", err)
-	}
-
-	loc, err := time.LoadLocation(timezone)
-	if err != nil {
-		return TimeBucket{}, fmt.Errorf("load location '%s': %w", timezone, err)
+		return time.Time{}, fmt.Errorf("invalid unix nano: %w", err)
 	}
-
-	t := time.Unix(0, ns).In(loc)
-	hour := t.Hour() // 0-23
-
-	// Determine weekend: Saturday or Sunday in given timezone.
-	wd := t.Weekday()
-	dayType := dayTypeWeekday
-	if wd == time.Saturday || wd == time.Sunday {
-		dayType = dayTypeWeekend
-	}
-
-	return TimeBucket{Hour: hour, DayType: dayType}, nil
+	return time.Unix(0, ns), nil
 }
 
 // MakeBaselineKey generates the baseline cache key for the given service/endpoint and time bucket.
-// Format: base:{service}|{endpoint}|{hour}|{dayType}
+// Format (v2): v2:base:{service}|{endpoint}|{hour}|{dayType}, components escaped.
 func MakeBaselineKey(service, endpoint string, bucket TimeBucket) string {
-	return fmt.Sprintf("base:%s|%s|%d|%s", service, endpoint, bucket.Hour, bucket.DayType)
+	return SeriesKey{Kind: KindBaseline, Service: service, Name: endpoint, Bucket: bucket}.String()
+}
+
+// MakeHistoryKey generates the key of the daily baseline history of the given
+// service/endpoint and time bucket.
+// Format (v2): v2:hist:{service}|{endpoint}|{hour}|{dayType}, components escaped.
+func MakeHistoryKey(service, endpoint string, bucket TimeBucket) string {
+	return SeriesKey{Kind: KindHistory, Service: service, Name: endpoint, Bucket: bucket}.String()
 }
 
 // MakeSpanBaselineKey generates the baseline cache key for span-level baselines.
-// Format: spanbase:{service}|{spanName}|{hour}|{dayType}
+// Format (v2): v2:spanbase:{service}|{spanName}|{hour}|{dayType}, components escaped.
 func MakeSpanBaselineKey(service, spanName string, bucket TimeBucket) string {
-	return fmt.Sprintf("spanbase:%s|%s|%d|%s", service, spanName, bucket.Hour, bucket.DayType)
+	return SeriesKey{Kind: KindSpanBaseline, Service: service, Name: spanName, Bucket: bucket}.String()
 }
 
-// MakeDurationKey generates the rolling duration list key for the given service/endpoint and time bucket.
-// Format: dur:{service}|{endpoint}|{hour}|{dayType}
+// MakeDurationKey generates the rolling duration key for the given service/endpoint and time bucket.
+// Format (v2): v2:dur:{service}|{endpoint}|{hour}|{dayType}, components escaped.
 func MakeDurationKey(service, endpoint string, bucket TimeBucket) string {
-	return fmt.Sprintf("dur:%s|%s|%d|%s", service, endpoint, bucket.Hour, bucket.DayType)
+	return SeriesKey{Kind: KindDuration, Service: service, Name: endpoint, Bucket: bucket}.String()
 }
 
-// MakeSpanDurationKey generates the rolling duration list key for span-level baselines.
-// Format: spandur:{service}|{spanName}|{hour}|{dayType}
+// MakeSpanDurationKey generates the rolling duration key for span-level baselines.
+// Format (v2): v2:spandur:{service}|{spanName}|{hour}|{dayType}, components escaped.
 func MakeSpanDurationKey(service, spanName string, bucket TimeBucket) string {
-	return fmt.Sprintf("spandur:%s|%s|%d|%s", service, spanName, bucket.Hour, bucket.DayType)
+	return SeriesKey{Kind: KindSpanDuration, Service: service, Name: spanName, Bucket: bucket}.String()
+}
+
+// MakeSketchKey generates the quantile sketch key of the duration window for the given
+// service/endpoint and time bucket.
+// Format (v2): v2:sketch:{service}|{endpoint}|{hour}|{dayType}, components escaped.
+func MakeSketchKey(service, endpoint string, bucket TimeBucket) string {
+	return SeriesKey{Kind: KindSketch, Service: service, Name: endpoint, Bucket: bucket}.String()
+}
+
+// MakeSpanSketchKey generates the quantile sketch key of the span duration window.
+// Format (v2): v2:spansketch:{service}|{spanName}|{hour}|{dayType}, components escaped.
+func MakeSpanSketchKey(service, spanName string, bucket TimeBucket) string {
+	return SeriesKey{Kind: KindSpanSketch, Service: service, Name: spanName, Bucket: bucket}.String()
+}
+
+// MakeErrorKey generates the key of the daily request and error counts of the given
+// service/endpoint and time bucket.
+// Format (v2): v2:err:{service}|{endpoint}|{hour}|{dayType}, components escaped.
+func MakeErrorKey(service, endpoint string, bucket TimeBucket) string {
+	return SeriesKey{Kind: KindErrors, Service: service, Name: endpoint, Bucket: bucket}.String()
+}
+
+// MakeSpanErrorKey generates the key of the daily request and error counts of spans.
+// Format (v2): v2:spanerr:{service}|{spanName}|{hour}|{dayType}, components escaped.
+func MakeSpanErrorKey(service, spanName string, bucket TimeBucket) string {
+	return SeriesKey{Kind: KindSpanErrors, Service: service, Name: spanName, Bucket: bucket}.String()
+}
+
+// SketchKeyForDurationKey maps a duration key (dur/spandur) to the quantile sketch
+// key (sketch/spansketch) of the same series and bucket.
+func SketchKeyForDurationKey(durKey string) (string, bool) {
+	sk, err := ParseSeriesKey(durKey)
+	if err != nil {
+		return "", false
+	}
+	switch sk.Kind {
+	case KindDuration:
+		return sk.WithKind(KindSketch).String(), true
+	case KindSpanDuration:
+		return sk.WithKind(KindSpanSketch).String(), true
+	default:
+		return "", false
+	}
+}
+
+// DurationKeyForBaselineKey maps a baseline key (base/spanbase) to the duration key
+// (dur/spandur) of the same series and bucket.
+func DurationKeyForBaselineKey(baseKey string) (string, bool) {
+	sk, err := ParseSeriesKey(baseKey)
+	if err != nil {
+		return "", false
+	}
+	switch sk.Kind {
+	case KindBaseline:
+		return sk.WithKind(KindDuration).String(), true
+	case KindSpanBaseline:
+		return sk.WithKind(KindSpanDuration).String(), true
+	default:
+		return "", false
+	}
+}
+
+// BaselineKeyForDurationKey maps a duration key (dur/spandur) to the baseline key
+// (base/spanbase) of the same series and bucket.
+func BaselineKeyForDurationKey(durKey string) (string, bool) {
+	sk, err := ParseSeriesKey(durKey)
+	if err != nil {
+		return "", false
+	}
+	switch sk.Kind {
+	case KindDuration:
+		return sk.WithKind(KindBaseline).String(), true
+	case KindSpanDuration:
+		return sk.WithKind(KindSpanBaseline).String(), true
+	default:
+		return "", false
+	}
 }
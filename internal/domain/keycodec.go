@@ -0,0 +1,236 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// KeyKind identifies what a series key stores.
+type KeyKind string
+
+const (
+	KindBaseline     KeyKind = "base"       // cached baseline stats (hash)
+	KindDuration     KeyKind = "dur"        // rolling duration samples
+	KindSpanBaseline KeyKind = "spanbase"   // span-level baseline stats
+	KindSpanDuration KeyKind = "spandur"    // span-level duration samples
+	KindSketch       KeyKind = "sketch"     // quantile sketch of the duration samples
+	KindSpanSketch   KeyKind = "spansketch" // quantile sketch of the span duration samples
+	KindHistory      KeyKind = "hist"       // daily history of the baseline stats
+	KindErrors       KeyKind = "err"        // daily request and error counts
+	KindSpanErrors   KeyKind = "spanerr"    // daily span request and error counts
+)
+
+// KeyVersion is the version of the series key layout produced by SeriesKey.String.
+//
+// v1 (legacy): {kind}:{service}|{name}|{hour}|{dayType}, unescaped. A "|" in the
+// name made the key ambiguous.
+//
+// v2: v2:{kind}:{service}|{name}|{hour}|{dayType}, where every component is
+// percent-escaped (see escapeKeyComponent) so it never contains "|", ":", "{", "}"
+// or an unescaped "%". The layout can always be split back into its components.
+// Sub-hour buckets write the hour as "{hour}h{minute}", and the repeated slot of
+// a DST fall-back day gets a "+" suffix (see TimeBucket.SlotLabel).
+const KeyVersion = 2
+
+const keyVersionPrefix = "v2:"
+
+var keyKinds = []KeyKind{KindBaseline, KindDuration, KindSpanBaseline, KindSpanDuration, KindSketch, KindSpanSketch, KindHistory, KindErrors, KindSpanErrors}
+
+// SeriesKey is the decoded form of a per-series store key:
+// one service/name pair (name is the endpoint or span name) in one time bucket.
+type SeriesKey struct {
+	Kind    KeyKind
+	Service string
+	Name    string
+	Bucket  TimeBucket
+}
+
+// String encodes the key in the current (v2) layout.
+func (k SeriesKey) String() string {
+	var b strings.Builder
+	b.WriteString(KeyPrefix(k.Kind))
+	b.WriteString(escapeKeyComponent(k.Service))
+	b.WriteByte('|')
+	b.WriteString(escapeKeyComponent(k.Name))
+	b.WriteByte('|')
+	b.WriteString(k.Bucket.SlotLabel())
+	b.WriteByte('|')
+	b.WriteString(escapeKeyComponent(k.Bucket.DayType))
+	return b.String()
+}
+
+// WithKind returns the same series and bucket under another kind,
+// e.g. the baseline key that belongs to a duration key.
+func (k SeriesKey) WithKind(kind KeyKind) SeriesKey {
+	k.Kind = kind
+	return k
+}
+
+// SeriesID identifies the service/name pair of the key regardless of kind and
+// bucket: "{service}|{name}", escaped like the key itself.
+func (k SeriesKey) SeriesID() string {
+	return escapeKeyComponent(k.Service) + "|" + escapeKeyComponent(k.Name)
+}
+
+// ParseSeriesID decodes an id produced by SeriesKey.SeriesID.
+func ParseSeriesID(id string) (service, name string, err error) {
+	svc, nm, ok := strings.Cut(id, "|")
+	if !ok || strings.Contains(nm, "|") {
+		return "", "", fmt.Errorf("invalid series id: %s", id)
+	}
+	if service, err = unescapeKeyComponent(svc); err != nil {
+		return "", "", fmt.Errorf("invalid series id %s: %w", id, err)
+	}
+	if name, err = unescapeKeyComponent(nm); err != nil {
+		return "", "", fmt.Errorf("invalid series id %s: %w", id, err)
+	}
+	return service, name, nil
+}
+
+// KeyPrefix returns the v2 prefix shared by all keys of kind, e.g. "v2:base:".
+func KeyPrefix(kind KeyKind) string {
+	return keyVersionPrefix + string(kind) + ":"
+}
+
+// ParseSeriesKey decodes a v2 series key.
+func ParseSeriesKey(key string) (SeriesKey, error) {
+	if !strings.HasPrefix(key, keyVersionPrefix) {
+		return SeriesKey{}, fmt.Errorf("unsupported key version: %s", key)
+	}
+	kindStr, body, ok := strings.Cut(strings.TrimPrefix(key, keyVersionPrefix), ":")
+	if !ok {
+		return SeriesKey{}, fmt.Errorf("invalid key format: %s", key)
+	}
+	kind, err := parseKeyKind(kindStr)
+	if err != nil {
+		return SeriesKey{}, fmt.Errorf("%w: %s", err, key)
+	}
+
+	parts := strings.Split(body, "|")
+	if len(parts) != 4 {
+		return SeriesKey{}, fmt.Errorf("invalid key format: %s", key)
+	}
+	var comps [3]string
+	for i, p := range []string{parts[0], parts[1], parts[3]} {
+		c, err := unescapeKeyComponent(p)
+		if err != nil {
+			return SeriesKey{}, fmt.Errorf("invalid key component in %s: %w", key, err)
+		}
+		comps[i] = c
+	}
+	bucket, err := ParseSlotLabel(parts[2])
+	if err != nil {
+		return SeriesKey{}, fmt.Errorf("invalid hour in key %s: %w", key, err)
+	}
+	bucket.DayType = comps[2]
+	return SeriesKey{
+		Kind:    kind,
+		Service: comps[0],
+		Name:    comps[1],
+		Bucket:  bucket,
+	}, nil
+}
+
+// ParseV1SeriesKey decodes a legacy v1 key. v1 components were not escaped, so the
+// service is taken up to the first "|", hour and day type from the last two
+// components and everything in between is the name (which may contain "|").
+func ParseV1SeriesKey(key string) (SeriesKey, error) {
+	kindStr, body, ok := strings.Cut(key, ":")
+	if !ok {
+		return SeriesKey{}, fmt.Errorf("invalid v1 key format: %s", key)
+	}
+	kind, err := parseKeyKind(kindStr)
+	if err != nil {
+		return SeriesKey{}, fmt.Errorf("%w: %s", err, key)
+	}
+	parts := strings.Split(body, "|")
+	if len(parts) < 4 {
+		return SeriesKey{}, fmt.Errorf("invalid v1 key format: %s", key)
+	}
+	hour, err := strconv.Atoi(parts[len(parts)-2])
+	if err != nil {
+		return SeriesKey{}, fmt.Errorf("invalid hour in key %s: %w", key, err)
+	}
+	return SeriesKey{
+		Kind:    kind,
+		Service: parts[0],
+		Name:    strings.Join(parts[1:len(parts)-2], "|"),
+		Bucket:  TimeBucket{Hour: hour, DayType: parts[len(parts)-1]},
+	}, nil
+}
+
+// IsV1SeriesKey reports whether key uses the legacy v1 layout.
+func IsV1SeriesKey(key string) bool {
+	for _, kind := range keyKinds {
+		if strings.HasPrefix(key, string(kind)+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// MigrateV1Key converts a v1 series key to the current layout. Keys that are not
+// v1 series keys are returned unchanged with ok=false.
+func MigrateV1Key(key string) (string, bool) {
+	if !IsV1SeriesKey(key) {
+		return key, false
+	}
+	sk, err := ParseV1SeriesKey(key)
+	if err != nil {
+		return key, false
+	}
+	return sk.String(), true
+}
+
+func parseKeyKind(s string) (KeyKind, error) {
+	for _, kind := range keyKinds {
+		if string(kind) == s {
+			return kind, nil
+		}
+	}
+	return "", fmt.Errorf("unknown key kind %q", s)
+}
+
+// keyEscapeChars are the bytes percent-escaped inside key components: the
+// separators, Redis hash-tag braces and the escape character itself.
+const keyEscapeChars = "%|:{}"
+
+func escapeKeyComponent(s string) string {
+	if !strings.ContainsAny(s, keyEscapeChars) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if strings.IndexByte(keyEscapeChars, c) >= 0 {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func unescapeKeyComponent(s string) (string, error) {
+	if !strings.Contains(s, "%") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+2 >= len(s) {
+			return "", fmt.Errorf("truncated escape at %d", i)
+		}
+		v, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("invalid escape at %d", i)
+		}
+		b.WriteByte(byte(v))
+		i += 2
+	}
+	return b.String(), nil
+}
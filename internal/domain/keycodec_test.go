@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeriesKey_RoundTrip(t *testing.T) {
+	cases := []SeriesKey{
+		{Kind: KindBaseline, Service: "svc", Name: "GET /api/users", Bucket: TimeBucket{Hour: 9, DayType: "weekday"}},
+		{Kind: KindDuration, Service: "svc", Name: "a|b|c", Bucket: TimeBucket{Hour: 0, DayType: "weekend"}},
+		{Kind: KindSpanBaseline, Service: "ns:svc", Name: "redis: GET {user}", Bucket: TimeBucket{Hour: 23, DayType: "weekday"}},
+		{Kind: KindSpanDuration, Service: "svc", Name: "100%|done", Bucket: TimeBucket{Hour: 12, DayType: "weekend"}},
+		{Kind: KindSketch, Service: "svc", Name: "GET /a", Bucket: TimeBucket{Hour: 9, Minute: 15, DayType: "mon"}},
+		{Kind: KindBaseline, Service: "svc", Name: "GET /a", Bucket: TimeBucket{DayType: "business_hours"}},
+		{Kind: KindDuration, Service: "svc", Name: "GET /a", Bucket: TimeBucket{Hour: 1, Minute: 30, DayType: "weekend", Fold: true}},
+	}
+	for _, want := range cases {
+		key := want.String()
+		got, err := ParseSeriesKey(key)
+		require.NoError(t, err, key)
+		assert.Equal(t, want, got, key)
+
+		svc, name, err := ParseSeriesID(want.SeriesID())
+		require.NoError(t, err, key)
+		assert.Equal(t, [2]string{want.Service, want.Name}, [2]string{svc, name}, key)
+	}
+}
+
+func TestSeriesKey_Encoding(t *testing.T) {
+	b := TimeBucket{Hour: 9, DayType: "weekday"}
+	assert.Equal(t, "v2:base:svc|GET /a|9|weekday", MakeBaselineKey("svc", "GET /a", b))
+	assert.Equal(t, "v2:dur:svc|a%7Cb%3Ac|9|weekday", MakeDurationKey("svc", "a|b:c", b))
+	assert.Equal(t, "v2:spanbase:svc|%7Bx%7D %25|9|weekday", MakeSpanBaselineKey("svc", "{x} %", b))
+	assert.Equal(t, "v2:base:svc|GET /a|9h05|weekday", MakeBaselineKey("svc", "GET /a", TimeBucket{Hour: 9, Minute: 5, DayType: "weekday"}))
+	assert.Equal(t, "v2:base:svc|GET /a|1+|weekend", MakeBaselineKey("svc", "GET /a", TimeBucket{Hour: 1, DayType: "weekend", Fold: true}))
+}
+
+func TestParseSeriesKey_Errors(t *testing.T) {
+	for _, key := range []string{
+		"base:svc|GET /a|9|weekday",      // v1
+		"v2:nope:svc|GET /a|9|weekday",   // unknown kind
+		"v2:base:svc|a|b|9|weekday",      // unescaped separator
+		"v2:base:svc|GET /a|x|weekday",   // bad hour
+		"v2:base:svc|GET /a|9hx|weekday", // bad minute
+		"v2:base:svc|bad%7|9|weekday",    // truncated escape
+	} {
+		_, err := ParseSeriesKey(key)
+		assert.Error(t, err, key)
+	}
+}
+
+func TestMigrateV1Key(t *testing.T) {
+	b := TimeBucket{Hour: 9, DayType: "weekday"}
+
+	got, ok := MigrateV1Key("base:svc|GET /a|b|9|weekday")
+	assert.True(t, ok)
+	assert.Equal(t, MakeBaselineKey("svc", "GET /a|b", b), got)
+
+	got, ok = MigrateV1Key("spandur:svc|db:query|9|weekday")
+	assert.True(t, ok)
+	assert.Equal(t, MakeSpanDurationKey("svc", "db:query", b), got)
+
+	_, ok = MigrateV1Key(MakeBaselineKey("svc", "GET /a", b))
+	assert.False(t, ok, "v2 keys are left alone")
+	_, ok = MigrateV1Key("seen:trace-1")
+	assert.False(t, ok)
+
+	dur, ok := BaselineKeyForDurationKey(MakeSpanDurationKey("svc", "x|y", b))
+	assert.True(t, ok)
+	assert.Equal(t, MakeSpanBaselineKey("svc", "x|y", b), dur)
+}
@@ -13,6 +13,16 @@ type TraceEvent struct {
 	// IsAnomaly indicates whether this trace duration is anomalous for the given
 	// (service, endpoint) baseline at the trace start time bucket.
 	IsAnomaly bool `json:"isAnomaly" example:"false"`
+	// Score, ZScore and Severity grade the duration; unset without a usable baseline.
+	Score    float64  `json:"score,omitempty" example:"0.83"`
+	ZScore   float64  `json:"zScore,omitempty" example:"1.2"`
+	Severity Severity `json:"severity,omitempty" example:"info"`
+	// Rule names the detection rule that matched the trace's root service and endpoint.
+	Rule string `json:"rule,omitempty" example:"health-checks"`
+	// ErrorRateAnomaly flags traces whose bucket had an anomalous error ratio on the
+	// trace's day (see ErrorRateCheckResponse); ErrorRatio is that day's ratio.
+	ErrorRateAnomaly bool    `json:"errorRateAnomaly,omitempty" example:"false"`
+	ErrorRatio       float64 `json:"errorRatio,omitempty" example:"0.012"`
 }
 
 // TraceLookupResponse returns traces matching a Tempo search.
@@ -65,10 +75,15 @@ type ErrorResponse struct {
 	Error ErrorDetail `json:"error"`
 }
 
-// TimeBucket captures hour-of-day and day type (weekday/weekend).
+// TimeBucket captures hour-of-day and day type (weekday/weekend). Depending on the
+// bucketing scheme the day type may also be a weekday ("mon".."sun") or a schedule
+// period, and Minute the start of a sub-hour slot. Fold marks the second pass of
+// a wall-clock slot repeated when DST ends, which is kept apart from the first.
 type TimeBucket struct {
-	Hour    int    `json:"hour" example:"9"`          // 0-23
-	DayType string `json:"dayType" example:"weekday"` // "weekday" or "weekend"
+	Hour    int    `json:"hour" example:"9"`              // 0-23
+	Minute  int    `json:"minute,omitempty" example:"30"` // slot start within the hour
+	DayType string `json:"dayType" example:"weekday"`     // "weekday" or "weekend"
+	Fold    bool   `json:"fold,omitempty" example:"false"`
 }
 
 // BaselineStats contains precomputed statistics for a given key/bucket.
@@ -78,6 +93,11 @@ type BaselineStats struct {
 	MAD         float64   `json:"mad" example:"0.0"`
 	SampleCount int       `json:"sampleCount" example:"188"`
 	UpdatedAt   time.Time `json:"updatedAt" example:"2026-01-16T02:00:00Z"`
+	// Percentiles holds the extra quantiles of stats.percentiles by name, e.g. "p99".
+	Percentiles map[string]float64 `json:"percentiles,omitempty"`
+	// Rejected counts the samples of the bucket the ingest guard excluded, dropped
+	// or clamped (0 for merged fallback baselines).
+	Rejected int `json:"rejected,omitempty" example:"0"`
 }
 
 // AnomalyCheckRequest is the input for anomaly checking.
@@ -96,6 +116,51 @@ type SpanAnomalyCheckRequest struct {
 	DurationMs    int64  `json:"durationMs" example:"120"`
 }
 
+// ErrorRateCheckRequest is the input for error-rate anomaly checking. Exactly one
+// of Endpoint and SpanName selects the endpoint or span series. Requests and Errors
+// are the counts to judge; without them the series' own counts of the day of
+// TimestampNano are used.
+type ErrorRateCheckRequest struct {
+	Service       string `json:"service" example:"orders"`
+	Endpoint      string `json:"endpoint,omitempty" example:"GET /api/orders"`
+	SpanName      string `json:"spanName,omitempty" example:"processPayment"`
+	TimestampNano int64  `json:"timestampNano" example:"1737000000000000000"`
+	Requests      int64  `json:"requests,omitempty" example:"200"`
+	Errors        int64  `json:"errors,omitempty" example:"14"`
+}
+
+// ErrorRatio is an error ratio with its Wilson score interval.
+type ErrorRatio struct {
+	Requests int64   `json:"requests" example:"200"`
+	Errors   int64   `json:"errors" example:"14"`
+	Ratio    float64 `json:"ratio" example:"0.07"`
+	Lower    float64 `json:"lower" example:"0.0324"`
+	Upper    float64 `json:"upper" example:"0.1446"`
+}
+
+// ErrorRateCheckResponse is the error-rate decision for one bucket and day.
+type ErrorRateCheckResponse struct {
+	IsAnomaly       bool        `json:"isAnomaly" example:"true"`
+	CannotDetermine bool        `json:"cannotDetermine,omitempty" example:"false"`
+	Bucket          TimeBucket  `json:"bucket"`
+	Timezone        string      `json:"timezone,omitempty" example:"Asia/Taipei"`
+	Observed        ErrorRatio  `json:"observed"`
+	Baseline        *ErrorRatio `json:"baseline,omitempty"`
+	// Days is the number of other days of the bucket the baseline covers.
+	Days        int     `json:"days,omitempty" example:"13"`
+	Z           float64 `json:"z,omitempty" example:"3"`
+	Explanation string  `json:"explanation" example:"error ratio 7.00% (lower bound 3.24%) above baseline 0.81% (upper bound 1.53%)"`
+}
+
+// Severity grades how far a duration is beyond its threshold.
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"     // within or just above the threshold
+	SeverityWarning  Severity = "warning"  // score above severity.warning
+	SeverityCritical Severity = "critical" // score above severity.critical
+)
+
 // BaselineSource indicates which fallback level was used to obtain the baseline.
 type BaselineSource string
 
@@ -112,10 +177,16 @@ type AnomalyCheckResponse struct {
 	IsAnomaly       bool           `json:"isAnomaly" example:"false"`
 	CannotDetermine bool           `json:"cannotDetermine,omitempty" example:"false"`
 	Bucket          TimeBucket     `json:"bucket"`
+	Timezone        string         `json:"timezone,omitempty" example:"Asia/Taipei"` // zone of the bucket's local time
 	Baseline        *BaselineStats `json:"baseline,omitempty"`
 	BaselineSource  BaselineSource `json:"baselineSource" example:"exact"`
 	FallbackLevel   int            `json:"fallbackLevel,omitempty" example:"1"`
 	SourceDetails   string         `json:"sourceDetails,omitempty" example:"exact match: 9|weekday"`
+	Detector        string         `json:"detector,omitempty" example:"hybrid"`
+	Score           float64        `json:"score,omitempty" example:"0.83"`
+	ZScore          float64        `json:"zScore,omitempty" example:"1.2"`
+	Severity        Severity       `json:"severity,omitempty" example:"info"`
+	Rule            string         `json:"rule,omitempty" example:"health-checks"`
 	Explanation     string         `json:"explanation" example:"duration 5ms within threshold 2.00ms"`
 }
 
@@ -125,11 +196,23 @@ type ChildSpanAnomaly struct {
 	IsAnomaly       bool           `json:"isAnomaly" example:"false"`
 	CannotDetermine bool           `json:"cannotDetermine,omitempty" example:"false"`
 	Bucket          TimeBucket     `json:"bucket"`
+	Timezone        string         `json:"timezone,omitempty" example:"Asia/Taipei"` // zone of the bucket's local time
 	Baseline        *BaselineStats `json:"baseline,omitempty"`
 	BaselineSource  BaselineSource `json:"baselineSource" example:"exact"`
 	FallbackLevel   int            `json:"fallbackLevel,omitempty" example:"1"`
 	SourceDetails   string         `json:"sourceDetails,omitempty" example:"exact match: 9|weekday"`
+	Detector        string         `json:"detector,omitempty" example:"hybrid"`
+	Score           float64        `json:"score,omitempty" example:"0.83"`
+	ZScore          float64        `json:"zScore,omitempty" example:"1.2"`
+	Severity        Severity       `json:"severity,omitempty" example:"info"`
+	Rule            string         `json:"rule,omitempty" example:"health-checks"`
 	Explanation     string         `json:"explanation" example:"duration 5ms within threshold 2.00ms"`
+	// Error reports whether the span itself failed (OTLP status or HTTP 5xx).
+	Error bool `json:"error,omitempty" example:"false"`
+	// ErrorRateAnomaly flags spans whose bucket had an anomalous error ratio on the
+	// span's day; ErrorRatio is that day's ratio.
+	ErrorRateAnomaly bool    `json:"errorRateAnomaly,omitempty" example:"false"`
+	ErrorRatio       float64 `json:"errorRatio,omitempty" example:"0.012"`
 }
 
 // ChildSpanAnomaliesResponse returns anomaly status for child spans of a parent span.
@@ -150,8 +233,39 @@ type ServiceEndpoint struct {
 }
 
 // AvailableServicesResponse is the output for listing available services and endpoints.
+// Spans lists span baselines the same way, with the span name in Endpoint.
 type AvailableServicesResponse struct {
 	TotalServices  int               `json:"totalServices" example:"4"`
 	TotalEndpoints int               `json:"totalEndpoints" example:"17"`
 	Services       []ServiceEndpoint `json:"services"`
+	TotalSpans     int               `json:"totalSpans" example:"42"`
+	Spans          []ServiceEndpoint `json:"spans"`
+}
+
+// BaselineShift is a lasting change in the daily baseline of one bucket found by
+// the drift detector (see config.DriftConfig).
+type BaselineShift struct {
+	Bucket TimeBucket `json:"bucket"`
+	// Metric is the baseline value that shifted: "p50" or "p95".
+	Metric string `json:"metric" example:"p50"`
+	// Direction is "up" or "down".
+	Direction string `json:"direction" example:"up"`
+	// StartedAt is the first day at the new level, DetectedAt the day the shift
+	// passed the detection threshold (both midnight UTC).
+	StartedAt  time.Time `json:"startedAt" example:"2026-01-08T00:00:00Z"`
+	DetectedAt time.Time `json:"detectedAt" example:"2026-01-12T00:00:00Z"`
+	BeforeMs   float64   `json:"beforeMs" example:"210"`
+	AfterMs    float64   `json:"afterMs" example:"275"`
+	// Magnitude is the relative change (after - before) / before, e.g. 0.31 = +31%.
+	Magnitude float64 `json:"magnitude" example:"0.31"`
+}
+
+// BaselineDriftResponse lists the shifts detected in the daily baseline history
+// of every bucket of an endpoint, most recently detected first.
+type BaselineDriftResponse struct {
+	Service  string `json:"service" example:"twdiw-customer-service-prod"`
+	Endpoint string `json:"endpoint" example:"GET /api/users"`
+	// Buckets is the number of buckets with a baseline history.
+	Buckets int             `json:"buckets" example:"48"`
+	Shifts  []BaselineShift `json:"shifts"`
 }
@@ -75,3 +75,228 @@ func TestParseTimeBucket_BoundaryHours(t *testing.T) {
     assert.Equal(t, 0, b2.Hour)
 }
 
+
+func TestBucketing_BucketAt(t *testing.T) {
+    loc, err := time.LoadLocation("Asia/Taipei")
+    if !assert.NoError(t, err) { return }
+
+    schedule := &Schedule{
+        Name:    "office",
+        Default: "off_hours",
+        Periods: []SchedulePeriod{{
+            Name:  "business_hours",
+            Days:  []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+            Start: 9 * 60,
+            End:   18 * 60,
+        }},
+    }
+    mon := time.Date(2024, 1, 8, 9, 44, 0, 0, loc)
+    sat := time.Date(2024, 1, 6, 10, 0, 0, 0, loc)
+
+    cases := []struct {
+        name   string
+        b      Bucketing
+        at     time.Time
+        expect TimeBucket
+    }{
+        {"weekday_hour default", Bucketing{}, mon, TimeBucket{Hour: 9, DayType: "weekday"}},
+        {"dow_hour", Bucketing{Scheme: SchemeDayOfWeekHour}, mon, TimeBucket{Hour: 9, DayType: "mon"}},
+        {"dow_hour saturday", Bucketing{Scheme: SchemeDayOfWeekHour}, sat, TimeBucket{Hour: 10, DayType: "sat"}},
+        {"15 minute slot", Bucketing{Scheme: SchemeSlot, SlotMinutes: 15}, mon, TimeBucket{Hour: 9, Minute: 30, DayType: "weekday"}},
+        {"30 minute slot", Bucketing{Scheme: SchemeSlot, SlotMinutes: 30}, mon, TimeBucket{Hour: 9, Minute: 30, DayType: "weekday"}},
+        {"schedule period", Bucketing{Scheme: SchemeSchedule, Schedule: schedule}, mon, TimeBucket{DayType: "business_hours"}},
+        {"schedule default", Bucketing{Scheme: SchemeSchedule, Schedule: schedule}, sat, TimeBucket{DayType: "off_hours"}},
+    }
+    for _, tc := range cases {
+        assert.Equal(t, tc.expect, tc.b.BucketAt(tc.at), tc.name)
+        got, err := tc.b.ParseTimeBucket(tsNanoAt(tc.at), "Asia/Taipei")
+        assert.NoError(t, err, tc.name)
+        assert.Equal(t, tc.expect, got, tc.name)
+    }
+}
+
+func TestBucketing_NearbyAndAll(t *testing.T) {
+    slots := Bucketing{Scheme: SchemeSlot, SlotMinutes: 30}
+    assert.Equal(t, []TimeBucket{
+        {Hour: 23, Minute: 30, DayType: "weekday"},
+        {Hour: 0, Minute: 30, DayType: "weekday"},
+        {Hour: 23, DayType: "weekday"},
+        {Hour: 1, DayType: "weekday"},
+    }, slots.Nearby(TimeBucket{Hour: 0, DayType: "weekday"}, 2))
+    assert.Len(t, slots.DayBuckets("weekday"), 48)
+    assert.Len(t, slots.All(), 96)
+
+    dow := Bucketing{Scheme: SchemeDayOfWeekHour}
+    assert.Equal(t, []TimeBucket{{Hour: 8, DayType: "tue"}, {Hour: 10, DayType: "tue"}},
+        dow.Nearby(TimeBucket{Hour: 9, DayType: "tue"}, 1))
+    assert.Len(t, dow.All(), 7*24)
+
+    // Neighbours never include the bucket itself, even when the range covers the day
+    assert.Len(t, Bucketing{}.Nearby(TimeBucket{Hour: 5, DayType: "weekday"}, 20), 23)
+
+    sched := Bucketing{Scheme: SchemeSchedule, Schedule: &Schedule{Name: "s", Default: "other", Periods: []SchedulePeriod{{Name: "peak", Start: 0, End: 60}}}}
+    assert.Nil(t, sched.Nearby(TimeBucket{DayType: "peak"}, 2))
+    assert.Equal(t, []TimeBucket{{DayType: "peak"}, {DayType: "other"}}, sched.All())
+}
+
+func TestBucketing_Validate(t *testing.T) {
+    assert.NoError(t, Bucketing{}.Validate())
+    assert.NoError(t, Bucketing{Scheme: SchemeSlot, SlotMinutes: 15}.Validate())
+    assert.Error(t, Bucketing{Scheme: SchemeSlot, SlotMinutes: 7}.Validate())
+    assert.Error(t, Bucketing{Scheme: SchemeSchedule}.Validate())
+    assert.Error(t, Bucketing{Scheme: "monthly"}.Validate())
+    assert.Error(t, Bucketing{Scheme: SchemeSchedule, Schedule: &Schedule{
+        Default: "off", Periods: []SchedulePeriod{{Name: "bad", Start: 600, End: 540}},
+    }}.Validate())
+}
+
+func TestBucketing_Calendar(t *testing.T) {
+    loc, err := time.LoadLocation("Asia/Taipei")
+    if !assert.NoError(t, err) { return }
+
+    cal := NewCalendar("sun")
+    cal.Set([]CalendarDay{
+        {Date: "2025-02-28", Name: "Peace Memorial Day"},
+        {Date: "2025-02-08", Name: "make-up workday", Workday: true},
+    })
+    holiday := time.Date(2025, 2, 28, 10, 0, 0, 0, loc) // Fri
+    workday := time.Date(2025, 2, 8, 10, 0, 0, 0, loc)  // Sat
+
+    dow := Bucketing{Scheme: SchemeDayOfWeekHour, Calendar: cal}
+    assert.Equal(t, TimeBucket{Hour: 10, DayType: "sun"}, dow.BucketAt(holiday))
+    assert.Equal(t, TimeBucket{Hour: 10, DayType: "mon"}, dow.BucketAt(workday))
+    assert.Equal(t, dayOfWeekTypes, dow.DayTypes(), "sun is already a day type")
+
+    sched := Bucketing{Scheme: SchemeSchedule, Calendar: cal, Schedule: &Schedule{
+        Name: "office", Default: "off_hours",
+        Periods: []SchedulePeriod{{Name: "business_hours", Days: []time.Weekday{time.Monday}, Start: 9 * 60, End: 18 * 60}},
+    }}
+    assert.Equal(t, "business_hours", sched.BucketAt(workday).DayType, "make-up workdays follow the Monday schedule")
+    assert.Equal(t, "sun", sched.BucketAt(holiday).DayType)
+    assert.Equal(t, []string{"business_hours", "off_hours", "sun"}, sched.DayTypes())
+
+    // Dates are local to the bucketed timestamp's zone
+    utc := holiday.Add(-10 * time.Hour).UTC() // 2025-02-28 00:00 Taipei, 2025-02-27 16:00 UTC
+    assert.Equal(t, "sun", dow.BucketAt(utc.In(loc)).DayType)
+    assert.Equal(t, "thu", dow.BucketAt(utc).DayType)
+}
+
+func TestBucketing_DSTTransitions(t *testing.T) {
+    slots := Bucketing{Scheme: SchemeSlot, SlotMinutes: 30}
+    cases := []struct {
+        name   string
+        zone   string
+        b      Bucketing
+        at     time.Time // UTC instant
+        expect TimeBucket
+    }{
+        // America/New_York: 2024-03-10 02:00 EST -> 03:00 EDT, 2024-11-03 02:00 EDT -> 01:00 EST
+        {"new york before spring forward", "America/New_York", Bucketing{}, time.Date(2024, 3, 10, 6, 59, 0, 0, time.UTC), TimeBucket{Hour: 1, DayType: "weekend"}},
+        {"new york after spring forward", "America/New_York", Bucketing{}, time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC), TimeBucket{Hour: 3, DayType: "weekend"}},
+        {"new york fall back first pass", "America/New_York", Bucketing{}, time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC), TimeBucket{Hour: 1, DayType: "weekend"}},
+        {"new york fall back second pass", "America/New_York", Bucketing{}, time.Date(2024, 11, 3, 6, 30, 0, 0, time.UTC), TimeBucket{Hour: 1, DayType: "weekend", Fold: true}},
+        {"new york after fall back", "America/New_York", Bucketing{}, time.Date(2024, 11, 3, 7, 30, 0, 0, time.UTC), TimeBucket{Hour: 2, DayType: "weekend"}},
+        {"new york second pass slot", "America/New_York", slots, time.Date(2024, 11, 3, 6, 45, 0, 0, time.UTC), TimeBucket{Hour: 1, Minute: 30, DayType: "weekend", Fold: true}},
+        // Europe/Berlin: 2024-03-31 02:00 CET -> 03:00 CEST, 2024-10-27 03:00 CEST -> 02:00 CET
+        {"berlin before spring forward", "Europe/Berlin", Bucketing{}, time.Date(2024, 3, 31, 0, 30, 0, 0, time.UTC), TimeBucket{Hour: 1, DayType: "weekend"}},
+        {"berlin after spring forward", "Europe/Berlin", Bucketing{}, time.Date(2024, 3, 31, 1, 0, 0, 0, time.UTC), TimeBucket{Hour: 3, DayType: "weekend"}},
+        {"berlin fall back first pass", "Europe/Berlin", Bucketing{}, time.Date(2024, 10, 27, 0, 30, 0, 0, time.UTC), TimeBucket{Hour: 2, DayType: "weekend"}},
+        {"berlin fall back second pass", "Europe/Berlin", Bucketing{}, time.Date(2024, 10, 27, 1, 30, 0, 0, time.UTC), TimeBucket{Hour: 2, DayType: "weekend", Fold: true}},
+        {"berlin after fall back", "Europe/Berlin", Bucketing{}, time.Date(2024, 10, 27, 2, 0, 0, 0, time.UTC), TimeBucket{Hour: 3, DayType: "weekend"}},
+        // Australia/Sydney: 2024-04-07 03:00 AEDT -> 02:00 AEST, 2024-10-06 02:00 AEST -> 03:00 AEDT
+        {"sydney fall back first pass", "Australia/Sydney", Bucketing{}, time.Date(2024, 4, 6, 15, 30, 0, 0, time.UTC), TimeBucket{Hour: 2, DayType: "weekend"}},
+        {"sydney fall back second pass", "Australia/Sydney", Bucketing{}, time.Date(2024, 4, 6, 16, 30, 0, 0, time.UTC), TimeBucket{Hour: 2, DayType: "weekend", Fold: true}},
+        {"sydney before spring forward", "Australia/Sydney", Bucketing{}, time.Date(2024, 10, 5, 15, 30, 0, 0, time.UTC), TimeBucket{Hour: 1, DayType: "weekend"}},
+        {"sydney after spring forward", "Australia/Sydney", Bucketing{}, time.Date(2024, 10, 5, 16, 0, 0, 0, time.UTC), TimeBucket{Hour: 3, DayType: "weekend"}},
+        // Australia/Lord_Howe moves by half an hour: 2024-04-07 02:00 +11 -> 01:30 +10:30
+        {"lord howe fall back first pass", "Australia/Lord_Howe", Bucketing{}, time.Date(2024, 4, 6, 14, 45, 0, 0, time.UTC), TimeBucket{Hour: 1, DayType: "weekend"}},
+        {"lord howe fall back second pass", "Australia/Lord_Howe", Bucketing{}, time.Date(2024, 4, 6, 15, 15, 0, 0, time.UTC), TimeBucket{Hour: 1, DayType: "weekend", Fold: true}},
+        {"lord howe after fall back", "Australia/Lord_Howe", Bucketing{}, time.Date(2024, 4, 6, 15, 30, 0, 0, time.UTC), TimeBucket{Hour: 2, DayType: "weekend"}},
+        // Asia/Taipei has no DST
+        {"taipei", "Asia/Taipei", Bucketing{}, time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC), TimeBucket{Hour: 13, DayType: "weekend"}},
+    }
+    for _, tc := range cases {
+        got, err := tc.b.ParseTimeBucket(tsNanoAt(tc.at), tc.zone)
+        if !assert.NoError(t, err, tc.name) { continue }
+        assert.Equal(t, tc.expect, got, tc.name)
+    }
+}
+
+func TestBucketing_NearbyAtDST(t *testing.T) {
+    slots := Bucketing{Scheme: SchemeSlot, SlotMinutes: 30}
+    cases := []struct {
+        name   string
+        zone   string
+        b      Bucketing
+        at     time.Time // UTC instant
+        n      int
+        expect []string // slot labels
+    }{
+        // The missing 02:00 hour is skipped
+        {"new york spring forward", "America/New_York", Bucketing{}, time.Date(2024, 3, 10, 7, 30, 0, 0, time.UTC), 2, []string{"1", "4", "0", "5"}},
+        {"new york spring forward slots", "America/New_York", slots, time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC), 2, []string{"1h30", "3h30", "1", "4"}},
+        {"berlin spring forward", "Europe/Berlin", Bucketing{}, time.Date(2024, 3, 31, 1, 0, 0, 0, time.UTC), 1, []string{"1", "4"}},
+        {"sydney spring forward", "Australia/Sydney", Bucketing{}, time.Date(2024, 10, 5, 16, 0, 0, 0, time.UTC), 1, []string{"1", "4"}},
+        // Both passes of the repeated hour neighbour each other
+        {"new york fall back first pass", "America/New_York", Bucketing{}, time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC), 2, []string{"0", "1+", "23", "2"}},
+        {"new york fall back second pass", "America/New_York", Bucketing{}, time.Date(2024, 11, 3, 6, 30, 0, 0, time.UTC), 2, []string{"1", "2", "0", "3"}},
+        {"berlin fall back second pass", "Europe/Berlin", Bucketing{}, time.Date(2024, 10, 27, 1, 30, 0, 0, time.UTC), 1, []string{"2", "3"}},
+        {"lord howe fall back second pass", "Australia/Lord_Howe", Bucketing{}, time.Date(2024, 4, 6, 15, 15, 0, 0, time.UTC), 1, []string{"1", "2"}},
+    }
+    for _, tc := range cases {
+        loc, err := time.LoadLocation(tc.zone)
+        if !assert.NoError(t, err, tc.name) { continue }
+        at := tc.at.In(loc)
+        var got []string
+        for _, nb := range tc.b.NearbyAt(at, tc.n) {
+            assert.Equal(t, tc.b.BucketAt(at).DayType, nb.DayType, tc.name)
+            got = append(got, nb.SlotLabel())
+        }
+        assert.Equal(t, tc.expect, got, tc.name)
+    }
+
+    // Off transition days NearbyAt matches the bucket's usual neighbours
+    loc, err := time.LoadLocation("America/New_York")
+    if !assert.NoError(t, err) { return }
+    for _, at := range []time.Time{time.Date(2024, 6, 5, 12, 0, 0, 0, loc), time.Date(2024, 6, 5, 0, 15, 0, 0, loc)} {
+        for _, b := range []Bucketing{{}, slots} {
+            assert.Equal(t, b.Nearby(b.BucketAt(at), 3), b.NearbyAt(at, 3), at.String())
+        }
+    }
+
+    // Without the instant a Fold bucket's first pass is its nearest neighbour
+    assert.Equal(t, []TimeBucket{{Hour: 1, DayType: "weekend"}, {Hour: 0, DayType: "weekend"}, {Hour: 2, DayType: "weekend"}},
+        Bucketing{}.Nearby(TimeBucket{Hour: 1, DayType: "weekend", Fold: true}, 1))
+}
+
+func TestBucketing_DayBucketsIncludeFolds(t *testing.T) {
+    newYork, err := time.LoadLocation("America/New_York")
+    if !assert.NoError(t, err) { return }
+    lordHowe, err := time.LoadLocation("Australia/Lord_Howe")
+    if !assert.NoError(t, err) { return }
+    taipei, err := time.LoadLocation("Asia/Taipei")
+    if !assert.NoError(t, err) { return }
+
+    // Zones without DST add no buckets
+    assert.Len(t, Bucketing{Locations: []*time.Location{taipei}}.DayBuckets("weekend"), 24)
+
+    // New York repeats 1:00-2:00; its Fold bucket follows the first pass
+    hours := Bucketing{Locations: []*time.Location{taipei, newYork}}
+    day := hours.DayBuckets("weekend")
+    if assert.Len(t, day, 25) {
+        assert.Equal(t, TimeBucket{Hour: 1, DayType: "weekend"}, day[1])
+        assert.Equal(t, TimeBucket{Hour: 1, DayType: "weekend", Fold: true}, day[2])
+    }
+    assert.Len(t, hours.All(), 50)
+    assert.Contains(t, hours.All(), TimeBucket{Hour: 1, DayType: "weekday", Fold: true})
+
+    // Lord Howe repeats 1:30-2:00, only the second half of the 1:00 hour
+    slots := Bucketing{Scheme: SchemeSlot, SlotMinutes: 30, Locations: []*time.Location{lordHowe}}
+    var folds []TimeBucket
+    for _, b := range slots.DayBuckets("weekend") {
+        if b.Fold {
+            folds = append(folds, b)
+        }
+    }
+    assert.Equal(t, []TimeBucket{{Hour: 1, Minute: 30, DayType: "weekend", Fold: true}}, folds)
+}
@@ -2,11 +2,12 @@ package jobs
 
 import (
 	"context"
+	"fmt"
 	"log"
-	"strings"
 	"time"
 
 	"github.com/alexchang/tempo-latency-anomaly-service/internal/config"
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
 	"github.com/alexchang/tempo-latency-anomaly-service/internal/service"
 	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
 )
@@ -50,31 +51,68 @@ func (b *BaselineRecompute) Run(ctx context.Context) {
 	}
 }
 
+// tick claims a batch of dirty keys and recomputes them. Each key is acknowledged
+// on success and negatively acknowledged on failure, so a failed or interrupted
+// tick never loses work: failed keys are retried and end up in the dead-letter
+// set after Polling.BaselineMaxRetries attempts.
 func (b *BaselineRecompute) tick(ctx context.Context) {
-	keys, err := b.store.PopDirtyBatch(ctx, b.batch)
+	lease := b.cfg.Polling.BaselineLease
+	if lease <= 0 {
+		lease = 5 * time.Minute
+	}
+	keys, err := b.store.ClaimDirtyBatch(ctx, b.batch, lease)
 	if err != nil {
-		log.Printf("dirty pop error: %v", err)
+		log.Printf("dirty claim error: %v", err)
 		return
 	}
+
+	var done []string
 	for _, k := range keys {
-		if strings.HasPrefix(k, "spanbase:") {
-			if b.spanBase == nil {
-				log.Printf("span baseline recompute skipped (not configured) for %s", k)
-				continue
-			}
-			if _, err := b.spanBase.RecomputeForKey(ctx, k); err != nil {
-				log.Printf("span baseline recompute error for %s: %v", k, err)
+		if err := b.recompute(ctx, k); err != nil {
+			log.Printf("%v", err)
+			dead, err := b.store.NackDirty(ctx, k, b.cfg.Polling.BaselineMaxRetries)
+			if err != nil {
+				log.Printf("dirty nack error for %s: %v", k, err)
+			} else if dead {
+				log.Printf("baseline key %s moved to dead-letter set after repeated failures", k)
 			}
 			continue
 		}
+		done = append(done, k)
+	}
 
-		if strings.HasPrefix(k, "base:") {
-			if _, err := b.baseline.RecomputeForKey(ctx, k); err != nil {
-				log.Printf("baseline recompute error for %s: %v", k, err)
-			}
-			continue
-		}
+	if err := b.store.AckDirty(ctx, done...); err != nil {
+		log.Printf("dirty ack error: %v", err)
+	}
+}
+
+// recompute refreshes the baseline for one dirty key. Keys that can never be
+// processed (unparseable or unknown kind, span baselines not configured) are
+// logged and treated as done.
+func (b *BaselineRecompute) recompute(ctx context.Context, k string) error {
+	sk, err := domain.ParseSeriesKey(k)
+	if err != nil {
+		log.Printf("unparseable baseline key %s: %v", k, err)
+		return nil
+	}
 
-		log.Printf("unknown baseline key prefix: %s", k)
+	switch sk.Kind {
+	case domain.KindSpanBaseline:
+		if b.spanBase == nil {
+			log.Printf("span baseline recompute skipped (not configured) for %s", k)
+			return nil
+		}
+		if _, err := b.spanBase.RecomputeForKey(ctx, k); err != nil {
+			return fmt.Errorf("span baseline recompute error for %s: %w", k, err)
+		}
+		return nil
+	case domain.KindBaseline:
+		if _, err := b.baseline.RecomputeForKey(ctx, k); err != nil {
+			return fmt.Errorf("baseline recompute error for %s: %w", k, err)
+		}
+		return nil
+	default:
+		log.Printf("unknown baseline key kind: %s", k)
+		return nil
 	}
 }
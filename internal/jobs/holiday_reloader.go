@@ -0,0 +1,76 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/config"
+)
+
+// HolidayReloader re-reads the holiday calendar file whenever its modification
+// time or size changes, so calendar updates apply without a restart. Samples that
+// were already bucketed keep their bucket.
+type HolidayReloader struct {
+	cfg *config.Config
+
+	modTime time.Time
+	size    int64
+}
+
+func NewHolidayReloader(cfg *config.Config) *HolidayReloader {
+	return &HolidayReloader{cfg: cfg}
+}
+
+func (h *HolidayReloader) Run(ctx context.Context) {
+	if h == nil || h.cfg == nil || h.cfg.Holidays.File == "" {
+		return
+	}
+	interval := h.cfg.Holidays.ReloadInterval
+	if interval <= 0 {
+		log.Printf("holiday calendar reload disabled (reload_interval=0)")
+		return
+	}
+
+	// The calendar was loaded with the config; remember the file it came from
+	h.changed()
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			h.tick()
+		}
+	}
+}
+
+func (h *HolidayReloader) tick() {
+	if !h.changed() {
+		return
+	}
+	n, err := h.cfg.ReloadHolidays()
+	if err != nil {
+		// Keep the previous calendar; a half-written file is retried on the next change
+		log.Printf("holiday calendar reload error: %v", err)
+		return
+	}
+	log.Printf("holiday calendar reloaded from %s: %d days", h.cfg.Holidays.File, n)
+}
+
+// changed reports whether the file differs from the last time it was seen.
+func (h *HolidayReloader) changed() bool {
+	fi, err := os.Stat(h.cfg.Holidays.File)
+	if err != nil {
+		log.Printf("holiday calendar stat error: %v", err)
+		return false
+	}
+	if fi.ModTime().Equal(h.modTime) && fi.Size() == h.size {
+		return false
+	}
+	h.modTime, h.size = fi.ModTime(), fi.Size()
+	return true
+}
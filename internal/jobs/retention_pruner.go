@@ -0,0 +1,73 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/config"
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+// RetentionPruner periodically drops duration samples older than cfg.Retention
+// and marks the baselines of the affected windows dirty so they are recomputed.
+type RetentionPruner struct {
+	cfg   *config.Config
+	store store.Store
+}
+
+func NewRetentionPruner(cfg *config.Config, st store.Store) *RetentionPruner {
+	return &RetentionPruner{cfg: cfg, store: st}
+}
+
+func (p *RetentionPruner) Run(ctx context.Context) {
+	if p == nil || p.store == nil || p.cfg == nil {
+		return
+	}
+	if p.cfg.Retention <= 0 {
+		log.Printf("retention pruner disabled (retention=0)")
+		return
+	}
+	interval := p.cfg.Polling.PruneInterval
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+
+	// Run immediately on startup
+	p.tick(ctx)
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			p.tick(ctx)
+		}
+	}
+}
+
+func (p *RetentionPruner) tick(ctx context.Context) {
+	before := time.Now().Add(-p.cfg.Retention)
+	keys, err := p.store.PruneDurations(ctx, before)
+	if err != nil {
+		log.Printf("retention prune error: %v", err)
+		return
+	}
+	if len(keys) == 0 {
+		return
+	}
+
+	for _, k := range keys {
+		baseKey, ok := domain.BaselineKeyForDurationKey(k)
+		if !ok {
+			continue
+		}
+		if err := p.store.MarkDirty(ctx, baseKey); err != nil {
+			log.Printf("retention prune: mark dirty %s: %v", baseKey, err)
+		}
+	}
+	log.Printf("retention prune: dropped samples older than %s from %d windows", before.UTC().Format(time.RFC3339), len(keys))
+}
@@ -195,25 +195,12 @@ func (p *TempoPoller) ingestTraceAndSpans(ctx context.Context, ev domain.TraceEv
 		return false, fmt.Errorf("ingest service not initialized")
 	}
 
-	ingested, err := p.ingest.TraceWithResult(ctx, ev)
-	if err != nil || !ingested {
-		return ingested, err
+	// The trace sample and all of its span samples are written as one atomic batch.
+	var fetch service.SpanFetcher
+	if p.spans != nil {
+		fetch = p.client.GetTraceSpans
 	}
-
-	if p.spans == nil {
-		return ingested, nil
-	}
-
-	spans, err := p.client.GetTraceSpans(ctx, ev.TraceID)
-	if err != nil {
-		return ingested, fmt.Errorf("fetch trace spans: %w", err)
-	}
-
-	if err := p.spans.Spans(ctx, spans); err != nil {
-		return ingested, fmt.Errorf("ingest span durations: %w", err)
-	}
-
-	return ingested, nil
+	return p.ingest.TraceWithSpans(ctx, ev, p.spans, fetch)
 }
 
 // (no additional types)
@@ -2,21 +2,52 @@ package observability
 
 import (
     "fmt"
+    "log"
     "net/http"
     "time"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/store"
 )
 
 // MetricsHandler exposes a minimal Prometheus-compatible metrics endpoint.
 // This is a lightweight placeholder without external deps.
-func MetricsHandler(w http.ResponseWriter, r *http.Request) {
-    w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
-    now := time.Now().Unix()
-    // Simple up metric and current unix time gauge
-    _, _ = fmt.Fprintf(w, "# HELP app_up Application up status\n")
-    _, _ = fmt.Fprintf(w, "# TYPE app_up gauge\n")
-    _, _ = fmt.Fprintf(w, "app_up 1\n")
-    _, _ = fmt.Fprintf(w, "# HELP app_now_unixtime Current unix time\n")
-    _, _ = fmt.Fprintf(w, "# TYPE app_now_unixtime gauge\n")
-    _, _ = fmt.Fprintf(w, "app_now_unixtime %d\n", now)
+// When queue is non-nil the dirty-key queue depth and oldest pending age of every
+// tenant are exported too, labelled with tenant ("" for the default tenant).
+func MetricsHandler(queue store.DirtyOps, tenants []string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+        now := time.Now().Unix()
+        // Simple up metric and current unix time gauge
+        _, _ = fmt.Fprintf(w, "# HELP app_up Application up status\n")
+        _, _ = fmt.Fprintf(w, "# TYPE app_up gauge\n")
+        _, _ = fmt.Fprintf(w, "app_up 1\n")
+        _, _ = fmt.Fprintf(w, "# HELP app_now_unixtime Current unix time\n")
+        _, _ = fmt.Fprintf(w, "# TYPE app_now_unixtime gauge\n")
+        _, _ = fmt.Fprintf(w, "app_now_unixtime %d\n", now)
+
+        if queue == nil {
+            return
+        }
+        stats := make([]store.DirtyQueueStats, len(tenants))
+        for i, t := range tenants {
+            st, err := queue.DirtyQueueStats(store.WithTenant(r.Context(), t))
+            if err != nil {
+                log.Printf("metrics: dirty queue stats (tenant %q): %v", t, err)
+                return
+            }
+            stats[i] = st
+        }
+        gauge(w, "dirty_queue_pending", "Baseline keys waiting to be recomputed", tenants, stats, func(st store.DirtyQueueStats) float64 { return float64(st.Pending) })
+        gauge(w, "dirty_queue_leased", "Baseline keys claimed by a recompute worker", tenants, stats, func(st store.DirtyQueueStats) float64 { return float64(st.Leased) })
+        gauge(w, "dirty_queue_dead_letter", "Baseline keys that exhausted their recompute retries", tenants, stats, func(st store.DirtyQueueStats) float64 { return float64(st.DeadLetter) })
+        gauge(w, "dirty_queue_oldest_pending_seconds", "Age of the oldest pending baseline key", tenants, stats, func(st store.DirtyQueueStats) float64 { return st.OldestPendingAge.Seconds() })
+    }
 }
 
+func gauge(w http.ResponseWriter, name, help string, tenants []string, stats []store.DirtyQueueStats, value func(store.DirtyQueueStats) float64) {
+    _, _ = fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+    _, _ = fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+    for i, t := range tenants {
+        _, _ = fmt.Fprintf(w, "%s{tenant=%q} %g\n", name, t, value(stats[i]))
+    }
+}
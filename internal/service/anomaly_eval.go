@@ -1,9 +1,10 @@
 package service
 
 import (
-	"fmt"
+	"math"
 
 	"github.com/alexchang/tempo-latency-anomaly-service/internal/config"
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
 	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
 )
 
@@ -12,40 +13,65 @@ type DurationEvaluation struct {
 	IsAnomaly   bool
 	ThresholdMs float64
 	Explanation string
+	// Detector names the detector that made the decision.
+	Detector string
+	// Score is duration / threshold; above 1 is anomalous.
+	Score float64
+	// ZScore is the robust z-score (duration - p50) / (1.4826*MAD) with MAD raised
+	// to stats.mad_epsilon; 0 if both are 0.
+	ZScore float64
+	// Severity grades Score by the severity cut points.
+	Severity domain.Severity
 }
 
-// EvaluateDuration applies the default threshold strategy and returns decision details.
+// EvaluateDuration applies the globally configured detector and returns decision details.
 func EvaluateDuration(cfg *config.Config, durationMs int64, baseline *store.Baseline) DurationEvaluation {
-	if cfg == nil || baseline == nil {
+	if cfg == nil {
+		return evaluateDuration(nil, config.Settings{}, durationMs, baseline)
+	}
+	return evaluateDuration(cfg, config.Settings{Stats: cfg.Stats, Detection: cfg.Detection, Fallback: cfg.Fallback}, durationMs, baseline)
+}
+
+// evaluateDuration applies the detector of the resolved settings (see config.SettingsFor).
+// baseline may be nil for detectors that do not need one (see Detector.NeedsBaseline).
+func evaluateDuration(cfg *config.Config, set config.Settings, durationMs int64, baseline *store.Baseline) DurationEvaluation {
+	d := settingsDetector(set)
+	if cfg == nil || (baseline == nil && d.NeedsBaseline()) {
 		return DurationEvaluation{
 			IsAnomaly:   false,
 			ThresholdMs: 0,
 			Explanation: "baseline unavailable",
 		}
 	}
-
-	rel := baseline.P95 * cfg.Stats.Factor
-	abs := baseline.P50 + float64(cfg.Stats.K)*baseline.MAD
-	threshold := rel
-	if abs > threshold {
-		threshold = abs
+	eval := d.Evaluate(durationMs, baseline)
+	// Thresholds below 1ms (e.g. an all-zero baseline) would inflate the score
+	eval.Score = float64(durationMs) / math.Max(eval.ThresholdMs, 1)
+	if baseline != nil {
+		if sigma := madToSigma * max(baseline.MAD, millis(set.Stats.MADEpsilon)); sigma > 0 {
+			eval.ZScore = robustZ(float64(durationMs), baseline.P50, sigma)
+		}
 	}
+	eval.Severity = severityOf(cfg.Severity, eval.Score)
+	return eval
+}
 
-	dur := float64(durationMs)
-	isAnomaly := dur > threshold
-	explanation := fmt.Sprintf(
-		"duration %.0fms %s threshold %.2fms (p50=%.2f, p95=%.2f, MAD=%.2f, factor=%.2f, k=%d)",
-		dur,
-		ternary(isAnomaly, "exceeds", "within"),
-		threshold,
-		baseline.P50, baseline.P95, baseline.MAD,
-		cfg.Stats.Factor,
-		cfg.Stats.K,
-	)
+// lacksBaseline reports whether the detector of set needs a baseline and b is
+// missing or has fewer than min_samples. Fixed-threshold detectors (slo) never
+// lack one, so they also judge new and low-traffic endpoints.
+func lacksBaseline(set config.Settings, b *store.Baseline) bool {
+	if !settingsDetector(set).NeedsBaseline() {
+		return false
+	}
+	return b == nil || b.SampleCount < set.Stats.MinSamples
+}
 
-	return DurationEvaluation{
-		IsAnomaly:   isAnomaly,
-		ThresholdMs: threshold,
-		Explanation: explanation,
+// severityOf grades score by the configured cut points.
+func severityOf(cfg config.SeverityConfig, score float64) domain.Severity {
+	switch {
+	case score > cfg.Critical:
+		return domain.SeverityCritical
+	case score > cfg.Warning:
+		return domain.SeverityWarning
 	}
+	return domain.SeverityInfo
 }
@@ -3,8 +3,7 @@ package service
 import (
     "context"
     "fmt"
-    "strconv"
-    "strings"
+    "sort"
     "time"
 
     "github.com/alexchang/tempo-latency-anomaly-service/internal/config"
@@ -24,64 +23,329 @@ func NewBaseline(store store.Store, cfg *config.Config) *Baseline {
 }
 
 // RecomputeForKey recomputes baseline stats for a single baseline key (base:{...}).
-// It derives the corresponding duration key (dur:{...}), fetches samples, computes stats,
-// and persists them back to the baseline hash.
+// It derives the corresponding duration key (dur:{...}), reads the bucket's quantile
+// sketch (or its decayed raw window, see recomputeStats), computes stats from it,
+// and persists them back to the baseline hash, unless the existing baseline has
+// min_samples and the window does not (see keepBaseline). Baselines with enough samples are
+// also recorded as the day's entry of the bucket's history (see Drift).
 func (s *Baseline) RecomputeForKey(ctx context.Context, baselineKey string) (*domain.BaselineStats, error) {
     if s == nil || s.store == nil || s.cfg == nil {
         return nil, fmt.Errorf("baseline service not initialized")
     }
 
-    service, endpoint, hour, dayType, err := parseBaselineKey(baselineKey)
+    service, endpoint, bucket, err := parseBaselineKey(baselineKey)
     if err != nil {
         return nil, err
     }
 
-    bucket := domain.TimeBucket{Hour: hour, DayType: dayType}
     durKey := domain.MakeDurationKey(service, endpoint, bucket)
 
-    samples, err := s.store.GetDurations(ctx, durKey)
+    sc := s.cfg.SettingsFor(service, endpoint).Stats
+    bs, err := recomputeStats(ctx, s.store, s.cfg, sc, durKey)
     if err != nil {
-        return nil, fmt.Errorf("get durations: %w", err)
+        return nil, err
+    }
+    kept, err := keepBaseline(ctx, s.store, baselineKey, bs, sc.MinSamples)
+    if err != nil || kept != nil {
+        return kept, err
     }
 
-    bs := stats.ComputeBaseline(samples)
     // Always store what we have; Check will guard on MinSamples
+    now := time.Now().UTC()
     err = s.store.SetBaseline(ctx, baselineKey, store.Baseline{
         P50:         bs.P50,
         P95:         bs.P95,
         MAD:         bs.MAD,
         SampleCount: bs.SampleCount,
-        UpdatedAt:   time.Now().UTC(),
+        UpdatedAt:   now,
+        Percentiles: bs.Percentiles,
     })
     if err != nil {
         return nil, fmt.Errorf("set baseline: %w", err)
     }
 
-    bs.UpdatedAt = time.Now().UTC()
+    // Thin baselines would only add noise to the drift detection
+    if s.cfg.Drift.History > 0 && bs.SampleCount >= sc.MinSamples {
+        snap := store.BaselineSnapshot{Day: now, P50: bs.P50, P95: bs.P95, MAD: bs.MAD, SampleCount: bs.SampleCount}
+        historyKey := domain.MakeHistoryKey(service, endpoint, bucket)
+        if err := s.store.RecordBaselineHistory(ctx, historyKey, snap, now.Add(-s.cfg.Drift.History)); err != nil {
+            return nil, fmt.Errorf("record baseline history: %w", err)
+        }
+    }
+
+    bs.UpdatedAt = now
     return &bs, nil
 }
 
-// parseBaselineKey expects format: base:{service}|{endpoint}|{hour}|{dayType}
-func parseBaselineKey(key string) (service, endpoint string, hour int, dayType string, err error) {
-    if !strings.HasPrefix(key, "base:") {
-        err = fmt.Errorf("invalid baseline key prefix: %s", key)
-        return
+// BackfillSketches rebuilds the sketch of every duration window that holds more
+// retained samples than its sketch counts (see backfillSketches) and returns how
+// many it rebuilt.
+func (s *Baseline) BackfillSketches(ctx context.Context) (int, error) {
+    if s == nil || s.store == nil || s.cfg == nil {
+        return 0, fmt.Errorf("baseline service not initialized")
     }
-    body := strings.TrimPrefix(key, "base:")
-    parts := strings.Split(body, "|")
-    if len(parts) != 4 {
-        err = fmt.Errorf("invalid baseline key format: %s", key)
-        return
+    return backfillSketches(ctx, s.store, s.cfg, domain.KindDuration)
+}
+
+// keepBaseline returns the stats of the baseline at key when it should stay
+// instead of recomputed stats bs with fewer than minSamples samples, nil otherwise.
+// A baseline with enough samples but no window behind them, e.g. imported from a
+// snapshot without samples or left from before a traffic gap, stays in use until
+// its window has refilled, rather than being replaced by the first new sample.
+func keepBaseline(ctx context.Context, st store.Store, key string, bs domain.BaselineStats, minSamples int) (*domain.BaselineStats, error) {
+    if bs.SampleCount >= minSamples {
+        return nil, nil
+    }
+    existing, err := st.GetBaseline(ctx, key)
+    if err != nil {
+        return nil, fmt.Errorf("get baseline: %w", err)
+    }
+    if existing == nil || existing.SampleCount < minSamples {
+        return nil, nil
+    }
+    return &domain.BaselineStats{
+        P50:         existing.P50,
+        P95:         existing.P95,
+        MAD:         existing.MAD,
+        SampleCount: existing.SampleCount,
+        UpdatedAt:   existing.UpdatedAt,
+        Percentiles: existing.Percentiles,
+        Rejected:    existing.Rejected,
+    }, nil
+}
+
+// recomputeStats computes the stats of the window at durKey with the baseline
+// model of sc: the retained sketch for "window", or the raw samples within
+// retention weighted by their age for "decayed". Windows without raw samples
+// (e.g. restored from sketches only) fall back to the sketch.
+func recomputeStats(ctx context.Context, st store.Store, cfg *config.Config, sc config.StatsConfig, durKey string) (domain.BaselineStats, error) {
+    if sc.Model == config.StatsModelDecayed {
+        samples, err := st.GetSamples(ctx, durKey, retentionSince(cfg))
+        if err != nil {
+            return domain.BaselineStats{}, fmt.Errorf("get durations: %w", err)
+        }
+        if len(samples) > 0 {
+            return decayedBaseline(cfg, samples, sc.HalfLife), nil
+        }
+    }
+
+    sk, err := retainedSketch(ctx, st, cfg, durKey)
+    if err != nil {
+        return domain.BaselineStats{}, err
+    }
+    return sketchBaseline(cfg, sk), nil
+}
+
+// decayedBaseline weighs samples (newest first) by their age relative to the
+// newest one and reads the weighted baseline stats.
+func decayedBaseline(cfg *config.Config, samples []store.Sample, halfLife time.Duration) domain.BaselineStats {
+    weighted := make([]stats.WeightedSample, len(samples))
+    for i, smp := range samples {
+        weighted[i] = stats.WeightedSample{
+            DurationMs: smp.DurationMs,
+            Weight:     stats.DecayWeight(samples[0].At.Sub(smp.At), halfLife),
+        }
+    }
+    return stats.DecayedBaseline(weighted, cfg.BaselinePercentiles())
+}
+
+// sketchBaseline reads the baseline stats of sk, including the extra quantiles
+// of stats.percentiles and those the configured detectors need.
+func sketchBaseline(cfg *config.Config, sk *stats.Sketch) domain.BaselineStats {
+    method, err := stats.ParsePercentileMethod(cfg.Stats.PercentileMethod)
+    if err != nil {
+        // Rejected by config validation; keep the default for hand-built configs.
+        method = stats.NearestRank
+    }
+    return stats.BaselineFromSketch(sk, cfg.BaselinePercentiles(), method)
+}
+
+// retainedSketch merges the day slices of the sketch behind durKey that are within
+// cfg.Retention, newest first, until they cover cfg.WindowSize samples (see
+// windowSketch). Buckets ingested before sketches existed have none yet; their
+// sketch is built once from the raw window.
+func retainedSketch(ctx context.Context, st store.Store, cfg *config.Config, durKey string) (*stats.Sketch, error) {
+    sketchKey, ok := domain.SketchKeyForDurationKey(durKey)
+    if !ok {
+        return nil, fmt.Errorf("invalid duration key: %s", durKey)
+    }
+    since := retentionSince(cfg)
+
+    slices, err := st.GetSketch(ctx, sketchKey, since)
+    if err != nil {
+        return nil, fmt.Errorf("get sketch: %w", err)
+    }
+    if len(slices) == 0 {
+        if slices, err = backfillSketch(ctx, st, durKey, sketchKey, since); err != nil {
+            return nil, err
+        }
+    }
+    return windowSketch(slices, cfg.WindowSize), nil
+}
+
+// retentionSince returns the oldest sample time within cfg.Retention
+// (zero when retention is disabled).
+func retentionSince(cfg *config.Config) time.Time {
+    if cfg.Retention <= 0 {
+        return time.Time{}
+    }
+    return time.Now().Add(-cfg.Retention)
+}
+
+// backfillSketch builds and stores the sketch of the samples at durKey observed
+// at or after since.
+func backfillSketch(ctx context.Context, st store.Store, durKey, sketchKey string, since time.Time) ([]store.SketchSlice, error) {
+    samples, err := st.GetSamples(ctx, durKey, since)
+    if err != nil {
+        return nil, fmt.Errorf("get durations: %w", err)
+    }
+    if len(samples) == 0 {
+        return nil, nil
+    }
+    slices := sketchSlices(samples)
+    if err := st.ReplaceSketch(ctx, sketchKey, slices); err != nil {
+        return nil, fmt.Errorf("backfill sketch: %w", err)
+    }
+    return slices, nil
+}
+
+// backfillSketches rebuilds the sketches of the windows of kind (domain.KindDuration
+// or domain.KindSpanDuration) from their retained samples where the sketch counts
+// fewer samples than the window holds. That is the case for windows written before
+// sketches existed: the first ingest after the upgrade creates their sketch with
+// that one sample, so the lazy backfill of retainedSketch never sees them without
+// one. Sketches keep whole days and all samples ever ingested, so a complete sketch
+// never counts fewer samples than its window. Run it before ingestion starts, as a
+// sample ingested during the rebuild may be lost from the sketch.
+func backfillSketches(ctx context.Context, st store.Store, cfg *config.Config, kind domain.KeyKind) (int, error) {
+    keys, err := st.ListKeys(ctx, domain.KeyPrefix(kind))
+    if err != nil {
+        return 0, fmt.Errorf("list keys: %w", err)
     }
-    service = parts[0]
-    endpoint = parts[1]
-    h, perr := strconv.Atoi(parts[2])
+    since := retentionSince(cfg)
+    var rebuilt int
+    for _, durKey := range keys {
+        sketchKey, ok := domain.SketchKeyForDurationKey(durKey)
+        if !ok {
+            continue
+        }
+        samples, err := st.GetSamples(ctx, durKey, since)
+        if err != nil {
+            return rebuilt, fmt.Errorf("get durations: %w", err)
+        }
+        if len(samples) == 0 {
+            continue
+        }
+        slices, err := st.GetSketch(ctx, sketchKey, since)
+        if err != nil {
+            return rebuilt, fmt.Errorf("get sketch: %w", err)
+        }
+        var counted int64
+        for _, slice := range slices {
+            for _, n := range slice.Bins {
+                counted += n
+            }
+        }
+        if counted >= int64(len(samples)) {
+            continue
+        }
+        if err := st.ReplaceSketch(ctx, sketchKey, sketchSlices(samples)); err != nil {
+            return rebuilt, fmt.Errorf("backfill sketch: %w", err)
+        }
+        rebuilt++
+    }
+    return rebuilt, nil
+}
+
+// windowSketch merges slices (newest first) until they cover windowSize samples.
+// A day that would overshoot the window is scaled down to the samples still
+// missing, keeping its distribution, so the result counts at most windowSize.
+func windowSketch(slices []store.SketchSlice, windowSize int) *stats.Sketch {
+    sk := stats.NewSketch()
+    for _, slice := range slices {
+        if windowSize <= 0 {
+            sk.MergeBins(slice.Bins)
+            continue
+        }
+        missing := int64(windowSize) - sk.Count()
+        if missing <= 0 {
+            break
+        }
+        sk.MergeBins(scaleBins(slice.Bins, missing))
+    }
+    return sk
+}
+
+// scaleBins returns bins scaled to n samples in total (bins unchanged if they hold
+// no more than n). Counts are rounded down and the remainder goes to the bins
+// with the largest fractions, lower bins first on ties.
+func scaleBins(bins map[int32]int64, n int64) map[int32]int64 {
+    var total int64
+    for _, c := range bins {
+        total += c
+    }
+    if total <= n {
+        return bins
+    }
+    keys := make([]int32, 0, len(bins))
+    for b := range bins {
+        keys = append(keys, b)
+    }
+    sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+    out := make(map[int32]int64, len(bins))
+    rem := make(map[int32]int64, len(bins))
+    left := n
+    for _, b := range keys {
+        out[b] = bins[b] * n / total
+        rem[b] = bins[b] * n % total
+        left -= out[b]
+    }
+    sort.SliceStable(keys, func(i, j int) bool { return rem[keys[i]] > rem[keys[j]] })
+    for i := int64(0); i < left; i++ {
+        out[keys[i]]++
+    }
+    return out
+}
+
+// sketchSlices counts samples into per-day sketch slices, newest day first.
+func sketchSlices(samples []store.Sample) []store.SketchSlice {
+    byDay := make(map[time.Time]*stats.Sketch)
+    var days []time.Time
+    for _, smp := range samples {
+        day := store.SketchDay(smp.At)
+        sk, ok := byDay[day]
+        if !ok {
+            sk = stats.NewSketch()
+            byDay[day] = sk
+            days = append(days, day)
+        }
+        sk.Add(smp.DurationMs)
+    }
+    sort.Slice(days, func(i, j int) bool { return days[i].After(days[j]) })
+    out := make([]store.SketchSlice, len(days))
+    for i, day := range days {
+        out[i] = store.SketchSlice{Day: day, Bins: byDay[day].Bins()}
+    }
+    return out
+}
+
+// sketchSample returns the sketch update for a duration sample appended to durKey's
+// window under sketchKey.
+func sketchSample(sketchKey string, durationMs int64, at time.Time) store.SketchSample {
+    return store.SketchSample{Key: sketchKey, Bin: stats.SketchBin(durationMs), At: at}
+}
+
+// parseBaselineKey decodes a baseline key (see domain.SeriesKey).
+func parseBaselineKey(key string) (service, endpoint string, bucket domain.TimeBucket, err error) {
+    sk, perr := domain.ParseSeriesKey(key)
     if perr != nil {
-        err = fmt.Errorf("invalid hour in key: %w", perr)
+        err = fmt.Errorf("invalid baseline key: %w", perr)
+        return
+    }
+    if sk.Kind != domain.KindBaseline {
+        err = fmt.Errorf("invalid baseline key kind: %s", key)
         return
     }
-    hour = h
-    dayType = parts[3]
-    return
+    return sk.Service, sk.Name, sk.Bucket, nil
 }
-
@@ -3,7 +3,6 @@ package service
 import (
     "context"
     "fmt"
-    "sort"
     "strings"
     "time"
 
@@ -16,11 +15,16 @@ import (
 type BaselineLookup struct {
     store store.Store
     cfg   *config.Config
+    cache *fallbackCache
 }
 
 // NewBaselineLookup constructs a new BaselineLookup service.
 func NewBaselineLookup(store store.Store, cfg *config.Config) *BaselineLookup {
-    return &BaselineLookup{store: store, cfg: cfg}
+    bl := &BaselineLookup{store: store, cfg: cfg}
+    if cfg != nil {
+        bl.cache = newFallbackCache(cfg.Fallback.CacheTTL)
+    }
+    return bl
 }
 
 // BaselineResult represents the outcome of a baseline lookup with fallback.
@@ -40,28 +44,61 @@ func (bl *BaselineLookup) LookupWithFallback(
     service, endpoint string,
     bucket domain.TimeBucket,
 ) (*BaselineResult, error) {
+    return bl.LookupAt(ctx, service, endpoint, bucket, time.Time{})
+}
+
+// LookupAt is LookupWithFallback for the bucket of the instant at (in the
+// series' local time). Nearby hours are then picked by elapsed time around at,
+// which differs from the bucket's usual neighbours on DST transition days. A zero
+// at behaves like LookupWithFallback.
+func (bl *BaselineLookup) LookupAt(
+    ctx context.Context,
+    service, endpoint string,
+    bucket domain.TimeBucket,
+    at time.Time,
+) (*BaselineResult, error) {
+    // Rules may override min_samples and the enabled fallback levels per series
+    var set config.Settings
+    if bl.cfg != nil {
+        set = bl.cfg.SettingsFor(service, endpoint)
+    }
+
     // Level 1: Exact hour | dayType match
-    if res := bl.tryExactMatch(ctx, service, endpoint, bucket); res != nil {
+    if res := bl.tryExactMatch(ctx, service, endpoint, bucket, set.Stats.MinSamples); res != nil {
         return res, nil
     }
 
     // Level 2: Nearby hours within configured range
-    if bl.cfg != nil && bl.cfg.Fallback.NearbyHoursEnabled {
-        if res := bl.tryNearbyHours(ctx, service, endpoint, bucket); res != nil {
+    if set.Fallback.NearbyHoursEnabled {
+        // DST transition days have their own neighbours, which are not cached per bucket
+        nearby, usual := nearbyBuckets(bl.cfg, bucket, at)
+        var res *BaselineResult
+        if usual {
+            res = bl.cache.get(ctx, 2, service, endpoint, bucket, func() *BaselineResult {
+                return bl.tryNearbyHours(ctx, service, endpoint, bucket, nearby, set)
+            })
+        } else {
+            res = bl.tryNearbyHours(ctx, service, endpoint, bucket, nearby, set)
+        }
+        if res != nil {
             return res, nil
         }
     }
 
     // Level 3: Day type global (all hours for same day type)
-    if bl.cfg != nil && bl.cfg.Fallback.DayTypeGlobalEnabled {
-        if res := bl.tryDayTypeGlobal(ctx, service, endpoint, bucket.DayType); res != nil {
+    if set.Fallback.DayTypeGlobalEnabled {
+        if res := bl.cache.get(ctx, 3, service, endpoint, domain.TimeBucket{DayType: bucket.DayType}, func() *BaselineResult {
+            return bl.tryDayTypeGlobal(ctx, service, endpoint, bucket.DayType, set)
+        }); res != nil {
             return res, nil
         }
     }
 
     // Level 4: Full global (all data, any hour/dayType)
-    if bl.cfg != nil && bl.cfg.Fallback.FullGlobalEnabled {
-        if res := bl.tryFullGlobal(ctx, service, endpoint); res != nil {
+    if set.Fallback.FullGlobalEnabled {
+        if res := bl.cache.get(ctx, 4, service, endpoint, domain.TimeBucket{}, func() *BaselineResult {
+            return bl.tryFullGlobal(ctx, service, endpoint, set)
+        }); res != nil {
             return res, nil
         }
     }
@@ -77,7 +114,7 @@ func (bl *BaselineLookup) LookupWithFallback(
 }
 
 // tryExactMatch attempts Level 1 exact match baseline: base:{service}|{endpoint}|{hour}|{dayType}
-func (bl *BaselineLookup) tryExactMatch(ctx context.Context, service, endpoint string, bucket domain.TimeBucket) *BaselineResult {
+func (bl *BaselineLookup) tryExactMatch(ctx context.Context, service, endpoint string, bucket domain.TimeBucket, minSamples int) *BaselineResult {
     if bl == nil || bl.store == nil || bl.cfg == nil {
         return nil
     }
@@ -87,10 +124,10 @@ func (bl *BaselineLookup) tryExactMatch(ctx context.Context, service, endpoint s
     if err != nil || b == nil {
         return nil
     }
-    if b.SampleCount < bl.cfg.Stats.MinSamples {
+    if b.SampleCount < minSamples {
         return nil
     }
-    detail := fmt.Sprintf("exact match: %d|%s", bucket.Hour, bucket.DayType)
+    detail := "exact match: " + bucket.Label()
     return &BaselineResult{
         Baseline:      b,
         Source:        domain.SourceExact,
@@ -100,34 +137,19 @@ func (bl *BaselineLookup) tryExactMatch(ctx context.Context, service, endpoint s
 }
 
 // tryNearbyHours attempts Level 2 nearby hours aggregation within configured ±range.
-func (bl *BaselineLookup) tryNearbyHours(ctx context.Context, service, endpoint string, bucket domain.TimeBucket) *BaselineResult {
+func (bl *BaselineLookup) tryNearbyHours(ctx context.Context, service, endpoint string, bucket domain.TimeBucket, nearby []domain.TimeBucket, set config.Settings) *BaselineResult {
     if bl == nil || bl.store == nil || bl.cfg == nil {
         return nil
     }
 
-    r := bl.cfg.Fallback.NearbyHoursRange
-    if r <= 0 {
-        return nil
-    }
-
-    // Generate neighbor hours in the sequence: ±1, ±2, ... within [0,23] with wrap-around
+    // Neighbor slots in the sequence ±1, ±2, ... on the same day type (see nearbyBuckets)
     type hk struct {
-        key  string
-        hour int
+        key    string
+        bucket domain.TimeBucket
     }
     var keys []hk
-    seen := make(map[int]bool)
-    center := bucket.Hour
-    wrap := func(h int) int { return (h%24 + 24) % 24 }
-    for d := 1; d <= r; d++ {
-        for _, h := range []int{wrap(center - d), wrap(center + d)} {
-            if seen[h] {
-                continue
-            }
-            seen[h] = true
-            k := domain.MakeBaselineKey(service, endpoint, domain.TimeBucket{Hour: h, DayType: bucket.DayType})
-            keys = append(keys, hk{key: k, hour: h})
-        }
+    for _, nb := range nearby {
+        keys = append(keys, hk{key: domain.MakeBaselineKey(service, endpoint, nb), bucket: nb})
     }
     if len(keys) == 0 {
         return nil
@@ -143,47 +165,35 @@ func (bl *BaselineLookup) tryNearbyHours(ctx context.Context, service, endpoint
         return nil
     }
 
-    // Aggregate weighted by sample count
+    // Collect the buckets that have data
     var totalSamples int
-    var sumP50, sumP95, sumMAD float64
+    var usedKeys []string
     var latest time.Time
-    var usedHours []int
+    var usedSlots []string
     for _, it := range keys { // keep order as generated (±1, then ±2, ...)
         b := m[it.key]
         if b == nil || b.SampleCount <= 0 {
             continue
         }
         totalSamples += b.SampleCount
-        sumP50 += b.P50 * float64(b.SampleCount)
-        sumP95 += b.P95 * float64(b.SampleCount)
-        sumMAD += b.MAD * float64(b.SampleCount)
+        usedKeys = append(usedKeys, it.key)
         if b.UpdatedAt.After(latest) {
             latest = b.UpdatedAt
         }
-        usedHours = append(usedHours, it.hour)
+        usedSlots = append(usedSlots, it.bucket.SlotLabel())
     }
-    if totalSamples < bl.cfg.Fallback.NearbyMinSamples {
+    if totalSamples < set.Fallback.NearbyMinSamples {
         return nil
     }
     if totalSamples == 0 {
         return nil
     }
 
-    // Details: list used hours in order, compact with comma
-    // Sort hours by closeness to center for nicer details (1, -1, 2, -2 order already maintained)
-    // but ensure deterministic formatting: 0-23 ascending by our generated order
-    detailParts := make([]string, 0, len(usedHours))
-    for _, h := range usedHours {
-        detailParts = append(detailParts, fmt.Sprintf("%d", h))
-    }
-    details := fmt.Sprintf("nearby hours: %s (%s)", strings.Join(detailParts, ","), bucket.DayType)
+    details := fmt.Sprintf("nearby hours: %s (%s)", strings.Join(usedSlots, ","), bucket.DayType)
 
-    agg := &store.Baseline{
-        P50:         sumP50 / float64(totalSamples),
-        P95:         sumP95 / float64(totalSamples),
-        MAD:         sumMAD / float64(totalSamples),
-        SampleCount: totalSamples,
-        UpdatedAt:   latest,
+    agg, err := mergedBaseline(ctx, bl.store, bl.cfg, set.Stats, usedKeys, m, latest)
+    if err != nil {
+        return nil
     }
     return &BaselineResult{
         Baseline:      agg,
@@ -194,15 +204,16 @@ func (bl *BaselineLookup) tryNearbyHours(ctx context.Context, service, endpoint
 }
 
 // tryDayTypeGlobal attempts Level 3 day-type global aggregation (all hours of same day type).
-func (bl *BaselineLookup) tryDayTypeGlobal(ctx context.Context, service, endpoint string, dayType string) *BaselineResult {
+func (bl *BaselineLookup) tryDayTypeGlobal(ctx context.Context, service, endpoint string, dayType string, set config.Settings) *BaselineResult {
     if bl == nil || bl.store == nil || bl.cfg == nil {
         return nil
     }
 
-    // Build all 24 hour keys for the given dayType
-    rawKeys := make([]string, 0, 24)
-    for h := 0; h < 24; h++ {
-        rawKeys = append(rawKeys, domain.MakeBaselineKey(service, endpoint, domain.TimeBucket{Hour: h, DayType: dayType}))
+    // Build the keys of every slot of the given dayType
+    buckets := bl.cfg.TimeBuckets().DayBuckets(dayType)
+    rawKeys := make([]string, 0, len(buckets))
+    for _, b := range buckets {
+        rawKeys = append(rawKeys, domain.MakeBaselineKey(service, endpoint, b))
     }
     m, err := bl.store.GetBaselines(ctx, rawKeys)
     if err != nil || len(m) == 0 {
@@ -210,45 +221,34 @@ func (bl *BaselineLookup) tryDayTypeGlobal(ctx context.Context, service, endpoin
     }
 
     var totalSamples int
-    var sumP50, sumP95, sumMAD float64
+    var usedKeys []string
     var latest time.Time
-    var usedHours []int
-    for h := 0; h < 24; h++ {
-        k := domain.MakeBaselineKey(service, endpoint, domain.TimeBucket{Hour: h, DayType: dayType})
+    var usedSlots []string
+    for i, k := range rawKeys {
         b := m[k]
         if b == nil || b.SampleCount <= 0 {
             continue
         }
         totalSamples += b.SampleCount
-        sumP50 += b.P50 * float64(b.SampleCount)
-        sumP95 += b.P95 * float64(b.SampleCount)
-        sumMAD += b.MAD * float64(b.SampleCount)
+        usedKeys = append(usedKeys, k)
         if b.UpdatedAt.After(latest) {
             latest = b.UpdatedAt
         }
-        usedHours = append(usedHours, h)
+        usedSlots = append(usedSlots, buckets[i].SlotLabel())
     }
-    if totalSamples < bl.cfg.Fallback.DayTypeGlobalMinSamples {
+    if totalSamples < set.Fallback.DayTypeGlobalMinSamples {
         return nil
     }
     if totalSamples == 0 {
         return nil
     }
 
-    // For readability, sort used hours ascending
-    sort.Ints(usedHours)
-    parts := make([]string, 0, len(usedHours))
-    for _, h := range usedHours {
-        parts = append(parts, fmt.Sprintf("%d", h))
-    }
-    details := fmt.Sprintf("daytype=%s hours=%s", dayType, strings.Join(parts, ","))
+    // Slots are listed in time order
+    details := fmt.Sprintf("daytype=%s hours=%s", dayType, strings.Join(usedSlots, ","))
 
-    agg := &store.Baseline{
-        P50:         sumP50 / float64(totalSamples),
-        P95:         sumP95 / float64(totalSamples),
-        MAD:         sumMAD / float64(totalSamples),
-        SampleCount: totalSamples,
-        UpdatedAt:   latest,
+    agg, err := mergedBaseline(ctx, bl.store, bl.cfg, set.Stats, usedKeys, m, latest)
+    if err != nil {
+        return nil
     }
     return &BaselineResult{
         Baseline:      agg,
@@ -259,17 +259,15 @@ func (bl *BaselineLookup) tryDayTypeGlobal(ctx context.Context, service, endpoin
 }
 
 // tryFullGlobal attempts Level 4 full global aggregation (all data for service/endpoint).
-func (bl *BaselineLookup) tryFullGlobal(ctx context.Context, service, endpoint string) *BaselineResult {
+func (bl *BaselineLookup) tryFullGlobal(ctx context.Context, service, endpoint string, set config.Settings) *BaselineResult {
     if bl == nil || bl.store == nil || bl.cfg == nil {
         return nil
     }
 
-    dayTypes := []string{"weekday", "weekend"}
-    rawKeys := make([]string, 0, 48)
-    for _, dt := range dayTypes {
-        for h := 0; h < 24; h++ {
-            rawKeys = append(rawKeys, domain.MakeBaselineKey(service, endpoint, domain.TimeBucket{Hour: h, DayType: dt}))
-        }
+    buckets := bl.cfg.TimeBuckets().All()
+    rawKeys := make([]string, 0, len(buckets))
+    for _, b := range buckets {
+        rawKeys = append(rawKeys, domain.MakeBaselineKey(service, endpoint, b))
     }
     m, err := bl.store.GetBaselines(ctx, rawKeys)
     if err != nil || len(m) == 0 {
@@ -277,7 +275,7 @@ func (bl *BaselineLookup) tryFullGlobal(ctx context.Context, service, endpoint s
     }
 
     var totalSamples int
-    var sumP50, sumP95, sumMAD float64
+    var usedKeys []string
     var latest time.Time
     for _, k := range rawKeys {
         b := m[k]
@@ -285,26 +283,21 @@ func (bl *BaselineLookup) tryFullGlobal(ctx context.Context, service, endpoint s
             continue
         }
         totalSamples += b.SampleCount
-        sumP50 += b.P50 * float64(b.SampleCount)
-        sumP95 += b.P95 * float64(b.SampleCount)
-        sumMAD += b.MAD * float64(b.SampleCount)
+        usedKeys = append(usedKeys, k)
         if b.UpdatedAt.After(latest) {
             latest = b.UpdatedAt
         }
     }
-    if totalSamples < bl.cfg.Fallback.FullGlobalMinSamples {
+    if totalSamples < set.Fallback.FullGlobalMinSamples {
         return nil
     }
     if totalSamples == 0 {
         return nil
     }
 
-    agg := &store.Baseline{
-        P50:         sumP50 / float64(totalSamples),
-        P95:         sumP95 / float64(totalSamples),
-        MAD:         sumMAD / float64(totalSamples),
-        SampleCount: totalSamples,
-        UpdatedAt:   latest,
+    agg, err := mergedBaseline(ctx, bl.store, bl.cfg, set.Stats, usedKeys, m, latest)
+    if err != nil {
+        return nil
     }
     return &BaselineResult{
         Baseline:      agg,
@@ -2,11 +2,13 @@ package service
 
 import (
     "context"
+    "slices"
     "testing"
     "time"
 
     "github.com/alexchang/tempo-latency-anomaly-service/internal/config"
     "github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/stats"
     "github.com/alexchang/tempo-latency-anomaly-service/internal/store"
     smocks "github.com/alexchang/tempo-latency-anomaly-service/internal/store/mocks"
     "github.com/stretchr/testify/assert"
@@ -54,7 +56,16 @@ func TestBaselineLookup_Level1_ExactMatch(t *testing.T) {
     m.AssertExpectations(t)
 }
 
-func TestBaselineLookup_Level2_NearbyHoursWeighted(t *testing.T) {
+// sketchOf returns a one-day sketch of n samples of durationMs each.
+func sketchOf(durationMs int64, n int) []store.SketchSlice {
+    sk := stats.NewSketch()
+    for i := 0; i < n; i++ {
+        sk.Add(durationMs)
+    }
+    return []store.SketchSlice{{Day: store.SketchDay(time.Now()), Bins: sk.Bins()}}
+}
+
+func TestBaselineLookup_Level2_NearbyHoursMergesSamples(t *testing.T) {
     ctx := context.Background()
     cfg := blCfg()
     m := new(smocks.MockStore)
@@ -64,15 +75,22 @@ func TestBaselineLookup_Level2_NearbyHoursWeighted(t *testing.T) {
     // Level1 miss
     m.On("GetBaseline", mock.Anything, mock.Anything).Return((*store.Baseline)(nil), nil)
 
-    // Provide ±1 hours with different sample weights
-    k9 := domain.MakeBaselineKey(svc, ep, domain.TimeBucket{Hour: 9, DayType: "weekday"})
-    k11 := domain.MakeBaselineKey(svc, ep, domain.TimeBucket{Hour: 11, DayType: "weekday"})
+    // Provide ±1 hours; hour 11 is slow
+    b9 := domain.TimeBucket{Hour: 9, DayType: "weekday"}
+    b11 := domain.TimeBucket{Hour: 11, DayType: "weekday"}
     latest := time.Now().UTC()
     mp := map[string]*store.Baseline{
-        k9:  {P50: 100, P95: 300, MAD: 10, SampleCount: 10, UpdatedAt: latest.Add(-time.Hour)},
-        k11: {P50: 200, P95: 400, MAD: 20, SampleCount: 30, UpdatedAt: latest},
+        domain.MakeBaselineKey(svc, ep, b9):  {P50: 100, P95: 100, SampleCount: 30, UpdatedAt: latest.Add(-time.Hour)},
+        domain.MakeBaselineKey(svc, ep, b11): {P50: 1000, P95: 1000, SampleCount: 10, UpdatedAt: latest},
     }
     m.On("GetBaselines", mock.Anything, mock.Anything).Return(mp, nil)
+    m.On("GetSketches", mock.Anything, []string{
+        domain.MakeSketchKey(svc, ep, b9),
+        domain.MakeSketchKey(svc, ep, b11),
+    }, time.Time{}).Return(map[string][]store.SketchSlice{
+        domain.MakeSketchKey(svc, ep, b9):  sketchOf(100, 30),
+        domain.MakeSketchKey(svc, ep, b11): sketchOf(1000, 10),
+    }, nil)
 
     bl := NewBaselineLookup(m, cfg)
     res, err := bl.LookupWithFallback(ctx, svc, ep, bucket)
@@ -80,13 +98,10 @@ func TestBaselineLookup_Level2_NearbyHoursWeighted(t *testing.T) {
         assert.Equal(t, domain.SourceNearby, res.Source)
         assert.Equal(t, 2, res.FallbackLevel)
         if assert.NotNil(t, res.Baseline) {
-            // Weighted by sample counts: totals = 40
-            // P50 = (100*10 + 200*30) / 40 = 175
-            // P95 = (300*10 + 400*30) / 40 = 375
-            // MAD = (10*10 + 20*30) / 40 = 17.5
-            assert.InDelta(t, 175.0, res.Baseline.P50, 1e-9)
-            assert.InDelta(t, 375.0, res.Baseline.P95, 1e-9)
-            assert.InDelta(t, 17.5, res.Baseline.MAD, 1e-9)
+            // Percentiles of the 40 merged samples: the slow hour's quarter of the
+            // traffic sets P95, where a weighted average would report 325.
+            assert.InEpsilon(t, 100.0, res.Baseline.P50, stats.SketchRelativeAccuracy)
+            assert.InEpsilon(t, 1000.0, res.Baseline.P95, stats.SketchRelativeAccuracy)
             assert.Equal(t, 40, res.Baseline.SampleCount)
             assert.Equal(t, latest, res.Baseline.UpdatedAt)
         }
@@ -95,6 +110,143 @@ func TestBaselineLookup_Level2_NearbyHoursWeighted(t *testing.T) {
     m.AssertExpectations(t)
 }
 
+func TestBaselineLookup_Level2_ImportedBaselinesOnly(t *testing.T) {
+    ctx := context.Background()
+    cfg := blCfg()
+    m := new(smocks.MockStore)
+
+    svc, ep := "svcA", "GET /foo"
+    bucket := domain.TimeBucket{Hour: 10, DayType: "weekday"}
+    m.On("GetBaseline", mock.Anything, mock.Anything).Return((*store.Baseline)(nil), nil)
+
+    // Baselines imported from a snapshot without samples: nothing to merge
+    b9 := domain.TimeBucket{Hour: 9, DayType: "weekday"}
+    b11 := domain.TimeBucket{Hour: 11, DayType: "weekday"}
+    latest := time.Now().UTC()
+    m.On("GetBaselines", mock.Anything, mock.Anything).Return(map[string]*store.Baseline{
+        domain.MakeBaselineKey(svc, ep, b9):  {P50: 100, P95: 200, MAD: 10, SampleCount: 30, UpdatedAt: latest, Percentiles: map[string]float64{"p99": 300}},
+        domain.MakeBaselineKey(svc, ep, b11): {P50: 200, P95: 400, MAD: 30, SampleCount: 10, UpdatedAt: latest, Percentiles: map[string]float64{"p99": 700}},
+    }, nil)
+    m.On("GetSketches", mock.Anything, mock.Anything, time.Time{}).Return(map[string][]store.SketchSlice{}, nil)
+    m.On("GetSamples", mock.Anything, mock.Anything, time.Time{}).Return([]store.Sample(nil), nil)
+
+    res, err := NewBaselineLookup(m, cfg).LookupWithFallback(ctx, svc, ep, bucket)
+    if assert.NoError(t, err) && assert.NotNil(t, res) && assert.NotNil(t, res.Baseline) {
+        assert.Equal(t, 2, res.FallbackLevel)
+        assert.Equal(t, 125.0, res.Baseline.P50)
+        assert.Equal(t, 250.0, res.Baseline.P95)
+        assert.Equal(t, 15.0, res.Baseline.MAD)
+        assert.Equal(t, 400.0, res.Baseline.Percentiles["p99"])
+        assert.Equal(t, 40, res.Baseline.SampleCount)
+    }
+    m.AssertExpectations(t)
+}
+
+func TestBaselineLookup_Level2_DecayedModel(t *testing.T) {
+    ctx := context.Background()
+    cfg := blCfg()
+    cfg.WindowSize = 1000
+    cfg.Rules = []config.Rule{{Service: "svcA", Model: config.StatsModelDecayed, HalfLife: 24 * time.Hour}}
+    m := new(smocks.MockStore)
+
+    svc, ep := "svcA", "GET /foo"
+    bucket := domain.TimeBucket{Hour: 10, DayType: "weekday"}
+    m.On("GetBaseline", mock.Anything, mock.Anything).Return((*store.Baseline)(nil), nil)
+
+    b9 := domain.TimeBucket{Hour: 9, DayType: "weekday"}
+    b11 := domain.TimeBucket{Hour: 11, DayType: "weekday"}
+    latest := time.Now().UTC()
+    m.On("GetBaselines", mock.Anything, mock.Anything).Return(map[string]*store.Baseline{
+        domain.MakeBaselineKey(svc, ep, b9):  {P50: 100, SampleCount: 60, UpdatedAt: latest},
+        domain.MakeBaselineKey(svc, ep, b11): {P50: 300, SampleCount: 40, UpdatedAt: latest},
+    }, nil)
+
+    // Hour 9 still holds 60 samples from before a deploy three days ago, hour 11
+    // has 40 since; unweighted, the old samples would hold the median at 100ms
+    now := time.Now()
+    var old, recent []store.Sample
+    for i := 0; i < 40; i++ {
+        recent = append(recent, store.Sample{At: now.Add(-time.Duration(i) * time.Minute), DurationMs: 300})
+    }
+    for i := 0; i < 60; i++ {
+        old = append(old, store.Sample{At: now.Add(-72*time.Hour - time.Duration(i)*time.Minute), DurationMs: 100})
+    }
+    m.On("GetSamples", mock.Anything, domain.MakeDurationKey(svc, ep, b9), time.Time{}).Return(old, nil)
+    m.On("GetSamples", mock.Anything, domain.MakeDurationKey(svc, ep, b11), time.Time{}).Return(recent, nil)
+
+    res, err := NewBaselineLookup(m, cfg).LookupWithFallback(ctx, svc, ep, bucket)
+    if assert.NoError(t, err) && assert.NotNil(t, res) && assert.NotNil(t, res.Baseline) {
+        assert.Equal(t, 2, res.FallbackLevel)
+        assert.Equal(t, 300.0, res.Baseline.P50)
+        assert.Equal(t, 100, res.Baseline.SampleCount)
+    }
+    m.AssertNotCalled(t, "GetSketches", mock.Anything, mock.Anything, mock.Anything)
+    m.AssertExpectations(t)
+}
+
+func TestBaselineLookup_Level2_FollowsSlotBucketing(t *testing.T) {
+    ctx := context.Background()
+    cfg := blCfg()
+    cfg.Bucketing = config.BucketingConfig{Scheme: domain.SchemeSlot, SlotMinutes: 15}
+    cfg.Fallback.NearbyHoursRange = 1
+    m := new(smocks.MockStore)
+
+    svc, ep := "svcA", "GET /foo"
+    bucket := domain.TimeBucket{Hour: 10, DayType: "weekday"}
+    before := domain.TimeBucket{Hour: 9, Minute: 45, DayType: "weekday"}
+    after := domain.TimeBucket{Hour: 10, Minute: 15, DayType: "weekday"}
+    m.On("GetBaseline", mock.Anything, domain.MakeBaselineKey(svc, ep, bucket)).Return((*store.Baseline)(nil), nil)
+
+    // The neighbours are the adjacent 15 minute slots, not the adjacent hours
+    m.On("GetBaselines", mock.Anything, []string{
+        domain.MakeBaselineKey(svc, ep, before),
+        domain.MakeBaselineKey(svc, ep, after),
+    }).Return(map[string]*store.Baseline{
+        domain.MakeBaselineKey(svc, ep, after): {P50: 100, P95: 100, SampleCount: 30},
+    }, nil)
+    m.On("GetSketches", mock.Anything, []string{domain.MakeSketchKey(svc, ep, after)}, time.Time{}).Return(map[string][]store.SketchSlice{
+        domain.MakeSketchKey(svc, ep, after): sketchOf(100, 30),
+    }, nil)
+
+    res, err := NewBaselineLookup(m, cfg).LookupWithFallback(ctx, svc, ep, bucket)
+    if assert.NoError(t, err) && assert.NotNil(t, res) {
+        assert.Equal(t, 2, res.FallbackLevel)
+        assert.Equal(t, "nearby hours: 10h15 (weekday)", res.SourceDetails)
+    }
+    m.AssertExpectations(t)
+}
+
+func TestBaselineLookup_FallbackIsCached(t *testing.T) {
+    ctx := context.Background()
+    cfg := blCfg()
+    cfg.Fallback.CacheTTL = time.Minute
+    m := new(smocks.MockStore)
+
+    svc, ep := "svcA", "GET /foo"
+    bucket := domain.TimeBucket{Hour: 10, DayType: "weekday"}
+    b9 := domain.TimeBucket{Hour: 9, DayType: "weekday"}
+    m.On("GetBaseline", mock.Anything, mock.Anything).Return((*store.Baseline)(nil), nil)
+    m.On("GetBaselines", mock.Anything, mock.Anything).Return(map[string]*store.Baseline{
+        domain.MakeBaselineKey(svc, ep, b9): {P50: 100, P95: 100, SampleCount: 30},
+    }, nil)
+    m.On("GetSketches", mock.Anything, mock.Anything, mock.Anything).Return(map[string][]store.SketchSlice{
+        domain.MakeSketchKey(svc, ep, b9): sketchOf(100, 30),
+    }, nil)
+
+    bl := NewBaselineLookup(m, cfg)
+    first, err := bl.LookupWithFallback(ctx, svc, ep, bucket)
+    assert.NoError(t, err)
+    second, err := bl.LookupWithFallback(ctx, svc, ep, bucket)
+    assert.NoError(t, err)
+    assert.Same(t, first, second)
+    m.AssertNumberOfCalls(t, "GetSketches", 1)
+
+    // Other tenants do not share cached results
+    _, err = bl.LookupWithFallback(store.WithTenant(ctx, "team-a"), svc, ep, bucket)
+    assert.NoError(t, err)
+    m.AssertNumberOfCalls(t, "GetSketches", 2)
+}
+
 func TestBaselineLookup_Level3_DayTypeGlobal(t *testing.T) {
     ctx := context.Background()
     cfg := blCfg()
@@ -109,6 +261,7 @@ func TestBaselineLookup_Level3_DayTypeGlobal(t *testing.T) {
 
     // Reply with a subset of hours with total samples >= min
     data := map[string]*store.Baseline{}
+    sketches := map[string][]store.SketchSlice{}
     hours := []int{1, 5, 9}
     total := 0
     latest := time.Now().UTC()
@@ -118,8 +271,10 @@ func TestBaselineLookup_Level3_DayTypeGlobal(t *testing.T) {
         data[domain.MakeBaselineKey(svc, ep, domain.TimeBucket{Hour: h, DayType: "weekday"})] = &store.Baseline{
             P50: float64(100 + h), P95: float64(200 + h), MAD: float64(10 + i), SampleCount: cnt, UpdatedAt: latest.Add(time.Duration(i) * time.Minute),
         }
+        sketches[domain.MakeSketchKey(svc, ep, domain.TimeBucket{Hour: h, DayType: "weekday"})] = sketchOf(int64(100+h), cnt)
     }
     m.On("GetBaselines", mock.Anything, mock.Anything).Return(data, nil)
+    m.On("GetSketches", mock.Anything, mock.Anything, mock.Anything).Return(sketches, nil)
 
     bl := NewBaselineLookup(m, cfg)
     res, err := bl.LookupWithFallback(ctx, svc, ep, bucket)
@@ -132,6 +287,43 @@ func TestBaselineLookup_Level3_DayTypeGlobal(t *testing.T) {
     m.AssertExpectations(t)
 }
 
+func TestBaselineLookup_Level3_IncludesFoldBucket(t *testing.T) {
+    ctx := context.Background()
+    cfg := blCfg()
+    cfg.Timezone = "America/New_York"
+    cfg.Fallback.NearbyHoursEnabled = false
+    m := new(smocks.MockStore)
+
+    svc, ep := "svcB", "POST /bar"
+    m.On("GetBaseline", mock.Anything, mock.Anything).Return((*store.Baseline)(nil), nil)
+
+    // The second pass of 1:00 on the fall-back day has a baseline of its own
+    first := domain.TimeBucket{Hour: 1, DayType: "weekend"}
+    fold := domain.TimeBucket{Hour: 1, DayType: "weekend", Fold: true}
+    now := time.Now().UTC()
+    data := map[string]*store.Baseline{
+        domain.MakeBaselineKey(svc, ep, first): {P50: 100, P95: 200, MAD: 10, SampleCount: 10, UpdatedAt: now},
+        domain.MakeBaselineKey(svc, ep, fold):  {P50: 300, P95: 400, MAD: 10, SampleCount: 10, UpdatedAt: now},
+    }
+    sketches := map[string][]store.SketchSlice{
+        domain.MakeSketchKey(svc, ep, first): sketchOf(100, 10),
+        domain.MakeSketchKey(svc, ep, fold):  sketchOf(300, 10),
+    }
+    m.On("GetBaselines", mock.Anything, mock.MatchedBy(func(keys []string) bool {
+        return slices.Contains(keys, domain.MakeBaselineKey(svc, ep, fold))
+    })).Return(data, nil)
+    m.On("GetSketches", mock.Anything, mock.Anything, mock.Anything).Return(sketches, nil)
+
+    bl := NewBaselineLookup(m, cfg)
+    res, err := bl.LookupWithFallback(ctx, svc, ep, domain.TimeBucket{Hour: 20, DayType: "weekend"})
+    if assert.NoError(t, err) && assert.NotNil(t, res) {
+        assert.Equal(t, 3, res.FallbackLevel)
+        assert.Equal(t, 20, res.Baseline.SampleCount)
+        assert.Equal(t, "daytype=weekend hours=1,1+", res.SourceDetails)
+    }
+    m.AssertExpectations(t)
+}
+
 func TestBaselineLookup_Level4_FullGlobal(t *testing.T) {
     ctx := context.Background()
     cfg := blCfg()
@@ -148,14 +340,17 @@ func TestBaselineLookup_Level4_FullGlobal(t *testing.T) {
     // Supply a couple of keys across both day types
     dt := []string{"weekday", "weekend"}
     mp := map[string]*store.Baseline{}
+    sketches := map[string][]store.SketchSlice{}
     total := 0
     for i, d := range dt {
         k := domain.MakeBaselineKey(svc, ep, domain.TimeBucket{Hour: i, DayType: d})
         cnt := (i + 1) * 10
         total += cnt
         mp[k] = &store.Baseline{P50: float64(100 + i), P95: float64(200 + i), MAD: float64(10 + i), SampleCount: cnt, UpdatedAt: time.Now().UTC()}
+        sketches[domain.MakeSketchKey(svc, ep, domain.TimeBucket{Hour: i, DayType: d})] = sketchOf(int64(100+i), cnt)
     }
     m.On("GetBaselines", mock.Anything, mock.Anything).Return(mp, nil)
+    m.On("GetSketches", mock.Anything, mock.Anything, mock.Anything).Return(sketches, nil)
 
     bl := NewBaselineLookup(m, cfg)
     res, err := bl.LookupWithFallback(ctx, svc, ep, bucket)
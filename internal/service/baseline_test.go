@@ -0,0 +1,181 @@
+package service
+
+import (
+    "context"
+    "math"
+    "testing"
+    "time"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/config"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/stats"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/store/memory"
+    smocks "github.com/alexchang/tempo-latency-anomaly-service/internal/store/mocks"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/mock"
+)
+
+func TestBaseline_RecomputeForKey_RespectsRetention(t *testing.T) {
+    ctx := context.Background()
+    cfg := &config.Config{WindowSize: 1000, Retention: 14 * 24 * time.Hour}
+    m := new(smocks.MockStore)
+    bucket := domain.TimeBucket{Hour: 9, DayType: "weekday"}
+
+    now := time.Now()
+    sinceWithinRetention := mock.MatchedBy(func(since time.Time) bool {
+        d := now.Add(-cfg.Retention).Sub(since)
+        return d > -time.Minute && d < time.Minute
+    })
+    // No sketch yet: it is built from the retained samples
+    m.On("GetSketch", mock.Anything, domain.MakeSketchKey("svc", "GET /a", bucket), sinceWithinRetention).Return(nil, nil)
+    m.On("GetSamples", mock.Anything, domain.MakeDurationKey("svc", "GET /a", bucket), sinceWithinRetention).Return([]store.Sample{
+        {At: now.Add(-time.Hour), DurationMs: 100},
+        {At: now.Add(-2 * time.Hour), DurationMs: 300},
+    }, nil)
+    m.On("ReplaceSketch", mock.Anything, domain.MakeSketchKey("svc", "GET /a", bucket), mock.Anything).Return(nil)
+    m.On("SetBaseline", mock.Anything, domain.MakeBaselineKey("svc", "GET /a", bucket), mock.MatchedBy(func(b store.Baseline) bool {
+        return b.SampleCount == 2 && math.Abs(b.P50-200) <= 200*stats.SketchRelativeAccuracy
+    })).Return(nil)
+
+    bs, err := NewBaseline(m, cfg).RecomputeForKey(ctx, domain.MakeBaselineKey("svc", "GET /a", bucket))
+    assert.NoError(t, err)
+    assert.Equal(t, 2, bs.SampleCount)
+    m.AssertExpectations(t)
+}
+
+func TestBaseline_RecomputeForKey_ReadsSketchUpToWindow(t *testing.T) {
+    ctx := context.Background()
+    cfg := &config.Config{WindowSize: 3}
+    m := new(smocks.MockStore)
+    bucket := domain.TimeBucket{Hour: 9, DayType: "weekday"}
+
+    today := stats.NewSketch()
+    today.Add(100)
+    today.Add(100)
+    yesterday := stats.NewSketch()
+    yesterday.Add(100)
+    older := stats.NewSketch()
+    for i := 0; i < 10; i++ {
+        older.Add(5000)
+    }
+    day := store.SketchDay(time.Now())
+    m.On("GetSketch", mock.Anything, domain.MakeSketchKey("svc", "GET /a", bucket), time.Time{}).Return([]store.SketchSlice{
+        {Day: day, Bins: today.Bins()},
+        {Day: day.Add(-24 * time.Hour), Bins: yesterday.Bins()},
+        {Day: day.Add(-48 * time.Hour), Bins: older.Bins()},
+    }, nil)
+    m.On("SetBaseline", mock.Anything, domain.MakeBaselineKey("svc", "GET /a", bucket), mock.Anything).Return(nil)
+
+    bs, err := NewBaseline(m, cfg).RecomputeForKey(ctx, domain.MakeBaselineKey("svc", "GET /a", bucket))
+    assert.NoError(t, err)
+    // The oldest day is not needed to fill the window
+    assert.Equal(t, 3, bs.SampleCount)
+    assert.InEpsilon(t, 100.0, bs.P95, stats.SketchRelativeAccuracy)
+    m.AssertNotCalled(t, "GetSamples", mock.Anything, mock.Anything, mock.Anything)
+    m.AssertExpectations(t)
+}
+
+func TestBaseline_RecomputeForKey_ScalesDayOvershootingWindow(t *testing.T) {
+    ctx := context.Background()
+    cfg := &config.Config{WindowSize: 10}
+    m := new(smocks.MockStore)
+    bucket := domain.TimeBucket{Hour: 9, DayType: "weekday"}
+
+    today := stats.NewSketch()
+    for i := 0; i < 4; i++ {
+        today.Add(100)
+    }
+    // Only 6 of yesterday's 60 samples fit the window, in the same 1:2 mix
+    yesterday := stats.NewSketch()
+    for i := 0; i < 20; i++ {
+        yesterday.Add(1000)
+    }
+    for i := 0; i < 40; i++ {
+        yesterday.Add(5000)
+    }
+    day := store.SketchDay(time.Now())
+    m.On("GetSketch", mock.Anything, domain.MakeSketchKey("svc", "GET /a", bucket), time.Time{}).Return([]store.SketchSlice{
+        {Day: day, Bins: today.Bins()},
+        {Day: day.Add(-24 * time.Hour), Bins: yesterday.Bins()},
+    }, nil)
+    m.On("SetBaseline", mock.Anything, domain.MakeBaselineKey("svc", "GET /a", bucket), mock.MatchedBy(func(b store.Baseline) bool {
+        return b.SampleCount == 10
+    })).Return(nil)
+
+    bs, err := NewBaseline(m, cfg).RecomputeForKey(ctx, domain.MakeBaselineKey("svc", "GET /a", bucket))
+    assert.NoError(t, err)
+    assert.Equal(t, 10, bs.SampleCount)
+    // 4 x 100ms, 2 x 1000ms, 4 x 5000ms: yesterday no longer outweighs today
+    assert.InEpsilon(t, 1000.0, bs.P50, stats.SketchRelativeAccuracy)
+    m.AssertExpectations(t)
+}
+
+func TestBaseline_RecomputeForKey_DecayedModel(t *testing.T) {
+    ctx := context.Background()
+    // Only GET /a uses the decayed model
+    cfg := &config.Config{
+        WindowSize: 1000,
+        Stats:      config.StatsConfig{Model: config.StatsModelWindow},
+        Rules:      []config.Rule{{Service: "svc", Endpoint: "GET /a", Model: config.StatsModelDecayed, HalfLife: 24 * time.Hour}},
+    }
+    m := new(smocks.MockStore)
+    bucket := domain.TimeBucket{Hour: 9, DayType: "weekday"}
+
+    // A deploy two days ago moved latency from 100ms to 300ms: 40 new samples
+    // against 60 older ones, which would still hold the window's median at 100ms
+    now := time.Now()
+    var samples []store.Sample
+    for i := 0; i < 40; i++ {
+        samples = append(samples, store.Sample{At: now.Add(-time.Duration(i) * time.Hour), DurationMs: 300})
+    }
+    for i := 0; i < 60; i++ {
+        samples = append(samples, store.Sample{At: now.Add(-72*time.Hour - time.Duration(i)*time.Hour), DurationMs: 100})
+    }
+    m.On("GetSamples", mock.Anything, domain.MakeDurationKey("svc", "GET /a", bucket), time.Time{}).Return(samples, nil)
+    m.On("SetBaseline", mock.Anything, domain.MakeBaselineKey("svc", "GET /a", bucket), mock.MatchedBy(func(b store.Baseline) bool {
+        return b.SampleCount == 100 && b.P50 == 300
+    })).Return(nil)
+
+    bs, err := NewBaseline(m, cfg).RecomputeForKey(ctx, domain.MakeBaselineKey("svc", "GET /a", bucket))
+    assert.NoError(t, err)
+    assert.Equal(t, 300.0, bs.P50)
+    assert.Equal(t, 300.0, bs.P95)
+    assert.Equal(t, 100, bs.SampleCount)
+    m.AssertNotCalled(t, "GetSketch", mock.Anything, mock.Anything, mock.Anything)
+    m.AssertExpectations(t)
+}
+
+func TestBaseline_BackfillSketches_AfterFirstIngest(t *testing.T) {
+    ctx := context.Background()
+    cfg := &config.Config{WindowSize: 1000, Timezone: "UTC"}
+    st := memory.New()
+    bucket := domain.TimeBucket{Hour: 9, DayType: "weekday"}
+    durKey := domain.MakeDurationKey("svc", "GET /a", bucket)
+    baseKey := domain.MakeBaselineKey("svc", "GET /a", bucket)
+
+    // A window written before sketches existed
+    now := time.Now()
+    for i := 0; i < 501; i++ {
+        assert.NoError(t, st.AppendDuration(ctx, durKey, 100, now.Add(-time.Duration(i+1)*time.Minute), cfg.WindowSize))
+    }
+    // The first ingest after the upgrade creates the sketch with its one sample
+    assert.NoError(t, st.IngestBatch(ctx, store.IngestBatch{
+        Samples:    []store.DurationSample{{Key: durKey, DurationMs: 120, At: now}},
+        Sketches:   []store.SketchSample{sketchSample(domain.MakeSketchKey("svc", "GET /a", bucket), 120, now)},
+        DirtyKeys:  []string{baseKey},
+        WindowSize: cfg.WindowSize,
+    }))
+
+    n, err := NewBaseline(st, cfg).BackfillSketches(ctx)
+    assert.NoError(t, err)
+    assert.Equal(t, 1, n)
+    bs, err := NewBaseline(st, cfg).RecomputeForKey(ctx, baseKey)
+    assert.NoError(t, err)
+    assert.Equal(t, 502, bs.SampleCount)
+
+    // Complete sketches are left alone
+    n, err = NewBaseline(st, cfg).BackfillSketches(ctx)
+    assert.NoError(t, err)
+    assert.Equal(t, 0, n)
+}
@@ -26,15 +26,18 @@ func (s *Check) Evaluate(ctx context.Context, req domain.AnomalyCheckRequest) (d
 		return domain.AnomalyCheckResponse{}, fmt.Errorf("check service not initialized")
 	}
 
-	// Convert int64 timestamp to string for ParseTimeBucket
+	// Convert int64 timestamp to string for LocalTime
 	timestampStr := fmt.Sprintf("%d", req.TimestampNano)
-	bucket, err := domain.ParseTimeBucket(timestampStr, s.cfg.Timezone)
+	// Buckets are in the service's local time, matching ingestion
+	tz := s.cfg.TimezoneFor(req.Service)
+	at, err := domain.LocalTime(timestampStr, tz)
 	if err != nil {
 		return domain.AnomalyCheckResponse{}, fmt.Errorf("parse time bucket: %w", err)
 	}
+	bucket := s.cfg.TimeBuckets().BucketAt(at)
 
 	// Use BaselineLookup with fallback strategy
-	res, err := s.baselineLookup.LookupWithFallback(ctx, req.Service, req.Endpoint, bucket)
+	res, err := s.baselineLookup.LookupAt(ctx, req.Service, req.Endpoint, bucket, at)
 	if err != nil {
 		return domain.AnomalyCheckResponse{}, fmt.Errorf("lookup baseline: %w", err)
 	}
@@ -44,7 +47,8 @@ func (s *Check) Evaluate(ctx context.Context, req domain.AnomalyCheckRequest) (d
 	}
 
 	// Prepare response scaffolding
-	resp := domain.AnomalyCheckResponse{Bucket: bucket}
+	set := s.cfg.SettingsFor(req.Service, req.Endpoint)
+	resp := domain.AnomalyCheckResponse{Bucket: bucket, Timezone: tz, Rule: set.Rule}
 	if res != nil {
 		resp.BaselineSource = res.Source
 		resp.FallbackLevel = res.FallbackLevel
@@ -58,28 +62,39 @@ func (s *Check) Evaluate(ctx context.Context, req domain.AnomalyCheckRequest) (d
 			MAD:         b.MAD,
 			SampleCount: b.SampleCount,
 			UpdatedAt:   b.UpdatedAt,
+			Percentiles: b.Percentiles,
+			Rejected:    b.Rejected,
 		}
 	}
 
 	// Insufficient baseline data
-	if b == nil || b.SampleCount < s.cfg.Stats.MinSamples {
+	if lacksBaseline(set, b) {
 		resp.IsAnomaly = false
 		resp.Explanation = fmt.Sprintf(
 			"no baseline available or insufficient samples (have %d, need >= %d)",
 			valueOrZero(b, func(x *store.Baseline) int { return x.SampleCount }),
-			s.cfg.Stats.MinSamples,
+			set.Stats.MinSamples,
 		)
 		return resp, nil
 	}
 
-	eval := EvaluateDuration(s.cfg, req.DurationMs, b)
+	eval := evaluateDuration(s.cfg, set, req.DurationMs, b)
+	// Detectors that need no baseline decide even when the lookup found none
+	resp.CannotDetermine = false
 	resp.IsAnomaly = eval.IsAnomaly
+	resp.Detector = eval.Detector
+	resp.Score = eval.Score
+	resp.ZScore = eval.ZScore
+	resp.Severity = eval.Severity
 	resp.Explanation = eval.Explanation
 	return resp, nil
 }
 
 // AnnotateTraces adds IsAnomaly to each TraceEvent using time-bucketed baselines.
 // It batches baseline lookups per unique (service|endpoint|hour|dayType) bucket to reduce store calls.
+// Unless error-rate detection is disabled, each trace also gets the error-rate
+// decision of its bucket on its day (ErrorRateAnomaly, ErrorRatio), read once per
+// bucket and day.
 //
 // Note: baseline keys are derived from each trace's RootServiceName/RootTraceName
 // (the same pair used during ingestion to build baselines).
@@ -92,8 +107,12 @@ func (s *Check) AnnotateTraces(ctx context.Context, traces []domain.TraceEvent)
 	out := make([]domain.TraceEvent, len(traces))
 	copy(out, traces)
 
-	// Cache baseline results by key "service|endpoint|hour|dayType".
+	// Cache baseline results by key "service|endpoint|hour|dayType", the
+	// resolved rule settings by "service|endpoint" and error-rate decisions by
+	// error key and day.
 	cache := make(map[string]*BaselineResult, 16)
+	settings := make(map[string]config.Settings, 16)
+	errorRates := make(map[string]domain.ErrorRateCheckResponse, 16)
 
 	for i := range out {
 		// Use trace's own root identifiers to match ingestion baseline keys.
@@ -111,16 +130,35 @@ func (s *Check) AnnotateTraces(ctx context.Context, traces []domain.TraceEvent)
 			continue
 		}
 
-		bucket, err := domain.ParseTimeBucket(fmt.Sprintf("%d", tsNano), s.cfg.Timezone)
+		at, err := domain.LocalTime(fmt.Sprintf("%d", tsNano), s.cfg.TimezoneFor(svc))
 		if err != nil {
 			out[i].IsAnomaly = false
 			continue
 		}
+		bucket := s.cfg.TimeBuckets().BucketAt(at)
+
+		// The date keeps DST transition days, whose nearby hours differ, apart
+		seriesID := domain.SeriesKey{Service: svc, Name: ep}.SeriesID()
+		bucketKey := seriesID + "|" + bucket.Label() + "|" + at.Format(domain.DateLayout)
+
+		if s.cfg.ErrorRate.Z > 0 {
+			errKey := domain.MakeErrorKey(svc, ep, bucket)
+			dayKey := errKey + "|" + store.SketchDay(at).Format(domain.DateLayout)
+			er, ok := errorRates[dayKey]
+			if !ok {
+				er, err = errorRateAt(ctx, s.store, s.cfg, errKey, at, nil)
+				if err != nil {
+					return nil, fmt.Errorf("error rate: %w", err)
+				}
+				errorRates[dayKey] = er
+			}
+			out[i].ErrorRateAnomaly = er.IsAnomaly
+			out[i].ErrorRatio = er.Observed.Ratio
+		}
 
-		bucketKey := fmt.Sprintf("%s|%s|%d|%s", svc, ep, bucket.Hour, bucket.DayType)
 		res, ok := cache[bucketKey]
 		if !ok {
-			res, err = s.baselineLookup.LookupWithFallback(ctx, svc, ep, bucket)
+			res, err = s.baselineLookup.LookupAt(ctx, svc, ep, bucket, at)
 			if err != nil {
 				return nil, fmt.Errorf("lookup baseline: %w", err)
 			}
@@ -132,14 +170,24 @@ func (s *Check) AnnotateTraces(ctx context.Context, traces []domain.TraceEvent)
 			b = res.Baseline
 		}
 
-		// If baseline is missing or insufficient, don't flag anomalies.
-		if b == nil || b.SampleCount < s.cfg.Stats.MinSamples {
+		set, ok := settings[seriesID]
+		if !ok {
+			set = s.cfg.SettingsFor(svc, ep)
+			settings[seriesID] = set
+		}
+		out[i].Rule = set.Rule
+
+		// If the detector needs a baseline and it is missing or insufficient, don't flag anomalies.
+		if lacksBaseline(set, b) {
 			out[i].IsAnomaly = false
 			continue
 		}
 
-		eval := EvaluateDuration(s.cfg, out[i].DurationMs, b)
+		eval := evaluateDuration(s.cfg, set, out[i].DurationMs, b)
 		out[i].IsAnomaly = eval.IsAnomaly
+		out[i].Score = eval.Score
+		out[i].ZScore = eval.ZScore
+		out[i].Severity = eval.Severity
 	}
 
 	return out, nil
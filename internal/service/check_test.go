@@ -25,7 +25,8 @@ func baseCfg() *config.Config {
             K:          3,
             MinSamples: 10,
         },
-        Dedup: config.DedupConfig{TTL: 6 * time.Hour},
+        Dedup:    config.DedupConfig{TTL: 6 * time.Hour},
+        Severity: config.SeverityConfig{Warning: 1, Critical: 2},
         Fallback: config.FallbackConfig{
             Enabled:                 true,
             NearbyHoursEnabled:      false,
@@ -85,12 +86,60 @@ func TestCheck_Evaluate_NormalAndAnomaly(t *testing.T) {
     })
     if assert.NoError(t, err) {
         assert.True(t, resp2.IsAnomaly)
+        assert.Equal(t, DetectorHybrid, resp2.Detector)
         assert.Contains(t, resp2.Explanation, "exceeds")
+        // 350 / 300 and (350 - 100) / (1.4826*20)
+        assert.InDelta(t, 350.0/300, resp2.Score, 1e-9)
+        assert.InDelta(t, 250/(1.4826*20), resp2.ZScore, 1e-9)
+        assert.Equal(t, domain.SeverityWarning, resp2.Severity)
     }
 
     m.AssertExpectations(t)
 }
 
+func TestEvaluateDuration_ThresholdPercentile(t *testing.T) {
+    cfg := baseCfg()
+    cfg.Stats.ThresholdPercentile = "p99"
+    b := &store.Baseline{P50: 100, P95: 200, MAD: 20, SampleCount: 100, Percentiles: map[string]float64{"p99": 300}}
+
+    // threshold = max(p99*factor, p50 + k*MAD) = 450
+    eval := EvaluateDuration(cfg, 400, b)
+    assert.False(t, eval.IsAnomaly)
+    assert.InDelta(t, 450.0, eval.ThresholdMs, 1e-9)
+    assert.Contains(t, eval.Explanation, "p99=300.00")
+
+    // Baselines without the percentile fall back to p95
+    b.Percentiles = nil
+    eval = EvaluateDuration(cfg, 400, b)
+    assert.True(t, eval.IsAnomaly)
+    assert.InDelta(t, 300.0, eval.ThresholdMs, 1e-9)
+    assert.Contains(t, eval.Explanation, "p95=200.00")
+}
+
+func TestEvaluateDuration_Severity(t *testing.T) {
+    cfg := baseCfg()
+    b := &store.Baseline{P50: 100, P95: 200, MAD: 0, SampleCount: 100}
+
+    // threshold = max(200*1.5, 100) = 300
+    cases := []struct {
+        durationMs int64
+        severity   domain.Severity
+    }{
+        {250, domain.SeverityInfo},
+        {300, domain.SeverityInfo},
+        {400, domain.SeverityWarning},
+        {600, domain.SeverityWarning},
+        {1500, domain.SeverityCritical},
+    }
+    for _, tc := range cases {
+        eval := EvaluateDuration(cfg, tc.durationMs, b)
+        assert.Equal(t, tc.severity, eval.Severity, "duration %d", tc.durationMs)
+        assert.InDelta(t, float64(tc.durationMs)/300, eval.Score, 1e-9)
+        // A zero MAD has no finite z-score
+        assert.Equal(t, 0.0, eval.ZScore)
+    }
+}
+
 func TestCheck_Evaluate_NoBaselineOrInsufficientSamples(t *testing.T) {
     ctx := context.Background()
     loc, _ := time.LoadLocation("Asia/Taipei")
@@ -129,3 +178,155 @@ func TestCheck_Evaluate_NoBaselineOrInsufficientSamples(t *testing.T) {
     m.AssertExpectations(t)
 }
 
+func TestCheck_Evaluate_SLOWithoutBaseline(t *testing.T) {
+    ctx := context.Background()
+    loc, _ := time.LoadLocation("Asia/Taipei")
+    ts := time.Date(2024, 1, 8, 9, 0, 0, 0, loc)
+
+    cfg := baseCfg()
+    cfg.Rules = []config.Rule{{Name: "new-endpoint", Endpoint: "GET /new", Detector: DetectorSLO, SLOMs: 300}}
+
+    // A brand-new endpoint: no bucket has a baseline yet
+    m := new(smocks.MockStore)
+    m.On("GetBaseline", mock.Anything, mock.Anything).Return((*store.Baseline)(nil), nil)
+    ck := NewCheck(m, cfg, NewBaselineLookup(m, cfg))
+
+    resp, err := ck.Evaluate(ctx, domain.AnomalyCheckRequest{
+        Service:       "svcA",
+        Endpoint:      "GET /new",
+        TimestampNano: tsNanoI64(ts),
+        DurationMs:    450,
+    })
+    if assert.NoError(t, err) {
+        assert.True(t, resp.IsAnomaly)
+        assert.False(t, resp.CannotDetermine)
+        assert.Equal(t, DetectorSLO, resp.Detector)
+        assert.Nil(t, resp.Baseline)
+        assert.InDelta(t, 1.5, resp.Score, 1e-9)
+        assert.Contains(t, resp.Explanation, "slo=300ms")
+    }
+
+    traces, err := ck.AnnotateTraces(ctx, []domain.TraceEvent{
+        {TraceID: "t1", RootServiceName: "svcA", RootTraceName: "GET /new", StartTimeUnixNano: fmt.Sprintf("%d", ts.UnixNano()), DurationMs: 450},
+        {TraceID: "t2", RootServiceName: "svcA", RootTraceName: "GET /new", StartTimeUnixNano: fmt.Sprintf("%d", ts.UnixNano()), DurationMs: 200},
+        {TraceID: "t3", RootServiceName: "svcA", RootTraceName: "GET /other", StartTimeUnixNano: fmt.Sprintf("%d", ts.UnixNano()), DurationMs: 450},
+    })
+    if assert.NoError(t, err) {
+        assert.True(t, traces[0].IsAnomaly)
+        assert.False(t, traces[1].IsAnomaly)
+        // Baseline detectors still need a baseline
+        assert.False(t, traces[2].IsAnomaly)
+    }
+}
+
+func TestCheck_AnnotateTraces_NamesWithSeparators(t *testing.T) {
+    ctx := context.Background()
+    loc, _ := time.LoadLocation("Asia/Taipei")
+    ts := time.Date(2024, 1, 8, 9, 0, 0, 0, loc)
+    bucket, _ := domain.ParseTimeBucket(fmt.Sprintf("%d", ts.UnixNano()), "Asia/Taipei")
+
+    // ("a|b", "c") and ("a", "b|c") are different series
+    cfg := baseCfg()
+    m := new(smocks.MockStore)
+    m.On("GetBaseline", mock.Anything, domain.MakeBaselineKey("a|b", "c", bucket)).Return(&store.Baseline{P50: 800, P95: 1000, MAD: 50, SampleCount: 50}, nil)
+    m.On("GetBaseline", mock.Anything, domain.MakeBaselineKey("a", "b|c", bucket)).Return(&store.Baseline{P50: 100, P95: 120, MAD: 5, SampleCount: 50}, nil)
+    ck := NewCheck(m, cfg, NewBaselineLookup(m, cfg))
+
+    traces, err := ck.AnnotateTraces(ctx, []domain.TraceEvent{
+        {TraceID: "t1", RootServiceName: "a|b", RootTraceName: "c", StartTimeUnixNano: fmt.Sprintf("%d", ts.UnixNano()), DurationMs: 450},
+        {TraceID: "t2", RootServiceName: "a", RootTraceName: "b|c", StartTimeUnixNano: fmt.Sprintf("%d", ts.UnixNano()), DurationMs: 450},
+    })
+    if assert.NoError(t, err) {
+        assert.False(t, traces[0].IsAnomaly)
+        assert.True(t, traces[1].IsAnomaly)
+    }
+    m.AssertExpectations(t)
+}
+
+func TestCheck_Evaluate_RuleOverrides(t *testing.T) {
+    ctx := context.Background()
+    loc, _ := time.LoadLocation("Asia/Taipei")
+    ts := time.Date(2024, 1, 8, 10, 30, 0, 0, loc)
+    bucket, _ := domain.ParseTimeBucket(fmt.Sprintf("%d", ts.UnixNano()), "Asia/Taipei")
+
+    cfg := baseCfg()
+    cfg.Fallback.NearbyHoursEnabled = true
+    cfg.Rules = []config.Rule{
+        {Name: "health-checks", Endpoint: "GET /health*", MinSamples: 5, FloorMs: 50, MaxFallbackLevel: 1},
+    }
+
+    // 8 samples: enough for the rule, not for the global min_samples of 10
+    b := &store.Baseline{P50: 2, P95: 4, MAD: 1, SampleCount: 8}
+    m := new(smocks.MockStore)
+    m.On("GetBaseline", mock.Anything, domain.MakeBaselineKey("svcA", "GET /healthz", bucket)).Return(b, nil)
+
+    ck := NewCheck(m, cfg, NewBaselineLookup(m, cfg))
+    resp, err := ck.Evaluate(ctx, domain.AnomalyCheckRequest{
+        Service:       "svcA",
+        Endpoint:      "GET /healthz",
+        TimestampNano: tsNanoI64(ts),
+        DurationMs:    40,
+    })
+    if assert.NoError(t, err) {
+        assert.Equal(t, "health-checks", resp.Rule)
+        assert.Equal(t, 1, resp.FallbackLevel)
+        // threshold max(4*1.5, 2+3*1) = 6 is raised to the 50ms floor
+        assert.False(t, resp.IsAnomaly)
+        assert.Contains(t, resp.Explanation, "min_threshold=50ms")
+    }
+
+    // Without a usable exact bucket the rule stops before the nearby-hours fallback
+    m2 := new(smocks.MockStore)
+    m2.On("GetBaseline", mock.Anything, mock.Anything).Return((*store.Baseline)(nil), nil)
+    ck = NewCheck(m2, cfg, NewBaselineLookup(m2, cfg))
+    resp, err = ck.Evaluate(ctx, domain.AnomalyCheckRequest{
+        Service:       "svcA",
+        Endpoint:      "GET /healthz",
+        TimestampNano: tsNanoI64(ts),
+        DurationMs:    40,
+    })
+    if assert.NoError(t, err) {
+        assert.Equal(t, 5, resp.FallbackLevel)
+    }
+    m.AssertExpectations(t)
+    m2.AssertNotCalled(t, "GetBaselines", mock.Anything, mock.Anything)
+}
+
+func TestCheck_Evaluate_ServiceTimezone(t *testing.T) {
+    ctx := context.Background()
+    cfg := baseCfg()
+    cfg.Timezones = []config.ServiceTimezone{{Service: "eu-*", Timezone: "Europe/Berlin"}}
+
+    // Monday 08:30 UTC is 16:30 in Taipei and 09:30 in Berlin
+    ts := time.Date(2024, 1, 8, 8, 30, 0, 0, time.UTC)
+    euBucket := domain.TimeBucket{Hour: 9, DayType: "weekday"}
+    twBucket := domain.TimeBucket{Hour: 16, DayType: "weekday"}
+    b := &store.Baseline{P50: 100, P95: 200, MAD: 20, SampleCount: 100}
+
+    m := new(smocks.MockStore)
+    m.On("GetBaseline", mock.Anything, domain.MakeBaselineKey("eu-orders", "GET /a", euBucket)).Return(b, nil)
+    m.On("GetBaseline", mock.Anything, domain.MakeBaselineKey("tw-orders", "GET /a", twBucket)).Return(b, nil)
+    ck := NewCheck(m, cfg, NewBaselineLookup(m, cfg))
+
+    resp, err := ck.Evaluate(ctx, domain.AnomalyCheckRequest{Service: "eu-orders", Endpoint: "GET /a", TimestampNano: tsNanoI64(ts), DurationMs: 100})
+    if assert.NoError(t, err) {
+        assert.Equal(t, euBucket, resp.Bucket)
+        assert.Equal(t, "Europe/Berlin", resp.Timezone)
+    }
+    resp, err = ck.Evaluate(ctx, domain.AnomalyCheckRequest{Service: "tw-orders", Endpoint: "GET /a", TimestampNano: tsNanoI64(ts), DurationMs: 100})
+    if assert.NoError(t, err) {
+        assert.Equal(t, twBucket, resp.Bucket)
+        assert.Equal(t, "Asia/Taipei", resp.Timezone)
+    }
+
+    // Trace annotation buckets each trace in its own service's zone
+    traces, err := ck.AnnotateTraces(ctx, []domain.TraceEvent{
+        {TraceID: "t1", RootServiceName: "eu-orders", RootTraceName: "GET /a", StartTimeUnixNano: fmt.Sprintf("%d", ts.UnixNano()), DurationMs: 100},
+        {TraceID: "t2", RootServiceName: "tw-orders", RootTraceName: "GET /a", StartTimeUnixNano: fmt.Sprintf("%d", ts.UnixNano()), DurationMs: 100},
+    })
+    if assert.NoError(t, err) {
+        assert.NotZero(t, traces[0].Score, "eu-orders has a baseline at 09:00 Berlin")
+        assert.NotZero(t, traces[1].Score, "tw-orders has a baseline at 16:00 Taipei")
+    }
+    m.AssertExpectations(t)
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/config"
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/stats"
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+// Detector names, as configured in detection.detector and reported in responses.
+const (
+	DetectorHybrid         = "hybrid"
+	DetectorRobustZ        = "robust_z"
+	DetectorIQR            = "iqr"
+	DetectorPercentileRank = "percentile_rank"
+	DetectorSLO            = "slo"
+)
+
+// madToSigma scales the MAD to a consistent estimate of the standard deviation
+// of normally distributed durations.
+const madToSigma = 1.4826
+
+// Detector decides whether a duration is anomalous against a baseline.
+type Detector interface {
+	// Name identifies the detector, e.g. "robust_z".
+	Name() string
+	// NeedsBaseline reports whether the detector judges against a baseline. Only
+	// detectors that do not may be evaluated with a nil one.
+	NeedsBaseline() bool
+	// Evaluate judges durationMs against baseline.
+	Evaluate(durationMs int64, baseline *store.Baseline) DurationEvaluation
+}
+
+// DetectorFor returns the detector configured for service's endpoint or span name,
+// including the overrides of a matching rule.
+func DetectorFor(cfg *config.Config, service, endpoint string) Detector {
+	return settingsDetector(cfg.SettingsFor(service, endpoint))
+}
+
+// settingsDetector returns the detector of resolved settings, with its threshold
+// raised to stats.min_threshold_ms and p50 + stats.min_excess_ms.
+func settingsDetector(set config.Settings) Detector {
+	d := newDetector(set.Stats, set.Detection)
+	if set.Stats.MinThresholdMs > 0 || set.Stats.MinExcessMs > 0 {
+		return flooredDetector{Detector: d, minThresholdMs: set.Stats.MinThresholdMs, minExcessMs: set.Stats.MinExcessMs}
+	}
+	return d
+}
+
+func newDetector(st config.StatsConfig, d config.DetectionConfig) Detector {
+	madEpsilon := millis(st.MADEpsilon)
+	hybrid := hybridDetector{factor: st.Factor, k: st.K, percentile: st.ThresholdPercentile, madEpsilon: madEpsilon}
+	switch d.Detector {
+	case DetectorRobustZ:
+		return robustZDetector{z: d.ZThreshold, madEpsilon: madEpsilon}
+	case DetectorIQR:
+		return iqrDetector{multiplier: d.IQRMultiplier, fallback: hybrid}
+	case DetectorPercentileRank:
+		return percentileRankDetector{percentile: stats.PercentileName(d.RankPercentile), fallback: hybrid}
+	case DetectorSLO:
+		return sloDetector{thresholdMs: d.SLOMs}
+	default:
+		return hybrid
+	}
+}
+
+// hybridDetector flags durations above max(percentile*factor, p50 + k*MAD).
+type hybridDetector struct {
+	factor     float64
+	k          int
+	percentile string
+	madEpsilon float64
+}
+
+func (hybridDetector) Name() string { return DetectorHybrid }
+
+func (hybridDetector) NeedsBaseline() bool { return true }
+
+func (d hybridDetector) Evaluate(durationMs int64, baseline *store.Baseline) DurationEvaluation {
+	// The relative threshold scales the configured percentile; baselines computed
+	// before it was configured fall back to p95.
+	pctName := d.percentile
+	pct, ok := baseline.Percentile(pctName)
+	if !ok || pctName == "" {
+		pctName, pct = "p95", baseline.P95
+	}
+	mad := max(baseline.MAD, d.madEpsilon)
+	rel := pct * d.factor
+	abs := baseline.P50 + float64(d.k)*mad
+	threshold := max(rel, abs)
+
+	return thresholdEvaluation(DetectorHybrid, durationMs, threshold, fmt.Sprintf(
+		"p50=%.2f, %s=%.2f, %s, factor=%.2f, k=%d",
+		baseline.P50, pctName, pct, explainMAD(baseline.MAD, d.madEpsilon), d.factor, d.k,
+	))
+}
+
+// robustZDetector flags durations whose robust z-score (deviation from the
+// median in units of 1.4826*MAD) exceeds z.
+type robustZDetector struct {
+	z          float64
+	madEpsilon float64
+}
+
+func (robustZDetector) Name() string { return DetectorRobustZ }
+
+func (robustZDetector) NeedsBaseline() bool { return true }
+
+func (d robustZDetector) Evaluate(durationMs int64, baseline *store.Baseline) DurationEvaluation {
+	sigma := madToSigma * max(baseline.MAD, d.madEpsilon)
+	threshold := baseline.P50 + d.z*sigma
+	return thresholdEvaluation(DetectorRobustZ, durationMs, threshold, fmt.Sprintf(
+		"z=%.2f, limit=%.2f, p50=%.2f, %s",
+		robustZ(float64(durationMs), baseline.P50, sigma), d.z, baseline.P50, explainMAD(baseline.MAD, d.madEpsilon),
+	))
+}
+
+// robustZ returns (x - median) / sigma; a zero sigma makes any deviation infinite.
+func robustZ(x, median, sigma float64) float64 {
+	if sigma > 0 {
+		return (x - median) / sigma
+	}
+	switch {
+	case x > median:
+		return math.Inf(1)
+	case x < median:
+		return math.Inf(-1)
+	}
+	return 0
+}
+
+// iqrDetector flags durations above Tukey's upper fence p75 + multiplier*IQR.
+// Baselines computed before p25/p75 were stored are judged by the fallback.
+type iqrDetector struct {
+	multiplier float64
+	fallback   Detector
+}
+
+func (iqrDetector) Name() string { return DetectorIQR }
+
+func (iqrDetector) NeedsBaseline() bool { return true }
+
+func (d iqrDetector) Evaluate(durationMs int64, baseline *store.Baseline) DurationEvaluation {
+	p25, ok25 := baseline.Percentile("p25")
+	p75, ok75 := baseline.Percentile("p75")
+	if !ok25 || !ok75 {
+		return withNote(d.fallback.Evaluate(durationMs, baseline), "baseline has no p25/p75")
+	}
+	iqr := p75 - p25
+	threshold := p75 + d.multiplier*iqr
+	return thresholdEvaluation(DetectorIQR, durationMs, threshold, fmt.Sprintf(
+		"p25=%.2f, p75=%.2f, IQR=%.2f, multiplier=%.2f",
+		p25, p75, iqr, d.multiplier,
+	))
+}
+
+// percentileRankDetector flags durations above a baseline percentile, i.e. whose
+// percentile rank within the baseline is above the cutoff.
+type percentileRankDetector struct {
+	percentile string
+	fallback   Detector
+}
+
+func (percentileRankDetector) Name() string { return DetectorPercentileRank }
+
+func (percentileRankDetector) NeedsBaseline() bool { return true }
+
+func (d percentileRankDetector) Evaluate(durationMs int64, baseline *store.Baseline) DurationEvaluation {
+	threshold, ok := baseline.Percentile(d.percentile)
+	if !ok {
+		return withNote(d.fallback.Evaluate(durationMs, baseline), "baseline has no "+d.percentile)
+	}
+	return thresholdEvaluation(DetectorPercentileRank, durationMs, threshold, fmt.Sprintf(
+		"%s=%.2f", d.percentile, threshold,
+	))
+}
+
+// sloDetector flags durations above a fixed threshold.
+type sloDetector struct {
+	thresholdMs float64
+}
+
+func (sloDetector) Name() string { return DetectorSLO }
+
+func (sloDetector) NeedsBaseline() bool { return false }
+
+func (d sloDetector) Evaluate(durationMs int64, _ *store.Baseline) DurationEvaluation {
+	return thresholdEvaluation(DetectorSLO, durationMs, d.thresholdMs, fmt.Sprintf("slo=%.0fms", d.thresholdMs))
+}
+
+// flooredDetector never lets the threshold drop below minThresholdMs or
+// p50 + minExcessMs, so tiny absolute deviations are not flagged. Without a
+// baseline only minThresholdMs applies.
+type flooredDetector struct {
+	Detector
+	minThresholdMs float64
+	minExcessMs    float64
+}
+
+func (d flooredDetector) Evaluate(durationMs int64, baseline *store.Baseline) DurationEvaluation {
+	eval := d.Detector.Evaluate(durationMs, baseline)
+	floor := d.minThresholdMs
+	var applied []string
+	if d.minThresholdMs > eval.ThresholdMs {
+		applied = append(applied, fmt.Sprintf("min_threshold=%.0fms", d.minThresholdMs))
+	}
+	if d.minExcessMs > 0 && baseline != nil {
+		if excess := baseline.P50 + d.minExcessMs; excess > eval.ThresholdMs {
+			floor = max(floor, excess)
+			applied = append(applied, fmt.Sprintf("p50+min_excess=%.2fms", excess))
+		}
+	}
+	if len(applied) == 0 {
+		return eval
+	}
+	return thresholdEvaluation(eval.Detector, durationMs, floor, fmt.Sprintf(
+		"%s above %s threshold %.2fms", strings.Join(applied, ", "), eval.Detector, eval.ThresholdMs,
+	))
+}
+
+// explainMAD formats the MAD used by a detector, noting when mad_epsilon raised it.
+func explainMAD(mad, madEpsilon float64) string {
+	if mad < madEpsilon {
+		return fmt.Sprintf("MAD=%.2f (mad_epsilon floor, raw %.2f)", madEpsilon, mad)
+	}
+	return fmt.Sprintf("MAD=%.2f", mad)
+}
+
+// millis converts d to fractional milliseconds.
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// thresholdEvaluation flags durationMs above threshold and explains the decision
+// with the detector's inputs in details.
+func thresholdEvaluation(detector string, durationMs int64, threshold float64, details string) DurationEvaluation {
+	dur := float64(durationMs)
+	isAnomaly := dur > threshold
+	return DurationEvaluation{
+		IsAnomaly:   isAnomaly,
+		ThresholdMs: threshold,
+		Detector:    detector,
+		Explanation: fmt.Sprintf(
+			"duration %.0fms %s threshold %.2fms (%s)",
+			dur,
+			ternary(isAnomaly, "exceeds", "within"),
+			threshold,
+			details,
+		),
+	}
+}
+
+// withNote appends why a fallback detector was used to the explanation.
+func withNote(eval DurationEvaluation, note string) DurationEvaluation {
+	eval.Explanation += "; " + note
+	return eval
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+    "math"
+    "testing"
+    "time"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/config"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestDetectors_Thresholds(t *testing.T) {
+    b := &store.Baseline{
+        P50: 100, P95: 200, MAD: 20, SampleCount: 100,
+        Percentiles: map[string]float64{"p25": 80, "p75": 130, "p99": 400},
+    }
+    st := config.StatsConfig{Factor: 1.5, K: 3, ThresholdPercentile: "p95"}
+
+    cases := []struct {
+        name      string
+        detection config.DetectionConfig
+        threshold float64
+    }{
+        // max(200*1.5, 100+3*20)
+        {DetectorHybrid, config.DetectionConfig{Detector: DetectorHybrid}, 300},
+        // 100 + 3.5*1.4826*20
+        {DetectorRobustZ, config.DetectionConfig{Detector: DetectorRobustZ, ZThreshold: 3.5}, 100 + 3.5*1.4826*20},
+        // 130 + 1.5*(130-80)
+        {DetectorIQR, config.DetectionConfig{Detector: DetectorIQR, IQRMultiplier: 1.5}, 205},
+        {DetectorPercentileRank, config.DetectionConfig{Detector: DetectorPercentileRank, RankPercentile: 99}, 400},
+        {DetectorSLO, config.DetectionConfig{Detector: DetectorSLO, SLOMs: 250}, 250},
+    }
+    for _, tc := range cases {
+        t.Run(tc.name, func(t *testing.T) {
+            d := newDetector(st, tc.detection)
+            assert.Equal(t, tc.name, d.Name())
+
+            eval := d.Evaluate(int64(math.Floor(tc.threshold)), b)
+            assert.False(t, eval.IsAnomaly)
+            assert.InDelta(t, tc.threshold, eval.ThresholdMs, 1e-9)
+            assert.Equal(t, tc.name, eval.Detector)
+
+            eval = d.Evaluate(int64(tc.threshold)+1, b)
+            assert.True(t, eval.IsAnomaly)
+            assert.Contains(t, eval.Explanation, "exceeds")
+        })
+    }
+}
+
+func TestDetectors_MissingPercentileFallsBackToHybrid(t *testing.T) {
+    b := &store.Baseline{P50: 100, P95: 200, MAD: 20, SampleCount: 100}
+    st := config.StatsConfig{Factor: 1.5, K: 3}
+
+    eval := newDetector(st, config.DetectionConfig{Detector: DetectorIQR, IQRMultiplier: 1.5}).Evaluate(250, b)
+    assert.Equal(t, DetectorHybrid, eval.Detector)
+    assert.InDelta(t, 300.0, eval.ThresholdMs, 1e-9)
+    assert.Contains(t, eval.Explanation, "baseline has no p25/p75")
+}
+
+func TestDetectorFor_PerEndpoint(t *testing.T) {
+    cfg := baseCfg()
+    cfg.Detection = config.DetectionConfig{Detector: DetectorHybrid}
+    cfg.Rules = []config.Rule{
+        {Service: "batch", Endpoint: "nightly", Detector: DetectorSLO, SLOMs: 30000},
+        {Service: "api", Detector: DetectorRobustZ, ZThreshold: 4},
+    }
+
+    assert.Equal(t, DetectorSLO, DetectorFor(cfg, "batch", "nightly").Name())
+    assert.Equal(t, DetectorHybrid, DetectorFor(cfg, "batch", "other").Name())
+    assert.Equal(t, DetectorRobustZ, DetectorFor(cfg, "api", "GET /users").Name())
+    assert.Equal(t, DetectorHybrid, DetectorFor(cfg, "web", "GET /").Name())
+}
+
+func TestDetectors_Floors(t *testing.T) {
+    // Identical samples: MAD=0 and a tiny p95
+    b := &store.Baseline{P50: 2, P95: 2, MAD: 0, SampleCount: 100}
+    set := config.Settings{
+        Stats:     config.StatsConfig{Factor: 1.5, K: 3, MADEpsilon: time.Millisecond},
+        Detection: config.DetectionConfig{Detector: DetectorHybrid},
+    }
+
+    // mad_epsilon raises MAD to 1: max(2*1.5, 2+3*1) = 5
+    eval := settingsDetector(set).Evaluate(4, b)
+    assert.False(t, eval.IsAnomaly)
+    assert.InDelta(t, 5.0, eval.ThresholdMs, 1e-9)
+    assert.Contains(t, eval.Explanation, "MAD=1.00 (mad_epsilon floor, raw 0.00)")
+
+    set.Detection = config.DetectionConfig{Detector: DetectorRobustZ, ZThreshold: 3}
+    eval = settingsDetector(set).Evaluate(4, b)
+    assert.InDelta(t, 2+3*1.4826, eval.ThresholdMs, 1e-9)
+
+    // min_threshold_ms and min_excess_ms raise the threshold further
+    set.Detection = config.DetectionConfig{Detector: DetectorHybrid}
+    set.Stats.MinThresholdMs = 8
+    eval = settingsDetector(set).Evaluate(7, b)
+    assert.False(t, eval.IsAnomaly)
+    assert.InDelta(t, 8.0, eval.ThresholdMs, 1e-9)
+    assert.Contains(t, eval.Explanation, "min_threshold=8ms above hybrid threshold 5.00ms")
+
+    set.Stats.MinExcessMs = 10
+    eval = settingsDetector(set).Evaluate(11, b)
+    assert.False(t, eval.IsAnomaly)
+    assert.InDelta(t, 12.0, eval.ThresholdMs, 1e-9)
+    assert.Contains(t, eval.Explanation, "p50+min_excess=12.00ms")
+    assert.True(t, settingsDetector(set).Evaluate(13, b).IsAnomaly)
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+    "context"
+    "fmt"
+    "sort"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/config"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/stats"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+// Drift detects lasting shifts in the normal latency of an endpoint by running a
+// CUSUM change-point detector (see stats.DetectShifts) over the daily baseline
+// history that Baseline.RecomputeForKey records for each of its buckets. Span
+// baselines record no history, so drift covers endpoint baselines only.
+type Drift struct {
+    store store.Store
+    cfg   *config.Config
+}
+
+func NewDrift(store store.Store, cfg *config.Config) *Drift {
+    return &Drift{store: store, cfg: cfg}
+}
+
+// Detect returns the shifts of the daily p50 and p95 of every bucket of
+// service/endpoint that has a history, most recently detected first.
+func (s *Drift) Detect(ctx context.Context, service, endpoint string) (*domain.BaselineDriftResponse, error) {
+    if s == nil || s.store == nil || s.cfg == nil {
+        return nil, fmt.Errorf("drift service not initialized")
+    }
+
+    all := s.cfg.TimeBuckets().All()
+    keys := make([]string, 0, len(all))
+    buckets := make(map[string]domain.TimeBucket, len(all))
+    for _, b := range all {
+        k := domain.MakeHistoryKey(service, endpoint, b)
+        keys = append(keys, k)
+        buckets[k] = b
+    }
+    histories, err := s.store.GetBaselineHistories(ctx, keys)
+    if err != nil {
+        return nil, fmt.Errorf("get baseline histories: %w", err)
+    }
+
+    params := stats.CUSUMParams{Warmup: s.cfg.Drift.WarmupDays, Slack: s.cfg.Drift.Slack, Threshold: s.cfg.Drift.Threshold}
+    resp := &domain.BaselineDriftResponse{Service: service, Endpoint: endpoint, Buckets: len(histories), Shifts: []domain.BaselineShift{}}
+    for k, history := range histories {
+        for _, metric := range []string{"p50", "p95"} {
+            values := make([]float64, len(history))
+            for i, h := range history {
+                values[i] = h.P50
+                if metric == "p95" {
+                    values[i] = h.P95
+                }
+            }
+            for _, sh := range stats.DetectShifts(values, params) {
+                direction := "up"
+                if sh.Magnitude < 0 {
+                    direction = "down"
+                }
+                resp.Shifts = append(resp.Shifts, domain.BaselineShift{
+                    Bucket:     buckets[k],
+                    Metric:     metric,
+                    Direction:  direction,
+                    StartedAt:  history[sh.Start].Day,
+                    DetectedAt: history[sh.Detected].Day,
+                    BeforeMs:   sh.Before,
+                    AfterMs:    sh.After,
+                    Magnitude:  sh.Magnitude,
+                })
+            }
+        }
+    }
+
+    sort.Slice(resp.Shifts, func(i, j int) bool {
+        a, b := resp.Shifts[i], resp.Shifts[j]
+        if !a.DetectedAt.Equal(b.DetectedAt) {
+            return a.DetectedAt.After(b.DetectedAt)
+        }
+        if a.Bucket.Label() != b.Bucket.Label() {
+            return a.Bucket.Label() < b.Bucket.Label()
+        }
+        return a.Metric < b.Metric
+    })
+    return resp, nil
+}
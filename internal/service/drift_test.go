@@ -0,0 +1,103 @@
+package service
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/config"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/store/memory"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+)
+
+func driftCfg() *config.Config {
+    return &config.Config{
+        WindowSize: 1000,
+        Stats:      config.StatsConfig{MinSamples: 10},
+        Drift:      config.DriftConfig{History: 90 * 24 * time.Hour, WarmupDays: 7, Slack: 0.05, Threshold: 0.5},
+    }
+}
+
+func TestDrift_DetectsShiftPerBucket(t *testing.T) {
+    ctx := context.Background()
+    st := memory.New()
+    shifted := domain.TimeBucket{Hour: 9, DayType: "weekday"}
+    stable := domain.TimeBucket{Hour: 10, DayType: "weekday"}
+    require.NoError(t, st.SetBaseline(ctx, domain.MakeBaselineKey("svc", "GET /a", shifted), store.Baseline{P50: 140, SampleCount: 50}))
+    require.NoError(t, st.SetBaseline(ctx, domain.MakeBaselineKey("svc", "GET /a", stable), store.Baseline{P50: 100, SampleCount: 50}))
+    require.NoError(t, st.SetBaseline(ctx, domain.MakeBaselineKey("svc", "GET /b", shifted), store.Baseline{P50: 100, SampleCount: 50}))
+
+    // 10 days at 100ms, then p50 jumps to 140ms (p95 stays) in the 9:00 bucket
+    day0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+    for d := 0; d < 14; d++ {
+        p50 := 100.0
+        if d >= 10 {
+            p50 = 140
+        }
+        day := day0.AddDate(0, 0, d)
+        require.NoError(t, st.RecordBaselineHistory(ctx, domain.MakeHistoryKey("svc", "GET /a", shifted), store.BaselineSnapshot{Day: day, P50: p50, P95: 300, SampleCount: 50}, time.Time{}))
+        require.NoError(t, st.RecordBaselineHistory(ctx, domain.MakeHistoryKey("svc", "GET /a", stable), store.BaselineSnapshot{Day: day, P50: 100, P95: 300, SampleCount: 50}, time.Time{}))
+    }
+
+    resp, err := NewDrift(st, driftCfg()).Detect(ctx, "svc", "GET /a")
+    require.NoError(t, err)
+    assert.Equal(t, 2, resp.Buckets)
+    if assert.Len(t, resp.Shifts, 1) {
+        s := resp.Shifts[0]
+        assert.Equal(t, shifted, s.Bucket)
+        assert.Equal(t, "p50", s.Metric)
+        assert.Equal(t, "up", s.Direction)
+        assert.Equal(t, day0.AddDate(0, 0, 10), s.StartedAt)
+        assert.Equal(t, day0.AddDate(0, 0, 11), s.DetectedAt)
+        assert.Equal(t, 100.0, s.BeforeMs)
+        assert.Equal(t, 140.0, s.AfterMs)
+        assert.InDelta(t, 0.4, s.Magnitude, 1e-9)
+    }
+
+    // GET /b has a baseline but no history yet
+    resp, err = NewDrift(st, driftCfg()).Detect(ctx, "svc", "GET /b")
+    require.NoError(t, err)
+    assert.Equal(t, 0, resp.Buckets)
+    assert.Empty(t, resp.Shifts)
+}
+
+func TestBaseline_RecomputeForKey_RecordsHistory(t *testing.T) {
+    ctx := context.Background()
+    cfg := driftCfg()
+    st := memory.New()
+    bucket := domain.TimeBucket{Hour: 9, DayType: "weekday"}
+    now := time.Now()
+    for i := 0; i < 20; i++ {
+        require.NoError(t, st.IngestBatch(ctx, store.IngestBatch{
+            Samples:    []store.DurationSample{{Key: domain.MakeDurationKey("svc", "GET /a", bucket), DurationMs: 100, At: now}},
+            Sketches:   []store.SketchSample{sketchSample(domain.MakeSketchKey("svc", "GET /a", bucket), 100, now)},
+            WindowSize: cfg.WindowSize,
+        }))
+    }
+    historyKey := domain.MakeHistoryKey("svc", "GET /a", bucket)
+    // An entry older than drift.history is dropped by the next recompute
+    require.NoError(t, st.RecordBaselineHistory(ctx, historyKey, store.BaselineSnapshot{Day: now.AddDate(0, 0, -100), P50: 50}, time.Time{}))
+
+    _, err := NewBaseline(st, cfg).RecomputeForKey(ctx, domain.MakeBaselineKey("svc", "GET /a", bucket))
+    require.NoError(t, err)
+    _, err = NewBaseline(st, cfg).RecomputeForKey(ctx, domain.MakeBaselineKey("svc", "GET /a", bucket))
+    require.NoError(t, err)
+
+    h, err := st.GetBaselineHistories(ctx, []string{historyKey})
+    require.NoError(t, err)
+    if assert.Len(t, h[historyKey], 1, "one entry per day") {
+        assert.Equal(t, store.SketchDay(now), h[historyKey][0].Day)
+        assert.Equal(t, 20, h[historyKey][0].SampleCount)
+    }
+
+    // Thin baselines are not recorded
+    cfg.Stats.MinSamples = 50
+    other := domain.TimeBucket{Hour: 10, DayType: "weekday"}
+    _, err = NewBaseline(st, cfg).RecomputeForKey(ctx, domain.MakeBaselineKey("svc", "GET /a", other))
+    require.NoError(t, err)
+    h, _ = st.GetBaselineHistories(ctx, []string{domain.MakeHistoryKey("svc", "GET /a", other)})
+    assert.Empty(t, h)
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/config"
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/stats"
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+// ErrorRate evaluates whether the error ratio of an endpoint or span is anomalous
+// in a time bucket, comparing one day of the bucket with its other days (see
+// config.ErrorRateConfig).
+type ErrorRate struct {
+	store store.Store
+	cfg   *config.Config
+}
+
+func NewErrorRate(store store.Store, cfg *config.Config) *ErrorRate {
+	return &ErrorRate{store: store, cfg: cfg}
+}
+
+// Evaluate judges the error ratio of req's endpoint or span in the bucket of
+// req.TimestampNano.
+func (s *ErrorRate) Evaluate(ctx context.Context, req domain.ErrorRateCheckRequest) (domain.ErrorRateCheckResponse, error) {
+	if s == nil || s.store == nil || s.cfg == nil {
+		return domain.ErrorRateCheckResponse{}, fmt.Errorf("error rate service not initialized")
+	}
+	if req.Service == "" || (req.Endpoint == "") == (req.SpanName == "") {
+		return domain.ErrorRateCheckResponse{}, fmt.Errorf("need service and exactly one of endpoint or spanName")
+	}
+
+	tz := s.cfg.TimezoneFor(req.Service)
+	at, err := domain.LocalTime(fmt.Sprintf("%d", req.TimestampNano), tz)
+	if err != nil {
+		return domain.ErrorRateCheckResponse{}, fmt.Errorf("parse time bucket: %w", err)
+	}
+	bucket := s.cfg.TimeBuckets().BucketAt(at)
+
+	key := domain.MakeErrorKey(req.Service, req.Endpoint, bucket)
+	if req.SpanName != "" {
+		key = domain.MakeSpanErrorKey(req.Service, req.SpanName, bucket)
+	}
+	var observed *store.ErrorSlice
+	if req.Requests > 0 {
+		observed = &store.ErrorSlice{Requests: req.Requests, Errors: req.Errors}
+	}
+	resp, err := errorRateAt(ctx, s.store, s.cfg, key, at, observed)
+	if err != nil {
+		return domain.ErrorRateCheckResponse{}, err
+	}
+	resp.Bucket = bucket
+	resp.Timezone = tz
+	return resp, nil
+}
+
+// errorRateAt judges the error counts at key on the day of at against the other
+// days within retention. A non-nil observed replaces the stored counts of that day.
+func errorRateAt(ctx context.Context, st store.Store, cfg *config.Config, key string, at time.Time, observed *store.ErrorSlice) (domain.ErrorRateCheckResponse, error) {
+	if cfg.ErrorRate.Z <= 0 {
+		return domain.ErrorRateCheckResponse{CannotDetermine: true, Explanation: "error-rate detection disabled (error_rate.z = 0)"}, nil
+	}
+	counts, err := st.GetErrorCounts(ctx, []string{key}, retentionSince(cfg))
+	if err != nil {
+		return domain.ErrorRateCheckResponse{}, fmt.Errorf("get error counts: %w", err)
+	}
+	return judgeErrorRate(cfg.ErrorRate, counts[key], store.SketchDay(at), observed), nil
+}
+
+// judgeErrorRate splits slices into the day being judged and the baseline made of
+// all other days, and flags the day when the lower bound of its Wilson interval
+// lies above the upper bound of the baseline's.
+func judgeErrorRate(ec config.ErrorRateConfig, slices []store.ErrorSlice, day time.Time, observed *store.ErrorSlice) domain.ErrorRateCheckResponse {
+	var obs, base store.ErrorSlice
+	days := 0
+	for _, slice := range slices {
+		if slice.Day.Equal(day) {
+			obs = slice
+			continue
+		}
+		base.Requests += slice.Requests
+		base.Errors += slice.Errors
+		days++
+	}
+	if observed != nil {
+		obs = *observed
+	}
+
+	resp := domain.ErrorRateCheckResponse{Observed: errorRatio(obs, ec.Z), Days: days, Z: ec.Z}
+	if days > 0 {
+		b := errorRatio(base, ec.Z)
+		resp.Baseline = &b
+	}
+
+	if obs.Requests < int64(ec.MinObserved) || obs.Requests == 0 {
+		resp.CannotDetermine = true
+		resp.Explanation = fmt.Sprintf("too few requests to judge (have %d, need >= %d)", obs.Requests, ec.MinObserved)
+		return resp
+	}
+	if base.Requests < int64(ec.MinRequests) || base.Requests == 0 {
+		resp.CannotDetermine = true
+		resp.Explanation = fmt.Sprintf("no error-rate baseline available or insufficient requests (have %d, need >= %d)", base.Requests, ec.MinRequests)
+		return resp
+	}
+
+	resp.IsAnomaly = resp.Observed.Lower > resp.Baseline.Upper
+	resp.Explanation = fmt.Sprintf("error ratio %.2f%% (lower bound %.2f%%) %s baseline %.2f%% (upper bound %.2f%%)",
+		100*resp.Observed.Ratio, 100*resp.Observed.Lower,
+		ternary(resp.IsAnomaly, "above", "within"),
+		100*resp.Baseline.Ratio, 100*resp.Baseline.Upper)
+	return resp
+}
+
+func errorRatio(c store.ErrorSlice, z float64) domain.ErrorRatio {
+	r := domain.ErrorRatio{Requests: c.Requests, Errors: c.Errors}
+	if c.Requests > 0 {
+		r.Ratio = float64(c.Errors) / float64(c.Requests)
+	}
+	r.Lower, r.Upper = stats.WilsonInterval(c.Errors, c.Requests, z)
+	return r
+}
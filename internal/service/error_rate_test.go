@@ -0,0 +1,153 @@
+package service
+
+import (
+    "context"
+    "fmt"
+    "testing"
+    "time"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/config"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/store/memory"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/tempo"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+)
+
+func errorRateCfg() *config.Config {
+    return &config.Config{
+        Timezone:   "UTC",
+        WindowSize: 1000,
+        Dedup:      config.DedupConfig{TTL: time.Hour},
+        ErrorRate:  config.ErrorRateConfig{Z: 3, MinRequests: 100, MinObserved: 20},
+    }
+}
+
+// seedErrorCounts counts requests with errors among them on each of the days
+// before at, in the bucket of at.
+func seedErrorCounts(t *testing.T, st store.Store, key string, at time.Time, days, requests, errors int) {
+    t.Helper()
+    var outcomes []store.OutcomeSample
+    for d := 1; d <= days; d++ {
+        for i := 0; i < requests; i++ {
+            outcomes = append(outcomes, store.OutcomeSample{Key: key, Error: i < errors, At: at.AddDate(0, 0, -d)})
+        }
+    }
+    require.NoError(t, st.IngestBatch(context.Background(), store.IngestBatch{Outcomes: outcomes}))
+}
+
+func TestErrorRate_FlagsDayAboveBaseline(t *testing.T) {
+    ctx := context.Background()
+    st := memory.New()
+    cfg := errorRateCfg()
+    at := time.Date(2026, 1, 15, 9, 30, 0, 0, time.UTC)
+    bucket := cfg.TimeBuckets().BucketAt(at)
+    key := domain.MakeErrorKey("svc", "GET /a", bucket)
+
+    // 1% errors on the 10 days before, 20% today
+    seedErrorCounts(t, st, key, at, 10, 100, 1)
+    var today []store.OutcomeSample
+    for i := 0; i < 50; i++ {
+        today = append(today, store.OutcomeSample{Key: key, Error: i < 10, At: at})
+    }
+    require.NoError(t, st.IngestBatch(ctx, store.IngestBatch{Outcomes: today}))
+
+    svc := NewErrorRate(st, cfg)
+    resp, err := svc.Evaluate(ctx, domain.ErrorRateCheckRequest{Service: "svc", Endpoint: "GET /a", TimestampNano: at.UnixNano()})
+    require.NoError(t, err)
+    assert.True(t, resp.IsAnomaly)
+    assert.False(t, resp.CannotDetermine)
+    assert.Equal(t, bucket, resp.Bucket)
+    assert.Equal(t, 10, resp.Days)
+    assert.Equal(t, domain.ErrorRatio{Requests: 50, Errors: 10, Ratio: 0.2, Lower: resp.Observed.Lower, Upper: resp.Observed.Upper}, resp.Observed)
+    if assert.NotNil(t, resp.Baseline) {
+        assert.Equal(t, int64(1000), resp.Baseline.Requests)
+        assert.Equal(t, int64(10), resp.Baseline.Errors)
+        assert.Greater(t, resp.Observed.Lower, resp.Baseline.Upper)
+    }
+    assert.Contains(t, resp.Explanation, "above baseline")
+
+    // Given counts replace today's; 1 error in 50 is within the baseline
+    resp, err = svc.Evaluate(ctx, domain.ErrorRateCheckRequest{Service: "svc", Endpoint: "GET /a", TimestampNano: at.UnixNano(), Requests: 50, Errors: 1})
+    require.NoError(t, err)
+    assert.False(t, resp.IsAnomaly)
+    assert.Contains(t, resp.Explanation, "within baseline")
+
+    // Too few requests today to judge
+    resp, err = svc.Evaluate(ctx, domain.ErrorRateCheckRequest{Service: "svc", Endpoint: "GET /a", TimestampNano: at.UnixNano(), Requests: 5, Errors: 5})
+    require.NoError(t, err)
+    assert.False(t, resp.IsAnomaly)
+    assert.True(t, resp.CannotDetermine)
+
+    // Spans have their own counts
+    resp, err = svc.Evaluate(ctx, domain.ErrorRateCheckRequest{Service: "svc", SpanName: "GET /a", TimestampNano: at.UnixNano(), Requests: 50, Errors: 10})
+    require.NoError(t, err)
+    assert.True(t, resp.CannotDetermine)
+    assert.Nil(t, resp.Baseline)
+    assert.Contains(t, resp.Explanation, "no error-rate baseline")
+
+    _, err = svc.Evaluate(ctx, domain.ErrorRateCheckRequest{Service: "svc", Endpoint: "GET /a", SpanName: "x", TimestampNano: at.UnixNano()})
+    assert.Error(t, err)
+
+    cfg.ErrorRate.Z = 0
+    resp, err = svc.Evaluate(ctx, domain.ErrorRateCheckRequest{Service: "svc", Endpoint: "GET /a", TimestampNano: at.UnixNano()})
+    require.NoError(t, err)
+    assert.False(t, resp.IsAnomaly)
+    assert.True(t, resp.CannotDetermine)
+    assert.Contains(t, resp.Explanation, "disabled")
+}
+
+func TestErrorRate_IngestedRootSpansAnnotateTraces(t *testing.T) {
+    ctx := context.Background()
+    st := memory.New()
+    cfg := errorRateCfg()
+    at := time.Date(2026, 1, 15, 9, 30, 0, 0, time.UTC)
+    bucket := cfg.TimeBuckets().BucketAt(at)
+    seedErrorCounts(t, st, domain.MakeErrorKey("svc", "GET /a", bucket), at, 10, 100, 1)
+
+    // 10 of today's 25 traces fail at their root span
+    ing := NewIngest(st, cfg)
+    spanIng := NewSpanIngest(st, cfg)
+    var traces []domain.TraceEvent
+    for i := 0; i < 25; i++ {
+        start := at.Add(time.Duration(i) * time.Second)
+        ev := domain.TraceEvent{
+            TraceID:           fmt.Sprintf("trace-%d", i),
+            RootServiceName:   "svc",
+            RootTraceName:     "GET /a",
+            StartTimeUnixNano: fmt.Sprintf("%d", start.UnixNano()),
+            DurationMs:        100,
+        }
+        status := tempo.StatusCodeUnset
+        if i < 10 {
+            status = tempo.StatusCodeError
+        }
+        fetch := func(ctx context.Context, traceID string) ([]tempo.SpanData, error) {
+            return []tempo.SpanData{{
+                TraceID: traceID, SpanID: "root", Name: "GET /a", ServiceName: "svc",
+                StartTimeUnixNano: ev.StartTimeUnixNano,
+                EndTimeUnixNano:   fmt.Sprintf("%d", start.Add(100*time.Millisecond).UnixNano()),
+                StatusCode:        status,
+            }}, nil
+        }
+        ingested, err := ing.TraceWithSpans(ctx, ev, spanIng, fetch)
+        require.NoError(t, err)
+        require.True(t, ingested)
+        traces = append(traces, ev)
+    }
+
+    counts, err := st.GetErrorCounts(ctx, []string{domain.MakeSpanErrorKey("svc", "GET /a", bucket)}, time.Time{})
+    require.NoError(t, err)
+    assert.Equal(t, []store.ErrorSlice{{Day: store.SketchDay(at), Requests: 25, Errors: 10}}, counts[domain.MakeSpanErrorKey("svc", "GET /a", bucket)])
+
+    ck := NewCheck(st, cfg, NewBaselineLookup(st, cfg))
+    annotated, err := ck.AnnotateTraces(ctx, traces)
+    require.NoError(t, err)
+    for _, tr := range annotated {
+        assert.True(t, tr.ErrorRateAnomaly)
+        assert.InDelta(t, 0.4, tr.ErrorRatio, 1e-9)
+        // No latency baseline yet
+        assert.False(t, tr.IsAnomaly)
+    }
+}
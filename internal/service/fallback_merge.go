@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/config"
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/stats"
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+// mergedBaseline computes a fallback baseline over the buckets of baseKeys from the
+// union of their samples with the baseline model of sc: the buckets' sketches are
+// merged (each bounded by retention and window size like RecomputeForKey) and the
+// percentiles read from the result, or for "decayed" the buckets' raw samples are
+// weighted by their age relative to the newest one. Unlike averaging per-bucket
+// percentiles this keeps a slow hour's tail in P95. Buckets whose baselines have
+// no retained samples behind them (imported without samples, or pruned) fall back
+// to the sample-weighted average of the stored baselines (see averagedBaseline).
+// updatedAt is carried over as the baseline's UpdatedAt.
+func mergedBaseline(ctx context.Context, st store.Store, cfg *config.Config, sc config.StatsConfig, baseKeys []string, stored map[string]*store.Baseline, updatedAt time.Time) (*store.Baseline, error) {
+	durKeys := make([]string, len(baseKeys))
+	sketchKeys := make([]string, len(baseKeys))
+	for i, baseKey := range baseKeys {
+		durKey, ok := domain.DurationKeyForBaselineKey(baseKey)
+		if !ok {
+			return nil, fmt.Errorf("invalid baseline key: %s", baseKey)
+		}
+		durKeys[i] = durKey
+		sketchKeys[i], _ = domain.SketchKeyForDurationKey(durKey)
+	}
+	since := retentionSince(cfg)
+
+	var bs domain.BaselineStats
+	if sc.Model == config.StatsModelDecayed {
+		samples, err := mergedSamples(ctx, st, durKeys, since)
+		if err != nil {
+			return nil, err
+		}
+		if len(samples) > 0 {
+			bs = decayedBaseline(cfg, samples, sc.HalfLife)
+		}
+	}
+
+	// Like recomputeStats, windows without raw samples fall back to the sketches
+	if bs.SampleCount == 0 {
+		sketches, err := st.GetSketches(ctx, sketchKeys, since)
+		if err != nil {
+			return nil, fmt.Errorf("get sketches: %w", err)
+		}
+
+		merged := stats.NewSketch()
+		for i, durKey := range durKeys {
+			slices, ok := sketches[sketchKeys[i]]
+			if !ok {
+				// Baselines computed before sketches existed; build the sketch from the window.
+				if slices, err = backfillSketch(ctx, st, durKey, sketchKeys[i], since); err != nil {
+					return nil, err
+				}
+			}
+			merged.Merge(windowSketch(slices, cfg.WindowSize))
+		}
+		if merged.Count() == 0 {
+			return averagedBaseline(baseKeys, stored, updatedAt)
+		}
+		bs = sketchBaseline(cfg, merged)
+	}
+
+	return &store.Baseline{
+		P50:         bs.P50,
+		P95:         bs.P95,
+		MAD:         bs.MAD,
+		SampleCount: bs.SampleCount,
+		UpdatedAt:   updatedAt,
+		Percentiles: bs.Percentiles,
+	}, nil
+}
+
+// averagedBaseline weighs the stored baselines of baseKeys by their sample
+// counts. Averaged percentiles understate a slow bucket's tail, so this only
+// stands in when the buckets have nothing to merge. Extra percentiles are kept
+// when every baseline has them.
+func averagedBaseline(baseKeys []string, stored map[string]*store.Baseline, updatedAt time.Time) (*store.Baseline, error) {
+	var used []*store.Baseline
+	for _, k := range baseKeys {
+		if b := stored[k]; b != nil && b.SampleCount > 0 {
+			used = append(used, b)
+		}
+	}
+	if len(used) == 0 {
+		return nil, fmt.Errorf("no samples in fallback buckets")
+	}
+
+	agg := &store.Baseline{UpdatedAt: updatedAt}
+	pcts := make(map[string]float64, len(used[0].Percentiles))
+	for name := range used[0].Percentiles {
+		pcts[name] = 0
+	}
+	for _, b := range used {
+		w := float64(b.SampleCount)
+		agg.P50 += b.P50 * w
+		agg.P95 += b.P95 * w
+		agg.MAD += b.MAD * w
+		agg.SampleCount += b.SampleCount
+		for name := range pcts {
+			v, ok := b.Percentiles[name]
+			if !ok {
+				delete(pcts, name)
+				continue
+			}
+			pcts[name] += v * w
+		}
+	}
+	n := float64(agg.SampleCount)
+	agg.P50 /= n
+	agg.P95 /= n
+	agg.MAD /= n
+	if len(pcts) > 0 {
+		agg.Percentiles = make(map[string]float64, len(pcts))
+		for name, sum := range pcts {
+			agg.Percentiles[name] = sum / n
+		}
+	}
+	return agg, nil
+}
+
+// mergedSamples returns the samples of durKeys observed at or after since,
+// newest first.
+func mergedSamples(ctx context.Context, st store.Store, durKeys []string, since time.Time) ([]store.Sample, error) {
+	var out []store.Sample
+	for _, durKey := range durKeys {
+		samples, err := st.GetSamples(ctx, durKey, since)
+		if err != nil {
+			return nil, fmt.Errorf("get durations: %w", err)
+		}
+		out = append(out, samples...)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].At.After(out[j].At) })
+	return out, nil
+}
+
+// nearbyBuckets returns the level-2 neighbours of bucket: by elapsed time around
+// at (see Bucketing.NearbyAt), or by slot alone when at is zero. usual reports
+// whether they are the bucket's regular neighbours, so the level-2 result may be
+// cached per bucket; on DST transition days they are not.
+func nearbyBuckets(cfg *config.Config, bucket domain.TimeBucket, at time.Time) (nearby []domain.TimeBucket, usual bool) {
+	if cfg == nil {
+		return nil, true
+	}
+	tb := cfg.TimeBuckets()
+	r := cfg.Fallback.NearbyHoursRange
+	nearby = tb.Nearby(bucket, r)
+	if at.IsZero() {
+		return nearby, true
+	}
+	nearbyAt := tb.NearbyAt(at, r)
+	return nearbyAt, slices.Equal(nearbyAt, nearby)
+}
+
+// fallbackCache keeps fallback results (levels 2-4) for cfg.Fallback.CacheTTL so
+// repeated checks against a sparse bucket do not re-read and re-merge the same
+// buckets. Misses (nil results) are cached as well.
+type fallbackCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]fallbackEntry
+	now     func() time.Time
+}
+
+type fallbackEntry struct {
+	res     *BaselineResult
+	expires time.Time
+}
+
+// fallbackCacheSweepSize is the entry count above which expired entries are
+// dropped on insert.
+const fallbackCacheSweepSize = 4096
+
+func newFallbackCache(ttl time.Duration) *fallbackCache {
+	return &fallbackCache{ttl: ttl, entries: make(map[string]fallbackEntry), now: time.Now}
+}
+
+// get returns the cached result of a fallback level for the series and bucket in
+// the context's tenant, or calls compute and caches its result. Levels that ignore
+// the hour or day type pass zero values for them. A nil cache or zero TTL disables
+// caching.
+func (c *fallbackCache) get(ctx context.Context, level int, service, name string, bucket domain.TimeBucket, compute func() *BaselineResult) *BaselineResult {
+	if c == nil || c.ttl <= 0 {
+		return compute()
+	}
+	id := domain.SeriesKey{Service: service, Name: name}.SeriesID()
+	ck := fmt.Sprintf("%s|%d|%s|%s", store.TenantFromContext(ctx), level, id, bucket.Label())
+	now := c.now()
+
+	c.mu.Lock()
+	e, ok := c.entries[ck]
+	c.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.res
+	}
+
+	res := compute()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= fallbackCacheSweepSize {
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	c.entries[ck] = fallbackEntry{res: res, expires: now.Add(c.ttl)}
+	return res
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"hash/fnv"
+
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/config"
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+// guardSample applies the ingest guard (see config.GuardConfig) to a sample of
+// durationMs whose bucket currently has baseline. It returns the duration to
+// store, whether to store the sample at all and whether the guard acted on it,
+// i.e. excluded, dropped or clamped it. id identifies the sample for the
+// downweight decision.
+func guardSample(cfg *config.Config, set config.Settings, id string, durationMs int64, baseline *store.Baseline) (stored int64, keep, rejected bool) {
+	if cfg == nil || !cfg.Guard.Enabled() || baseline == nil || baseline.SampleCount < set.Stats.MinSamples {
+		return durationMs, true, false
+	}
+	limit := cfg.Guard.Multiple * evaluateDuration(cfg, set, durationMs, baseline).ThresholdMs
+	if limit <= 0 || float64(durationMs) <= limit {
+		return durationMs, true, false
+	}
+
+	switch cfg.Guard.Mode {
+	case config.GuardModeExclude:
+		return durationMs, false, true
+	case config.GuardModeDownweight:
+		if guardKeeps(id, cfg.Guard.Weight) {
+			return durationMs, true, false
+		}
+		return durationMs, false, true
+	case config.GuardModeWinsorize:
+		return int64(limit), true, true
+	}
+	return durationMs, true, false
+}
+
+// guardKeeps keeps a guarded sample with probability weight. The decision is a
+// hash of id rather than random, so re-ingesting a trace decides the same way.
+func guardKeeps(id string, weight float64) bool {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(id))
+	return float64(h.Sum32()%1000) < weight*1000
+}
@@ -7,18 +7,28 @@ import (
 	"github.com/alexchang/tempo-latency-anomaly-service/internal/config"
 	"github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
 	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/tempo"
 )
 
 // Ingest handles ingestion of a single trace event:
 // - Deduplicate by traceID
 // - Derive time bucket and keys
+// - Apply the ingest guard against the bucket's current baseline (config.GuardConfig)
 // - Append duration sample to rolling window
 // - Mark corresponding baseline key as dirty for recomputation
+// - Count the root span's outcome in the bucket's error counts (needs the spans)
+//
+// All writes for one trace (and optionally its spans) go to the store as a single
+// atomic store.IngestBatch. Tempo search results carry no span status, so traces
+// ingested without their spans are not counted in the error counts.
 type Ingest struct {
 	store store.Store
 	cfg   *config.Config
 }
 
+// SpanFetcher loads the spans of a trace, e.g. tempo.Client.GetTraceSpans.
+type SpanFetcher func(ctx context.Context, traceID string) ([]tempo.SpanData, error)
+
 func NewIngest(store store.Store, cfg *config.Config) *Ingest {
 	return &Ingest{store: store, cfg: cfg}
 }
@@ -31,6 +41,13 @@ func (s *Ingest) Trace(ctx context.Context, ev domain.TraceEvent) error {
 
 // TraceWithResult ingests a trace event and returns whether it was newly ingested.
 func (s *Ingest) TraceWithResult(ctx context.Context, ev domain.TraceEvent) (bool, error) {
+	return s.TraceWithSpans(ctx, ev, nil, nil)
+}
+
+// TraceWithSpans ingests a trace event together with its span samples in one batch.
+// Spans are fetched only for traces that are not duplicates. If fetching spans fails
+// the trace sample is still written and the fetch error is returned alongside true.
+func (s *Ingest) TraceWithSpans(ctx context.Context, ev domain.TraceEvent, spans *SpanIngest, fetch SpanFetcher) (bool, error) {
 	if s == nil || s.store == nil || s.cfg == nil {
 		return false, fmt.Errorf("ingest service not initialized")
 	}
@@ -44,10 +61,14 @@ func (s *Ingest) TraceWithResult(ctx context.Context, ev domain.TraceEvent) (boo
 		return false, nil
 	}
 
-	bucket, err := domain.ParseTimeBucket(ev.StartTimeUnixNano, s.cfg.Timezone)
+	bucket, err := s.cfg.TimeBuckets().ParseTimeBucket(ev.StartTimeUnixNano, s.cfg.TimezoneFor(ev.RootServiceName))
 	if err != nil {
 		return false, fmt.Errorf("parse time bucket: %w", err)
 	}
+	at, err := domain.ParseUnixNano(ev.StartTimeUnixNano)
+	if err != nil {
+		return false, fmt.Errorf("parse start time: %w", err)
+	}
 
 	service := ev.RootServiceName
 	endpoint := ev.RootTraceName
@@ -55,13 +76,61 @@ func (s *Ingest) TraceWithResult(ctx context.Context, ev domain.TraceEvent) (boo
 	durKey := domain.MakeDurationKey(service, endpoint, bucket)
 	baseKey := domain.MakeBaselineKey(service, endpoint, bucket)
 
-	if err := s.store.AppendDuration(ctx, durKey, ev.DurationMs, s.cfg.WindowSize); err != nil {
-		return false, fmt.Errorf("append duration: %w", err)
+	batch := store.IngestBatch{WindowSize: s.cfg.WindowSize}
+	durationMs, keep, rejected := ev.DurationMs, true, false
+	if s.cfg.Guard.Enabled() {
+		baseline, err := s.store.GetBaseline(ctx, baseKey)
+		if err != nil {
+			return false, fmt.Errorf("guard baseline: %w", err)
+		}
+		durationMs, keep, rejected = guardSample(s.cfg, s.cfg.SettingsFor(service, endpoint), ev.TraceID, ev.DurationMs, baseline)
+	}
+	if rejected {
+		batch.Rejected = append(batch.Rejected, baseKey)
+	}
+	if keep {
+		batch.Samples = append(batch.Samples, store.DurationSample{Key: durKey, DurationMs: durationMs, At: at})
+		batch.Sketches = append(batch.Sketches, sketchSample(domain.MakeSketchKey(service, endpoint, bucket), durationMs, at))
+		batch.DirtyKeys = append(batch.DirtyKeys, baseKey)
+	}
+
+	var spanErr error
+	if spans != nil && fetch != nil {
+		data, err := fetch(ctx, ev.TraceID)
+		if err != nil {
+			spanErr = fmt.Errorf("fetch trace spans: %w", err)
+		} else {
+			if root, ok := findRootSpanData(data, endpoint); ok {
+				batch.Outcomes = append(batch.Outcomes, store.OutcomeSample{Key: domain.MakeErrorKey(service, endpoint, bucket), Error: root.IsError(), At: at})
+			}
+			if err := spans.addToBatch(ctx, &batch, data); err != nil {
+				spanErr = err
+			}
+		}
 	}
 
-	if err := s.store.MarkDirty(ctx, baseKey); err != nil {
-		return false, fmt.Errorf("mark dirty: %w", err)
+	if err := s.store.IngestBatch(ctx, batch); err != nil {
+		return false, fmt.Errorf("ingest batch: %w", err)
 	}
 
-	return true, nil
+	return true, spanErr
+}
+
+// findRootSpanData returns the root span of a trace: the span without a parent
+// named like the trace's root, or else the first span without a parent.
+func findRootSpanData(spans []tempo.SpanData, rootName string) (tempo.SpanData, bool) {
+	var root tempo.SpanData
+	found := false
+	for _, span := range spans {
+		if span.ParentSpanID != "" {
+			continue
+		}
+		if span.Name == rootName {
+			return span, true
+		}
+		if !found {
+			root, found = span, true
+		}
+	}
+	return root, found
 }
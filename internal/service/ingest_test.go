@@ -8,8 +8,11 @@ import (
 
     "github.com/alexchang/tempo-latency-anomaly-service/internal/config"
     "github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/stats"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/store"
     smocks "github.com/alexchang/tempo-latency-anomaly-service/internal/store/mocks"
     "github.com/stretchr/testify/assert"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/tempo"
     "github.com/stretchr/testify/mock"
 )
 
@@ -41,8 +44,7 @@ func TestIngest_Trace_DedupSkip(t *testing.T) {
 
     err := ing.Trace(ctx, ev)
     assert.NoError(t, err)
-    m.AssertNotCalled(t, "AppendDuration", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
-    m.AssertNotCalled(t, "MarkDirty", mock.Anything, mock.Anything)
+    m.AssertNotCalled(t, "IngestBatch", mock.Anything, mock.Anything)
     m.AssertExpectations(t)
 }
 
@@ -67,8 +69,12 @@ func TestIngest_Trace_ProcessAndMarkDirty(t *testing.T) {
     baseKey := domain.MakeBaselineKey(ev.RootServiceName, ev.RootTraceName, bucket)
 
     m.On("IsDuplicateOrMark", mock.Anything, ev.TraceID, cfg.Dedup.TTL).Return(false, nil)
-    m.On("AppendDuration", mock.Anything, durKey, int64(250), cfg.WindowSize).Return(nil)
-    m.On("MarkDirty", mock.Anything, baseKey).Return(nil)
+    m.On("IngestBatch", mock.Anything, store.IngestBatch{
+        Samples:    []store.DurationSample{{Key: durKey, DurationMs: 250, At: time.Unix(0, ts.UnixNano())}},
+        Sketches:   []store.SketchSample{{Key: domain.MakeSketchKey(ev.RootServiceName, ev.RootTraceName, bucket), Bin: stats.SketchBin(250), At: time.Unix(0, ts.UnixNano())}},
+        DirtyKeys:  []string{baseKey},
+        WindowSize: cfg.WindowSize,
+    }).Return(nil)
 
     ing := NewIngest(m, cfg)
     err := ing.Trace(ctx, ev)
@@ -76,3 +82,179 @@ func TestIngest_Trace_ProcessAndMarkDirty(t *testing.T) {
     m.AssertExpectations(t)
 }
 
+
+func TestIngest_TraceWithSpans_SingleBatch(t *testing.T) {
+    ctx := context.Background()
+    cfg := ingestCfg()
+    m := new(smocks.MockStore)
+
+    loc, _ := time.LoadLocation("Asia/Taipei")
+    ts := time.Date(2024, 1, 8, 9, 0, 0, 0, loc)
+    start := fmt.Sprintf("%d", ts.UnixNano())
+    end := fmt.Sprintf("%d", ts.Add(40*time.Millisecond).UnixNano())
+    bucket, _ := domain.ParseTimeBucket(start, cfg.Timezone)
+
+    ev := domain.TraceEvent{
+        TraceID:           "trace-3",
+        RootServiceName:   "svcZ",
+        RootTraceName:     "GET /c",
+        StartTimeUnixNano: start,
+        DurationMs:        90,
+    }
+    spans := []tempo.SpanData{
+        {TraceID: "trace-3", Name: "db.query", ServiceName: "svcZ", StartTimeUnixNano: start, EndTimeUnixNano: end},
+        {TraceID: "trace-3", Name: "db.query", ServiceName: "svcZ", StartTimeUnixNano: start, EndTimeUnixNano: end, StatusCode: tempo.StatusCodeError},
+        {TraceID: "trace-3", Name: "", ServiceName: "svcZ", StartTimeUnixNano: start, EndTimeUnixNano: end},
+    }
+    spanDur := domain.MakeSpanDurationKey("svcZ", "db.query", bucket)
+    spanSketch := domain.MakeSpanSketchKey("svcZ", "db.query", bucket)
+
+    m.On("IsDuplicateOrMark", mock.Anything, ev.TraceID, cfg.Dedup.TTL).Return(false, nil)
+    m.On("IngestBatch", mock.Anything, store.IngestBatch{
+        Samples: []store.DurationSample{
+            {Key: domain.MakeDurationKey("svcZ", "GET /c", bucket), DurationMs: 90, At: time.Unix(0, ts.UnixNano())},
+            {Key: spanDur, DurationMs: 40, At: time.Unix(0, ts.UnixNano())},
+            {Key: spanDur, DurationMs: 40, At: time.Unix(0, ts.UnixNano())},
+        },
+        Sketches: []store.SketchSample{
+            {Key: domain.MakeSketchKey("svcZ", "GET /c", bucket), Bin: stats.SketchBin(90), At: time.Unix(0, ts.UnixNano())},
+            {Key: spanSketch, Bin: stats.SketchBin(40), At: time.Unix(0, ts.UnixNano())},
+            {Key: spanSketch, Bin: stats.SketchBin(40), At: time.Unix(0, ts.UnixNano())},
+        },
+        DirtyKeys: []string{
+            domain.MakeBaselineKey("svcZ", "GET /c", bucket),
+            domain.MakeSpanBaselineKey("svcZ", "db.query", bucket),
+        },
+        Outcomes: []store.OutcomeSample{
+            // No span is named like the trace root, so the first one without a parent stands in
+            {Key: domain.MakeErrorKey("svcZ", "GET /c", bucket), At: time.Unix(0, ts.UnixNano())},
+            {Key: domain.MakeSpanErrorKey("svcZ", "db.query", bucket), At: time.Unix(0, ts.UnixNano())},
+            {Key: domain.MakeSpanErrorKey("svcZ", "db.query", bucket), Error: true, At: time.Unix(0, ts.UnixNano())},
+        },
+        WindowSize: cfg.WindowSize,
+    }).Return(nil).Once()
+
+    fetch := func(ctx context.Context, traceID string) ([]tempo.SpanData, error) {
+        return spans, nil
+    }
+    ing := NewIngest(m, cfg)
+    ingested, err := ing.TraceWithSpans(ctx, ev, NewSpanIngest(m, cfg), fetch)
+    assert.NoError(t, err)
+    assert.True(t, ingested)
+    m.AssertExpectations(t)
+}
+
+func TestIngest_Trace_ServiceTimezone(t *testing.T) {
+    ctx := context.Background()
+    cfg := ingestCfg()
+    cfg.Timezones = []config.ServiceTimezone{{Service: "us-*", Timezone: "America/New_York"}}
+    m := new(smocks.MockStore)
+
+    // Tuesday 02:00 in Taipei is still Monday 13:00 in New York
+    ts := time.Date(2024, 1, 9, 2, 0, 0, 0, time.FixedZone("CST", 8*3600))
+    bucket := domain.TimeBucket{Hour: 13, DayType: "weekday"}
+    ev := domain.TraceEvent{
+        TraceID:           "trace-4",
+        RootServiceName:   "us-checkout",
+        RootTraceName:     "POST /pay",
+        StartTimeUnixNano: fmt.Sprintf("%d", ts.UnixNano()),
+        DurationMs:        80,
+    }
+
+    m.On("IsDuplicateOrMark", mock.Anything, ev.TraceID, cfg.Dedup.TTL).Return(false, nil)
+    m.On("IngestBatch", mock.Anything, mock.MatchedBy(func(b store.IngestBatch) bool {
+        return len(b.DirtyKeys) == 1 && b.DirtyKeys[0] == domain.MakeBaselineKey("us-checkout", "POST /pay", bucket)
+    })).Return(nil)
+
+    assert.NoError(t, NewIngest(m, cfg).Trace(ctx, ev))
+    m.AssertExpectations(t)
+}
+
+func TestIngest_Trace_Guard(t *testing.T) {
+    loc, _ := time.LoadLocation("Asia/Taipei")
+    ts := time.Date(2024, 1, 8, 11, 0, 0, 0, loc)
+    baseline := &store.Baseline{P50: 100, P95: 150, MAD: 10, SampleCount: 50}
+
+    tests := []struct {
+        mode       string
+        durationMs int64
+        wantStored int64 // 0 = not stored
+        rejected   bool
+    }{
+        {mode: config.GuardModeOff, durationMs: 100000, wantStored: 100000},
+        {mode: config.GuardModeExclude, durationMs: 120, wantStored: 120},
+        {mode: config.GuardModeExclude, durationMs: 100000, rejected: true},
+        {mode: config.GuardModeWinsorize, durationMs: 100000, wantStored: -1, rejected: true},
+    }
+    for _, tt := range tests {
+        t.Run(fmt.Sprintf("%s/%d", tt.mode, tt.durationMs), func(t *testing.T) {
+            ctx := context.Background()
+            cfg := ingestCfg()
+            cfg.Stats = config.StatsConfig{Factor: 2, K: 10, MinSamples: 10}
+            cfg.Guard = config.GuardConfig{Mode: tt.mode, Multiple: 3}
+            m := new(smocks.MockStore)
+
+            ev := domain.TraceEvent{
+                TraceID:           "trace-g",
+                RootServiceName:   "svcG",
+                RootTraceName:     "GET /g",
+                StartTimeUnixNano: fmt.Sprintf("%d", ts.UnixNano()),
+                DurationMs:        tt.durationMs,
+            }
+            bucket, _ := domain.ParseTimeBucket(ev.StartTimeUnixNano, cfg.Timezone)
+            baseKey := domain.MakeBaselineKey("svcG", "GET /g", bucket)
+            limit := int64(3 * evaluateDuration(cfg, cfg.SettingsFor("svcG", "GET /g"), 0, baseline).ThresholdMs)
+            want := tt.wantStored
+            if want < 0 {
+                want = limit
+            }
+
+            m.On("IsDuplicateOrMark", mock.Anything, ev.TraceID, cfg.Dedup.TTL).Return(false, nil)
+            if cfg.Guard.Enabled() {
+                m.On("GetBaseline", mock.Anything, baseKey).Return(baseline, nil)
+            }
+            m.On("IngestBatch", mock.Anything, mock.MatchedBy(func(b store.IngestBatch) bool {
+                if tt.rejected != (len(b.Rejected) == 1 && b.Rejected[0] == baseKey) {
+                    return false
+                }
+                if want == 0 {
+                    return len(b.Samples) == 0 && len(b.Sketches) == 0 && len(b.DirtyKeys) == 0
+                }
+                return len(b.Samples) == 1 && b.Samples[0].DurationMs == want &&
+                    len(b.Sketches) == 1 && b.Sketches[0].Bin == stats.SketchBin(want)
+            })).Return(nil)
+
+            assert.NoError(t, NewIngest(m, cfg).Trace(ctx, ev))
+            m.AssertExpectations(t)
+        })
+    }
+}
+
+func TestIngest_GuardSkipsThinBaselines(t *testing.T) {
+    cfg := ingestCfg()
+    cfg.Guard = config.GuardConfig{Mode: config.GuardModeExclude, Multiple: 3}
+    set := config.Settings{Stats: config.StatsConfig{Factor: 2, K: 10, MinSamples: 30}}
+
+    stored, keep, rejected := guardSample(cfg, set, "t", 100000, &store.Baseline{P50: 100, P95: 150, MAD: 10, SampleCount: 29})
+    assert.Equal(t, int64(100000), stored)
+    assert.True(t, keep)
+    assert.False(t, rejected)
+
+    _, keep, rejected = guardSample(cfg, set, "t", 100000, nil)
+    assert.True(t, keep)
+    assert.False(t, rejected)
+}
+
+func TestGuardKeeps(t *testing.T) {
+    kept := 0
+    for i := 0; i < 10000; i++ {
+        id := fmt.Sprintf("trace-%d", i)
+        assert.Equal(t, guardKeeps(id, 0.1), guardKeeps(id, 0.1), "decision is deterministic")
+        if guardKeeps(id, 0.1) {
+            kept++
+        }
+    }
+    assert.InDelta(t, 1000, kept, 150)
+    assert.False(t, guardKeeps("trace-1", 0))
+    assert.True(t, guardKeeps("trace-1", 1))
+}
@@ -3,7 +3,6 @@ package service
 import (
     "context"
     "sort"
-    "strings"
 
     "github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
     "github.com/alexchang/tempo-latency-anomaly-service/internal/store"
@@ -13,91 +12,86 @@ import (
 type ListAvailable struct {
     store      store.Store
     minSamples int
+    buckets    domain.Bucketing
 }
 
-// NewListAvailable creates a new ListAvailable service.
-func NewListAvailable(st store.Store, minSamples int) *ListAvailable {
+// NewListAvailable creates a new ListAvailable service. Only buckets of the
+// bucketing scheme are listed, so series ingested under a previous scheme drop
+// out of the listing.
+func NewListAvailable(st store.Store, minSamples int, buckets domain.Bucketing) *ListAvailable {
     return &ListAvailable{
         store:      st,
         minSamples: minSamples,
+        buckets:    buckets,
     }
 }
 
-// GetAvailableServices retrieves all services and endpoints with sufficient samples.
+// GetAvailableServices retrieves all services, endpoints and spans with sufficient samples.
+// It reads the store's series index instead of scanning baseline keys.
 func (s *ListAvailable) GetAvailableServices(ctx context.Context) (*domain.AvailableServicesResponse, error) {
-    // Get all baseline keys with sufficient samples
-    keys, err := s.store.ListBaselineKeys(ctx, s.minSamples)
+    endpoints, err := s.available(ctx, domain.KindBaseline)
+    if err != nil {
+        return nil, err
+    }
+    spans, err := s.available(ctx, domain.KindSpanBaseline)
     if err != nil {
         return nil, err
     }
 
-    // Parse keys and group by service and endpoint
-    // Key format: base:{service}|{endpoint}|{hour}|{dayType}
-    serviceMap := make(map[string]map[string][]string) // service -> endpoint -> buckets
-
-    for _, key := range keys {
-        // Remove "base:" prefix
-        if !strings.HasPrefix(key, "base:") {
-            continue
-        }
-        keyPart := strings.TrimPrefix(key, "base:")
-        
-        // Split by "|"
-        parts := strings.Split(keyPart, "|")
-        if len(parts) < 4 {
-            continue
-        }
+    // Count distinct services among endpoint baselines
+    serviceSet := make(map[string]struct{})
+    for _, e := range endpoints {
+        serviceSet[e.Service] = struct{}{}
+    }
 
-        service := parts[0]
-        endpoint := strings.Join(parts[1:len(parts)-2], "|") // Handle endpoints with "|" in name
-        hour := parts[len(parts)-2]
-        dayType := parts[len(parts)-1]
-        bucket := hour + "|" + dayType
+    return &domain.AvailableServicesResponse{
+        TotalServices:  len(serviceSet),
+        TotalEndpoints: len(endpoints),
+        Services:       endpoints,
+        TotalSpans:     len(spans),
+        Spans:          spans,
+    }, nil
+}
 
-        if serviceMap[service] == nil {
-            serviceMap[service] = make(map[string][]string)
-        }
-        serviceMap[service][endpoint] = append(serviceMap[service][endpoint], bucket)
+// available lists the series of kind that have at least one bucket with minSamples,
+// sorted by service and name.
+func (s *ListAvailable) available(ctx context.Context, kind domain.KeyKind) ([]domain.ServiceEndpoint, error) {
+    series, err := s.store.ListSeries(ctx, kind)
+    if err != nil {
+        return nil, err
     }
 
-    // Convert map to response structure
-    // Initialize as empty slice instead of nil to ensure JSON serialization as []
-    services := make([]domain.ServiceEndpoint, 0)
-    totalEndpoints := 0
-
-    // Sort services for consistent output
-    var sortedServices []string
-    for service := range serviceMap {
-        sortedServices = append(sortedServices, service)
+    inScheme := make(map[domain.TimeBucket]bool)
+    for _, b := range s.buckets.All() {
+        inScheme[b] = true
     }
-    sort.Strings(sortedServices)
 
-    for _, service := range sortedServices {
-        endpoints := serviceMap[service]
-        
-        // Sort endpoints for consistent output
-        var sortedEndpoints []string
-        for endpoint := range endpoints {
-            sortedEndpoints = append(sortedEndpoints, endpoint)
+    // Initialize as empty slice instead of nil to ensure JSON serialization as []
+    out := make([]domain.ServiceEndpoint, 0, len(series))
+    for _, e := range series {
+        var buckets []string
+        for b, n := range e.Buckets {
+            if n >= s.minSamples && inScheme[b] {
+                buckets = append(buckets, b.Label())
+            }
         }
-        sort.Strings(sortedEndpoints)
-
-        for _, endpoint := range sortedEndpoints {
-            buckets := endpoints[endpoint]
-            sort.Strings(buckets) // Sort buckets too
-            
-            services = append(services, domain.ServiceEndpoint{
-                Service:  service,
-                Endpoint: endpoint,
-                Buckets:  buckets,
-            })
-            totalEndpoints++
+        if len(buckets) == 0 {
+            continue
         }
+        sort.Strings(buckets)
+        out = append(out, domain.ServiceEndpoint{
+            Service:  e.Service,
+            Endpoint: e.Name,
+            Buckets:  buckets,
+        })
     }
 
-    return &domain.AvailableServicesResponse{
-        TotalServices:  len(serviceMap),
-        TotalEndpoints: totalEndpoints,
-        Services:       services,
-    }, nil
+    // Sort for consistent output
+    sort.Slice(out, func(i, j int) bool {
+        if out[i].Service != out[j].Service {
+            return out[i].Service < out[j].Service
+        }
+        return out[i].Endpoint < out[j].Endpoint
+    })
+    return out, nil
 }
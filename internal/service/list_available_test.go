@@ -0,0 +1,67 @@
+package service
+
+import (
+    "context"
+    "testing"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+    smocks "github.com/alexchang/tempo-latency-anomaly-service/internal/store/mocks"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/mock"
+    "github.com/stretchr/testify/require"
+)
+
+func TestListAvailable_UsesSeriesIndex(t *testing.T) {
+    m := new(smocks.MockStore)
+    m.On("ListSeries", mock.Anything, domain.KindBaseline).Return([]store.SeriesIndexEntry{
+        {Service: "svc-b", Name: "GET /x", Buckets: map[domain.TimeBucket]int{
+            {Hour: 9, DayType: "weekday"}: 10,
+        }},
+        {Service: "svc-a", Name: "GET /a|b", Buckets: map[domain.TimeBucket]int{
+            {Hour: 17, DayType: "weekday"}: 80,
+            {Hour: 9, DayType: "weekday"}:  50,
+            {Hour: 3, DayType: "weekend"}:  49,
+        }},
+    }, nil)
+    m.On("ListSeries", mock.Anything, domain.KindSpanBaseline).Return([]store.SeriesIndexEntry{
+        {Service: "svc-a", Name: "db.query", Buckets: map[domain.TimeBucket]int{
+            {Hour: 9, DayType: "weekday"}: 60,
+        }},
+    }, nil)
+
+    resp, err := NewListAvailable(m, 50, domain.Bucketing{}).GetAvailableServices(context.Background())
+    require.NoError(t, err)
+
+    // svc-b has no bucket with enough samples
+    assert.Equal(t, 1, resp.TotalServices)
+    assert.Equal(t, 1, resp.TotalEndpoints)
+    assert.Equal(t, []domain.ServiceEndpoint{
+        {Service: "svc-a", Endpoint: "GET /a|b", Buckets: []string{"17|weekday", "9|weekday"}},
+    }, resp.Services)
+    assert.Equal(t, 1, resp.TotalSpans)
+    assert.Equal(t, []domain.ServiceEndpoint{
+        {Service: "svc-a", Endpoint: "db.query", Buckets: []string{"9|weekday"}},
+    }, resp.Spans)
+    m.AssertNotCalled(t, "ListBaselineKeys", mock.Anything, mock.Anything)
+}
+
+func TestListAvailable_FollowsBucketing(t *testing.T) {
+    m := new(smocks.MockStore)
+    m.On("ListSeries", mock.Anything, domain.KindBaseline).Return([]store.SeriesIndexEntry{
+        {Service: "svc", Name: "GET /a", Buckets: map[domain.TimeBucket]int{
+            {Hour: 9, Minute: 30, DayType: "weekday"}: 60,
+            {Hour: 9, DayType: "weekday"}:             60,
+            {Hour: 9, Minute: 45, DayType: "weekday"}: 60, // not a 30 minute slot
+            {Hour: 9, DayType: "mon"}:                 60, // another scheme
+        }},
+    }, nil)
+    m.On("ListSeries", mock.Anything, domain.KindSpanBaseline).Return([]store.SeriesIndexEntry{}, nil)
+
+    slots := domain.Bucketing{Scheme: domain.SchemeSlot, SlotMinutes: 30}
+    resp, err := NewListAvailable(m, 50, slots).GetAvailableServices(context.Background())
+    require.NoError(t, err)
+    assert.Equal(t, []domain.ServiceEndpoint{
+        {Service: "svc", Endpoint: "GET /a", Buckets: []string{"9h30|weekday", "9|weekday"}},
+    }, resp.Services)
+}
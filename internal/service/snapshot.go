@@ -0,0 +1,357 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/config"
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+// SnapshotFormatVersion is the version of the NDJSON snapshot layout written by Export.
+const SnapshotFormatVersion = 1
+
+// Snapshot record types. A snapshot starts with one header line followed by
+// baseline records and, optionally, sample window records.
+const (
+	SnapshotRecordHeader   = "header"
+	SnapshotRecordBaseline = "baseline"
+	SnapshotRecordSamples  = "samples"
+)
+
+// SnapshotRecord is one line of an NDJSON snapshot.
+type SnapshotRecord struct {
+	Type string `json:"type"`
+
+	// Header fields
+	Format     int       `json:"format,omitempty"`
+	KeyVersion int       `json:"keyVersion,omitempty"`
+	ExportedAt time.Time `json:"exportedAt,omitzero"`
+
+	// Key is a base:/spanbase: key for baseline records and a dur:/spandur: key for samples records.
+	Key      string          `json:"key,omitempty"`
+	Baseline *store.Baseline `json:"baseline,omitempty"`
+	// Samples are ordered newest first.
+	Samples []store.Sample `json:"samples,omitempty"`
+}
+
+// SnapshotMode controls how imported records are combined with existing data.
+type SnapshotMode string
+
+const (
+	// SnapshotMerge keeps the more recently updated of the existing and imported
+	// baseline and adds imported samples that are not already in the window.
+	SnapshotMerge SnapshotMode = "merge"
+	// SnapshotOverwrite replaces existing baselines and windows with the imported ones.
+	SnapshotOverwrite SnapshotMode = "overwrite"
+)
+
+// ParseSnapshotMode parses an import mode; an empty string selects SnapshotMerge.
+func ParseSnapshotMode(s string) (SnapshotMode, error) {
+	switch SnapshotMode(s) {
+	case "", SnapshotMerge:
+		return SnapshotMerge, nil
+	case SnapshotOverwrite:
+		return SnapshotOverwrite, nil
+	default:
+		return "", fmt.Errorf("invalid snapshot mode %q (want merge or overwrite)", s)
+	}
+}
+
+// SnapshotStats summarizes an export or import.
+type SnapshotStats struct {
+	Baselines int `json:"baselines"`
+	Windows   int `json:"windows"`
+	Samples   int `json:"samples"`
+	// Skipped counts imported baselines that lost to a newer existing one (merge mode).
+	Skipped int `json:"skipped"`
+	// Dirty counts baseline keys queued for recomputation after an import.
+	Dirty int `json:"dirty"`
+}
+
+// snapshotPageSize bounds the number of baselines fetched per GetBaselines call.
+const snapshotPageSize = 100
+
+// maxSnapshotLine bounds a single NDJSON line (a full sample window).
+const maxSnapshotLine = 64 << 20
+
+// Snapshot exports and imports baselines and duration windows as NDJSON,
+// e.g. to back them up or to seed a new environment.
+type Snapshot struct {
+	store store.Store
+	cfg   *config.Config
+}
+
+func NewSnapshot(store store.Store, cfg *config.Config) *Snapshot {
+	return &Snapshot{store: store, cfg: cfg}
+}
+
+// Export writes every baseline and span baseline to w, one record per line.
+// With includeSamples the raw duration and span duration windows follow.
+func (s *Snapshot) Export(ctx context.Context, w io.Writer, includeSamples bool) (SnapshotStats, error) {
+	var st SnapshotStats
+	if s == nil || s.store == nil {
+		return st, fmt.Errorf("snapshot service not initialized")
+	}
+
+	enc := json.NewEncoder(w)
+	header := SnapshotRecord{
+		Type:       SnapshotRecordHeader,
+		Format:     SnapshotFormatVersion,
+		KeyVersion: domain.KeyVersion,
+		ExportedAt: time.Now().UTC(),
+	}
+	if err := enc.Encode(header); err != nil {
+		return st, fmt.Errorf("write header: %w", err)
+	}
+
+	for _, kind := range []domain.KeyKind{domain.KindBaseline, domain.KindSpanBaseline} {
+		keys, err := s.store.ListKeys(ctx, domain.KeyPrefix(kind))
+		if err != nil {
+			return st, fmt.Errorf("list %s keys: %w", kind, err)
+		}
+		for start := 0; start < len(keys); start += snapshotPageSize {
+			page := keys[start:min(start+snapshotPageSize, len(keys))]
+			baselines, err := s.store.GetBaselines(ctx, page)
+			if err != nil {
+				return st, fmt.Errorf("get baselines: %w", err)
+			}
+			for _, key := range page {
+				b, ok := baselines[key]
+				if !ok {
+					// Deleted since it was listed, or a window key sharing the prefix.
+					continue
+				}
+				if err := enc.Encode(SnapshotRecord{Type: SnapshotRecordBaseline, Key: key, Baseline: b}); err != nil {
+					return st, fmt.Errorf("write baseline: %w", err)
+				}
+				st.Baselines++
+			}
+		}
+	}
+
+	if !includeSamples {
+		return st, nil
+	}
+	for _, kind := range []domain.KeyKind{domain.KindDuration, domain.KindSpanDuration} {
+		keys, err := s.store.ListKeys(ctx, domain.KeyPrefix(kind))
+		if err != nil {
+			return st, fmt.Errorf("list %s keys: %w", kind, err)
+		}
+		for _, key := range keys {
+			samples, err := s.store.GetSamples(ctx, key, time.Time{})
+			if err != nil {
+				return st, fmt.Errorf("get samples: %w", err)
+			}
+			if len(samples) == 0 {
+				continue
+			}
+			if err := enc.Encode(SnapshotRecord{Type: SnapshotRecordSamples, Key: key, Samples: samples}); err != nil {
+				return st, fmt.Errorf("write samples: %w", err)
+			}
+			st.Windows++
+			st.Samples += len(samples)
+		}
+	}
+	return st, nil
+}
+
+// Import reads a snapshot written by Export and applies it according to mode.
+// v1 keys are migrated to the current key layout. Records are applied as they
+// are read, so an error stops the import at the offending line.
+//
+// Afterwards every imported baseline whose duration window holds samples (imported
+// or already present) is marked dirty, so BaselineRecompute reconciles it with the
+// window. Baselines without any local samples are kept as imported until their
+// window holds min_samples samples of live traffic (see keepBaseline).
+func (s *Snapshot) Import(ctx context.Context, r io.Reader, mode SnapshotMode) (SnapshotStats, error) {
+	var st SnapshotStats
+	if s == nil || s.store == nil || s.cfg == nil {
+		return st, fmt.Errorf("snapshot service not initialized")
+	}
+
+	// baseline key -> whether its window is known to hold samples
+	touched := make(map[string]bool)
+	var order []string
+	touch := func(key string, hasSamples bool) {
+		seen, ok := touched[key]
+		if !ok {
+			order = append(order, key)
+		}
+		touched[key] = seen || hasSamples
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxSnapshotLine)
+	line := 0
+	for sc.Scan() {
+		line++
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var rec SnapshotRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return st, fmt.Errorf("line %d: decode: %w", line, err)
+		}
+		if rec.Type == SnapshotRecordHeader {
+			if rec.Format > SnapshotFormatVersion {
+				return st, fmt.Errorf("line %d: unsupported snapshot format %d", line, rec.Format)
+			}
+			continue
+		}
+
+		key := rec.Key
+		if k, ok := domain.MigrateV1Key(key); ok {
+			key = k
+		}
+		sk, err := domain.ParseSeriesKey(key)
+		if err != nil {
+			return st, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		switch rec.Type {
+		case SnapshotRecordBaseline:
+			if sk.Kind != domain.KindBaseline && sk.Kind != domain.KindSpanBaseline {
+				return st, fmt.Errorf("line %d: %q is not a baseline key", line, key)
+			}
+			if rec.Baseline == nil {
+				return st, fmt.Errorf("line %d: missing baseline", line)
+			}
+			applied, err := s.importBaseline(ctx, key, *rec.Baseline, mode)
+			if err != nil {
+				return st, fmt.Errorf("line %d: %w", line, err)
+			}
+			if !applied {
+				st.Skipped++
+				continue
+			}
+			st.Baselines++
+			touch(key, false)
+		case SnapshotRecordSamples:
+			baseKey, ok := domain.BaselineKeyForDurationKey(key)
+			if !ok {
+				return st, fmt.Errorf("line %d: %q is not a duration key", line, key)
+			}
+			n, err := s.importSamples(ctx, key, rec.Samples, mode)
+			if err != nil {
+				return st, fmt.Errorf("line %d: %w", line, err)
+			}
+			st.Windows++
+			st.Samples += n
+			touch(baseKey, true)
+		default:
+			return st, fmt.Errorf("line %d: unknown record type %q", line, rec.Type)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return st, fmt.Errorf("read snapshot: %w", err)
+	}
+
+	for _, key := range order {
+		if !touched[key] {
+			has, err := s.hasSamples(ctx, key)
+			if err != nil {
+				return st, err
+			}
+			if !has {
+				continue
+			}
+		}
+		if err := s.store.MarkDirty(ctx, key); err != nil {
+			return st, fmt.Errorf("mark dirty: %w", err)
+		}
+		st.Dirty++
+	}
+	return st, nil
+}
+
+// importBaseline stores b at key and reports whether it was written.
+func (s *Snapshot) importBaseline(ctx context.Context, key string, b store.Baseline, mode SnapshotMode) (bool, error) {
+	if mode == SnapshotMerge {
+		existing, err := s.store.GetBaseline(ctx, key)
+		if err != nil {
+			return false, fmt.Errorf("get baseline: %w", err)
+		}
+		if existing != nil && !b.UpdatedAt.After(existing.UpdatedAt) {
+			return false, nil
+		}
+	}
+	if err := s.store.SetBaseline(ctx, key, b); err != nil {
+		return false, fmt.Errorf("set baseline: %w", err)
+	}
+	return true, nil
+}
+
+// importSamples writes samples to the window at key, counts them into the window's
+// sketch and returns how many were added.
+func (s *Snapshot) importSamples(ctx context.Context, key string, samples []store.Sample, mode SnapshotMode) (int, error) {
+	sketchKey, ok := domain.SketchKeyForDurationKey(key)
+	if !ok {
+		return 0, fmt.Errorf("%q is not a duration key", key)
+	}
+	if mode == SnapshotOverwrite {
+		if err := s.store.ReplaceSamples(ctx, key, samples, s.cfg.WindowSize); err != nil {
+			return 0, fmt.Errorf("replace samples: %w", err)
+		}
+		if err := s.store.ReplaceSketch(ctx, sketchKey, sketchSlices(samples)); err != nil {
+			return 0, fmt.Errorf("replace sketch: %w", err)
+		}
+		return len(samples), nil
+	}
+
+	existing, err := s.store.GetSamples(ctx, key, time.Time{})
+	if err != nil {
+		return 0, fmt.Errorf("get samples: %w", err)
+	}
+	type sampleID struct {
+		at int64
+		v  int64
+	}
+	have := make(map[sampleID]struct{}, len(existing))
+	for _, smp := range existing {
+		have[sampleID{smp.At.UnixNano(), smp.DurationMs}] = struct{}{}
+	}
+
+	batch := store.IngestBatch{WindowSize: s.cfg.WindowSize}
+	for _, smp := range samples {
+		// Re-importing the same snapshot must not duplicate samples
+		if _, ok := have[sampleID{smp.At.UnixNano(), smp.DurationMs}]; ok {
+			continue
+		}
+		batch.Samples = append(batch.Samples, store.DurationSample{Key: key, DurationMs: smp.DurationMs, At: smp.At})
+		batch.Sketches = append(batch.Sketches, sketchSample(sketchKey, smp.DurationMs, smp.At))
+	}
+	if len(batch.Samples) == 0 {
+		return 0, nil
+	}
+	if err := s.store.IngestBatch(ctx, batch); err != nil {
+		return 0, fmt.Errorf("ingest samples: %w", err)
+	}
+	return len(batch.Samples), nil
+}
+
+// hasSamples reports whether the duration window behind baseKey holds retained samples.
+func (s *Snapshot) hasSamples(ctx context.Context, baseKey string) (bool, error) {
+	sk, err := domain.ParseSeriesKey(baseKey)
+	if err != nil {
+		return false, err
+	}
+	durKind := domain.KindDuration
+	if sk.Kind == domain.KindSpanBaseline {
+		durKind = domain.KindSpanDuration
+	}
+	var since time.Time
+	if s.cfg.Retention > 0 {
+		since = time.Now().Add(-s.cfg.Retention)
+	}
+	samples, err := s.store.GetSamples(ctx, sk.WithKind(durKind).String(), since)
+	if err != nil {
+		return false, fmt.Errorf("get samples: %w", err)
+	}
+	return len(samples) > 0, nil
+}
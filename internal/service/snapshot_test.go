@@ -0,0 +1,165 @@
+package service
+
+import (
+    "bytes"
+    "context"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/config"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/store/memory"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+)
+
+func TestSnapshot_ExportImportRoundTrip(t *testing.T) {
+    ctx := context.Background()
+    cfg := &config.Config{WindowSize: 1000}
+    bucket := domain.TimeBucket{Hour: 9, DayType: "weekday"}
+    baseKey := domain.MakeBaselineKey("svc", "GET /a", bucket)
+    durKey := domain.MakeDurationKey("svc", "GET /a", bucket)
+    spanKey := domain.MakeSpanBaselineKey("svc", "db.query", bucket)
+    updated := time.Date(2026, 1, 15, 8, 0, 0, 0, time.UTC)
+    at := time.Now().UTC().Truncate(time.Millisecond)
+
+    src := memory.New()
+    require.NoError(t, src.SetBaseline(ctx, baseKey, store.Baseline{P50: 100, P95: 200, MAD: 10, SampleCount: 2, UpdatedAt: updated}))
+    require.NoError(t, src.SetBaseline(ctx, spanKey, store.Baseline{P50: 5, P95: 9, MAD: 1, SampleCount: 30, UpdatedAt: updated}))
+    require.NoError(t, src.AppendDuration(ctx, durKey, 90, at.Add(-time.Minute), cfg.WindowSize))
+    require.NoError(t, src.AppendDuration(ctx, durKey, 110, at, cfg.WindowSize))
+
+    var buf bytes.Buffer
+    st, err := NewSnapshot(src, cfg).Export(ctx, &buf, true)
+    require.NoError(t, err)
+    assert.Equal(t, SnapshotStats{Baselines: 2, Windows: 1, Samples: 2}, st)
+    assert.Equal(t, 4, strings.Count(buf.String(), "\n"), "header, two baselines, one window")
+
+    dst := memory.New()
+    snap := NewSnapshot(dst, cfg)
+    st, err = snap.Import(ctx, bytes.NewReader(buf.Bytes()), SnapshotMerge)
+    require.NoError(t, err)
+    assert.Equal(t, SnapshotStats{Baselines: 2, Windows: 1, Samples: 2, Dirty: 1}, st)
+
+    b, err := dst.GetBaseline(ctx, baseKey)
+    require.NoError(t, err)
+    assert.Equal(t, 100.0, b.P50)
+    assert.Equal(t, updated, b.UpdatedAt)
+    durs, err := dst.GetDurations(ctx, durKey)
+    require.NoError(t, err)
+    assert.Equal(t, []int64{110, 90}, durs)
+
+    // Only the baseline backed by samples is queued; the span baseline is kept as imported.
+    keys, err := dst.ClaimDirtyBatch(ctx, 10, time.Minute)
+    require.NoError(t, err)
+    assert.Equal(t, []string{baseKey}, keys)
+
+    // Merging the same snapshot again changes nothing.
+    st, err = snap.Import(ctx, bytes.NewReader(buf.Bytes()), SnapshotMerge)
+    require.NoError(t, err)
+    assert.Equal(t, 2, st.Skipped)
+    assert.Equal(t, 0, st.Samples)
+    durs, err = dst.GetDurations(ctx, durKey)
+    require.NoError(t, err)
+    assert.Len(t, durs, 2)
+}
+
+func TestSnapshot_ImportModes(t *testing.T) {
+    ctx := context.Background()
+    cfg := &config.Config{WindowSize: 1000}
+    bucket := domain.TimeBucket{Hour: 9, DayType: "weekday"}
+    baseKey := domain.MakeBaselineKey("svc", "GET /a", bucket)
+    durKey := domain.MakeDurationKey("svc", "GET /a", bucket)
+    older := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+    newer := older.Add(24 * time.Hour)
+    at := time.Now().UTC().Truncate(time.Millisecond)
+
+    snapshot := `{"type":"header","format":1,"keyVersion":2}
+{"type":"baseline","key":"` + baseKey + `","baseline":{"p50":1,"p95":2,"mad":0,"sampleCount":1,"updatedAt":"` + older.Format(time.RFC3339) + `"}}
+{"type":"samples","key":"` + durKey + `","samples":[{"t":"` + at.Format(time.RFC3339Nano) + `","v":7}]}
+`
+
+    dst := memory.New()
+    require.NoError(t, dst.SetBaseline(ctx, baseKey, store.Baseline{P50: 50, SampleCount: 3, UpdatedAt: newer}))
+    require.NoError(t, dst.AppendDuration(ctx, durKey, 40, at.Add(-time.Minute), cfg.WindowSize))
+
+    // merge: the newer existing baseline wins, samples are added
+    st, err := NewSnapshot(dst, cfg).Import(ctx, strings.NewReader(snapshot), SnapshotMerge)
+    require.NoError(t, err)
+    assert.Equal(t, 1, st.Skipped)
+    b, _ := dst.GetBaseline(ctx, baseKey)
+    assert.Equal(t, 50.0, b.P50)
+    durs, _ := dst.GetDurations(ctx, durKey)
+    assert.Equal(t, []int64{7, 40}, durs)
+
+    // overwrite: imported baseline and window replace existing ones
+    _, err = NewSnapshot(dst, cfg).Import(ctx, strings.NewReader(snapshot), SnapshotOverwrite)
+    require.NoError(t, err)
+    b, _ = dst.GetBaseline(ctx, baseKey)
+    assert.Equal(t, 1.0, b.P50)
+    durs, _ = dst.GetDurations(ctx, durKey)
+    assert.Equal(t, []int64{7}, durs)
+}
+
+func TestSnapshot_ImportedBaselineSurvivesFirstTraffic(t *testing.T) {
+    ctx := context.Background()
+    cfg := &config.Config{WindowSize: 1000, Stats: config.StatsConfig{MinSamples: 10}}
+    bucket := domain.TimeBucket{Hour: 9, DayType: "weekday"}
+    baseKey := domain.MakeBaselineKey("svc", "GET /a", bucket)
+    updated := time.Date(2026, 1, 15, 8, 0, 0, 0, time.UTC)
+
+    // A baseline copied to a new region without its samples
+    snapshot := `{"type":"header","format":1,"keyVersion":2}
+{"type":"baseline","key":"` + baseKey + `","baseline":{"p50":100,"p95":200,"mad":10,"sampleCount":500,"updatedAt":"` + updated.Format(time.RFC3339) + `"}}
+`
+    dst := memory.New()
+    _, err := NewSnapshot(dst, cfg).Import(ctx, strings.NewReader(snapshot), SnapshotMerge)
+    require.NoError(t, err)
+
+    // The first live sample marks the bucket dirty; its one-sample recompute must
+    // not replace the imported baseline
+    add := func(durationMs int64) {
+        now := time.Now()
+        require.NoError(t, dst.IngestBatch(ctx, store.IngestBatch{
+            Samples:    []store.DurationSample{{Key: domain.MakeDurationKey("svc", "GET /a", bucket), DurationMs: durationMs, At: now}},
+            Sketches:   []store.SketchSample{sketchSample(domain.MakeSketchKey("svc", "GET /a", bucket), durationMs, now)},
+            DirtyKeys:  []string{baseKey},
+            WindowSize: cfg.WindowSize,
+        }))
+    }
+    add(5000)
+    bs, err := NewBaseline(dst, cfg).RecomputeForKey(ctx, baseKey)
+    require.NoError(t, err)
+    assert.Equal(t, 500, bs.SampleCount)
+    b, err := dst.GetBaseline(ctx, baseKey)
+    require.NoError(t, err)
+    assert.Equal(t, 100.0, b.P50)
+    assert.Equal(t, updated, b.UpdatedAt)
+
+    // Once the window holds min_samples the live baseline takes over
+    for i := 0; i < 9; i++ {
+        add(5000)
+    }
+    bs, err = NewBaseline(dst, cfg).RecomputeForKey(ctx, baseKey)
+    require.NoError(t, err)
+    assert.Equal(t, 10, bs.SampleCount)
+    b, err = dst.GetBaseline(ctx, baseKey)
+    require.NoError(t, err)
+    assert.InEpsilon(t, 5000.0, b.P50, 0.02)
+}
+
+func TestSnapshot_ImportRejectsBadRecords(t *testing.T) {
+    ctx := context.Background()
+    snap := NewSnapshot(memory.New(), &config.Config{WindowSize: 10})
+
+    _, err := snap.Import(ctx, strings.NewReader(`{"type":"baseline","key":"`+domain.MakeDurationKey("svc", "GET /a", domain.TimeBucket{Hour: 1, DayType: "weekday"})+`","baseline":{}}`), SnapshotMerge)
+    assert.ErrorContains(t, err, "line 1")
+
+    _, err = snap.Import(ctx, strings.NewReader("{\"type\":\"header\",\"format\":99}\n"), SnapshotMerge)
+    assert.ErrorContains(t, err, "unsupported snapshot format")
+
+    _, err = ParseSnapshotMode("replace")
+    assert.Error(t, err)
+}
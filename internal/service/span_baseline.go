@@ -3,13 +3,10 @@ package service
 import (
 	"context"
 	"fmt"
-	"strconv"
-	"strings"
 	"time"
 
 	"github.com/alexchang/tempo-latency-anomaly-service/internal/config"
 	"github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
-	"github.com/alexchang/tempo-latency-anomaly-service/internal/stats"
 	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
 )
 
@@ -29,26 +26,30 @@ func (s *SpanBaseline) RecomputeForKey(ctx context.Context, baselineKey string)
 		return nil, fmt.Errorf("span baseline service not initialized")
 	}
 
-	service, spanName, hour, dayType, err := parseSpanBaselineKey(baselineKey)
+	service, spanName, bucket, err := parseSpanBaselineKey(baselineKey)
 	if err != nil {
 		return nil, err
 	}
 
-	bucket := domain.TimeBucket{Hour: hour, DayType: dayType}
 	durKey := domain.MakeSpanDurationKey(service, spanName, bucket)
 
-	samples, err := s.store.GetDurations(ctx, durKey)
+	sc := s.cfg.SettingsFor(service, spanName).Stats
+	bs, err := recomputeStats(ctx, s.store, s.cfg, sc, durKey)
 	if err != nil {
-		return nil, fmt.Errorf("get durations: %w", err)
+		return nil, err
+	}
+	kept, err := keepBaseline(ctx, s.store, baselineKey, bs, sc.MinSamples)
+	if err != nil || kept != nil {
+		return kept, err
 	}
 
-	bs := stats.ComputeBaseline(samples)
 	err = s.store.SetBaseline(ctx, baselineKey, store.Baseline{
 		P50:         bs.P50,
 		P95:         bs.P95,
 		MAD:         bs.MAD,
 		SampleCount: bs.SampleCount,
 		UpdatedAt:   time.Now().UTC(),
+		Percentiles: bs.Percentiles,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("set baseline: %w", err)
@@ -58,26 +59,26 @@ func (s *SpanBaseline) RecomputeForKey(ctx context.Context, baselineKey string)
 	return &bs, nil
 }
 
-// parseSpanBaselineKey expects format: spanbase:{service}|{spanName}|{hour}|{dayType}
-func parseSpanBaselineKey(key string) (service, spanName string, hour int, dayType string, err error) {
-	if !strings.HasPrefix(key, "spanbase:") {
-		err = fmt.Errorf("invalid span baseline key prefix: %s", key)
-		return
+// BackfillSketches rebuilds the sketch of every span duration window that holds
+// more retained samples than its sketch counts (see backfillSketches) and returns
+// how many it rebuilt.
+func (s *SpanBaseline) BackfillSketches(ctx context.Context) (int, error) {
+	if s == nil || s.store == nil || s.cfg == nil {
+		return 0, fmt.Errorf("span baseline service not initialized")
 	}
-	body := strings.TrimPrefix(key, "spanbase:")
-	parts := strings.Split(body, "|")
-	if len(parts) != 4 {
-		err = fmt.Errorf("invalid span baseline key format: %s", key)
+	return backfillSketches(ctx, s.store, s.cfg, domain.KindSpanDuration)
+}
+
+// parseSpanBaselineKey decodes a span baseline key (see domain.SeriesKey).
+func parseSpanBaselineKey(key string) (service, spanName string, bucket domain.TimeBucket, err error) {
+	sk, perr := domain.ParseSeriesKey(key)
+	if perr != nil {
+		err = fmt.Errorf("invalid span baseline key: %w", perr)
 		return
 	}
-	service = parts[0]
-	spanName = parts[1]
-	h, perr := strconv.Atoi(parts[2])
-	if perr != nil {
-		err = fmt.Errorf("invalid hour in key: %w", perr)
+	if sk.Kind != domain.KindSpanBaseline {
+		err = fmt.Errorf("invalid span baseline key kind: %s", key)
 		return
 	}
-	hour = h
-	dayType = parts[3]
-	return
+	return sk.Service, sk.Name, sk.Bucket, nil
 }
@@ -3,7 +3,6 @@ package service
 import (
 	"context"
 	"fmt"
-	"sort"
 	"strings"
 	"time"
 
@@ -16,11 +15,16 @@ import (
 type SpanBaselineLookup struct {
 	store store.Store
 	cfg   *config.Config
+	cache *fallbackCache
 }
 
 // NewSpanBaselineLookup constructs a new SpanBaselineLookup service.
 func NewSpanBaselineLookup(store store.Store, cfg *config.Config) *SpanBaselineLookup {
-	return &SpanBaselineLookup{store: store, cfg: cfg}
+	bl := &SpanBaselineLookup{store: store, cfg: cfg}
+	if cfg != nil {
+		bl.cache = newFallbackCache(cfg.Fallback.CacheTTL)
+	}
+	return bl
 }
 
 // LookupWithFallback attempts to find an appropriate baseline using the configured
@@ -30,24 +34,54 @@ func (bl *SpanBaselineLookup) LookupWithFallback(
 	service, spanName string,
 	bucket domain.TimeBucket,
 ) (*BaselineResult, error) {
-	if res := bl.tryExactMatch(ctx, service, spanName, bucket); res != nil {
+	return bl.LookupAt(ctx, service, spanName, bucket, time.Time{})
+}
+
+// LookupAt is LookupWithFallback for the bucket of the instant at, with nearby
+// hours picked by elapsed time around at (see BaselineLookup.LookupAt).
+func (bl *SpanBaselineLookup) LookupAt(
+	ctx context.Context,
+	service, spanName string,
+	bucket domain.TimeBucket,
+	at time.Time,
+) (*BaselineResult, error) {
+	// Rules may override min_samples and the enabled fallback levels per series
+	var set config.Settings
+	if bl.cfg != nil {
+		set = bl.cfg.SettingsFor(service, spanName)
+	}
+
+	if res := bl.tryExactMatch(ctx, service, spanName, bucket, set.Stats.MinSamples); res != nil {
 		return res, nil
 	}
 
-	if bl.cfg != nil && bl.cfg.Fallback.NearbyHoursEnabled {
-		if res := bl.tryNearbyHours(ctx, service, spanName, bucket); res != nil {
+	if set.Fallback.NearbyHoursEnabled {
+		nearby, usual := nearbyBuckets(bl.cfg, bucket, at)
+		var res *BaselineResult
+		if usual {
+			res = bl.cache.get(ctx, 2, service, spanName, bucket, func() *BaselineResult {
+				return bl.tryNearbyHours(ctx, service, spanName, bucket, nearby, set)
+			})
+		} else {
+			res = bl.tryNearbyHours(ctx, service, spanName, bucket, nearby, set)
+		}
+		if res != nil {
 			return res, nil
 		}
 	}
 
-	if bl.cfg != nil && bl.cfg.Fallback.DayTypeGlobalEnabled {
-		if res := bl.tryDayTypeGlobal(ctx, service, spanName, bucket.DayType); res != nil {
+	if set.Fallback.DayTypeGlobalEnabled {
+		if res := bl.cache.get(ctx, 3, service, spanName, domain.TimeBucket{DayType: bucket.DayType}, func() *BaselineResult {
+			return bl.tryDayTypeGlobal(ctx, service, spanName, bucket.DayType, set)
+		}); res != nil {
 			return res, nil
 		}
 	}
 
-	if bl.cfg != nil && bl.cfg.Fallback.FullGlobalEnabled {
-		if res := bl.tryFullGlobal(ctx, service, spanName); res != nil {
+	if set.Fallback.FullGlobalEnabled {
+		if res := bl.cache.get(ctx, 4, service, spanName, domain.TimeBucket{}, func() *BaselineResult {
+			return bl.tryFullGlobal(ctx, service, spanName, set)
+		}); res != nil {
 			return res, nil
 		}
 	}
@@ -61,7 +95,7 @@ func (bl *SpanBaselineLookup) LookupWithFallback(
 	}, nil
 }
 
-func (bl *SpanBaselineLookup) tryExactMatch(ctx context.Context, service, spanName string, bucket domain.TimeBucket) *BaselineResult {
+func (bl *SpanBaselineLookup) tryExactMatch(ctx context.Context, service, spanName string, bucket domain.TimeBucket, minSamples int) *BaselineResult {
 	if bl == nil || bl.store == nil || bl.cfg == nil {
 		return nil
 	}
@@ -71,10 +105,10 @@ func (bl *SpanBaselineLookup) tryExactMatch(ctx context.Context, service, spanNa
 	if err != nil || b == nil {
 		return nil
 	}
-	if b.SampleCount < bl.cfg.Stats.MinSamples {
+	if b.SampleCount < minSamples {
 		return nil
 	}
-	detail := fmt.Sprintf("exact match: %d|%s", bucket.Hour, bucket.DayType)
+	detail := "exact match: " + bucket.Label()
 	return &BaselineResult{
 		Baseline:      b,
 		Source:        domain.SourceExact,
@@ -83,33 +117,18 @@ func (bl *SpanBaselineLookup) tryExactMatch(ctx context.Context, service, spanNa
 	}
 }
 
-func (bl *SpanBaselineLookup) tryNearbyHours(ctx context.Context, service, spanName string, bucket domain.TimeBucket) *BaselineResult {
+func (bl *SpanBaselineLookup) tryNearbyHours(ctx context.Context, service, spanName string, bucket domain.TimeBucket, nearby []domain.TimeBucket, set config.Settings) *BaselineResult {
 	if bl == nil || bl.store == nil || bl.cfg == nil {
 		return nil
 	}
 
-	r := bl.cfg.Fallback.NearbyHoursRange
-	if r <= 0 {
-		return nil
-	}
-
 	type hk struct {
-		key  string
-		hour int
+		key    string
+		bucket domain.TimeBucket
 	}
 	var keys []hk
-	seen := make(map[int]bool)
-	center := bucket.Hour
-	wrap := func(h int) int { return (h%24 + 24) % 24 }
-	for d := 1; d <= r; d++ {
-		for _, h := range []int{wrap(center - d), wrap(center + d)} {
-			if seen[h] {
-				continue
-			}
-			seen[h] = true
-			k := domain.MakeSpanBaselineKey(service, spanName, domain.TimeBucket{Hour: h, DayType: bucket.DayType})
-			keys = append(keys, hk{key: k, hour: h})
-		}
+	for _, nb := range nearby {
+		keys = append(keys, hk{key: domain.MakeSpanBaselineKey(service, spanName, nb), bucket: nb})
 	}
 	if len(keys) == 0 {
 		return nil
@@ -125,42 +144,33 @@ func (bl *SpanBaselineLookup) tryNearbyHours(ctx context.Context, service, spanN
 	}
 
 	var totalSamples int
-	var sumP50, sumP95, sumMAD float64
+	var usedKeys []string
 	var latest time.Time
-	var usedHours []int
+	var usedSlots []string
 	for _, it := range keys {
 		b := m[it.key]
 		if b == nil || b.SampleCount <= 0 {
 			continue
 		}
 		totalSamples += b.SampleCount
-		sumP50 += b.P50 * float64(b.SampleCount)
-		sumP95 += b.P95 * float64(b.SampleCount)
-		sumMAD += b.MAD * float64(b.SampleCount)
+		usedKeys = append(usedKeys, it.key)
 		if b.UpdatedAt.After(latest) {
 			latest = b.UpdatedAt
 		}
-		usedHours = append(usedHours, it.hour)
+		usedSlots = append(usedSlots, it.bucket.SlotLabel())
 	}
-	if totalSamples < bl.cfg.Fallback.NearbyMinSamples {
+	if totalSamples < set.Fallback.NearbyMinSamples {
 		return nil
 	}
 	if totalSamples == 0 {
 		return nil
 	}
 
-	detailParts := make([]string, 0, len(usedHours))
-	for _, h := range usedHours {
-		detailParts = append(detailParts, fmt.Sprintf("%d", h))
-	}
-	details := fmt.Sprintf("nearby hours: %s (%s)", strings.Join(detailParts, ","), bucket.DayType)
+	details := fmt.Sprintf("nearby hours: %s (%s)", strings.Join(usedSlots, ","), bucket.DayType)
 
-	agg := &store.Baseline{
-		P50:         sumP50 / float64(totalSamples),
-		P95:         sumP95 / float64(totalSamples),
-		MAD:         sumMAD / float64(totalSamples),
-		SampleCount: totalSamples,
-		UpdatedAt:   latest,
+	agg, err := mergedBaseline(ctx, bl.store, bl.cfg, set.Stats, usedKeys, m, latest)
+	if err != nil {
+		return nil
 	}
 	return &BaselineResult{
 		Baseline:      agg,
@@ -170,14 +180,15 @@ func (bl *SpanBaselineLookup) tryNearbyHours(ctx context.Context, service, spanN
 	}
 }
 
-func (bl *SpanBaselineLookup) tryDayTypeGlobal(ctx context.Context, service, spanName string, dayType string) *BaselineResult {
+func (bl *SpanBaselineLookup) tryDayTypeGlobal(ctx context.Context, service, spanName string, dayType string, set config.Settings) *BaselineResult {
 	if bl == nil || bl.store == nil || bl.cfg == nil {
 		return nil
 	}
 
-	rawKeys := make([]string, 0, 24)
-	for h := 0; h < 24; h++ {
-		rawKeys = append(rawKeys, domain.MakeSpanBaselineKey(service, spanName, domain.TimeBucket{Hour: h, DayType: dayType}))
+	buckets := bl.cfg.TimeBuckets().DayBuckets(dayType)
+	rawKeys := make([]string, 0, len(buckets))
+	for _, b := range buckets {
+		rawKeys = append(rawKeys, domain.MakeSpanBaselineKey(service, spanName, b))
 	}
 	m, err := bl.store.GetBaselines(ctx, rawKeys)
 	if err != nil || len(m) == 0 {
@@ -185,44 +196,33 @@ func (bl *SpanBaselineLookup) tryDayTypeGlobal(ctx context.Context, service, spa
 	}
 
 	var totalSamples int
-	var sumP50, sumP95, sumMAD float64
+	var usedKeys []string
 	var latest time.Time
-	var usedHours []int
-	for h := 0; h < 24; h++ {
-		k := domain.MakeSpanBaselineKey(service, spanName, domain.TimeBucket{Hour: h, DayType: dayType})
+	var usedSlots []string
+	for i, k := range rawKeys {
 		b := m[k]
 		if b == nil || b.SampleCount <= 0 {
 			continue
 		}
 		totalSamples += b.SampleCount
-		sumP50 += b.P50 * float64(b.SampleCount)
-		sumP95 += b.P95 * float64(b.SampleCount)
-		sumMAD += b.MAD * float64(b.SampleCount)
+		usedKeys = append(usedKeys, k)
 		if b.UpdatedAt.After(latest) {
 			latest = b.UpdatedAt
 		}
-		usedHours = append(usedHours, h)
+		usedSlots = append(usedSlots, buckets[i].SlotLabel())
 	}
-	if totalSamples < bl.cfg.Fallback.DayTypeGlobalMinSamples {
+	if totalSamples < set.Fallback.DayTypeGlobalMinSamples {
 		return nil
 	}
 	if totalSamples == 0 {
 		return nil
 	}
 
-	sort.Ints(usedHours)
-	parts := make([]string, 0, len(usedHours))
-	for _, h := range usedHours {
-		parts = append(parts, fmt.Sprintf("%d", h))
-	}
-	details := fmt.Sprintf("daytype=%s hours=%s", dayType, strings.Join(parts, ","))
+	details := fmt.Sprintf("daytype=%s hours=%s", dayType, strings.Join(usedSlots, ","))
 
-	agg := &store.Baseline{
-		P50:         sumP50 / float64(totalSamples),
-		P95:         sumP95 / float64(totalSamples),
-		MAD:         sumMAD / float64(totalSamples),
-		SampleCount: totalSamples,
-		UpdatedAt:   latest,
+	agg, err := mergedBaseline(ctx, bl.store, bl.cfg, set.Stats, usedKeys, m, latest)
+	if err != nil {
+		return nil
 	}
 	return &BaselineResult{
 		Baseline:      agg,
@@ -232,17 +232,15 @@ func (bl *SpanBaselineLookup) tryDayTypeGlobal(ctx context.Context, service, spa
 	}
 }
 
-func (bl *SpanBaselineLookup) tryFullGlobal(ctx context.Context, service, spanName string) *BaselineResult {
+func (bl *SpanBaselineLookup) tryFullGlobal(ctx context.Context, service, spanName string, set config.Settings) *BaselineResult {
 	if bl == nil || bl.store == nil || bl.cfg == nil {
 		return nil
 	}
 
-	dayTypes := []string{"weekday", "weekend"}
-	rawKeys := make([]string, 0, 48)
-	for _, dt := range dayTypes {
-		for h := 0; h < 24; h++ {
-			rawKeys = append(rawKeys, domain.MakeSpanBaselineKey(service, spanName, domain.TimeBucket{Hour: h, DayType: dt}))
-		}
+	buckets := bl.cfg.TimeBuckets().All()
+	rawKeys := make([]string, 0, len(buckets))
+	for _, b := range buckets {
+		rawKeys = append(rawKeys, domain.MakeSpanBaselineKey(service, spanName, b))
 	}
 	m, err := bl.store.GetBaselines(ctx, rawKeys)
 	if err != nil || len(m) == 0 {
@@ -250,7 +248,7 @@ func (bl *SpanBaselineLookup) tryFullGlobal(ctx context.Context, service, spanNa
 	}
 
 	var totalSamples int
-	var sumP50, sumP95, sumMAD float64
+	var usedKeys []string
 	var latest time.Time
 	for _, k := range rawKeys {
 		b := m[k]
@@ -258,26 +256,21 @@ func (bl *SpanBaselineLookup) tryFullGlobal(ctx context.Context, service, spanNa
 			continue
 		}
 		totalSamples += b.SampleCount
-		sumP50 += b.P50 * float64(b.SampleCount)
-		sumP95 += b.P95 * float64(b.SampleCount)
-		sumMAD += b.MAD * float64(b.SampleCount)
+		usedKeys = append(usedKeys, k)
 		if b.UpdatedAt.After(latest) {
 			latest = b.UpdatedAt
 		}
 	}
-	if totalSamples < bl.cfg.Fallback.FullGlobalMinSamples {
+	if totalSamples < set.Fallback.FullGlobalMinSamples {
 		return nil
 	}
 	if totalSamples == 0 {
 		return nil
 	}
 
-	agg := &store.Baseline{
-		P50:         sumP50 / float64(totalSamples),
-		P95:         sumP95 / float64(totalSamples),
-		MAD:         sumMAD / float64(totalSamples),
-		SampleCount: totalSamples,
-		UpdatedAt:   latest,
+	agg, err := mergedBaseline(ctx, bl.store, bl.cfg, set.Stats, usedKeys, m, latest)
+	if err != nil {
+		return nil
 	}
 	return &BaselineResult{
 		Baseline:      agg,
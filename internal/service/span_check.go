@@ -26,12 +26,15 @@ func (s *SpanCheck) Evaluate(ctx context.Context, req domain.SpanAnomalyCheckReq
 	}
 
 	timestampStr := fmt.Sprintf("%d", req.TimestampNano)
-	bucket, err := domain.ParseTimeBucket(timestampStr, s.cfg.Timezone)
+	// Buckets are in the service's local time, matching ingestion
+	tz := s.cfg.TimezoneFor(req.Service)
+	at, err := domain.LocalTime(timestampStr, tz)
 	if err != nil {
 		return domain.AnomalyCheckResponse{}, fmt.Errorf("parse time bucket: %w", err)
 	}
+	bucket := s.cfg.TimeBuckets().BucketAt(at)
 
-	res, err := s.baselineLookup.LookupWithFallback(ctx, req.Service, req.SpanName, bucket)
+	res, err := s.baselineLookup.LookupAt(ctx, req.Service, req.SpanName, bucket, at)
 	if err != nil {
 		return domain.AnomalyCheckResponse{}, fmt.Errorf("lookup baseline: %w", err)
 	}
@@ -40,7 +43,8 @@ func (s *SpanCheck) Evaluate(ctx context.Context, req domain.SpanAnomalyCheckReq
 		b = res.Baseline
 	}
 
-	resp := domain.AnomalyCheckResponse{Bucket: bucket}
+	set := s.cfg.SettingsFor(req.Service, req.SpanName)
+	resp := domain.AnomalyCheckResponse{Bucket: bucket, Timezone: tz, Rule: set.Rule}
 	if res != nil {
 		resp.BaselineSource = res.Source
 		resp.FallbackLevel = res.FallbackLevel
@@ -54,21 +58,29 @@ func (s *SpanCheck) Evaluate(ctx context.Context, req domain.SpanAnomalyCheckReq
 			MAD:         b.MAD,
 			SampleCount: b.SampleCount,
 			UpdatedAt:   b.UpdatedAt,
+			Percentiles: b.Percentiles,
+			Rejected:    b.Rejected,
 		}
 	}
 
-	if b == nil || b.SampleCount < s.cfg.Stats.MinSamples {
+	if lacksBaseline(set, b) {
 		resp.IsAnomaly = false
 		resp.Explanation = fmt.Sprintf(
 			"no baseline available or insufficient samples (have %d, need >= %d)",
 			valueOrZero(b, func(x *store.Baseline) int { return x.SampleCount }),
-			s.cfg.Stats.MinSamples,
+			set.Stats.MinSamples,
 		)
 		return resp, nil
 	}
 
-	eval := EvaluateDuration(s.cfg, req.DurationMs, b)
+	eval := evaluateDuration(s.cfg, set, req.DurationMs, b)
+	// Detectors that need no baseline decide even when the lookup found none
+	resp.CannotDetermine = false
 	resp.IsAnomaly = eval.IsAnomaly
+	resp.Detector = eval.Detector
+	resp.Score = eval.Score
+	resp.ZScore = eval.ZScore
+	resp.Severity = eval.Severity
 	resp.Explanation = eval.Explanation
 	return resp, nil
 }
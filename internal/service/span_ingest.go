@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/alexchang/tempo-latency-anomaly-service/internal/config"
 	"github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
@@ -21,12 +22,36 @@ func NewSpanIngest(store store.Store, cfg *config.Config) *SpanIngest {
 	return &SpanIngest{store: store, cfg: cfg}
 }
 
-// Spans ingests span duration samples and marks span baselines as dirty.
+// Spans ingests span duration samples and marks span baselines as dirty in one batch.
 func (s *SpanIngest) Spans(ctx context.Context, spans []tempo.SpanData) error {
 	if s == nil || s.store == nil || s.cfg == nil {
 		return fmt.Errorf("span ingest service not initialized")
 	}
 
+	batch := store.IngestBatch{WindowSize: s.cfg.WindowSize}
+	if err := s.addToBatch(ctx, &batch, spans); err != nil {
+		return err
+	}
+	if err := s.store.IngestBatch(ctx, batch); err != nil {
+		return fmt.Errorf("ingest span batch: %w", err)
+	}
+	return nil
+}
+
+// addToBatch appends a sample, sketch update, outcome and dirty mark for every span with a
+// usable name, service and time range. Invalid spans are skipped. With the ingest guard on, each
+// sample is first checked against its bucket's baseline; batch is left unchanged if
+// those baselines cannot be read.
+func (s *SpanIngest) addToBatch(ctx context.Context, batch *store.IngestBatch, spans []tempo.SpanData) error {
+	type spanSample struct {
+		span       tempo.SpanData
+		durationMs int64
+		bucket     domain.TimeBucket
+		at         time.Time
+		baseKey    string
+	}
+	samples := make([]spanSample, 0, len(spans))
+	var baseKeys []string
 	for _, span := range spans {
 		if span.ServiceName == "" || span.Name == "" {
 			continue
@@ -39,21 +64,48 @@ func (s *SpanIngest) Spans(ctx context.Context, spans []tempo.SpanData) error {
 		if err != nil || end <= start {
 			continue
 		}
-		durationMs := (end - start) / int64(1e6)
-		bucket, err := domain.ParseTimeBucket(span.StartTimeUnixNano, s.cfg.Timezone)
+		bucket, err := s.cfg.TimeBuckets().ParseTimeBucket(span.StartTimeUnixNano, s.cfg.TimezoneFor(span.ServiceName))
 		if err != nil {
 			continue
 		}
-
-		durKey := domain.MakeSpanDurationKey(span.ServiceName, span.Name, bucket)
 		baseKey := domain.MakeSpanBaselineKey(span.ServiceName, span.Name, bucket)
-		if err := s.store.AppendDuration(ctx, durKey, durationMs, s.cfg.WindowSize); err != nil {
-			return fmt.Errorf("append span duration: %w", err)
-		}
-		if err := s.store.MarkDirty(ctx, baseKey); err != nil {
-			return fmt.Errorf("mark span baseline dirty: %w", err)
+		samples = append(samples, spanSample{span: span, durationMs: (end - start) / int64(1e6), bucket: bucket, at: time.Unix(0, start), baseKey: baseKey})
+		baseKeys = append(baseKeys, baseKey)
+	}
+
+	var baselines map[string]*store.Baseline
+	if s.cfg.Guard.Enabled() && len(baseKeys) > 0 {
+		var err error
+		if baselines, err = s.store.GetBaselines(ctx, baseKeys); err != nil {
+			return fmt.Errorf("guard span baselines: %w", err)
 		}
 	}
 
+	dirty := make(map[string]bool, len(spans))
+	for _, k := range batch.DirtyKeys {
+		dirty[k] = true
+	}
+	for _, sm := range samples {
+		span := sm.span
+		// Outcomes count every span; the guard only judges durations
+		batch.Outcomes = append(batch.Outcomes, store.OutcomeSample{Key: domain.MakeSpanErrorKey(span.ServiceName, span.Name, sm.bucket), Error: span.IsError(), At: sm.at})
+		durationMs, keep, rejected := sm.durationMs, true, false
+		if baselines != nil {
+			durationMs, keep, rejected = guardSample(s.cfg, s.cfg.SettingsFor(span.ServiceName, span.Name), span.TraceID+"/"+span.SpanID, sm.durationMs, baselines[sm.baseKey])
+		}
+		if rejected {
+			batch.Rejected = append(batch.Rejected, sm.baseKey)
+		}
+		if !keep {
+			continue
+		}
+		durKey := domain.MakeSpanDurationKey(span.ServiceName, span.Name, sm.bucket)
+		batch.Samples = append(batch.Samples, store.DurationSample{Key: durKey, DurationMs: durationMs, At: sm.at})
+		batch.Sketches = append(batch.Sketches, sketchSample(domain.MakeSpanSketchKey(span.ServiceName, span.Name, sm.bucket), durationMs, sm.at))
+		if !dirty[sm.baseKey] {
+			dirty[sm.baseKey] = true
+			batch.DirtyKeys = append(batch.DirtyKeys, sm.baseKey)
+		}
+	}
 	return nil
 }
@@ -28,3 +28,29 @@ func ComputeBaseline(samples []int64) domain.BaselineStats {
     }
 }
 
+
+// BaselineFromSketch reads baseline statistics from a quantile sketch. The
+// values are within SketchRelativeAccuracy of ComputeBaseline on the same
+// samples, at a cost that does not grow with the number of samples.
+// Each of percentiles (in percent, e.g. 99.9) is added to Percentiles under its
+// PercentileName, computed with method.
+func BaselineFromSketch(s *Sketch, percentiles []float64, method PercentileMethod) domain.BaselineStats {
+    if s == nil || s.Count() == 0 {
+        return domain.BaselineStats{}
+    }
+
+    p50 := s.Median()
+    bs := domain.BaselineStats{
+        P50:         p50,
+        P95:         s.Quantile(0.95),
+        MAD:         s.MAD(p50),
+        SampleCount: int(s.Count()),
+    }
+    if len(percentiles) > 0 {
+        bs.Percentiles = make(map[string]float64, len(percentiles))
+        for _, p := range percentiles {
+            bs.Percentiles[PercentileName(p)] = s.Percentile(p/100, method)
+        }
+    }
+    return bs
+}
@@ -40,6 +40,29 @@ func TestP95_NearestRankAndBoundaries(t *testing.T) {
     assert.Equal(t, 7.0, P95([]int64{1, 7}))
 }
 
+func TestPercentile_Methods(t *testing.T) {
+    // Sorted 1..10
+    s := []int64{10, 9, 8, 7, 6, 5, 4, 3, 2, 1}
+    orig := append([]int64(nil), s...)
+
+    // Nearest rank: ceil(0.9*10)=9 → 9; ceil(0.99*10)=10 → 10
+    assert.Equal(t, 9.0, Percentile(s, 0.90, NearestRank))
+    assert.Equal(t, 10.0, Percentile(s, 0.99, NearestRank))
+    assert.Equal(t, P95(s), Percentile(s, 0.95, NearestRank))
+
+    // Linear: position 0.9*9=8.1 → 9 + 0.1*(10-9)
+    assert.InDelta(t, 9.1, Percentile(s, 0.90, Linear), 1e-9)
+    assert.InDelta(t, 5.5, Percentile(s, 0.50, Linear), 1e-9)
+    assert.Equal(t, orig, s, "Percentile must not modify input slice")
+
+    assert.Equal(t, 0.0, Percentile(nil, 0.99, Linear))
+    assert.Equal(t, "p99.9", PercentileName(99.9))
+    assert.Equal(t, "p90", PercentileName(90))
+
+    _, err := ParsePercentileMethod("cubic")
+    assert.Error(t, err)
+}
+
 func TestMAD_Computation(t *testing.T) {
     // Simple set: median=2, deviations=[1,0,1] → MAD=1
     samples := []int64{1, 2, 3}
@@ -0,0 +1,123 @@
+package stats
+
+import (
+    "math"
+    "slices"
+    "time"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
+)
+
+// WeightedSample is a duration (ms) counted with Weight instead of once.
+type WeightedSample struct {
+    DurationMs int64
+    Weight     float64
+}
+
+// DecayWeight returns the weight 2^(-age/halfLife) of a sample observed age
+// before a reference time, so a sample one half-life older than another counts
+// half as much. A halfLife <= 0 disables decay (weight 1).
+func DecayWeight(age, halfLife time.Duration) float64 {
+    if halfLife <= 0 {
+        return 1
+    }
+    return math.Exp2(-float64(age) / float64(halfLife))
+}
+
+// DecayedBaseline computes weighted baseline statistics: P50, P95, MAD and each
+// of percentiles (in percent, e.g. 99.9) are weighted quantiles, so recent
+// samples dominate once an old latency level has decayed. With equal weights
+// the result matches ComputeBaseline; extra percentiles use the nearest rank.
+// SampleCount is the number of samples regardless of their weight.
+func DecayedBaseline(samples []WeightedSample, percentiles []float64) domain.BaselineStats {
+    values := make([]decayedValue, 0, len(samples))
+    var total float64
+    for _, s := range samples {
+        if s.Weight > 0 {
+            values = append(values, decayedValue{value: float64(s.DurationMs), weight: s.Weight})
+            total += s.Weight
+        }
+    }
+    if len(values) == 0 {
+        return domain.BaselineStats{}
+    }
+    sortDecayed(values)
+
+    p50 := decayedMedian(values, total)
+    bs := domain.BaselineStats{
+        P50:         p50,
+        P95:         decayedQuantile(values, total, 0.95),
+        MAD:         decayedMAD(values, total, p50),
+        SampleCount: len(samples),
+    }
+    if len(percentiles) > 0 {
+        bs.Percentiles = make(map[string]float64, len(percentiles))
+        for _, p := range percentiles {
+            bs.Percentiles[PercentileName(p)] = decayedQuantile(values, total, p/100)
+        }
+    }
+    return bs
+}
+
+type decayedValue struct {
+    value  float64
+    weight float64
+}
+
+// decayedTolerance absorbs rounding in cumulative weights, so equal weights
+// reach a rank exactly as integer counts would.
+const decayedTolerance = 1e-9
+
+func sortDecayed(values []decayedValue) {
+    slices.SortFunc(values, func(a, b decayedValue) int {
+        switch {
+        case a.value < b.value:
+            return -1
+        case a.value > b.value:
+            return 1
+        }
+        return 0
+    })
+}
+
+// decayedQuantile returns the first of the ascending values whose cumulative
+// weight reaches q of total (the weighted nearest rank).
+func decayedQuantile(values []decayedValue, total, q float64) float64 {
+    target := q * total * (1 - decayedTolerance)
+    var cum float64
+    for _, v := range values {
+        cum += v.weight
+        if cum >= target {
+            return v.value
+        }
+    }
+    return values[len(values)-1].value
+}
+
+// decayedMedian is decayedQuantile at 0.5, averaged with the next value when
+// the cumulative weight splits exactly in half (as P50 does for an even count).
+func decayedMedian(values []decayedValue, total float64) float64 {
+    half := total / 2
+    var cum float64
+    for i, v := range values {
+        cum += v.weight
+        if cum < half*(1-decayedTolerance) {
+            continue
+        }
+        if cum <= half*(1+decayedTolerance) && i+1 < len(values) {
+            return (v.value + values[i+1].value) / 2
+        }
+        return v.value
+    }
+    return values[len(values)-1].value
+}
+
+// decayedMAD is the weighted median of |value - median|.
+func decayedMAD(values []decayedValue, total, median float64) float64 {
+    devs := make([]decayedValue, len(values))
+    for i, v := range values {
+        devs[i] = decayedValue{value: math.Abs(v.value - median), weight: v.weight}
+    }
+    sortDecayed(devs)
+    return decayedMedian(devs, total)
+}
@@ -0,0 +1,59 @@
+package stats
+
+import (
+    "math/rand/v2"
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestDecayedBaseline_EqualWeightsMatchComputeBaseline(t *testing.T) {
+    rng := rand.New(rand.NewPCG(3, 4))
+    for _, n := range []int{1, 2, 7, 100, 1001} {
+        samples := make([]int64, n)
+        weighted := make([]WeightedSample, n)
+        for i := range samples {
+            samples[i] = int64(10 + rng.IntN(500))
+            weighted[i] = WeightedSample{DurationMs: samples[i], Weight: DecayWeight(0, time.Hour)}
+        }
+        exact := ComputeBaseline(samples)
+        got := DecayedBaseline(weighted, []float64{99})
+        assert.Equal(t, exact.P50, got.P50, "n=%d", n)
+        assert.Equal(t, exact.P95, got.P95, "n=%d", n)
+        assert.Equal(t, exact.MAD, got.MAD, "n=%d", n)
+        assert.Equal(t, n, got.SampleCount)
+        assert.Equal(t, Percentile(samples, 0.99, NearestRank), got.Percentiles["p99"], "n=%d", n)
+    }
+}
+
+func TestDecayedBaseline_FollowsRecentSamples(t *testing.T) {
+    var samples []WeightedSample
+    // 30 recent samples at 300ms outweigh 70 older ones at 100ms that are three
+    // half-lives older (each counts 1/8)
+    for i := 0; i < 30; i++ {
+        samples = append(samples, WeightedSample{DurationMs: 300, Weight: DecayWeight(0, time.Hour)})
+    }
+    for i := 0; i < 70; i++ {
+        samples = append(samples, WeightedSample{DurationMs: 100, Weight: DecayWeight(3*time.Hour, time.Hour)})
+    }
+    bs := DecayedBaseline(samples, nil)
+    assert.Equal(t, 300.0, bs.P50)
+    assert.Equal(t, 0.0, bs.MAD)
+    assert.Equal(t, 100, bs.SampleCount)
+
+    // Without decay the older level still holds the median
+    raw := make([]int64, len(samples))
+    for i, s := range samples {
+        raw[i] = s.DurationMs
+    }
+    assert.Equal(t, 100.0, ComputeBaseline(raw).P50)
+}
+
+func TestDecayWeight(t *testing.T) {
+    assert.Equal(t, 1.0, DecayWeight(0, time.Hour))
+    assert.InDelta(t, 0.5, DecayWeight(time.Hour, time.Hour), 1e-12)
+    assert.InDelta(t, 0.25, DecayWeight(2*time.Hour, time.Hour), 1e-12)
+    assert.Equal(t, 1.0, DecayWeight(24*time.Hour, 0), "no half-life disables decay")
+    assert.Empty(t, DecayedBaseline(nil, nil).P50)
+}
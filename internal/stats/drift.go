@@ -0,0 +1,100 @@
+package stats
+
+import (
+    "slices"
+)
+
+// CUSUMParams configures DetectShifts. Slack and Threshold are fractions of the
+// reference level, e.g. 0.05 = 5%.
+type CUSUMParams struct {
+    // Warmup is the number of leading values whose median is the first reference level.
+    Warmup int
+    // Slack is the relative change per value that is tolerated without accumulating.
+    Slack float64
+    // Threshold is the accumulated relative excess at which a shift is reported.
+    Threshold float64
+}
+
+// Shift is a lasting change of level found by DetectShifts. Indices refer to
+// the values passed in.
+type Shift struct {
+    // Start is the first value at the new level (where the accumulation began).
+    Start int
+    // Detected is the value at which the accumulated excess passed the threshold.
+    Detected int
+    // Before is the reference level before the shift.
+    Before float64
+    // After is the median of the values from Start to Detected.
+    After float64
+    // Magnitude is the relative change (After - Before) / Before, e.g. 0.3 = +30%.
+    Magnitude float64
+}
+
+// DetectShifts runs a two-sided tabular CUSUM over values (e.g. a bucket's daily
+// p50) relative to a reference level: x = (v - ref) / ref accumulates upwards as
+// S+ = max(0, S+ + x - Slack) and downwards as S- = max(0, S- - x - Slack), and a
+// shift is reported when either passes Threshold. The reference then moves to the
+// new level and accumulation restarts, so a gradual creep is reported once it has
+// added up and a series may report several shifts. Series no longer than Warmup
+// have no shifts.
+func DetectShifts(values []float64, p CUSUMParams) []Shift {
+    warmup := max(p.Warmup, 1)
+    if len(values) <= warmup {
+        return nil
+    }
+
+    var shifts []Shift
+    ref := medianOf(values[:warmup])
+    var up, down float64
+    upStart, downStart := warmup, warmup
+    for i := warmup; i < len(values); i++ {
+        if ref <= 0 {
+            // A zero level has no relative scale; start over from the next value.
+            ref = values[i]
+            up, down = 0, 0
+            continue
+        }
+        if up == 0 {
+            upStart = i
+        }
+        if down == 0 {
+            downStart = i
+        }
+        x := (values[i] - ref) / ref
+        up = max(0, up+x-p.Slack)
+        down = max(0, down-x-p.Slack)
+
+        start := -1
+        switch {
+        case up > p.Threshold:
+            start = upStart
+        case down > p.Threshold:
+            start = downStart
+        }
+        if start < 0 {
+            continue
+        }
+        after := medianOf(values[start : i+1])
+        shifts = append(shifts, Shift{
+            Start:     start,
+            Detected:  i,
+            Before:    ref,
+            After:     after,
+            Magnitude: (after - ref) / ref,
+        })
+        ref = after
+        up, down = 0, 0
+    }
+    return shifts
+}
+
+// medianOf returns the median of values, averaging the middle two of an even count.
+func medianOf(values []float64) float64 {
+    sorted := slices.Clone(values)
+    slices.Sort(sorted)
+    n := len(sorted)
+    if n%2 == 1 {
+        return sorted[n/2]
+    }
+    return (sorted[n/2-1] + sorted[n/2]) / 2
+}
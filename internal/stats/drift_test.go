@@ -0,0 +1,63 @@
+package stats
+
+import (
+    "math/rand/v2"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+var testCUSUM = CUSUMParams{Warmup: 7, Slack: 0.05, Threshold: 0.5}
+
+func TestDetectShifts_StableSeries(t *testing.T) {
+    rng := rand.New(rand.NewPCG(1, 2))
+    values := make([]float64, 90)
+    for i := range values {
+        // ±4% daily noise stays within the slack
+        values[i] = 200 * (0.96 + 0.08*rng.Float64())
+    }
+    assert.Empty(t, DetectShifts(values, testCUSUM))
+    assert.Nil(t, DetectShifts(values[:7], testCUSUM), "warmup only")
+}
+
+func TestDetectShifts_Step(t *testing.T) {
+    values := []float64{100, 101, 99, 100, 102, 98, 100, 100, 99, 101, 140, 141, 139, 140, 140, 140}
+    shifts := DetectShifts(values, testCUSUM)
+    if assert.Len(t, shifts, 1) {
+        s := shifts[0]
+        assert.Equal(t, 10, s.Start)
+        assert.Equal(t, 11, s.Detected)
+        assert.Equal(t, 100.0, s.Before)
+        assert.Equal(t, 140.5, s.After)
+        assert.InDelta(t, 0.405, s.Magnitude, 1e-9)
+    }
+
+    // Downward shifts are reported with a negative magnitude
+    down := []float64{100, 100, 100, 100, 100, 100, 100, 60, 60, 60}
+    shifts = DetectShifts(down, testCUSUM)
+    if assert.Len(t, shifts, 1) {
+        assert.Equal(t, 7, shifts[0].Start)
+        assert.InDelta(t, -0.4, shifts[0].Magnitude, 1e-9)
+    }
+}
+
+func TestDetectShifts_Creep(t *testing.T) {
+    // p50 creeps up 30% over a week, then stays there
+    values := []float64{100, 100, 100, 100, 100, 100, 100}
+    for d := 1; d <= 7; d++ {
+        values = append(values, 100+30*float64(d)/7)
+    }
+    for d := 0; d < 14; d++ {
+        values = append(values, 130)
+    }
+    shifts := DetectShifts(values, testCUSUM)
+    if assert.NotEmpty(t, shifts) {
+        s := shifts[0]
+        // The first day of the creep (+4.3%) is still within the slack
+        assert.Equal(t, 8, s.Start)
+        assert.LessOrEqual(t, s.Detected, 13, "detected before the creep ends")
+        assert.Greater(t, s.Magnitude, 0.1)
+    }
+    last := shifts[len(shifts)-1]
+    assert.InDelta(t, 130, last.After, 10, "the reference follows the new level")
+}
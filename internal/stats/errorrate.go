@@ -0,0 +1,26 @@
+package stats
+
+import "math"
+
+// WilsonInterval returns the Wilson score interval of the ratio errors/total at
+// z standard deviations, e.g. z = 1.96 for 95%. Unlike the normal approximation
+// it stays within [0, 1] and remains usable for ratios near 0 and small totals.
+// A zero total has no information and yields [0, 1].
+func WilsonInterval(errors, total int64, z float64) (lower, upper float64) {
+    if total <= 0 {
+        return 0, 1
+    }
+    if errors < 0 {
+        errors = 0
+    }
+    if errors > total {
+        errors = total
+    }
+    n := float64(total)
+    p := float64(errors) / n
+    z2 := z * z
+    denom := 1 + z2/n
+    center := (p + z2/(2*n)) / denom
+    half := z / denom * math.Sqrt(p*(1-p)/n+z2/(4*n*n))
+    return math.Max(0, center-half), math.Min(1, center+half)
+}
@@ -0,0 +1,38 @@
+package stats
+
+import (
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestWilsonInterval(t *testing.T) {
+    // Reference values for 10/100 at 95%
+    lower, upper := WilsonInterval(10, 100, 1.96)
+    assert.InDelta(t, 0.0552, lower, 1e-4)
+    assert.InDelta(t, 0.1744, upper, 1e-4)
+
+    // No errors still leaves room above 0
+    lower, upper = WilsonInterval(0, 50, 1.96)
+    assert.Equal(t, 0.0, lower)
+    assert.InDelta(t, 0.0714, upper, 1e-4)
+
+    // All errors
+    lower, upper = WilsonInterval(20, 20, 1.96)
+    assert.InDelta(t, 0.8389, lower, 1e-4)
+    assert.InDelta(t, 1.0, upper, 1e-9)
+
+    lower, upper = WilsonInterval(0, 0, 1.96)
+    assert.Equal(t, 0.0, lower)
+    assert.Equal(t, 1.0, upper)
+}
+
+func TestWilsonInterval_NarrowsWithTotal(t *testing.T) {
+    l1, u1 := WilsonInterval(5, 50, 3)
+    l2, u2 := WilsonInterval(500, 5000, 3)
+    assert.Less(t, u2-l2, u1-l1)
+    assert.Less(t, l1, 0.1)
+    assert.Greater(t, u1, 0.1)
+    assert.Less(t, l2, 0.1)
+    assert.Greater(t, u2, 0.1)
+}
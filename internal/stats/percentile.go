@@ -1,8 +1,10 @@
 package stats
 
 import (
+    "fmt"
     "math"
     "sort"
+    "strconv"
 )
 
 // P50 computes the median (50th percentile) of the given samples.
@@ -51,3 +53,64 @@ func P95(samples []int64) float64 {
     return float64(arr[idx])
 }
 
+
+// PercentileMethod selects how Percentile picks a value when the requested rank
+// falls between two samples.
+type PercentileMethod string
+
+const (
+    // NearestRank returns the sample at rank ceil(q*n), like P95.
+    NearestRank PercentileMethod = "nearest_rank"
+    // Linear interpolates between the two samples around the 0-based rank q*(n-1).
+    Linear PercentileMethod = "linear"
+)
+
+// ParsePercentileMethod parses a method name; an empty string selects NearestRank.
+func ParsePercentileMethod(s string) (PercentileMethod, error) {
+    switch PercentileMethod(s) {
+    case "", NearestRank:
+        return NearestRank, nil
+    case Linear:
+        return Linear, nil
+    default:
+        return "", fmt.Errorf("invalid percentile method %q (want nearest_rank or linear)", s)
+    }
+}
+
+// Percentile computes the q-quantile (0 < q <= 1) of the given samples using method.
+// It does not modify the input slice.
+func Percentile(samples []int64, q float64, method PercentileMethod) float64 {
+    n := len(samples)
+    if n == 0 {
+        return 0
+    }
+
+    arr := make([]int64, n)
+    copy(arr, samples)
+    sort.Slice(arr, func(i, j int) bool { return arr[i] < arr[j] })
+
+    if method == Linear {
+        pos := q * float64(n-1)
+        lo := int(math.Floor(pos))
+        hi := int(math.Ceil(pos))
+        lo = min(max(lo, 0), n-1)
+        hi = min(max(hi, 0), n-1)
+        frac := pos - math.Floor(pos)
+        return float64(arr[lo]) + frac*float64(arr[hi]-arr[lo])
+    }
+
+    return float64(arr[nearestRank(q, int64(n))-1])
+}
+
+// nearestRank returns the 1-based rank of the q-quantile among n samples. q*n
+// tolerates float error, so 99.9/100 (0.9990000000000001) of 5000 is rank 4995.
+func nearestRank(q float64, n int64) int64 {
+    rank := int64(math.Ceil(q*float64(n) - 1e-9))
+    return min(max(rank, 1), n)
+}
+
+// PercentileName returns the name a percentile p (in percent, e.g. 99.9) is
+// reported and stored under, e.g. "p99.9".
+func PercentileName(p float64) string {
+    return "p" + strconv.FormatFloat(p, 'f', -1, 64)
+}
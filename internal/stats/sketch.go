@@ -0,0 +1,189 @@
+package stats
+
+import (
+    "math"
+    "slices"
+)
+
+// SketchRelativeAccuracy bounds the relative error of every value read from a
+// Sketch: a quantile q is reported within 1% of the sample at rank q.
+const SketchRelativeAccuracy = 0.01
+
+// ZeroBin is the bin of durations that round to 0ms, which the logarithmic
+// mapping cannot represent.
+const ZeroBin = math.MinInt32
+
+var (
+    sketchGamma    = (1 + SketchRelativeAccuracy) / (1 - SketchRelativeAccuracy)
+    sketchLogGamma = math.Log(sketchGamma)
+)
+
+// Sketch is a mergeable quantile sketch in the style of DDSketch. Positive values
+// are counted in logarithmically sized bins (bin i covers (γ^(i-1), γ^i]), so the
+// sketch size depends on the value range rather than the number of samples and
+// two sketches merge by adding their bin counts.
+type Sketch struct {
+    bins  map[int32]int64
+    count int64
+}
+
+// NewSketch returns an empty sketch.
+func NewSketch() *Sketch {
+    return &Sketch{bins: make(map[int32]int64)}
+}
+
+// SketchBin returns the bin a duration (ms) is counted in.
+func SketchBin(durationMs int64) int32 {
+    if durationMs <= 0 {
+        return ZeroBin
+    }
+    return int32(math.Ceil(math.Log(float64(durationMs)) / sketchLogGamma))
+}
+
+// binValue returns the representative value of bin: the point with equal
+// relative distance to both bin bounds.
+func binValue(bin int32) float64 {
+    if bin == ZeroBin {
+        return 0
+    }
+    return 2 * math.Pow(sketchGamma, float64(bin)) / (sketchGamma + 1)
+}
+
+// Add counts one duration (ms).
+func (s *Sketch) Add(durationMs int64) {
+    s.AddBin(SketchBin(durationMs), 1)
+}
+
+// AddBin adds n samples to bin.
+func (s *Sketch) AddBin(bin int32, n int64) {
+    if n <= 0 {
+        return
+    }
+    s.bins[bin] += n
+    s.count += n
+}
+
+// MergeBins adds serialized bin counts (see Bins) to the sketch.
+func (s *Sketch) MergeBins(bins map[int32]int64) {
+    for bin, n := range bins {
+        s.AddBin(bin, n)
+    }
+}
+
+// Merge adds all samples of o to the sketch.
+func (s *Sketch) Merge(o *Sketch) {
+    if o == nil {
+        return
+    }
+    s.MergeBins(o.bins)
+}
+
+// Count returns the number of samples in the sketch.
+func (s *Sketch) Count() int64 {
+    return s.count
+}
+
+// Bins returns a copy of the bin counts, e.g. to persist the sketch.
+func (s *Sketch) Bins() map[int32]int64 {
+    out := make(map[int32]int64, len(s.bins))
+    for bin, n := range s.bins {
+        out[bin] = n
+    }
+    return out
+}
+
+// Quantile returns the q-quantile (0..1) using the nearest-rank method,
+// matching P95 on the raw samples. An empty sketch returns 0.
+func (s *Sketch) Quantile(q float64) float64 {
+    if s.count == 0 {
+        return 0
+    }
+    return valueAtRank(s.sortedBins(), nearestRank(q, s.count))
+}
+
+// Percentile returns the q-quantile (0..1) using method. For Linear the values
+// of the two ranks around q*(n-1) are interpolated.
+func (s *Sketch) Percentile(q float64, method PercentileMethod) float64 {
+    if method != Linear || s.count == 0 {
+        return s.Quantile(q)
+    }
+    values := s.sortedBins()
+    pos := q * float64(s.count-1)
+    lo := min(max(int64(math.Floor(pos)), 0), s.count-1)
+    hi := min(max(int64(math.Ceil(pos)), 0), s.count-1)
+    a, b := valueAtRank(values, lo+1), valueAtRank(values, hi+1)
+    return a + (pos-math.Floor(pos))*(b-a)
+}
+
+// Median returns the median, averaging the two middle values for an even
+// count like P50 does on the raw samples.
+func (s *Sketch) Median() float64 {
+    return weightedMedian(s.sortedBins(), s.count)
+}
+
+// MAD approximates the median absolute deviation around median. Every sample
+// is represented by its bin value, so the deviations inherit the bin accuracy.
+func (s *Sketch) MAD(median float64) float64 {
+    if s.count == 0 {
+        return 0
+    }
+    devs := make([]weightedValue, 0, len(s.bins))
+    for bin, n := range s.bins {
+        devs = append(devs, weightedValue{value: math.Abs(binValue(bin) - median), n: n})
+    }
+    slices.SortFunc(devs, func(a, b weightedValue) int {
+        switch {
+        case a.value < b.value:
+            return -1
+        case a.value > b.value:
+            return 1
+        }
+        return 0
+    })
+    return weightedMedian(devs, s.count)
+}
+
+type weightedValue struct {
+    value float64
+    n     int64
+}
+
+// sortedBins returns the bin values with their counts in ascending order.
+func (s *Sketch) sortedBins() []weightedValue {
+    keys := make([]int32, 0, len(s.bins))
+    for bin := range s.bins {
+        keys = append(keys, bin)
+    }
+    slices.Sort(keys)
+    out := make([]weightedValue, len(keys))
+    for i, bin := range keys {
+        out[i] = weightedValue{value: binValue(bin), n: s.bins[bin]}
+    }
+    return out
+}
+
+// valueAtRank returns the value of the sample at 1-based rank in sorted values.
+func valueAtRank(values []weightedValue, rank int64) float64 {
+    var seen int64
+    for _, v := range values {
+        seen += v.n
+        if seen >= rank {
+            return v.value
+        }
+    }
+    if len(values) == 0 {
+        return 0
+    }
+    return values[len(values)-1].value
+}
+
+func weightedMedian(values []weightedValue, count int64) float64 {
+    if count == 0 {
+        return 0
+    }
+    mid := count/2 + 1
+    if count%2 == 1 {
+        return valueAtRank(values, mid)
+    }
+    return (valueAtRank(values, mid-1) + valueAtRank(values, mid)) / 2.0
+}
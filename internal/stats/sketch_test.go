@@ -0,0 +1,66 @@
+package stats
+
+import (
+    "math/rand/v2"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestSketch_MatchesExactStatsWithinAccuracy(t *testing.T) {
+    rng := rand.New(rand.NewPCG(1, 2))
+    samples := make([]int64, 5000)
+    sk := NewSketch()
+    for i := range samples {
+        samples[i] = int64(50 + rng.ExpFloat64()*200)
+        sk.Add(samples[i])
+    }
+
+    exact := ComputeBaseline(samples)
+    got := BaselineFromSketch(sk, []float64{90, 99.9}, NearestRank)
+    assert.Equal(t, exact.SampleCount, got.SampleCount)
+    assert.InEpsilon(t, exact.P50, got.P50, SketchRelativeAccuracy)
+    assert.InEpsilon(t, exact.P95, got.P95, SketchRelativeAccuracy)
+    assert.InEpsilon(t, Percentile(samples, 0.90, NearestRank), got.Percentiles["p90"], SketchRelativeAccuracy)
+    assert.InEpsilon(t, Percentile(samples, 0.999, NearestRank), got.Percentiles["p99.9"], SketchRelativeAccuracy)
+    assert.InEpsilon(t, Percentile(samples, 0.99, Linear), sk.Percentile(0.99, Linear), SketchRelativeAccuracy)
+    // Deviations inherit the absolute error of the values they are taken from.
+    assert.InDelta(t, exact.MAD, got.MAD, 2*SketchRelativeAccuracy*exact.P95)
+}
+
+func TestSketch_MergeEqualsCombinedSketch(t *testing.T) {
+    a, b, all := NewSketch(), NewSketch(), NewSketch()
+    for i := int64(0); i < 100; i++ {
+        a.Add(i * 3)
+        b.Add(i*7 + 1)
+        all.Add(i * 3)
+        all.Add(i*7 + 1)
+    }
+
+    merged := NewSketch()
+    merged.Merge(a)
+    merged.MergeBins(b.Bins())
+    assert.Equal(t, all.Bins(), merged.Bins())
+    assert.Equal(t, int64(200), merged.Count())
+    assert.Equal(t, all.Quantile(0.95), merged.Quantile(0.95))
+}
+
+func TestSketch_EdgeCases(t *testing.T) {
+    empty := NewSketch()
+    assert.Equal(t, 0.0, empty.Quantile(0.5))
+    assert.Equal(t, 0, BaselineFromSketch(empty, nil, NearestRank).SampleCount)
+
+    // 0ms samples have their own bin and read back as exactly 0
+    zeros := NewSketch()
+    zeros.Add(0)
+    zeros.Add(0)
+    zeros.Add(100)
+    assert.Equal(t, 0.0, zeros.Median())
+    assert.InEpsilon(t, 100.0, zeros.Quantile(1), SketchRelativeAccuracy)
+
+    // Even count averages the two middle values like P50
+    even := NewSketch()
+    even.Add(100)
+    even.Add(300)
+    assert.InEpsilon(t, 200.0, even.Median(), SketchRelativeAccuracy)
+}
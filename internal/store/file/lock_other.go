@@ -0,0 +1,19 @@
+//go:build !unix
+
+package file
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// lockDir creates the LOCK file in dir. Platforms without flock get no
+// protection against a second process opening the same directory.
+func lockDir(dir string) (*os.File, error) {
+	return os.OpenFile(filepath.Join(dir, lockFile), os.O_CREATE|os.O_RDWR, 0o644)
+}
+
+// unlockDir releases a lock taken by lockDir.
+func unlockDir(f *os.File) error {
+	return f.Close()
+}
@@ -0,0 +1,263 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+type appendDurationArgs struct {
+	Key        string    `json:"key"`
+	DurationMs int64     `json:"v"`
+	At         time.Time `json:"at"`
+	WindowSize int       `json:"w"`
+}
+
+type pruneDurationsArgs struct {
+	Before time.Time `json:"before"`
+}
+
+type replaceSamplesArgs struct {
+	Key        string         `json:"key"`
+	Samples    []store.Sample `json:"s"`
+	WindowSize int            `json:"w"`
+}
+
+type replaceSketchArgs struct {
+	Key    string              `json:"key"`
+	Slices []store.SketchSlice `json:"s"`
+}
+
+type setBaselineArgs struct {
+	Key      string         `json:"key"`
+	Baseline store.Baseline `json:"b"`
+}
+
+type recordHistoryArgs struct {
+	Key      string                 `json:"key"`
+	Snapshot store.BaselineSnapshot `json:"s"`
+	Before   time.Time              `json:"before"`
+}
+
+type markSeenArgs struct {
+	TraceID string        `json:"id"`
+	TTL     time.Duration `json:"ttl"`
+}
+
+type markDirtyArgs struct {
+	Key string `json:"key"`
+}
+
+type claimDirtyArgs struct {
+	Count int64         `json:"n"`
+	Lease time.Duration `json:"lease"`
+}
+
+type ackDirtyArgs struct {
+	Keys []string `json:"keys"`
+}
+
+type nackDirtyArgs struct {
+	Key        string `json:"key"`
+	MaxRetries int    `json:"max"`
+}
+
+// AppendDuration appends a sample and records it in the WAL.
+func (s *Store) AppendDuration(ctx context.Context, key string, durationMs int64, at time.Time, windowSize int) error {
+	return s.logged(ctx, opAppendDuration, appendDurationArgs{Key: key, DurationMs: durationMs, At: at, WindowSize: windowSize}, func() error {
+		return s.Store.AppendDuration(ctx, key, durationMs, at, windowSize)
+	})
+}
+
+// PruneDurations drops expired samples and records the cut-off in the WAL.
+func (s *Store) PruneDurations(ctx context.Context, before time.Time) ([]string, error) {
+	var pruned []string
+	err := s.logged(ctx, opPruneDurations, pruneDurationsArgs{Before: before}, func() error {
+		var err error
+		pruned, err = s.Store.PruneDurations(ctx, before)
+		return err
+	})
+	return pruned, err
+}
+
+// ReplaceSamples replaces a window and records the new contents in the WAL.
+func (s *Store) ReplaceSamples(ctx context.Context, key string, samples []store.Sample, windowSize int) error {
+	return s.logged(ctx, opReplaceSamples, replaceSamplesArgs{Key: key, Samples: samples, WindowSize: windowSize}, func() error {
+		return s.Store.ReplaceSamples(ctx, key, samples, windowSize)
+	})
+}
+
+// ReplaceSketch replaces a sketch and records the new contents in the WAL.
+func (s *Store) ReplaceSketch(ctx context.Context, key string, slices []store.SketchSlice) error {
+	return s.logged(ctx, opReplaceSketch, replaceSketchArgs{Key: key, Slices: slices}, func() error {
+		return s.Store.ReplaceSketch(ctx, key, slices)
+	})
+}
+
+// IngestBatch applies a batch and records it as a single WAL line, so a torn
+// write at crash time drops the whole batch rather than part of it.
+func (s *Store) IngestBatch(ctx context.Context, b store.IngestBatch) error {
+	return s.logged(ctx, opIngestBatch, b, func() error {
+		return s.Store.IngestBatch(ctx, b)
+	})
+}
+
+// SetBaseline stores a baseline and records it in the WAL.
+func (s *Store) SetBaseline(ctx context.Context, key string, b store.Baseline) error {
+	return s.logged(ctx, opSetBaseline, setBaselineArgs{Key: key, Baseline: b}, func() error {
+		return s.Store.SetBaseline(ctx, key, b)
+	})
+}
+
+// RecordBaselineHistory stores a history entry and records it in the WAL.
+func (s *Store) RecordBaselineHistory(ctx context.Context, key string, snap store.BaselineSnapshot, before time.Time) error {
+	return s.logged(ctx, opRecordHistory, recordHistoryArgs{Key: key, Snapshot: snap, Before: before}, func() error {
+		return s.Store.RecordBaselineHistory(ctx, key, snap, before)
+	})
+}
+
+// IsDuplicateOrMark deduplicates traceID; only new marks are recorded in the WAL.
+func (s *Store) IsDuplicateOrMark(ctx context.Context, traceID string, ttl time.Duration) (bool, error) {
+	var dup bool
+	seen := func() (bool, error) { return s.Store.Seen(ctx, traceID), nil }
+	err := s.loggedUnless(ctx, opMarkSeen, markSeenArgs{TraceID: traceID, TTL: ttl}, seen, func() error {
+		var err error
+		dup, err = s.Store.IsDuplicateOrMark(ctx, traceID, ttl)
+		return err
+	})
+	return dup, err
+}
+
+// MarkDirty marks key dirty and records it in the WAL.
+func (s *Store) MarkDirty(ctx context.Context, key string) error {
+	return s.logged(ctx, opMarkDirty, markDirtyArgs{Key: key}, func() error {
+		return s.Store.MarkDirty(ctx, key)
+	})
+}
+
+// ClaimDirtyBatch leases dirty keys and records the claim in the WAL.
+// Claims are FIFO in the memory store and replayed at the recorded time,
+// so replaying the count and lease reproduces the same leases.
+func (s *Store) ClaimDirtyBatch(ctx context.Context, count int64, lease time.Duration) ([]string, error) {
+	var keys []string
+	// A claim on an empty queue without leases to re-queue changes nothing
+	idle := func() (bool, error) {
+		st, err := s.Store.DirtyQueueStats(ctx)
+		return st.Pending == 0 && st.Leased == 0, err
+	}
+	err := s.loggedUnless(ctx, opClaimDirty, claimDirtyArgs{Count: count, Lease: lease}, idle, func() error {
+		var err error
+		keys, err = s.Store.ClaimDirtyBatch(ctx, count, lease)
+		return err
+	})
+	return keys, err
+}
+
+// AckDirty acknowledges processed keys and records the ack in the WAL.
+func (s *Store) AckDirty(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return s.logged(ctx, opAckDirty, ackDirtyArgs{Keys: keys}, func() error {
+		return s.Store.AckDirty(ctx, keys...)
+	})
+}
+
+// NackDirty re-queues or dead-letters a failed key and records it in the WAL.
+func (s *Store) NackDirty(ctx context.Context, key string, maxRetries int) (bool, error) {
+	var dead bool
+	err := s.logged(ctx, opNackDirty, nackDirtyArgs{Key: key, MaxRetries: maxRetries}, func() error {
+		var err error
+		dead, err = s.Store.NackDirty(ctx, key, maxRetries)
+		return err
+	})
+	return dead, err
+}
+
+// apply re-executes a WAL record against the embedded memory store.
+func (s *Store) apply(rec record) error {
+	ctx := store.WithTenant(context.Background(), rec.Tenant)
+	switch rec.Op {
+	case opAppendDuration:
+		var a appendDurationArgs
+		if err := json.Unmarshal(rec.Args, &a); err != nil {
+			return err
+		}
+		return s.Store.AppendDuration(ctx, a.Key, a.DurationMs, a.At, a.WindowSize)
+	case opPruneDurations:
+		var a pruneDurationsArgs
+		if err := json.Unmarshal(rec.Args, &a); err != nil {
+			return err
+		}
+		_, err := s.Store.PruneDurations(ctx, a.Before)
+		return err
+	case opReplaceSamples:
+		var a replaceSamplesArgs
+		if err := json.Unmarshal(rec.Args, &a); err != nil {
+			return err
+		}
+		return s.Store.ReplaceSamples(ctx, a.Key, a.Samples, a.WindowSize)
+	case opReplaceSketch:
+		var a replaceSketchArgs
+		if err := json.Unmarshal(rec.Args, &a); err != nil {
+			return err
+		}
+		return s.Store.ReplaceSketch(ctx, a.Key, a.Slices)
+	case opIngestBatch:
+		var b store.IngestBatch
+		if err := json.Unmarshal(rec.Args, &b); err != nil {
+			return err
+		}
+		return s.Store.IngestBatch(ctx, b)
+	case opSetBaseline:
+		var a setBaselineArgs
+		if err := json.Unmarshal(rec.Args, &a); err != nil {
+			return err
+		}
+		return s.Store.SetBaseline(ctx, a.Key, a.Baseline)
+	case opRecordHistory:
+		var a recordHistoryArgs
+		if err := json.Unmarshal(rec.Args, &a); err != nil {
+			return err
+		}
+		return s.Store.RecordBaselineHistory(ctx, a.Key, a.Snapshot, a.Before)
+	case opMarkSeen:
+		var a markSeenArgs
+		if err := json.Unmarshal(rec.Args, &a); err != nil {
+			return err
+		}
+		_, err := s.Store.IsDuplicateOrMark(ctx, a.TraceID, a.TTL)
+		return err
+	case opMarkDirty:
+		var a markDirtyArgs
+		if err := json.Unmarshal(rec.Args, &a); err != nil {
+			return err
+		}
+		return s.Store.MarkDirty(ctx, a.Key)
+	case opClaimDirty:
+		var a claimDirtyArgs
+		if err := json.Unmarshal(rec.Args, &a); err != nil {
+			return err
+		}
+		_, err := s.Store.ClaimDirtyBatch(ctx, a.Count, a.Lease)
+		return err
+	case opAckDirty:
+		var a ackDirtyArgs
+		if err := json.Unmarshal(rec.Args, &a); err != nil {
+			return err
+		}
+		return s.Store.AckDirty(ctx, a.Keys...)
+	case opNackDirty:
+		var a nackDirtyArgs
+		if err := json.Unmarshal(rec.Args, &a); err != nil {
+			return err
+		}
+		_, err := s.Store.NackDirty(ctx, a.Key, a.MaxRetries)
+		return err
+	default:
+		return fmt.Errorf("unknown operation %q", rec.Op)
+	}
+}
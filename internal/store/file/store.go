@@ -0,0 +1,252 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/store/memory"
+)
+
+const (
+	snapshotFile = "snapshot.json"
+	walFile      = "wal.log"
+	lockFile     = "LOCK"
+
+	// compactEvery is the number of WAL records after which the log is folded into a new snapshot.
+	compactEvery = 50000
+)
+
+// Store implements store.Store on top of the in-memory store and persists it
+// under a data directory as a JSON snapshot plus an append-only operation log (WAL).
+//
+// Reads are served from memory. Every mutation is appended to the WAL together
+// with the time it happens and synced before it is applied in memory, so replaying
+// the log on startup reproduces every acknowledged write, including TTL-dependent
+// state (dedup marks), exactly.
+// The WAL is folded into a fresh snapshot on open, on close and every compactEvery records.
+// One process at a time may open a data directory; Open fails while another holds it.
+type Store struct {
+	*memory.Store
+
+	mu      sync.Mutex
+	dir     string
+	lock    *os.File
+	wal     *os.File
+	walSize int64
+	records int
+
+	// The embedded memory store reads time from s.now. While an operation is
+	// applied (live or replayed) the clock is pinned to the time recorded in the WAL.
+	clockMu  sync.Mutex
+	pinnedAt time.Time
+}
+
+// Open loads (or creates) a file-backed store in dir.
+func Open(dir string) (*Store, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("file store: data directory is required")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("file store: create data dir: %w", err)
+	}
+
+	lock, err := lockDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{dir: dir, lock: lock}
+	s.Store = memory.NewWithClock(s.now)
+	if err := s.open(); err != nil {
+		_ = unlockDir(lock)
+		return nil, err
+	}
+	return s, nil
+}
+
+// open loads the snapshot, replays the WAL and compacts both into a new snapshot.
+func (s *Store) open() error {
+	if err := s.loadSnapshot(); err != nil {
+		return err
+	}
+	if err := s.replayWAL(); err != nil {
+		return err
+	}
+	// Data written before the v2 key layout is renamed in place; compaction below persists it.
+	if n := s.Store.RewriteKeys(domain.MigrateV1Key); n > 0 {
+		log.Printf("file store: migrated %d keys to key layout v%d", n, domain.KeyVersion)
+	}
+	// Fold the replayed log into a fresh snapshot so the next start is fast.
+	return s.compactLocked()
+}
+
+// Close writes a final snapshot and closes the WAL.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.wal == nil {
+		return nil
+	}
+	err := s.compactLocked()
+	if cerr := s.wal.Close(); err == nil {
+		err = cerr
+	}
+	s.wal = nil
+	if uerr := unlockDir(s.lock); err == nil {
+		err = uerr
+	}
+	return err
+}
+
+func (s *Store) now() time.Time {
+	s.clockMu.Lock()
+	defer s.clockMu.Unlock()
+	if !s.pinnedAt.IsZero() {
+		return s.pinnedAt
+	}
+	return time.Now()
+}
+
+func (s *Store) pinClock(t time.Time) {
+	s.clockMu.Lock()
+	s.pinnedAt = t
+	s.clockMu.Unlock()
+}
+
+func (s *Store) loadSnapshot() error {
+	b, err := os.ReadFile(filepath.Join(s.dir, snapshotFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("file store: read snapshot: %w", err)
+	}
+	var snap memory.Snapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return fmt.Errorf("file store: decode snapshot: %w", err)
+	}
+	s.Store.Restore(snap)
+	return nil
+}
+
+func (s *Store) replayWAL() error {
+	f, err := os.Open(filepath.Join(s.dir, walFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("file store: open wal: %w", err)
+	}
+	defer f.Close()
+	defer s.pinClock(time.Time{})
+
+	r := bufio.NewReader(f)
+	applied := 0
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var rec record
+			if derr := json.Unmarshal(line, &rec); derr != nil {
+				// A torn final write after a crash is expected; anything after it is ignored.
+				log.Printf("file store: stopping wal replay at corrupt record %d: %v", applied+1, derr)
+				break
+			}
+			s.pinClock(time.Unix(0, rec.At))
+			if aerr := s.apply(rec); aerr != nil {
+				return fmt.Errorf("file store: replay record %d (%s): %w", applied+1, rec.Op, aerr)
+			}
+			applied++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("file store: read wal: %w", err)
+		}
+	}
+	if applied > 0 {
+		log.Printf("file store: replayed %d wal records from %s", applied, s.dir)
+	}
+	return nil
+}
+
+// compactLocked writes the current state to a new snapshot and starts an empty WAL.
+// Callers must hold s.mu (or have exclusive access during Open).
+func (s *Store) compactLocked() error {
+	snap := s.Store.Snapshot()
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("file store: encode snapshot: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(s.dir, snapshotFile), b); err != nil {
+		return fmt.Errorf("file store: write snapshot: %w", err)
+	}
+
+	if s.wal != nil {
+		_ = s.wal.Close()
+		s.wal = nil
+	}
+	wal, err := os.OpenFile(filepath.Join(s.dir, walFile), os.O_CREATE|os.O_TRUNC|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("file store: open wal: %w", err)
+	}
+	// A freshly created WAL must survive a crash along with its records
+	if err := syncDir(s.dir); err != nil {
+		_ = wal.Close()
+		return fmt.Errorf("file store: sync data dir: %w", err)
+	}
+	s.wal = wal
+	s.walSize = 0
+	s.records = 0
+	return nil
+}
+
+// writeFileAtomic writes data to a temp file, fsyncs it, renames it over path and
+// fsyncs the directory so the rename itself is durable.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir fsyncs a directory, persisting the creation, rename or removal of its entries.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return err
+	}
+	return d.Close()
+}
+
+var _ store.Store = (*Store)(nil)
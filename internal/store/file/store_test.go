@@ -0,0 +1,243 @@
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// crash drops s like a killed process would: without a final snapshot, leaving
+// the WAL as written and releasing the directory lock.
+func crash(t *testing.T, s *Store) {
+	t.Helper()
+	require.NoError(t, s.wal.Close())
+	require.NoError(t, unlockDir(s.lock))
+}
+
+func TestStore_SurvivesReopen(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s, err := Open(dir)
+	require.NoError(t, err)
+
+	for i := int64(1); i <= 4; i++ {
+		require.NoError(t, s.AppendDuration(ctx, "v2:dur:svc|ep|9|weekday", i*10, time.Time{}, 3))
+	}
+	require.NoError(t, s.AppendDuration(ctx, "v2:spandur:svc|span|9|weekday", 7, time.Time{}, 10))
+	require.NoError(t, s.SetBaseline(ctx, "v2:base:svc|ep|9|weekday", store.Baseline{P50: 20, P95: 40, MAD: 5, SampleCount: 3}))
+	require.NoError(t, s.SetBaseline(ctx, "v2:spanbase:svc|span|9|weekday", store.Baseline{P50: 7, SampleCount: 1}))
+	require.NoError(t, s.MarkDirty(ctx, "v2:base:svc|ep|9|weekday"))
+	require.NoError(t, s.MarkDirty(ctx, "v2:spanbase:svc|span|9|weekday"))
+	claimed, err := s.ClaimDirtyBatch(ctx, 1, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v2:base:svc|ep|9|weekday"}, claimed)
+	require.NoError(t, s.AckDirty(ctx, claimed...))
+	dup, err := s.IsDuplicateOrMark(ctx, "trace-1", time.Hour)
+	require.NoError(t, err)
+	assert.False(t, dup)
+
+	// Simulate a crash: drop the handle without Close so state lives only in the WAL.
+	crash(t, s)
+
+	s2, err := Open(dir)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s2.Close() })
+
+	durs, err := s2.GetDurations(ctx, "v2:dur:svc|ep|9|weekday")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{40, 30, 20}, durs)
+
+	b, err := s2.GetBaseline(ctx, "v2:base:svc|ep|9|weekday")
+	require.NoError(t, err)
+	if assert.NotNil(t, b) {
+		assert.Equal(t, 40.0, b.P95)
+		assert.Equal(t, 3, b.SampleCount)
+	}
+	sb, err := s2.GetBaseline(ctx, "v2:spanbase:svc|span|9|weekday")
+	require.NoError(t, err)
+	assert.NotNil(t, sb)
+
+	dirty, err := s2.ClaimDirtyBatch(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v2:spanbase:svc|span|9|weekday"}, dirty)
+
+	dup, err = s2.IsDuplicateOrMark(ctx, "trace-1", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, dup, "dedup mark must survive a restart")
+}
+
+func TestStore_UnackedClaimIsRequeuedAfterCrash(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s, err := Open(dir)
+	require.NoError(t, err)
+	require.NoError(t, s.MarkDirty(ctx, "base:a"))
+	claimed, err := s.ClaimDirtyBatch(ctx, 10, time.Nanosecond)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"base:a"}, claimed)
+	crash(t, s)
+
+	s2, err := Open(dir)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s2.Close() })
+
+	st, err := s2.DirtyQueueStats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), st.Leased)
+
+	keys, err := s2.ClaimDirtyBatch(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"base:a"}, keys, "expired lease is claimable again")
+}
+
+func TestStore_IgnoresTornWALTail(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s, err := Open(dir)
+	require.NoError(t, err)
+	require.NoError(t, s.AppendDuration(ctx, "dur:a", 1, time.Time{}, 10))
+	_, err = s.wal.WriteString(`{"t":1,"op":"appendDur`)
+	require.NoError(t, err)
+	crash(t, s)
+
+	s2, err := Open(dir)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s2.Close() })
+
+	durs, err := s2.GetDurations(ctx, "dur:a")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, durs)
+}
+
+func TestStore_FailedWALWriteLeavesMemoryUnchanged(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s, err := Open(dir)
+	require.NoError(t, err)
+	require.NoError(t, s.AppendDuration(ctx, "dur:a", 1, time.Time{}, 10))
+
+	// Writes to a closed file fail like a full disk would
+	require.NoError(t, s.wal.Close())
+	assert.Error(t, s.AppendDuration(ctx, "dur:a", 2, time.Time{}, 10))
+	durs, err := s.GetDurations(ctx, "dur:a")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, durs, "memory must match the wal")
+
+	// Duplicate checks and idle claims do not write records
+	require.NoError(t, unlockDir(s.lock))
+	s2, err := Open(dir)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s2.Close() })
+	_, err = s2.IsDuplicateOrMark(ctx, "trace-1", time.Hour)
+	require.NoError(t, err)
+	size := s2.walSize
+	dup, err := s2.IsDuplicateOrMark(ctx, "trace-1", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, dup)
+	_, err = s2.ClaimDirtyBatch(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, size, s2.walSize)
+}
+
+func TestStore_OpenFailsWhileDirIsInUse(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := Open(dir)
+	require.NoError(t, err)
+	_, err = Open(dir)
+	assert.ErrorContains(t, err, "in use by another process")
+
+	require.NoError(t, s.Close())
+	s2, err := Open(dir)
+	require.NoError(t, err)
+	require.NoError(t, s2.Close())
+}
+
+func TestStore_CloseCompactsWAL(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s, err := Open(dir)
+	require.NoError(t, err)
+	require.NoError(t, s.MarkDirty(ctx, "base:a"))
+	require.NoError(t, s.Close())
+
+	info, err := os.Stat(filepath.Join(dir, walFile))
+	require.NoError(t, err)
+	assert.Zero(t, info.Size(), "wal is folded into the snapshot on close")
+
+	s2, err := Open(dir)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s2.Close() })
+	keys, err := s2.ClaimDirtyBatch(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"base:a"}, keys)
+}
+
+func TestStore_MigratesV1Keys(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s, err := Open(dir)
+	require.NoError(t, err)
+	require.NoError(t, s.AppendDuration(ctx, "dur:svc|GET /a|b|9|weekday", 5, time.Time{}, 10))
+	require.NoError(t, s.SetBaseline(ctx, "base:svc|GET /a|b|9|weekday", store.Baseline{P50: 5, SampleCount: 1}))
+	require.NoError(t, s.MarkDirty(ctx, "base:svc|GET /a|b|9|weekday"))
+	crash(t, s)
+
+	s2, err := Open(dir)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s2.Close() })
+
+	bucket := domain.TimeBucket{Hour: 9, DayType: "weekday"}
+	durs, err := s2.GetDurations(ctx, domain.MakeDurationKey("svc", "GET /a|b", bucket))
+	require.NoError(t, err)
+	assert.Equal(t, []int64{5}, durs)
+	b, err := s2.GetBaseline(ctx, domain.MakeBaselineKey("svc", "GET /a|b", bucket))
+	require.NoError(t, err)
+	assert.NotNil(t, b)
+	keys, err := s2.ClaimDirtyBatch(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, []string{domain.MakeBaselineKey("svc", "GET /a|b", bucket)}, keys)
+}
+
+func TestStore_TenantsSurviveReopen(t *testing.T) {
+	dir := t.TempDir()
+	staging := store.WithTenant(context.Background(), "staging")
+	key := "v2:base:svc|ep|9|weekday"
+
+	s, err := Open(dir)
+	require.NoError(t, err)
+	require.NoError(t, s.SetBaseline(staging, key, store.Baseline{P50: 1, SampleCount: 1}))
+	require.NoError(t, s.MarkDirty(staging, key))
+	crash(t, s)
+
+	s2, err := Open(dir)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s2.Close() })
+
+	b, err := s2.GetBaseline(context.Background(), key)
+	require.NoError(t, err)
+	assert.Nil(t, b, "default tenant must not see staging data")
+	b, err = s2.GetBaseline(staging, key)
+	require.NoError(t, err)
+	assert.NotNil(t, b)
+
+	claimed, err := s2.ClaimDirtyBatch(context.Background(), 10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+	claimed, err = s2.ClaimDirtyBatch(staging, 10, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, []string{key}, claimed)
+}
@@ -0,0 +1,124 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+// Operation names recorded in the WAL.
+const (
+	opAppendDuration = "appendDuration"
+	opIngestBatch    = "ingestBatch"
+	opPruneDurations = "pruneDurations"
+	opReplaceSamples = "replaceSamples"
+	opReplaceSketch  = "replaceSketch"
+	opSetBaseline    = "setBaseline"
+	opRecordHistory  = "recordHistory"
+	opMarkSeen       = "markSeen"
+	opMarkDirty      = "markDirty"
+	opClaimDirty     = "claimDirty"
+	opAckDirty       = "ackDirty"
+	opNackDirty      = "nackDirty"
+)
+
+// record is a single WAL line. At is the unix-nano time the operation was applied
+// and Tenant the tenant it was scoped to ("" for the default tenant).
+type record struct {
+	At     int64           `json:"t"`
+	Op     string          `json:"op"`
+	Tenant string          `json:"ns,omitempty"`
+	Args   json.RawMessage `json:"a"`
+}
+
+// logged appends op/args, together with the tenant of ctx, to the WAL, syncs it and
+// only then applies fn under the store lock, so memory never holds a change that is
+// not on disk. If fn fails the record is cut from the WAL again. The clock is pinned
+// for the duration of fn so the recorded time matches the one fn observes.
+func (s *Store) logged(ctx context.Context, op string, args any, fn func() error) error {
+	return s.loggedUnless(ctx, op, args, nil, fn)
+}
+
+// loggedUnless is logged for operations that often change nothing (a dedup check
+// that finds a duplicate, a claim on an empty queue): when skip reports true, fn
+// runs without a WAL record. skip runs under the lock at the pinned time and must
+// only report true when fn will not change any state worth replaying.
+func (s *Store) loggedUnless(ctx context.Context, op string, args any, skip func() (bool, error), fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.wal == nil {
+		return fmt.Errorf("file store: closed")
+	}
+	at := time.Now()
+	s.pinClock(at)
+	defer s.pinClock(time.Time{})
+
+	if skip != nil {
+		skipped, err := skip()
+		if err != nil {
+			return err
+		}
+		if skipped {
+			return fn()
+		}
+	}
+
+	size := s.walSize
+	if err := s.appendLocked(at, store.TenantFromContext(ctx), op, args); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		if terr := s.truncateLocked(size); terr != nil {
+			return fmt.Errorf("%w (and the wal record could not be removed: %v)", err, terr)
+		}
+		return err
+	}
+
+	s.records++
+	if s.records >= compactEvery {
+		if err := s.compactLocked(); err != nil {
+			// The WAL is still intact; compaction will be retried on the next append.
+			log.Printf("file store: compaction failed: %v", err)
+		}
+	}
+	return nil
+}
+
+// appendLocked writes one record and syncs the WAL. A failed write is cut off
+// again, so a partial line cannot hide the records written after it from replay.
+func (s *Store) appendLocked(at time.Time, tenant, op string, args any) error {
+	a, err := json.Marshal(args)
+	if err != nil {
+		return fmt.Errorf("file store: encode %s: %w", op, err)
+	}
+	line, err := json.Marshal(record{At: at.UnixNano(), Op: op, Tenant: tenant, Args: a})
+	if err != nil {
+		return fmt.Errorf("file store: encode record: %w", err)
+	}
+	line = append(line, '\n')
+	size := s.walSize
+	if _, err := s.wal.Write(line); err != nil {
+		_ = s.truncateLocked(size)
+		return fmt.Errorf("file store: append wal: %w", err)
+	}
+	if err := s.wal.Sync(); err != nil {
+		_ = s.truncateLocked(size)
+		return fmt.Errorf("file store: sync wal: %w", err)
+	}
+	s.walSize = size + int64(len(line))
+	return nil
+}
+
+// truncateLocked cuts the WAL back to size bytes.
+func (s *Store) truncateLocked(size int64) error {
+	if err := s.wal.Truncate(size); err != nil {
+		return err
+	}
+	s.walSize = size
+	return s.wal.Sync()
+}
@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"context"
+	"maps"
+
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+// GetBaseline returns the baseline stored at key, or (nil, nil) if absent.
+func (s *Store) GetBaseline(ctx context.Context, key string) (*store.Baseline, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.baselines[tenantKey(ctx, key)]
+	if !ok {
+		return nil, nil
+	}
+	return &b, nil
+}
+
+// SetBaseline stores baseline stats at key, defaulting UpdatedAt to now. A zero
+// Rejected keeps the stored count.
+func (s *Store) SetBaseline(ctx context.Context, key string, b store.Baseline) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if b.UpdatedAt.IsZero() {
+		b.UpdatedAt = s.now().UTC()
+	}
+	// Strip the monotonic reading so stored values compare equal after a round trip.
+	b.UpdatedAt = b.UpdatedAt.Round(0).UTC()
+	b.Percentiles = maps.Clone(b.Percentiles)
+	if b.Rejected == 0 {
+		b.Rejected = s.baselines[tenantKey(ctx, key)].Rejected
+	}
+	s.baselines[tenantKey(ctx, key)] = b
+	return nil
+}
+
+// GetBaselines returns baselines for the keys that exist; missing keys are omitted.
+func (s *Store) GetBaselines(ctx context.Context, keys []string) (map[string]*store.Baseline, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(map[string]*store.Baseline, len(keys))
+	for _, k := range keys {
+		if b, ok := s.baselines[tenantKey(ctx, k)]; ok {
+			b := b
+			out[k] = &b
+		}
+	}
+	return out, nil
+}
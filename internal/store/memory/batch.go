@@ -0,0 +1,37 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+// IngestBatch applies all samples, sketch and outcome updates and dirty marks under a single lock.
+func (s *Store) IngestBatch(ctx context.Context, b store.IngestBatch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sample := range b.Samples {
+		s.appendDurationLocked(tenantKey(ctx, sample.Key), sample.DurationMs, sample.At, b.WindowSize)
+	}
+	for _, sample := range b.Sketches {
+		s.addSketchLocked(tenantKey(ctx, sample.Key), sample.Bin, sample.At, 1)
+	}
+	for _, o := range b.Outcomes {
+		var errors int64
+		if o.Error {
+			errors = 1
+		}
+		s.addOutcomesLocked(tenantKey(ctx, o.Key), o.At, 1, errors)
+	}
+	for _, k := range b.DirtyKeys {
+		s.markDirtyLocked(tenantKey(ctx, k))
+	}
+	for _, k := range b.Rejected {
+		if base, ok := s.baselines[tenantKey(ctx, k)]; ok {
+			base.Rejected++
+			s.baselines[tenantKey(ctx, k)] = base
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package memory
+
+import (
+	"context"
+	"time"
+)
+
+// sweepInterval bounds how often expired dedup marks are purged.
+const sweepInterval = time.Minute
+
+// IsDuplicateOrMark reports whether traceID was seen within its TTL and marks it otherwise.
+// Returns true if the traceID has been seen (duplicate), false if newly marked.
+func (s *Store) IsDuplicateOrMark(ctx context.Context, traceID string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweepExpiredLocked(now)
+
+	id := tenantKey(ctx, traceID)
+	if exp, ok := s.seen[id]; ok && now.Before(exp) {
+		return true, nil
+	}
+	s.seen[id] = now.Add(ttl)
+	return false, nil
+}
+
+// Seen reports whether traceID was seen within its TTL, without marking it.
+func (s *Store) Seen(ctx context.Context, traceID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exp, ok := s.seen[tenantKey(ctx, traceID)]
+	return ok && s.now().Before(exp)
+}
+
+// sweepExpiredLocked drops expired dedup marks so the map does not grow without bound.
+// Callers must hold s.mu.
+func (s *Store) sweepExpiredLocked(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for id, exp := range s.seen {
+		if !now.Before(exp) {
+			delete(s.seen, id)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+// MarkDirty enqueues key. Keys are claimed in the order they were first marked.
+func (s *Store) MarkDirty(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.markDirtyLocked(tenantKey(ctx, key))
+	return nil
+}
+
+// markDirtyLocked requires s.mu to be held.
+func (s *Store) markDirtyLocked(key string) {
+	s.enqueueLocked(key, s.now())
+}
+
+// enqueueLocked appends key to the pending queue unless it is already pending.
+func (s *Store) enqueueLocked(key string, at time.Time) {
+	if _, ok := s.dirty[key]; ok {
+		return
+	}
+	s.dirty[key] = at
+	s.dirtyOrder = append(s.dirtyOrder, key)
+}
+
+// ClaimDirtyBatch leases up to count pending keys of the context's tenant. Keys whose
+// lease has expired are re-queued first so they are not lost when a worker dies mid-batch.
+func (s *Store) ClaimDirtyBatch(ctx context.Context, count int64, lease time.Duration) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if count <= 0 {
+		count = 1
+	}
+	now := s.now()
+	tenant := store.TenantFromContext(ctx)
+	s.requeueExpiredLocked(now, tenant)
+
+	deadline := now.Add(lease)
+	out := make([]string, 0, min(int(count), len(s.dirtyOrder)))
+	rest := s.dirtyOrder[:0]
+	for _, k := range s.dirtyOrder {
+		logical, ok := store.TrimTenantKey(tenant, k)
+		if !ok || len(out) == int(count) {
+			rest = append(rest, k)
+			continue
+		}
+		out = append(out, logical)
+		delete(s.dirty, k)
+		s.leases[k] = deadline
+	}
+	s.dirtyOrder = rest
+	return out, nil
+}
+
+// requeueExpiredLocked moves keys of tenant with an expired lease back to the queue,
+// in deadline order so replays are deterministic.
+func (s *Store) requeueExpiredLocked(now time.Time, tenant string) {
+	var expired []string
+	for k, deadline := range s.leases {
+		if _, ok := store.TrimTenantKey(tenant, k); !ok {
+			continue
+		}
+		if !now.Before(deadline) {
+			expired = append(expired, k)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		di, dj := s.leases[expired[i]], s.leases[expired[j]]
+		if di.Equal(dj) {
+			return expired[i] < expired[j]
+		}
+		return di.Before(dj)
+	})
+	for _, k := range expired {
+		delete(s.leases, k)
+		s.enqueueLocked(k, now)
+	}
+}
+
+// AckDirty releases the leases of processed keys and forgets their failures.
+func (s *Store) AckDirty(ctx context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, k := range keys {
+		k = tenantKey(ctx, k)
+		delete(s.leases, k)
+		delete(s.retries, k)
+		delete(s.dead, k)
+	}
+	return nil
+}
+
+// NackDirty re-queues a failed key or dead-letters it after maxRetries failures.
+func (s *Store) NackDirty(ctx context.Context, key string, maxRetries int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key = tenantKey(ctx, key)
+	delete(s.leases, key)
+	s.retries[key]++
+	if s.retries[key] > maxRetries {
+		delete(s.retries, key)
+		s.dead[key] = struct{}{}
+		return true, nil
+	}
+	s.enqueueLocked(key, s.now())
+	return false, nil
+}
+
+// DirtyQueueStats reports the queue state of the context's tenant.
+func (s *Store) DirtyQueueStats(ctx context.Context) (store.DirtyQueueStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tenant := store.TenantFromContext(ctx)
+	owned := func(k string) bool {
+		_, ok := store.TrimTenantKey(tenant, k)
+		return ok
+	}
+
+	var st store.DirtyQueueStats
+	for _, k := range s.dirtyOrder {
+		if !owned(k) {
+			continue
+		}
+		if st.Pending == 0 {
+			if age := s.now().Sub(s.dirty[k]); age > 0 {
+				st.OldestPendingAge = age
+			}
+		}
+		st.Pending++
+	}
+	for k := range s.leases {
+		if owned(k) {
+			st.Leased++
+		}
+	}
+	for k := range s.dead {
+		if owned(k) {
+			st.DeadLetter++
+		}
+	}
+	return st, nil
+}
@@ -0,0 +1,111 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+// AppendDuration inserts durationMs observed at at into the window at key and
+// trims it to the windowSize most recent samples.
+func (s *Store) AppendDuration(ctx context.Context, key string, durationMs int64, at time.Time, windowSize int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.appendDurationLocked(tenantKey(ctx, key), durationMs, at, windowSize)
+	return nil
+}
+
+// appendDurationLocked requires s.mu to be held.
+func (s *Store) appendDurationLocked(key string, durationMs int64, at time.Time, windowSize int) {
+	if at.IsZero() {
+		at = s.now()
+	}
+	at = at.Round(0).UTC()
+
+	// Newest first, mirroring ZREVRANGE over the timestamp-scored set in the Redis store.
+	// Among equal timestamps the latest insert comes first.
+	list := s.durations[key]
+	i := sort.Search(len(list), func(i int) bool { return !list[i].At.After(at) })
+	list = append(list, store.Sample{})
+	copy(list[i+1:], list[i:])
+	list[i] = store.Sample{At: at, DurationMs: durationMs}
+	if windowSize > 0 && len(list) > windowSize {
+		list = list[:windowSize]
+	}
+	s.durations[key] = list
+}
+
+// GetDurations returns a copy of all duration samples (ms) in the window at key.
+func (s *Store) GetDurations(ctx context.Context, key string) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := s.durations[tenantKey(ctx, key)]
+	out := make([]int64, len(list))
+	for i, v := range list {
+		out[i] = v.DurationMs
+	}
+	return out, nil
+}
+
+// GetSamples returns the samples at key observed at or after since, newest first.
+func (s *Store) GetSamples(ctx context.Context, key string, since time.Time) ([]store.Sample, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := s.durations[tenantKey(ctx, key)]
+	n := len(list)
+	if !since.IsZero() {
+		n = sort.Search(len(list), func(i int) bool { return list[i].At.Before(since) })
+	}
+	out := make([]store.Sample, n)
+	copy(out, list[:n])
+	return out, nil
+}
+
+// PruneDurations drops samples of the context's tenant observed before the given
+// time and deletes empty windows. Expired sketch and error count slices are dropped too.
+func (s *Store) PruneDurations(ctx context.Context, before time.Time) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tenant := store.TenantFromContext(ctx)
+	var pruned []string
+	for key, list := range s.durations {
+		logical, ok := store.TrimTenantKey(tenant, key)
+		if !ok {
+			continue
+		}
+		n := sort.Search(len(list), func(i int) bool { return list[i].At.Before(before) })
+		if n == len(list) {
+			continue
+		}
+		pruned = append(pruned, logical)
+		if n == 0 {
+			delete(s.durations, key)
+			continue
+		}
+		s.durations[key] = list[:n:n]
+	}
+	s.pruneSketchesLocked(tenant, before)
+	s.pruneErrorCountsLocked(tenant, before)
+	sort.Strings(pruned)
+	return pruned, nil
+}
+
+// ReplaceSamples replaces the window at key with samples, keeping the windowSize most recent.
+func (s *Store) ReplaceSamples(ctx context.Context, key string, samples []store.Sample, windowSize int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key = tenantKey(ctx, key)
+	delete(s.durations, key)
+	// Insert oldest first so samples sharing a timestamp keep their given (newest first) order.
+	for i := len(samples) - 1; i >= 0; i-- {
+		s.appendDurationLocked(key, samples[i].DurationMs, samples[i].At, windowSize)
+	}
+	return nil
+}
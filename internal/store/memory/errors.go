@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+// errorDays holds the request and error counts of one bucket per day (unix
+// seconds of midnight UTC).
+type errorDays map[int64]store.ErrorSlice
+
+// GetErrorCounts returns the day slices of every key that has any.
+func (s *Store) GetErrorCounts(ctx context.Context, keys []string, since time.Time) (map[string][]store.ErrorSlice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(map[string][]store.ErrorSlice, len(keys))
+	for _, key := range keys {
+		if slices := s.errorCountsLocked(tenantKey(ctx, key), since); len(slices) > 0 {
+			out[key] = slices
+		}
+	}
+	return out, nil
+}
+
+// errorCountsLocked copies the day slices of the error counts at key, newest
+// first. It requires s.mu to be held.
+func (s *Store) errorCountsLocked(key string, since time.Time) []store.ErrorSlice {
+	days := s.errorCounts[key]
+	out := make([]store.ErrorSlice, 0, len(days))
+	for _, slice := range days {
+		if !since.IsZero() && !slice.Day.Add(24*time.Hour).After(since) {
+			continue
+		}
+		out = append(out, slice)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Day.After(out[j].Day) })
+	return out
+}
+
+// addOutcomesLocked adds requests and errors to the day slice covering at.
+// It requires s.mu to be held.
+func (s *Store) addOutcomesLocked(key string, at time.Time, requests, errors int64) {
+	if requests <= 0 {
+		return
+	}
+	if at.IsZero() {
+		at = s.now()
+	}
+	days := s.errorCounts[key]
+	if days == nil {
+		days = make(errorDays)
+		s.errorCounts[key] = days
+	}
+	day := store.SketchDay(at)
+	slice := days[day.Unix()]
+	slice.Day = day
+	slice.Requests += requests
+	slice.Errors += errors
+	days[day.Unix()] = slice
+}
+
+// pruneErrorCountsLocked drops the tenant's day slices that ended before the
+// given time and deletes error counts left empty. It requires s.mu to be held.
+func (s *Store) pruneErrorCountsLocked(tenant string, before time.Time) {
+	for key, days := range s.errorCounts {
+		if _, ok := store.TrimTenantKey(tenant, key); !ok {
+			continue
+		}
+		for day := range days {
+			if !time.Unix(day, 0).Add(24 * time.Hour).After(before) {
+				delete(days, day)
+			}
+		}
+		if len(days) == 0 {
+			delete(s.errorCounts, key)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+// RecordBaselineHistory stores snap under its day and drops days before the given time.
+func (s *Store) RecordBaselineHistory(ctx context.Context, key string, snap store.BaselineSnapshot, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.recordHistoryLocked(tenantKey(ctx, key), snap, before)
+	return nil
+}
+
+// recordHistoryLocked requires s.mu to be held.
+func (s *Store) recordHistoryLocked(key string, snap store.BaselineSnapshot, before time.Time) {
+	snap.Day = store.SketchDay(snap.Day)
+	var cutoff time.Time
+	if !before.IsZero() {
+		cutoff = store.SketchDay(before)
+	}
+	history := make([]store.BaselineSnapshot, 0, len(s.histories[key])+1)
+	for _, h := range s.histories[key] {
+		if h.Day.Equal(snap.Day) || h.Day.Before(cutoff) {
+			continue
+		}
+		history = append(history, h)
+	}
+	if !snap.Day.Before(cutoff) {
+		history = append(history, snap)
+	}
+	sort.Slice(history, func(i, j int) bool { return history[i].Day.Before(history[j].Day) })
+	if len(history) == 0 {
+		delete(s.histories, key)
+		return
+	}
+	s.histories[key] = history
+}
+
+// GetBaselineHistories returns copies of the histories of every key that has any.
+func (s *Store) GetBaselineHistories(ctx context.Context, keys []string) (map[string][]store.BaselineSnapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(map[string][]store.BaselineSnapshot, len(keys))
+	for _, key := range keys {
+		if history := s.histories[tenantKey(ctx, key)]; len(history) > 0 {
+			out[key] = append([]store.BaselineSnapshot(nil), history...)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+// ListBaselineKeys returns all baseline keys whose SampleCount is at least minSamples.
+func (s *Store) ListBaselineKeys(ctx context.Context, minSamples int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tenant := store.TenantFromContext(ctx)
+	var result []string
+	for k, b := range s.baselines {
+		k, ok := store.TrimTenantKey(tenant, k)
+		if !ok || !strings.HasPrefix(k, domain.KeyPrefix(domain.KindBaseline)) {
+			continue
+		}
+		if b.SampleCount >= minSamples {
+			result = append(result, k)
+		}
+	}
+	return result, nil
+}
+
+// ListKeys returns every baseline or duration key starting with prefix, sorted.
+func (s *Store) ListKeys(ctx context.Context, prefix string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tenant := store.TenantFromContext(ctx)
+	var result []string
+	add := func(k string) {
+		if k, ok := store.TrimTenantKey(tenant, k); ok && strings.HasPrefix(k, prefix) {
+			result = append(result, k)
+		}
+	}
+	for k := range s.baselines {
+		add(k)
+	}
+	for k := range s.durations {
+		add(k)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// ListSeries groups the baselines of kind by service/name. The memory store keeps
+// no separate index; the baseline map is small enough to walk.
+func (s *Store) ListSeries(ctx context.Context, kind domain.KeyKind) ([]store.SeriesIndexEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tenant := store.TenantFromContext(ctx)
+	byID := make(map[string]*store.SeriesIndexEntry)
+	for k, b := range s.baselines {
+		k, ok := store.TrimTenantKey(tenant, k)
+		if !ok {
+			continue
+		}
+		sk, err := domain.ParseSeriesKey(k)
+		if err != nil || sk.Kind != kind {
+			continue
+		}
+		e, ok := byID[sk.SeriesID()]
+		if !ok {
+			e = &store.SeriesIndexEntry{Service: sk.Service, Name: sk.Name, Buckets: make(map[domain.TimeBucket]int)}
+			byID[sk.SeriesID()] = e
+		}
+		e.Buckets[sk.Bucket] = b.SampleCount
+	}
+
+	ids := make([]string, 0, len(byID))
+	for id := range byID {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	out := make([]store.SeriesIndexEntry, len(ids))
+	for i, id := range ids {
+		out[i] = *byID[id]
+	}
+	return out, nil
+}
@@ -0,0 +1,62 @@
+package memory
+
+import "time"
+
+// RewriteKeys renames every series key (durations, baselines and dirty queue
+// entries) for which rename returns ok. Existing entries under the new name win.
+// It returns the number of keys renamed.
+func (s *Store) RewriteKeys(rename func(key string) (string, bool)) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for k, v := range s.durations {
+		if nk, ok := rename(k); ok {
+			delete(s.durations, k)
+			if _, exists := s.durations[nk]; !exists {
+				s.durations[nk] = v
+			}
+			n++
+		}
+	}
+	for k, v := range s.baselines {
+		if nk, ok := rename(k); ok {
+			delete(s.baselines, k)
+			if _, exists := s.baselines[nk]; !exists {
+				s.baselines[nk] = v
+			}
+			n++
+		}
+	}
+
+	order := s.dirtyOrder
+	dirty := s.dirty
+	s.dirty = make(map[string]time.Time, len(dirty))
+	s.dirtyOrder = nil
+	for _, k := range order {
+		nk, ok := rename(k)
+		if !ok {
+			nk = k
+		}
+		s.enqueueLocked(nk, dirty[k])
+	}
+	for k, v := range s.leases {
+		if nk, ok := rename(k); ok {
+			delete(s.leases, k)
+			s.leases[nk] = v
+		}
+	}
+	for k, v := range s.retries {
+		if nk, ok := rename(k); ok {
+			delete(s.retries, k)
+			s.retries[nk] = v
+		}
+	}
+	for k := range s.dead {
+		if nk, ok := rename(k); ok {
+			delete(s.dead, k)
+			s.dead[nk] = struct{}{}
+		}
+	}
+	return n
+}
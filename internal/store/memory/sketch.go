@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+// sketchDays holds the bin counts of one sketch per day (unix seconds of midnight UTC).
+type sketchDays map[int64]map[int32]int64
+
+// GetSketch returns the day slices of the sketch at key ending after since, newest first.
+func (s *Store) GetSketch(ctx context.Context, key string, since time.Time) ([]store.SketchSlice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.sketchLocked(tenantKey(ctx, key), since), nil
+}
+
+// GetSketches returns the day slices of every key that has any.
+func (s *Store) GetSketches(ctx context.Context, keys []string, since time.Time) (map[string][]store.SketchSlice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(map[string][]store.SketchSlice, len(keys))
+	for _, key := range keys {
+		if slices := s.sketchLocked(tenantKey(ctx, key), since); len(slices) > 0 {
+			out[key] = slices
+		}
+	}
+	return out, nil
+}
+
+// sketchLocked copies the day slices of the sketch at key. It requires s.mu to be held.
+func (s *Store) sketchLocked(key string, since time.Time) []store.SketchSlice {
+	days := s.sketches[key]
+	out := make([]store.SketchSlice, 0, len(days))
+	for day, bins := range days {
+		start := time.Unix(day, 0).UTC()
+		if !since.IsZero() && !start.Add(24*time.Hour).After(since) {
+			continue
+		}
+		slice := store.SketchSlice{Day: start, Bins: make(map[int32]int64, len(bins))}
+		for bin, n := range bins {
+			slice.Bins[bin] = n
+		}
+		out = append(out, slice)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Day.After(out[j].Day) })
+	return out
+}
+
+// ReplaceSketch replaces the sketch at key with slices.
+func (s *Store) ReplaceSketch(ctx context.Context, key string, slices []store.SketchSlice) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key = tenantKey(ctx, key)
+	delete(s.sketches, key)
+	for _, slice := range slices {
+		for bin, n := range slice.Bins {
+			s.addSketchLocked(key, bin, slice.Day, n)
+		}
+	}
+	return nil
+}
+
+// addSketchLocked requires s.mu to be held.
+func (s *Store) addSketchLocked(key string, bin int32, at time.Time, n int64) {
+	if n <= 0 {
+		return
+	}
+	if at.IsZero() {
+		at = s.now()
+	}
+	days := s.sketches[key]
+	if days == nil {
+		days = make(sketchDays)
+		s.sketches[key] = days
+	}
+	day := store.SketchDay(at).Unix()
+	bins := days[day]
+	if bins == nil {
+		bins = make(map[int32]int64)
+		days[day] = bins
+	}
+	bins[bin] += n
+}
+
+// pruneSketchesLocked drops the tenant's day slices that ended before the given
+// time and deletes sketches left empty. It requires s.mu to be held.
+func (s *Store) pruneSketchesLocked(tenant string, before time.Time) {
+	for key, days := range s.sketches {
+		if _, ok := store.TrimTenantKey(tenant, key); !ok {
+			continue
+		}
+		for day := range days {
+			if !time.Unix(day, 0).Add(24 * time.Hour).After(before) {
+				delete(days, day)
+			}
+		}
+		if len(days) == 0 {
+			delete(s.sketches, key)
+		}
+	}
+}
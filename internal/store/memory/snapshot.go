@@ -0,0 +1,142 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+// Snapshot is a point-in-time copy of the full store contents.
+// It is used by persistent backends built on top of the in-memory store.
+type Snapshot struct {
+	Samples map[string][]store.Sample `json:"samples"`
+	// Sketches holds the day slices of every sketch.
+	Sketches map[string][]store.SketchSlice `json:"sketches,omitempty"`
+	// ErrorCounts holds the day slices of every request and error count.
+	ErrorCounts map[string][]store.ErrorSlice `json:"errorCounts,omitempty"`
+	Baselines   map[string]store.Baseline     `json:"baselines"`
+	// Histories holds the daily baseline history of every bucket, oldest day first.
+	Histories map[string][]store.BaselineSnapshot `json:"histories,omitempty"`
+	Seen      map[string]time.Time                `json:"seen"`
+	Dirty     []string                            `json:"dirty"`
+	// DirtySince, Leases, Retries and Dead hold the rest of the dirty queue state.
+	DirtySince map[string]time.Time `json:"dirtySince,omitempty"`
+	Leases     map[string]time.Time `json:"leases,omitempty"`
+	Retries    map[string]int       `json:"retries,omitempty"`
+	Dead       []string             `json:"dead,omitempty"`
+}
+
+// Snapshot returns a deep copy of the current contents. Expired dedup marks are omitted.
+func (s *Store) Snapshot() Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	snap := Snapshot{
+		Samples:     make(map[string][]store.Sample, len(s.durations)),
+		Sketches:    make(map[string][]store.SketchSlice, len(s.sketches)),
+		ErrorCounts: make(map[string][]store.ErrorSlice, len(s.errorCounts)),
+		Baselines:   make(map[string]store.Baseline, len(s.baselines)),
+		Histories:   make(map[string][]store.BaselineSnapshot, len(s.histories)),
+		Seen:        make(map[string]time.Time, len(s.seen)),
+		Dirty:       append([]string(nil), s.dirtyOrder...),
+		DirtySince:  make(map[string]time.Time, len(s.dirty)),
+		Leases:      make(map[string]time.Time, len(s.leases)),
+		Retries:     make(map[string]int, len(s.retries)),
+	}
+	for k, v := range s.durations {
+		snap.Samples[k] = append([]store.Sample(nil), v...)
+	}
+	for k, days := range s.sketches {
+		for day, bins := range days {
+			slice := store.SketchSlice{Day: time.Unix(day, 0).UTC(), Bins: make(map[int32]int64, len(bins))}
+			for bin, n := range bins {
+				slice.Bins[bin] = n
+			}
+			snap.Sketches[k] = append(snap.Sketches[k], slice)
+		}
+	}
+	for k := range s.errorCounts {
+		snap.ErrorCounts[k] = s.errorCountsLocked(k, time.Time{})
+	}
+	for k, v := range s.baselines {
+		snap.Baselines[k] = v
+	}
+	for k, v := range s.histories {
+		snap.Histories[k] = append([]store.BaselineSnapshot(nil), v...)
+	}
+	for k, exp := range s.seen {
+		if now.Before(exp) {
+			snap.Seen[k] = exp
+		}
+	}
+	for k, at := range s.dirty {
+		snap.DirtySince[k] = at
+	}
+	for k, deadline := range s.leases {
+		snap.Leases[k] = deadline
+	}
+	for k, n := range s.retries {
+		snap.Retries[k] = n
+	}
+	for k := range s.dead {
+		snap.Dead = append(snap.Dead, k)
+	}
+	sort.Strings(snap.Dead)
+	return snap
+}
+
+// Restore replaces the current contents with snap.
+func (s *Store) Restore(snap Snapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.durations = make(map[string][]store.Sample, len(snap.Samples))
+	for k, v := range snap.Samples {
+		s.durations[k] = append([]store.Sample(nil), v...)
+	}
+	s.sketches = make(map[string]sketchDays, len(snap.Sketches))
+	for k, slices := range snap.Sketches {
+		for _, slice := range slices {
+			for bin, n := range slice.Bins {
+				s.addSketchLocked(k, bin, slice.Day, n)
+			}
+		}
+	}
+	s.errorCounts = make(map[string]errorDays, len(snap.ErrorCounts))
+	for k, slices := range snap.ErrorCounts {
+		for _, slice := range slices {
+			s.addOutcomesLocked(k, slice.Day, slice.Requests, slice.Errors)
+		}
+	}
+	s.baselines = make(map[string]store.Baseline, len(snap.Baselines))
+	for k, v := range snap.Baselines {
+		s.baselines[k] = v
+	}
+	s.histories = make(map[string][]store.BaselineSnapshot, len(snap.Histories))
+	for k, v := range snap.Histories {
+		s.histories[k] = append([]store.BaselineSnapshot(nil), v...)
+	}
+	s.seen = make(map[string]time.Time, len(snap.Seen))
+	for k, exp := range snap.Seen {
+		s.seen[k] = exp
+	}
+	s.dirty = make(map[string]time.Time, len(snap.Dirty))
+	s.dirtyOrder = s.dirtyOrder[:0]
+	for _, k := range snap.Dirty {
+		s.enqueueLocked(k, snap.DirtySince[k])
+	}
+	s.leases = make(map[string]time.Time, len(snap.Leases))
+	for k, deadline := range snap.Leases {
+		s.leases[k] = deadline
+	}
+	s.retries = make(map[string]int, len(snap.Retries))
+	for k, n := range snap.Retries {
+		s.retries[k] = n
+	}
+	s.dead = make(map[string]struct{}, len(snap.Dead))
+	for _, k := range snap.Dead {
+		s.dead[k] = struct{}{}
+	}
+}
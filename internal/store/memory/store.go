@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+// Store implements store.Store entirely in process memory.
+// It is intended for single-node deployments, local development and tests;
+// all data is lost when the process exits.
+type Store struct {
+	mu        sync.Mutex
+	durations map[string][]store.Sample
+	sketches  map[string]sketchDays
+	baselines map[string]store.Baseline
+	histories map[string][]store.BaselineSnapshot
+	seen      map[string]time.Time
+	// errorCounts holds the per-day request and error counts (err/spanerr keys).
+	errorCounts map[string]errorDays
+	// dirty maps pending keys to the time they were queued.
+	dirty map[string]time.Time
+	// dirtyOrder keeps pending keys in FIFO order so claims are deterministic.
+	dirtyOrder []string
+	leases     map[string]time.Time
+	retries    map[string]int
+	dead       map[string]struct{}
+
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// New creates an empty in-memory store.
+func New() *Store {
+	return NewWithClock(time.Now)
+}
+
+// NewWithClock creates an empty in-memory store that reads the current time from now.
+// Dedup expiry is evaluated against this clock, which lets callers replay
+// recorded operations deterministically.
+func NewWithClock(now func() time.Time) *Store {
+	return &Store{
+		durations:   make(map[string][]store.Sample),
+		sketches:    make(map[string]sketchDays),
+		errorCounts: make(map[string]errorDays),
+		baselines:   make(map[string]store.Baseline),
+		histories:   make(map[string][]store.BaselineSnapshot),
+		seen:        make(map[string]time.Time),
+		dirty:       make(map[string]time.Time),
+		leases:      make(map[string]time.Time),
+		retries:     make(map[string]int),
+		dead:        make(map[string]struct{}),
+		now:         now,
+	}
+}
+
+// tenantKey maps a logical key to the key used in the maps: the key prefixed with
+// the context's tenant (see store.TenantKeyPrefix). All tenants share one set of maps.
+func tenantKey(ctx context.Context, k string) string {
+	return store.TenantKeyPrefix(store.TenantFromContext(ctx)) + k
+}
+
+// Close releases nothing; it exists to satisfy store.Store.
+func (s *Store) Close() error {
+	return nil
+}
+
+var _ store.Store = (*Store)(nil)
@@ -0,0 +1,362 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore_AppendDurationTrimsWindow(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	for i := int64(1); i <= 5; i++ {
+		assert.NoError(t, s.AppendDuration(ctx, "dur:a", i, time.Time{}, 3))
+	}
+	got, err := s.GetDurations(ctx, "dur:a")
+	assert.NoError(t, err)
+	// Newest first, capped at window size
+	assert.Equal(t, []int64{5, 4, 3}, got)
+
+	// Returned slice must be a copy
+	got[0] = 99
+	again, _ := s.GetDurations(ctx, "dur:a")
+	assert.Equal(t, int64(5), again[0])
+
+	empty, err := s.GetDurations(ctx, "dur:missing")
+	assert.NoError(t, err)
+	assert.Empty(t, empty)
+}
+
+func TestStore_Baselines(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	b, err := s.GetBaseline(ctx, "v2:base:a")
+	assert.NoError(t, err)
+	assert.Nil(t, b)
+
+	assert.NoError(t, s.SetBaseline(ctx, "v2:base:a", store.Baseline{P50: 1, P95: 2, MAD: 3, SampleCount: 60, Percentiles: map[string]float64{"p99": 9}}))
+	assert.NoError(t, s.SetBaseline(ctx, "v2:base:b", store.Baseline{P50: 4, SampleCount: 5}))
+	assert.NoError(t, s.SetBaseline(ctx, "v2:spanbase:c", store.Baseline{P50: 4, SampleCount: 500}))
+
+	b, err = s.GetBaseline(ctx, "v2:base:a")
+	if assert.NoError(t, err) && assert.NotNil(t, b) {
+		assert.Equal(t, 2.0, b.P95)
+		assert.Equal(t, map[string]float64{"p99": 9}, b.Percentiles)
+		assert.False(t, b.UpdatedAt.IsZero(), "UpdatedAt defaults to now")
+	}
+
+	m, err := s.GetBaselines(ctx, []string{"v2:base:a", "v2:base:missing", "v2:base:b"})
+	assert.NoError(t, err)
+	assert.Len(t, m, 2)
+	assert.Contains(t, m, "v2:base:a")
+	assert.Contains(t, m, "v2:base:b")
+
+	keys, err := s.ListBaselineKeys(ctx, 50)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"v2:base:a"}, keys)
+}
+
+func TestStore_DedupTTL(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	dup, err := s.IsDuplicateOrMark(ctx, "t1", time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, dup)
+
+	dup, _ = s.IsDuplicateOrMark(ctx, "t1", time.Hour)
+	assert.True(t, dup)
+
+	// After TTL expiry the trace is treated as new again and the old mark is swept
+	now = now.Add(2 * time.Hour)
+	dup, _ = s.IsDuplicateOrMark(ctx, "t1", time.Hour)
+	assert.False(t, dup)
+	assert.Len(t, s.seen, 1)
+}
+
+func TestStore_DirtyQueueLeases(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)
+	s := NewWithClock(func() time.Time { return now })
+
+	for _, k := range []string{"base:a", "base:b", "base:c", "base:a"} {
+		assert.NoError(t, s.MarkDirty(ctx, k))
+	}
+
+	first, err := s.ClaimDirtyBatch(ctx, 2, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"base:a", "base:b"}, first)
+
+	now = now.Add(10 * time.Second)
+	st, _ := s.DirtyQueueStats(ctx)
+	assert.Equal(t, store.DirtyQueueStats{Pending: 1, Leased: 2, OldestPendingAge: 10 * time.Second}, st)
+
+	// base:a succeeds, base:b is never acknowledged
+	assert.NoError(t, s.AckDirty(ctx, "base:a"))
+
+	rest, err := s.ClaimDirtyBatch(ctx, 10, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"base:c"}, rest)
+	assert.NoError(t, s.AckDirty(ctx, rest...))
+
+	// Once its lease expires base:b is handed out again
+	now = now.Add(time.Minute)
+	again, err := s.ClaimDirtyBatch(ctx, 10, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"base:b"}, again)
+}
+
+func TestStore_DirtyQueueDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	assert.NoError(t, s.MarkDirty(ctx, "base:bad"))
+	for i := 0; i < 2; i++ {
+		keys, _ := s.ClaimDirtyBatch(ctx, 10, time.Minute)
+		assert.Equal(t, []string{"base:bad"}, keys)
+		dead, err := s.NackDirty(ctx, "base:bad", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, i == 1, dead)
+	}
+
+	st, _ := s.DirtyQueueStats(ctx)
+	assert.Equal(t, int64(0), st.Pending)
+	assert.Equal(t, int64(0), st.Leased)
+	assert.Equal(t, int64(1), st.DeadLetter)
+
+	// New traffic re-queues the key; a success clears the dead letter
+	assert.NoError(t, s.MarkDirty(ctx, "base:bad"))
+	keys, _ := s.ClaimDirtyBatch(ctx, 10, time.Minute)
+	assert.NoError(t, s.AckDirty(ctx, keys...))
+	st, _ = s.DirtyQueueStats(ctx)
+	assert.Equal(t, int64(0), st.DeadLetter)
+}
+
+func TestStore_IngestBatch(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	err := s.IngestBatch(ctx, store.IngestBatch{
+		Samples: []store.DurationSample{
+			{Key: "dur:svc|GET /a|10|weekday", DurationMs: 1},
+			{Key: "dur:svc|GET /a|10|weekday", DurationMs: 2},
+			{Key: "dur:svc|GET /a|10|weekday", DurationMs: 3},
+			{Key: "spandur:svc|db|10|weekday", DurationMs: 4},
+		},
+		DirtyKeys:  []string{"base:svc|GET /a|10|weekday", "spanbase:svc|db|10|weekday"},
+		WindowSize: 2,
+	})
+	assert.NoError(t, err)
+
+	durs, _ := s.GetDurations(ctx, "dur:svc|GET /a|10|weekday")
+	assert.Equal(t, []int64{3, 2}, durs)
+	durs, _ = s.GetDurations(ctx, "spandur:svc|db|10|weekday")
+	assert.Equal(t, []int64{4}, durs)
+
+	dirty, _ := s.ClaimDirtyBatch(ctx, 10, time.Minute)
+	assert.Equal(t, []string{"base:svc|GET /a|10|weekday", "spanbase:svc|db|10|weekday"}, dirty)
+}
+
+func TestStore_RejectedCount(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	assert.NoError(t, s.SetBaseline(ctx, "v2:base:a", store.Baseline{P50: 1, SampleCount: 60}))
+	assert.NoError(t, s.IngestBatch(ctx, store.IngestBatch{Rejected: []string{"v2:base:a", "v2:base:a", "v2:base:missing"}}))
+
+	b, _ := s.GetBaseline(ctx, "v2:base:a")
+	assert.Equal(t, 2, b.Rejected)
+	missing, _ := s.GetBaseline(ctx, "v2:base:missing")
+	assert.Nil(t, missing, "rejections do not create baselines")
+
+	// Recomputing keeps the count
+	assert.NoError(t, s.SetBaseline(ctx, "v2:base:a", store.Baseline{P50: 2, SampleCount: 61}))
+	b, _ = s.GetBaseline(ctx, "v2:base:a")
+	assert.Equal(t, 2.0, b.P50)
+	assert.Equal(t, 2, b.Rejected)
+}
+
+func TestStore_BaselineHistory(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	day := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	assert.NoError(t, s.RecordBaselineHistory(ctx, "v2:hist:a", store.BaselineSnapshot{Day: day.Add(2 * time.Hour), P50: 1}, time.Time{}))
+	assert.NoError(t, s.RecordBaselineHistory(ctx, "v2:hist:a", store.BaselineSnapshot{Day: day.AddDate(0, 0, -1), P50: 2}, time.Time{}))
+	// A later recompute of the same day replaces its entry
+	assert.NoError(t, s.RecordBaselineHistory(ctx, "v2:hist:a", store.BaselineSnapshot{Day: day.Add(20 * time.Hour), P50: 3}, time.Time{}))
+
+	h, err := s.GetBaselineHistories(ctx, []string{"v2:hist:a", "v2:hist:missing"})
+	assert.NoError(t, err)
+	assert.NotContains(t, h, "v2:hist:missing")
+	assert.Equal(t, []store.BaselineSnapshot{{Day: day.AddDate(0, 0, -1), P50: 2}, {Day: day, P50: 3}}, h["v2:hist:a"])
+
+	// Days before the cut-off are dropped
+	assert.NoError(t, s.RecordBaselineHistory(ctx, "v2:hist:a", store.BaselineSnapshot{Day: day.AddDate(0, 0, 1), P50: 4}, day.Add(time.Hour)))
+	h, _ = s.GetBaselineHistories(ctx, []string{"v2:hist:a"})
+	assert.Equal(t, []store.BaselineSnapshot{{Day: day, P50: 3}, {Day: day.AddDate(0, 0, 1), P50: 4}}, h["v2:hist:a"])
+}
+
+func TestStore_ErrorCountDaySlices(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	s := New()
+
+	assert.NoError(t, s.IngestBatch(ctx, store.IngestBatch{Outcomes: []store.OutcomeSample{
+		{Key: "v2:err:a", At: day.Add(9 * time.Hour)},
+		{Key: "v2:err:a", At: day.Add(10 * time.Hour), Error: true},
+		{Key: "v2:err:a", At: day.Add(-time.Hour), Error: true},
+	}}))
+
+	counts, err := s.GetErrorCounts(ctx, []string{"v2:err:a", "v2:err:missing"}, time.Time{})
+	assert.NoError(t, err)
+	assert.NotContains(t, counts, "v2:err:missing")
+	assert.Equal(t, []store.ErrorSlice{
+		{Day: day, Requests: 2, Errors: 1},
+		{Day: day.AddDate(0, 0, -1), Requests: 1, Errors: 1},
+	}, counts["v2:err:a"])
+
+	counts, _ = s.GetErrorCounts(ctx, []string{"v2:err:a"}, day.Add(time.Hour))
+	assert.Len(t, counts["v2:err:a"], 1)
+
+	// Expired days are pruned alongside the samples and survive a snapshot
+	_, err = s.PruneDurations(ctx, day.Add(time.Hour))
+	assert.NoError(t, err)
+	restored := New()
+	restored.Restore(s.Snapshot())
+	counts, _ = restored.GetErrorCounts(ctx, []string{"v2:err:a"}, time.Time{})
+	assert.Equal(t, []store.ErrorSlice{{Day: day, Requests: 2, Errors: 1}}, counts["v2:err:a"])
+}
+
+func TestStore_SamplesByTimeAndPrune(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)
+	s := New()
+
+	// Inserted out of order (e.g. backfill); the window is kept ordered by time
+	assert.NoError(t, s.AppendDuration(ctx, "dur:a", 2, base.Add(2*time.Hour), 3))
+	assert.NoError(t, s.AppendDuration(ctx, "dur:a", 1, base.Add(time.Hour), 3))
+	assert.NoError(t, s.AppendDuration(ctx, "dur:a", 3, base.Add(3*time.Hour), 3))
+	assert.NoError(t, s.AppendDuration(ctx, "dur:a", 0, base, 3))
+	assert.NoError(t, s.AppendDuration(ctx, "dur:b", 9, base, 3))
+
+	durs, _ := s.GetDurations(ctx, "dur:a")
+	assert.Equal(t, []int64{3, 2, 1}, durs, "oldest sample is trimmed, not the last inserted")
+
+	recent, err := s.GetSamples(ctx, "dur:a", base.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, []store.Sample{
+		{At: base.Add(3 * time.Hour), DurationMs: 3},
+		{At: base.Add(2 * time.Hour), DurationMs: 2},
+	}, recent)
+
+	pruned, err := s.PruneDurations(ctx, base.Add(90*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dur:a", "dur:b"}, pruned)
+	durs, _ = s.GetDurations(ctx, "dur:a")
+	assert.Equal(t, []int64{3, 2}, durs)
+	assert.NotContains(t, s.durations, "dur:b", "empty windows are removed")
+}
+
+func TestStore_ReplaceSamplesAndListKeys(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)
+	s := New()
+
+	assert.NoError(t, s.AppendDuration(ctx, "v2:dur:a", 1, base, 10))
+	assert.NoError(t, s.ReplaceSamples(ctx, "v2:dur:a", []store.Sample{
+		{At: base.Add(2 * time.Hour), DurationMs: 3},
+		{At: base.Add(time.Hour), DurationMs: 2},
+		{At: base, DurationMs: 9},
+	}, 2))
+	durs, _ := s.GetDurations(ctx, "v2:dur:a")
+	assert.Equal(t, []int64{3, 2}, durs)
+
+	assert.NoError(t, s.SetBaseline(ctx, "v2:base:b", store.Baseline{SampleCount: 1}))
+	assert.NoError(t, s.SetBaseline(ctx, "v2:base:a", store.Baseline{SampleCount: 1}))
+	keys, err := s.ListKeys(ctx, "v2:base:")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"v2:base:a", "v2:base:b"}, keys)
+
+	assert.NoError(t, s.ReplaceSamples(ctx, "v2:dur:a", nil, 2))
+	keys, _ = s.ListKeys(ctx, "v2:dur:")
+	assert.Empty(t, keys, "replacing with no samples deletes the window")
+}
+
+func TestStore_SketchDaySlices(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+	s := New()
+
+	assert.NoError(t, s.IngestBatch(ctx, store.IngestBatch{Sketches: []store.SketchSample{
+		{Key: "v2:sketch:a", Bin: 5, At: day.Add(9 * time.Hour)},
+		{Key: "v2:sketch:a", Bin: 5, At: day.Add(10 * time.Hour)},
+		{Key: "v2:sketch:a", Bin: 7, At: day.Add(33 * time.Hour)},
+	}}))
+
+	slices, err := s.GetSketch(ctx, "v2:sketch:a", time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, []store.SketchSlice{
+		{Day: day.Add(24 * time.Hour), Bins: map[int32]int64{7: 1}},
+		{Day: day, Bins: map[int32]int64{5: 2}},
+	}, slices, "newest day first")
+
+	// A day is kept until it has ended before the cut-off
+	slices, _ = s.GetSketch(ctx, "v2:sketch:a", day.Add(23*time.Hour))
+	assert.Len(t, slices, 2)
+	slices, _ = s.GetSketch(ctx, "v2:sketch:a", day.Add(24*time.Hour))
+	assert.Len(t, slices, 1)
+
+	_, err = s.PruneDurations(ctx, day.Add(30*time.Hour))
+	assert.NoError(t, err)
+	slices, _ = s.GetSketch(ctx, "v2:sketch:a", time.Time{})
+	assert.Equal(t, []store.SketchSlice{{Day: day.Add(24 * time.Hour), Bins: map[int32]int64{7: 1}}}, slices)
+
+	assert.NoError(t, s.ReplaceSketch(ctx, "v2:sketch:a", nil))
+	assert.NotContains(t, s.sketches, "v2:sketch:a", "replacing with no slices deletes the sketch")
+}
+
+func TestStore_TenantIsolation(t *testing.T) {
+	def := context.Background()
+	a := store.WithTenant(def, "a")
+	b := store.WithTenant(def, "b")
+	s := New()
+
+	for _, ctx := range []context.Context{def, a, b} {
+		dup, err := s.IsDuplicateOrMark(ctx, "trace-1", time.Hour)
+		assert.NoError(t, err)
+		assert.False(t, dup, "dedup marks are per tenant")
+	}
+
+	assert.NoError(t, s.AppendDuration(a, "v2:dur:x", 1, time.Time{}, 10))
+	assert.NoError(t, s.SetBaseline(a, "v2:base:x", store.Baseline{SampleCount: 60}))
+	assert.NoError(t, s.MarkDirty(a, "v2:base:x"))
+	assert.NoError(t, s.MarkDirty(b, "v2:base:y"))
+	assert.NoError(t, s.MarkDirty(def, "v2:base:z"))
+
+	durs, _ := s.GetDurations(b, "v2:dur:x")
+	assert.Empty(t, durs)
+	keys, _ := s.ListBaselineKeys(a, 50)
+	assert.Equal(t, []string{"v2:base:x"}, keys)
+	keys, _ = s.ListBaselineKeys(def, 50)
+	assert.Empty(t, keys)
+
+	st, _ := s.DirtyQueueStats(a)
+	assert.Equal(t, int64(1), st.Pending)
+	claimed, _ := s.ClaimDirtyBatch(b, 10, time.Minute)
+	assert.Equal(t, []string{"v2:base:y"}, claimed)
+	claimed, _ = s.ClaimDirtyBatch(def, 10, time.Minute)
+	assert.Equal(t, []string{"v2:base:z"}, claimed)
+
+	pruned, _ := s.PruneDurations(def, time.Now().Add(time.Hour))
+	assert.Empty(t, pruned, "pruning is scoped to the tenant")
+	pruned, _ = s.PruneDurations(a, time.Now().Add(time.Hour))
+	assert.Equal(t, []string{"v2:dur:x"}, pruned)
+}
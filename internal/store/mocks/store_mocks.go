@@ -4,6 +4,7 @@ import (
     "context"
     "time"
 
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
     "github.com/alexchang/tempo-latency-anomaly-service/internal/store"
     "github.com/stretchr/testify/mock"
 )
@@ -14,8 +15,8 @@ type MockStore struct {
 }
 
 // DurationOps
-func (m *MockStore) AppendDuration(ctx context.Context, key string, durationMs int64, windowSize int) error {
-    args := m.Called(ctx, key, durationMs, windowSize)
+func (m *MockStore) AppendDuration(ctx context.Context, key string, durationMs int64, at time.Time, windowSize int) error {
+    args := m.Called(ctx, key, durationMs, at, windowSize)
     return args.Error(0)
 }
 
@@ -27,6 +28,64 @@ func (m *MockStore) GetDurations(ctx context.Context, key string) ([]int64, erro
     return nil, args.Error(1)
 }
 
+func (m *MockStore) GetSamples(ctx context.Context, key string, since time.Time) ([]store.Sample, error) {
+    args := m.Called(ctx, key, since)
+    if v, ok := args.Get(0).([]store.Sample); ok {
+        return v, args.Error(1)
+    }
+    return nil, args.Error(1)
+}
+
+func (m *MockStore) PruneDurations(ctx context.Context, before time.Time) ([]string, error) {
+    args := m.Called(ctx, before)
+    if v, ok := args.Get(0).([]string); ok {
+        return v, args.Error(1)
+    }
+    return nil, args.Error(1)
+}
+
+func (m *MockStore) ReplaceSamples(ctx context.Context, key string, samples []store.Sample, windowSize int) error {
+    args := m.Called(ctx, key, samples, windowSize)
+    return args.Error(0)
+}
+
+// SketchOps
+func (m *MockStore) GetSketch(ctx context.Context, key string, since time.Time) ([]store.SketchSlice, error) {
+    args := m.Called(ctx, key, since)
+    if v, ok := args.Get(0).([]store.SketchSlice); ok {
+        return v, args.Error(1)
+    }
+    return nil, args.Error(1)
+}
+
+func (m *MockStore) GetSketches(ctx context.Context, keys []string, since time.Time) (map[string][]store.SketchSlice, error) {
+    args := m.Called(ctx, keys, since)
+    if v, ok := args.Get(0).(map[string][]store.SketchSlice); ok {
+        return v, args.Error(1)
+    }
+    return nil, args.Error(1)
+}
+
+func (m *MockStore) ReplaceSketch(ctx context.Context, key string, slices []store.SketchSlice) error {
+    args := m.Called(ctx, key, slices)
+    return args.Error(0)
+}
+
+// ErrorOps
+func (m *MockStore) GetErrorCounts(ctx context.Context, keys []string, since time.Time) (map[string][]store.ErrorSlice, error) {
+    args := m.Called(ctx, keys, since)
+    if v, ok := args.Get(0).(map[string][]store.ErrorSlice); ok {
+        return v, args.Error(1)
+    }
+    return nil, args.Error(1)
+}
+
+// BatchOps
+func (m *MockStore) IngestBatch(ctx context.Context, b store.IngestBatch) error {
+    args := m.Called(ctx, b)
+    return args.Error(0)
+}
+
 // BaselineOps
 func (m *MockStore) GetBaseline(ctx context.Context, key string) (*store.Baseline, error) {
     args := m.Called(ctx, key)
@@ -49,6 +108,20 @@ func (m *MockStore) GetBaselines(ctx context.Context, keys []string) (map[string
     return nil, args.Error(1)
 }
 
+// HistoryOps
+func (m *MockStore) RecordBaselineHistory(ctx context.Context, key string, snap store.BaselineSnapshot, before time.Time) error {
+    args := m.Called(ctx, key, snap, before)
+    return args.Error(0)
+}
+
+func (m *MockStore) GetBaselineHistories(ctx context.Context, keys []string) (map[string][]store.BaselineSnapshot, error) {
+    args := m.Called(ctx, keys)
+    if v, ok := args.Get(0).(map[string][]store.BaselineSnapshot); ok {
+        return v, args.Error(1)
+    }
+    return nil, args.Error(1)
+}
+
 // DedupOps
 func (m *MockStore) IsDuplicateOrMark(ctx context.Context, traceID string, ttl time.Duration) (bool, error) {
     args := m.Called(ctx, traceID, ttl)
@@ -61,14 +134,32 @@ func (m *MockStore) MarkDirty(ctx context.Context, key string) error {
     return args.Error(0)
 }
 
-func (m *MockStore) PopDirtyBatch(ctx context.Context, count int64) ([]string, error) {
-    args := m.Called(ctx, count)
+func (m *MockStore) ClaimDirtyBatch(ctx context.Context, count int64, lease time.Duration) ([]string, error) {
+    args := m.Called(ctx, count, lease)
     if v, ok := args.Get(0).([]string); ok {
         return v, args.Error(1)
     }
     return nil, args.Error(1)
 }
 
+func (m *MockStore) AckDirty(ctx context.Context, keys ...string) error {
+    args := m.Called(ctx, keys)
+    return args.Error(0)
+}
+
+func (m *MockStore) NackDirty(ctx context.Context, key string, maxRetries int) (bool, error) {
+    args := m.Called(ctx, key, maxRetries)
+    return args.Bool(0), args.Error(1)
+}
+
+func (m *MockStore) DirtyQueueStats(ctx context.Context) (store.DirtyQueueStats, error) {
+    args := m.Called(ctx)
+    if v, ok := args.Get(0).(store.DirtyQueueStats); ok {
+        return v, args.Error(1)
+    }
+    return store.DirtyQueueStats{}, args.Error(1)
+}
+
 // ListOps
 func (m *MockStore) ListBaselineKeys(ctx context.Context, minSamples int) ([]string, error) {
     args := m.Called(ctx, minSamples)
@@ -78,6 +169,22 @@ func (m *MockStore) ListBaselineKeys(ctx context.Context, minSamples int) ([]str
     return nil, args.Error(1)
 }
 
+func (m *MockStore) ListSeries(ctx context.Context, kind domain.KeyKind) ([]store.SeriesIndexEntry, error) {
+    args := m.Called(ctx, kind)
+    if v, ok := args.Get(0).([]store.SeriesIndexEntry); ok {
+        return v, args.Error(1)
+    }
+    return nil, args.Error(1)
+}
+
+func (m *MockStore) ListKeys(ctx context.Context, prefix string) ([]string, error) {
+    args := m.Called(ctx, prefix)
+    if v, ok := args.Get(0).([]string); ok {
+        return v, args.Error(1)
+    }
+    return nil, args.Error(1)
+}
+
 // Close
 func (m *MockStore) Close() error {
     args := m.Called()
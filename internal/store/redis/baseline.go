@@ -3,6 +3,7 @@ package redis
 import (
     "context"
     "strconv"
+    "strings"
     "time"
 
     goRedis "github.com/redis/go-redis/v9"
@@ -16,12 +17,15 @@ const (
     fieldMAD         = "mad"
     fieldSampleCount = "sampleCount"
     fieldUpdatedAt   = "updatedAt"
+    fieldRejected    = "rejected"
+    // fieldPercentilePrefix prefixes one field per extra percentile, e.g. "pct:p99".
+    fieldPercentilePrefix = "pct:"
     timeLayout       = time.RFC3339Nano
 )
 
 // GetBaseline reads baseline stats from Redis hash at key.
 func (c *Client) GetBaseline(ctx context.Context, key string) (*store.Baseline, error) {
-    m, err := c.rdb.HGetAll(ctx, key).Result()
+    m, err := c.rdb.HGetAll(ctx, c.key(ctx, key)).Result()
     if err != nil {
         return nil, err
     }
@@ -61,11 +65,14 @@ func (c *Client) GetBaseline(ctx context.Context, key string) (*store.Baseline,
         MAD:         parseFloat(fieldMAD),
         SampleCount: parseInt(fieldSampleCount),
         UpdatedAt:   parseTime(fieldUpdatedAt),
+        Percentiles: parsePercentiles(m),
+        Rejected:    parseInt(fieldRejected),
     }
     return b, nil
 }
 
-// SetBaseline writes baseline stats to Redis hash at key.
+// SetBaseline writes baseline stats to Redis hash at key and records the bucket's
+// sample count in the series index.
 func (c *Client) SetBaseline(ctx context.Context, key string, b store.Baseline) error {
     // Ensure UpdatedAt is set; if zero, set to now.
     if b.UpdatedAt.IsZero() {
@@ -79,11 +86,50 @@ func (c *Client) SetBaseline(ctx context.Context, key string, b store.Baseline)
         fieldSampleCount: strconv.Itoa(b.SampleCount),
         fieldUpdatedAt:   b.UpdatedAt.Format(timeLayout),
     }
-    return c.rdb.HSet(ctx, key, fields).Err()
+    if b.Rejected > 0 {
+        fields[fieldRejected] = strconv.Itoa(b.Rejected)
+    }
+    for name, v := range b.Percentiles {
+        fields[fieldPercentilePrefix+name] = strconv.FormatFloat(v, 'f', -1, 64)
+    }
+    args := make([]interface{}, 0, 2*len(fields))
+    for f, v := range fields {
+        args = append(args, f, v)
+    }
+    _, err := c.rdb.TxPipelined(ctx, func(pipe goRedis.Pipeliner) error {
+        setBaselineScript.Eval(ctx, pipe, []string{c.key(ctx, key)}, args...)
+        c.indexBaseline(ctx, pipe, key, b.SampleCount)
+        return nil
+    })
+    return err
 }
 
+// setBaselineScript replaces the whole hash at KEYS[1] with the ARGV field/value
+// pairs, so percentiles dropped from the config disappear, but keeps the stored
+// rejected count unless ARGV sets one.
+var setBaselineScript = goRedis.NewScript(`
+local rejected = redis.call('HGET', KEYS[1], 'rejected')
+redis.call('DEL', KEYS[1])
+redis.call('HSET', KEYS[1], unpack(ARGV))
+if rejected then
+    redis.call('HSETNX', KEYS[1], 'rejected', rejected)
+end
+return 1
+`)
+
+// incrRejectedScript increments the rejected count of the baseline at KEYS[1]
+// if it exists, so a missing baseline is not recreated as a bare counter.
+var incrRejectedScript = goRedis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+    return redis.call('HINCRBY', KEYS[1], 'rejected', 1)
+end
+return 0
+`)
+
 // GetBaselines reads multiple baseline hashes using a Redis pipeline for efficiency.
 // Returns a map for keys that exist (missing keys are omitted).
+// In Cluster mode the pipeline is split per node; keys of one service/endpoint share
+// a hash tag, so the usual fallback lookups stay on a single node.
 func (c *Client) GetBaselines(ctx context.Context, keys []string) (map[string]*store.Baseline, error) {
     if len(keys) == 0 {
         return map[string]*store.Baseline{}, nil
@@ -94,7 +140,7 @@ func (c *Client) GetBaselines(ctx context.Context, keys []string) (map[string]*s
 
     // Queue HGETALL for each key in the pipeline
     for i, k := range keys {
-        cmds[i] = pipe.HGetAll(ctx, k)
+        cmds[i] = pipe.HGetAll(ctx, c.key(ctx, k))
     }
 
     if _, err := pipe.Exec(ctx); err != nil {
@@ -143,8 +189,30 @@ func (c *Client) GetBaselines(ctx context.Context, keys []string) (map[string]*s
             MAD:         parseFloat(fieldMAD),
             SampleCount: parseInt(fieldSampleCount),
             UpdatedAt:   parseTime(fieldUpdatedAt),
+            Percentiles: parsePercentiles(m),
+            Rejected:    parseInt(fieldRejected),
         }
     }
 
     return out, nil
 }
+
+// parsePercentiles collects the pct:* fields of a baseline hash, or nil if it has none.
+func parsePercentiles(m map[string]string) map[string]float64 {
+    var out map[string]float64
+    for field, v := range m {
+        name, ok := strings.CutPrefix(field, fieldPercentilePrefix)
+        if !ok {
+            continue
+        }
+        f, err := strconv.ParseFloat(v, 64)
+        if err != nil {
+            continue
+        }
+        if out == nil {
+            out = make(map[string]float64)
+        }
+        out[name] = f
+    }
+    return out
+}
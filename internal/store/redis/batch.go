@@ -0,0 +1,114 @@
+package redis
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "encoding/json"
+
+    goRedis "github.com/redis/go-redis/v9"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+// IngestBatch writes all samples, sketch and outcome counts, dirty marks and rejected counts inside one
+// MULTI/EXEC transaction.
+// Each window is trimmed once after all of its inserts, so a crash can no longer leave
+// a window grown but untrimmed.
+//
+// In Cluster mode one transaction cannot span hash slots: the writes of each
+// service/endpoint (which share a hash tag) are atomic together, but not with the
+// dirty queue. The dirty keys are therefore recorded as an intent in the queue's
+// slot first and enqueued once the series writes are done; if the process dies in
+// between, ClaimDirtyBatch replays the intent (see replayDirtyIntents).
+func (c *Client) IngestBatch(ctx context.Context, b store.IngestBatch) error {
+    if len(b.Samples) == 0 && len(b.Sketches) == 0 && len(b.DirtyKeys) == 0 && len(b.Rejected) == 0 && len(b.Outcomes) == 0 {
+        return nil
+    }
+
+    if !c.cluster || len(b.DirtyKeys) == 0 {
+        _, err := c.rdb.TxPipelined(ctx, func(pipe goRedis.Pipeliner) error {
+            c.writeSeries(ctx, pipe, b)
+            c.enqueueDirty(ctx, pipe, b.DirtyKeys)
+            return nil
+        })
+        return err
+    }
+
+    id, err := newBatchID()
+    if err != nil {
+        return err
+    }
+    keys, err := json.Marshal(b.DirtyKeys)
+    if err != nil {
+        return err
+    }
+    if _, err := c.rdb.TxPipelined(ctx, func(pipe goRedis.Pipeliner) error {
+        pipe.ZAdd(ctx, c.key(ctx, dirtyIntentsKey), goRedis.Z{Score: nowMs(), Member: id})
+        pipe.HSet(ctx, c.key(ctx, dirtyIntentKeysKey), id, keys)
+        return nil
+    }); err != nil {
+        return err
+    }
+    // go-redis runs one MULTI/EXEC per slot
+    if _, err := c.rdb.TxPipelined(ctx, func(pipe goRedis.Pipeliner) error {
+        c.writeSeries(ctx, pipe, b)
+        return nil
+    }); err != nil {
+        return err
+    }
+    _, err = c.rdb.TxPipelined(ctx, func(pipe goRedis.Pipeliner) error {
+        c.enqueueDirty(ctx, pipe, b.DirtyKeys)
+        pipe.ZRem(ctx, c.key(ctx, dirtyIntentsKey), id)
+        pipe.HDel(ctx, c.key(ctx, dirtyIntentKeysKey), id)
+        return nil
+    })
+    return err
+}
+
+// writeSeries queues the per-series writes of b on pipe: samples and window
+// trims, sketch and outcome counts and rejected counts.
+func (c *Client) writeSeries(ctx context.Context, pipe goRedis.Pipeliner, b store.IngestBatch) {
+    trimmed := make(map[string]bool, len(b.Samples))
+    for _, s := range b.Samples {
+        c.appendDuration(ctx, pipe, s.Key, s.DurationMs, s.At)
+    }
+    for _, s := range b.Samples {
+        if trimmed[s.Key] {
+            continue
+        }
+        trimmed[s.Key] = true
+        c.trimWindow(ctx, pipe, s.Key, b.WindowSize)
+    }
+    for _, s := range b.Sketches {
+        c.addSketch(ctx, pipe, s)
+    }
+    for _, o := range b.Outcomes {
+        c.addOutcome(ctx, pipe, o)
+    }
+    for _, k := range b.Rejected {
+        incrRejectedScript.Eval(ctx, pipe, []string{c.key(ctx, k)})
+    }
+}
+
+// enqueueDirty queues keys on pipe; already pending keys keep their enqueue time.
+func (c *Client) enqueueDirty(ctx context.Context, pipe goRedis.Pipeliner, keys []string) {
+    if len(keys) == 0 {
+        return
+    }
+    now := nowMs()
+    members := make([]goRedis.Z, len(keys))
+    for i, k := range keys {
+        members[i] = goRedis.Z{Score: now, Member: k}
+    }
+    pipe.ZAddNX(ctx, c.key(ctx, dirtyQueueKey), members...)
+}
+
+// newBatchID returns a random ID for a dirty intent.
+func newBatchID() (string, error) {
+    var b [8]byte
+    if _, err := rand.Read(b[:]); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(b[:]), nil
+}
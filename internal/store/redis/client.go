@@ -2,7 +2,10 @@ package redis
 
 import (
     "context"
+    "crypto/tls"
+    "crypto/x509"
     "fmt"
+    "os"
 
     goRedis "github.com/redis/go-redis/v9"
 
@@ -10,25 +13,118 @@ import (
     "github.com/alexchang/tempo-latency-anomaly-service/internal/store"
 )
 
-// Client implements store.Store backed by Redis.
+// Client implements store.Store backed by Redis (single node, Sentinel or Cluster).
 type Client struct {
-    rdb *goRedis.Client
+    rdb     goRedis.UniversalClient
+    cluster bool
 }
 
 // New creates a new Redis client using application config and verifies connectivity.
-func New(cfg config.RedisConfig) (store.Store, error) {
-    addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
-    rdb := goRedis.NewClient(&goRedis.Options{
-        Addr:     addr,
-        Password: cfg.Password,
-        DB:       cfg.DB,
-    })
+// The topology is selected from cfg: Sentinel when MasterName is set, Cluster when
+// ClusterAddrs is set, otherwise a single node at Host:Port. The one-off data
+// migrations and the series index build run in every tenant's key space.
+func New(cfg config.RedisConfig, tenants []string) (store.Store, error) {
+    tlsCfg, err := buildTLSConfig(cfg.TLS)
+    if err != nil {
+        return nil, fmt.Errorf("redis tls: %w", err)
+    }
+
+    var (
+        rdb     goRedis.UniversalClient
+        cluster bool
+    )
+    switch {
+    case cfg.MasterName != "":
+        rdb = goRedis.NewFailoverClient(&goRedis.FailoverOptions{
+            MasterName:       cfg.MasterName,
+            SentinelAddrs:    cfg.SentinelAddrs,
+            SentinelUsername: cfg.SentinelUsername,
+            SentinelPassword: cfg.SentinelPassword,
+            Username:         cfg.Username,
+            Password:         cfg.Password,
+            DB:               cfg.DB,
+            TLSConfig:        tlsCfg,
+        })
+    case len(cfg.ClusterAddrs) > 0:
+        rdb = goRedis.NewClusterClient(&goRedis.ClusterOptions{
+            Addrs:     cfg.ClusterAddrs,
+            Username:  cfg.Username,
+            Password:  cfg.Password,
+            TLSConfig: tlsCfg,
+        })
+        cluster = true
+    default:
+        rdb = goRedis.NewClient(&goRedis.Options{
+            Addr:      fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+            Username:  cfg.Username,
+            Password:  cfg.Password,
+            DB:        cfg.DB,
+            TLSConfig: tlsCfg,
+        })
+    }
 
     if err := rdb.Ping(context.Background()).Err(); err != nil {
+        _ = rdb.Close()
         return nil, fmt.Errorf("redis ping: %w", err)
     }
 
-    return &Client{rdb: rdb}, nil
+    c := &Client{rdb: rdb, cluster: cluster}
+    for _, tenant := range tenants {
+        if err := c.prepare(store.WithTenant(context.Background(), tenant)); err != nil {
+            _ = rdb.Close()
+            return nil, fmt.Errorf("tenant %q: %w", tenant, err)
+        }
+    }
+    return c, nil
+}
+
+// prepare migrates the tenant in ctx to the current data layout and builds its
+// series index. Each step records a marker, so later starts skip it.
+func (c *Client) prepare(ctx context.Context) error {
+    if err := c.migrateLegacyDirtySet(ctx); err != nil {
+        return fmt.Errorf("migrate dirty set: %w", err)
+    }
+    if err := c.migrateLegacyDurationLists(ctx); err != nil {
+        return fmt.Errorf("migrate duration lists: %w", err)
+    }
+    if err := c.migrateKeysToV2(ctx); err != nil {
+        return fmt.Errorf("migrate keys: %w", err)
+    }
+    if err := c.buildSeriesIndex(ctx); err != nil {
+        return fmt.Errorf("build series index: %w", err)
+    }
+    return nil
+}
+
+// buildTLSConfig returns nil when TLS is disabled.
+func buildTLSConfig(cfg config.RedisTLSConfig) (*tls.Config, error) {
+    if !cfg.Enabled {
+        return nil, nil
+    }
+    tlsCfg := &tls.Config{
+        MinVersion:         tls.VersionTLS12,
+        ServerName:         cfg.ServerName,
+        InsecureSkipVerify: cfg.InsecureSkipVerify, // #nosec G402 -- opt-in via config
+    }
+    if cfg.CAFile != "" {
+        pem, err := os.ReadFile(cfg.CAFile)
+        if err != nil {
+            return nil, fmt.Errorf("read ca file: %w", err)
+        }
+        pool := x509.NewCertPool()
+        if !pool.AppendCertsFromPEM(pem) {
+            return nil, fmt.Errorf("no certificates found in %s", cfg.CAFile)
+        }
+        tlsCfg.RootCAs = pool
+    }
+    if cfg.CertFile != "" || cfg.KeyFile != "" {
+        cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+        if err != nil {
+            return nil, fmt.Errorf("load client certificate: %w", err)
+        }
+        tlsCfg.Certificates = []tls.Certificate{cert}
+    }
+    return tlsCfg, nil
 }
 
 // Close releases Redis resources.
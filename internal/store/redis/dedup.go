@@ -10,7 +10,7 @@ import (
 // Returns true if the traceID has been seen (duplicate), false if newly marked.
 func (c *Client) IsDuplicateOrMark(ctx context.Context, traceID string, ttl time.Duration) (bool, error) {
     key := fmt.Sprintf("seen:%s", traceID)
-    ok, err := c.rdb.SetNX(ctx, key, "1", ttl).Result()
+    ok, err := c.rdb.SetNX(ctx, c.key(ctx, key), "1", ttl).Result()
     if err != nil {
         return false, err
     }
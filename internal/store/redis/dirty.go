@@ -2,24 +2,222 @@ package redis
 
 import (
     "context"
+    "encoding/json"
+    "fmt"
+    "strconv"
+    "time"
+
+    goRedis "github.com/redis/go-redis/v9"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+// Dirty queue keys. They share the {dirty} hash tag so the Lua scripts below,
+// which touch several of them, stay on one slot in Cluster mode.
+const (
+    // dirtyQueueKey is a sorted set of pending keys scored by enqueue time (unix ms).
+    dirtyQueueKey = "{dirty}:queue"
+    // dirtyLeaseKey is a sorted set of claimed keys scored by lease deadline (unix ms).
+    dirtyLeaseKey = "{dirty}:leases"
+    // dirtyRetryKey is a hash of key -> consecutive failure count.
+    dirtyRetryKey = "{dirty}:retries"
+    // dirtyDeadKey is the dead-letter set.
+    dirtyDeadKey = "{dirty}:dead"
+    // dirtyIntentsKey is a sorted set of in-flight Cluster batch IDs scored by start time (unix ms).
+    dirtyIntentsKey = "{dirty}:intents"
+    // dirtyIntentKeysKey is a hash of batch ID -> JSON list of the batch's dirty keys.
+    dirtyIntentKeysKey = "{dirty}:intent-keys"
+
+    // legacyDirtySetKey is the plain set used before the lease-based queue.
+    legacyDirtySetKey = "dirtyKeys"
 )
 
-const dirtySetKey = "dirtyKeys"
+// dirtyIntentGrace is how long a Cluster batch may take between recording its
+// dirty intent and enqueueing its keys before the intent is replayed.
+const dirtyIntentGrace = time.Minute
 
-// MarkDirty adds key to the global dirty set.
+// claimScript re-queues expired leases, then moves up to ARGV[2] of the oldest
+// pending keys to the lease set with deadline ARGV[1]+ARGV[3].
+var claimScript = goRedis.NewScript(`
+local now = tonumber(ARGV[1])
+local expired = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', now)
+for _, k in ipairs(expired) do
+    redis.call('ZREM', KEYS[2], k)
+    redis.call('ZADD', KEYS[1], 'NX', now, k)
+end
+local keys = redis.call('ZRANGE', KEYS[1], 0, tonumber(ARGV[2]) - 1)
+local deadline = now + tonumber(ARGV[3])
+for _, k in ipairs(keys) do
+    redis.call('ZREM', KEYS[1], k)
+    redis.call('ZADD', KEYS[2], deadline, k)
+end
+return keys
+`)
+
+// nackScript releases the lease of ARGV[1] and either re-queues it or, after
+// more than ARGV[3] failures, moves it to the dead-letter set. Returns 1 if dead-lettered.
+var nackScript = goRedis.NewScript(`
+redis.call('ZREM', KEYS[2], ARGV[1])
+local n = redis.call('HINCRBY', KEYS[3], ARGV[1], 1)
+if n > tonumber(ARGV[3]) then
+    redis.call('HDEL', KEYS[3], ARGV[1])
+    redis.call('SADD', KEYS[4], ARGV[1])
+    return 1
+end
+redis.call('ZADD', KEYS[1], 'NX', ARGV[2], ARGV[1])
+return 0
+`)
+
+// MarkDirty enqueues key; an already pending key keeps its original enqueue time.
 func (c *Client) MarkDirty(ctx context.Context, key string) error {
-    return c.rdb.SAdd(ctx, dirtySetKey, key).Err()
+    return c.rdb.ZAddNX(ctx, c.key(ctx, dirtyQueueKey), goRedis.Z{Score: nowMs(), Member: key}).Err()
 }
 
-// PopDirtyBatch pops up to count keys from the dirty set.
-func (c *Client) PopDirtyBatch(ctx context.Context, count int64) ([]string, error) {
+// ClaimDirtyBatch leases up to count pending keys for the lease duration.
+func (c *Client) ClaimDirtyBatch(ctx context.Context, count int64, lease time.Duration) ([]string, error) {
     if count <= 0 {
         count = 1
     }
-    res, err := c.rdb.SPopN(ctx, dirtySetKey, count).Result()
+    if c.cluster {
+        if err := c.replayDirtyIntents(ctx); err != nil {
+            return nil, fmt.Errorf("replay dirty intents: %w", err)
+        }
+    }
+    keys := []string{c.key(ctx, dirtyQueueKey), c.key(ctx, dirtyLeaseKey)}
+    res, err := claimScript.Run(ctx, c.rdb, keys, nowMs(), count, lease.Milliseconds()).StringSlice()
     if err != nil {
         return nil, err
     }
     return res, nil
 }
 
+// replayDirtyIntents enqueues the dirty keys of Cluster batches that recorded
+// their intent more than dirtyIntentGrace ago but never enqueued them, e.g.
+// because the process died between slots (see IngestBatch). Their series writes
+// may or may not have landed; recomputing an unchanged key is harmless.
+func (c *Client) replayDirtyIntents(ctx context.Context) error {
+    cutoff := time.Now().Add(-dirtyIntentGrace).UnixMilli()
+    ids, err := c.rdb.ZRangeByScore(ctx, c.key(ctx, dirtyIntentsKey), &goRedis.ZRangeBy{
+        Min: "-inf", Max: strconv.FormatInt(cutoff, 10), Count: 100,
+    }).Result()
+    if err != nil || len(ids) == 0 {
+        return err
+    }
+    vals, err := c.rdb.HMGet(ctx, c.key(ctx, dirtyIntentKeysKey), ids...).Result()
+    if err != nil {
+        return err
+    }
+    var keys []string
+    for _, v := range vals {
+        raw, ok := v.(string)
+        if !ok {
+            continue
+        }
+        var batch []string
+        if err := json.Unmarshal([]byte(raw), &batch); err != nil {
+            continue
+        }
+        keys = append(keys, batch...)
+    }
+    members := make([]interface{}, len(ids))
+    for i, id := range ids {
+        members[i] = id
+    }
+    _, err = c.rdb.TxPipelined(ctx, func(pipe goRedis.Pipeliner) error {
+        c.enqueueDirty(ctx, pipe, keys)
+        pipe.ZRem(ctx, c.key(ctx, dirtyIntentsKey), members...)
+        pipe.HDel(ctx, c.key(ctx, dirtyIntentKeysKey), ids...)
+        return nil
+    })
+    return err
+}
+
+// AckDirty releases leases and clears retry counters and dead letters for keys.
+func (c *Client) AckDirty(ctx context.Context, keys ...string) error {
+    if len(keys) == 0 {
+        return nil
+    }
+    members := make([]interface{}, len(keys))
+    for i, k := range keys {
+        members[i] = k
+    }
+    _, err := c.rdb.TxPipelined(ctx, func(pipe goRedis.Pipeliner) error {
+        pipe.ZRem(ctx, c.key(ctx, dirtyLeaseKey), members...)
+        pipe.HDel(ctx, c.key(ctx, dirtyRetryKey), keys...)
+        pipe.SRem(ctx, c.key(ctx, dirtyDeadKey), members...)
+        return nil
+    })
+    return err
+}
+
+// NackDirty re-queues a failed key or dead-letters it after maxRetries failures.
+func (c *Client) NackDirty(ctx context.Context, key string, maxRetries int) (bool, error) {
+    keys := []string{c.key(ctx, dirtyQueueKey), c.key(ctx, dirtyLeaseKey), c.key(ctx, dirtyRetryKey), c.key(ctx, dirtyDeadKey)}
+    dead, err := nackScript.Run(ctx, c.rdb, keys, key, nowMs(), maxRetries).Int()
+    if err != nil {
+        return false, err
+    }
+    return dead == 1, nil
+}
+
+// DirtyQueueStats reads queue depth, leases, dead letters and the oldest pending age.
+func (c *Client) DirtyQueueStats(ctx context.Context) (store.DirtyQueueStats, error) {
+    var (
+        pending, leased *goRedis.IntCmd
+        dead            *goRedis.IntCmd
+        oldest          *goRedis.ZSliceCmd
+    )
+    _, err := c.rdb.Pipelined(ctx, func(pipe goRedis.Pipeliner) error {
+        pending = pipe.ZCard(ctx, c.key(ctx, dirtyQueueKey))
+        leased = pipe.ZCard(ctx, c.key(ctx, dirtyLeaseKey))
+        dead = pipe.SCard(ctx, c.key(ctx, dirtyDeadKey))
+        oldest = pipe.ZRangeWithScores(ctx, c.key(ctx, dirtyQueueKey), 0, 0)
+        return nil
+    })
+    if err != nil {
+        return store.DirtyQueueStats{}, err
+    }
+
+    st := store.DirtyQueueStats{
+        Pending:    pending.Val(),
+        Leased:     leased.Val(),
+        DeadLetter: dead.Val(),
+    }
+    if z := oldest.Val(); len(z) > 0 {
+        if age := time.Duration(nowMs()-z[0].Score) * time.Millisecond; age > 0 {
+            st.OldestPendingAge = age
+        }
+    }
+    return st, nil
+}
+
+// migrateLegacyDirtySet moves keys from the pre-lease dirty set into the queue.
+// Members are removed only after they were enqueued, so an interrupted run loses nothing.
+func (c *Client) migrateLegacyDirtySet(ctx context.Context) error {
+    for {
+        members, err := c.rdb.SRandMemberN(ctx, c.key(ctx, legacyDirtySetKey), 1000).Result()
+        if err != nil {
+            return err
+        }
+        if len(members) == 0 {
+            return nil
+        }
+        now := nowMs()
+        zs := make([]goRedis.Z, len(members))
+        rem := make([]interface{}, len(members))
+        for i, m := range members {
+            zs[i] = goRedis.Z{Score: now, Member: m}
+            rem[i] = m
+        }
+        if err := c.rdb.ZAddNX(ctx, c.key(ctx, dirtyQueueKey), zs...).Err(); err != nil {
+            return err
+        }
+        if err := c.rdb.SRem(ctx, c.key(ctx, legacyDirtySetKey), rem...).Err(); err != nil {
+            return err
+        }
+    }
+}
+
+func nowMs() float64 {
+    return float64(time.Now().UnixMilli())
+}
@@ -2,36 +2,224 @@ package redis
 
 import (
     "context"
+    "math/rand/v2"
+    "sort"
     "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    goRedis "github.com/redis/go-redis/v9"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/store"
 )
 
-// AppendDuration pushes durationMs to the list at key and trims to windowSize.
-func (c *Client) AppendDuration(ctx context.Context, key string, durationMs int64, windowSize int) error {
-    if err := c.rdb.LPush(ctx, key, durationMs).Err(); err != nil {
-        return err
+// Duration windows are sorted sets scored by sample time (unix ms). Members are
+// "{durationMs}:{unixNano}:{nonce}"; the nonce keeps equal samples from collapsing.
+
+// AppendDuration adds durationMs observed at at to the window at key and trims it
+// to the windowSize most recent samples.
+func (c *Client) AppendDuration(ctx context.Context, key string, durationMs int64, at time.Time, windowSize int) error {
+    _, err := c.rdb.TxPipelined(ctx, func(pipe goRedis.Pipeliner) error {
+        c.appendDuration(ctx, pipe, key, durationMs, at)
+        c.trimWindow(ctx, pipe, key, windowSize)
+        return nil
+    })
+    return err
+}
+
+func (c *Client) appendDuration(ctx context.Context, pipe goRedis.Pipeliner, key string, durationMs int64, at time.Time) {
+    if at.IsZero() {
+        at = time.Now()
     }
-    // Keep only the most recent windowSize items (list is newest at head due to LPUSH)
-    return c.rdb.LTrim(ctx, key, 0, int64(windowSize-1)).Err()
+    pipe.ZAdd(ctx, c.key(ctx, key), goRedis.Z{Score: float64(at.UnixMilli()), Member: encodeSample(durationMs, at)})
 }
 
-// GetDurations returns all duration samples (ms) from the list at key.
+// trimWindow keeps only the windowSize highest-scored (most recent) samples.
+func (c *Client) trimWindow(ctx context.Context, pipe goRedis.Pipeliner, key string, windowSize int) {
+    if windowSize <= 0 {
+        return
+    }
+    pipe.ZRemRangeByRank(ctx, c.key(ctx, key), 0, int64(-windowSize-1))
+}
+
+// GetDurations returns all duration samples (ms) in the window at key, newest first.
 func (c *Client) GetDurations(ctx context.Context, key string) ([]int64, error) {
-    vals, err := c.rdb.LRange(ctx, key, 0, -1).Result()
+    samples, err := c.GetSamples(ctx, key, time.Time{})
+    if err != nil {
+        return nil, err
+    }
+    out := make([]int64, len(samples))
+    for i, s := range samples {
+        out[i] = s.DurationMs
+    }
+    return out, nil
+}
+
+// GetSamples returns the samples at key observed at or after since, newest first.
+func (c *Client) GetSamples(ctx context.Context, key string, since time.Time) ([]store.Sample, error) {
+    min := "-inf"
+    if !since.IsZero() {
+        min = strconv.FormatInt(since.UnixMilli(), 10)
+    }
+    vals, err := c.rdb.ZRevRangeByScore(ctx, c.key(ctx, key), &goRedis.ZRangeBy{Min: min, Max: "+inf"}).Result()
     if err != nil {
         return nil, err
     }
-    out := make([]int64, 0, len(vals))
-    for _, s := range vals {
-        if s == "" {
+    out := make([]store.Sample, 0, len(vals))
+    for _, v := range vals {
+        s, ok := decodeSample(v)
+        if !ok {
+            // skip malformed entries rather than failing entire read
             continue
         }
-        n, err := strconv.ParseInt(s, 10, 64)
-        if err != nil {
-            // skip malformed entries rather than failing entire read
+        if !since.IsZero() && s.At.Before(since) {
             continue
         }
-        out = append(out, n)
+        out = append(out, s)
     }
     return out, nil
 }
 
+// ReplaceSamples replaces the window at key with samples in one transaction and
+// trims it to the windowSize most recent samples.
+func (c *Client) ReplaceSamples(ctx context.Context, key string, samples []store.Sample, windowSize int) error {
+    _, err := c.rdb.TxPipelined(ctx, func(pipe goRedis.Pipeliner) error {
+        pipe.Del(ctx, c.key(ctx, key))
+        if len(samples) == 0 {
+            return nil
+        }
+        for _, s := range samples {
+            c.appendDuration(ctx, pipe, key, s.DurationMs, s.At)
+        }
+        c.trimWindow(ctx, pipe, key, windowSize)
+        return nil
+    })
+    return err
+}
+
+// PruneDurations removes samples observed before the given time from every
+// duration and span duration window, and expired days from every sketch and
+// error count.
+// Redis deletes sorted sets and hashes that become empty.
+func (c *Client) PruneDurations(ctx context.Context, before time.Time) ([]string, error) {
+    max := "(" + strconv.FormatInt(before.UnixMilli(), 10)
+    var (
+        mu     sync.Mutex
+        pruned []string
+    )
+    patterns := []string{domain.KeyPrefix(domain.KindDuration) + "*", domain.KeyPrefix(domain.KindSpanDuration) + "*"}
+    for _, pattern := range patterns {
+        err := c.scan(ctx, pattern, func(rdb goRedis.Cmdable, keys []string) error {
+            pipe := rdb.Pipeline()
+            cmds := make([]*goRedis.IntCmd, len(keys))
+            for i, key := range keys {
+                cmds[i] = pipe.ZRemRangeByScore(ctx, key, "-inf", max)
+            }
+            if _, err := pipe.Exec(ctx); err != nil {
+                return err
+            }
+
+            mu.Lock()
+            defer mu.Unlock()
+            for i, key := range keys {
+                if cmds[i].Val() > 0 {
+                    pruned = append(pruned, c.logicalKey(ctx, key))
+                }
+            }
+            return nil
+        })
+        if err != nil {
+            return nil, err
+        }
+    }
+    if err := c.pruneSketches(ctx, before); err != nil {
+        return nil, err
+    }
+    if err := c.pruneErrorCounts(ctx, before); err != nil {
+        return nil, err
+    }
+    sort.Strings(pruned)
+    return pruned, nil
+}
+
+// durationListsVersionKey marks that legacy duration lists have been converted.
+const durationListsVersionKey = "meta:durationLists"
+
+// migrateLegacyDurationLists converts v1 dur:/spandur: LISTs written before samples
+// carried timestamps into sorted sets, once. Legacy samples are stamped with the
+// migration time (one millisecond apart, keeping their order), so they age out one
+// retention period after the upgrade.
+func (c *Client) migrateLegacyDurationLists(ctx context.Context) error {
+    n, err := c.rdb.Get(ctx, c.key(ctx, durationListsVersionKey)).Int()
+    if err == nil && n >= 1 {
+        return nil
+    }
+    if err != nil && err != goRedis.Nil {
+        return err
+    }
+
+    for _, pattern := range []string{"dur:*", "spandur:*"} {
+        err := c.scan(ctx, pattern, func(rdb goRedis.Cmdable, keys []string) error {
+            for _, key := range keys {
+                typ, err := rdb.Type(ctx, key).Result()
+                if err != nil {
+                    return err
+                }
+                if typ != "list" {
+                    continue
+                }
+                vals, err := rdb.LRange(ctx, key, 0, -1).Result()
+                if err != nil {
+                    return err
+                }
+                now := time.Now()
+                members := make([]goRedis.Z, 0, len(vals))
+                for i, v := range vals {
+                    d, err := strconv.ParseInt(v, 10, 64)
+                    if err != nil {
+                        continue
+                    }
+                    at := now.Add(-time.Duration(i) * time.Millisecond)
+                    members = append(members, goRedis.Z{Score: float64(at.UnixMilli()), Member: encodeSample(d, at)})
+                }
+                _, err = rdb.TxPipelined(ctx, func(pipe goRedis.Pipeliner) error {
+                    pipe.Del(ctx, key)
+                    if len(members) > 0 {
+                        pipe.ZAdd(ctx, key, members...)
+                    }
+                    return nil
+                })
+                if err != nil {
+                    return err
+                }
+            }
+            return nil
+        })
+        if err != nil {
+            return err
+        }
+    }
+    return c.rdb.Set(ctx, c.key(ctx, durationListsVersionKey), 1, 0).Err()
+}
+
+func encodeSample(durationMs int64, at time.Time) string {
+    return strconv.FormatInt(durationMs, 10) + ":" + strconv.FormatInt(at.UnixNano(), 10) + ":" + strconv.FormatUint(uint64(rand.Uint32()), 36)
+}
+
+func decodeSample(member string) (store.Sample, bool) {
+    parts := strings.SplitN(member, ":", 3)
+    if len(parts) < 2 {
+        return store.Sample{}, false
+    }
+    d, err := strconv.ParseInt(parts[0], 10, 64)
+    if err != nil {
+        return store.Sample{}, false
+    }
+    ns, err := strconv.ParseInt(parts[1], 10, 64)
+    if err != nil {
+        return store.Sample{}, false
+    }
+    return store.Sample{At: time.Unix(0, ns).UTC(), DurationMs: d}, true
+}
@@ -0,0 +1,118 @@
+package redis
+
+import (
+    "context"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    goRedis "github.com/redis/go-redis/v9"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+// Error counts are hashes with two fields per day, "{yyyymmdd}:n" (requests) and
+// "{yyyymmdd}:e" (errors), laid out like sketches so ingest updates them with
+// HINCRBY and the pruner drops expired days with HDEL.
+const (
+    errorFieldRequests = "n"
+    errorFieldErrors   = "e"
+)
+
+func errorField(day time.Time, kind string) string {
+    return day.Format(sketchDayLayout) + ":" + kind
+}
+
+func parseErrorField(field string) (time.Time, string, bool) {
+    dayStr, kind, ok := strings.Cut(field, ":")
+    if !ok || (kind != errorFieldRequests && kind != errorFieldErrors) {
+        return time.Time{}, "", false
+    }
+    day, err := time.Parse(sketchDayLayout, dayStr)
+    if err != nil {
+        return time.Time{}, "", false
+    }
+    return day, kind, true
+}
+
+func (c *Client) addOutcome(ctx context.Context, pipe goRedis.Pipeliner, o store.OutcomeSample) {
+    at := o.At
+    if at.IsZero() {
+        at = time.Now()
+    }
+    day := store.SketchDay(at)
+    key := c.key(ctx, o.Key)
+    pipe.HIncrBy(ctx, key, errorField(day, errorFieldRequests), 1)
+    if o.Error {
+        pipe.HIncrBy(ctx, key, errorField(day, errorFieldErrors), 1)
+    }
+}
+
+// GetErrorCounts fetches several error counts in one pipeline.
+func (c *Client) GetErrorCounts(ctx context.Context, keys []string, since time.Time) (map[string][]store.ErrorSlice, error) {
+    out := make(map[string][]store.ErrorSlice, len(keys))
+    if len(keys) == 0 {
+        return out, nil
+    }
+    pipe := c.rdb.Pipeline()
+    cmds := make([]*goRedis.MapStringStringCmd, len(keys))
+    for i, key := range keys {
+        cmds[i] = pipe.HGetAll(ctx, c.key(ctx, key))
+    }
+    if _, err := pipe.Exec(ctx); err != nil {
+        return nil, err
+    }
+    for i, key := range keys {
+        if slices := decodeErrorCounts(cmds[i].Val(), since); len(slices) > 0 {
+            out[key] = slices
+        }
+    }
+    return out, nil
+}
+
+// decodeErrorCounts groups the fields of an error count hash into day slices
+// ending after since, newest first.
+func decodeErrorCounts(vals map[string]string, since time.Time) []store.ErrorSlice {
+    byDay := make(map[time.Time]*store.ErrorSlice)
+    for field, v := range vals {
+        day, kind, ok := parseErrorField(field)
+        if !ok {
+            continue
+        }
+        if !since.IsZero() && sketchExpired(day, since) {
+            continue
+        }
+        n, err := strconv.ParseInt(v, 10, 64)
+        if err != nil {
+            continue
+        }
+        slice := byDay[day]
+        if slice == nil {
+            slice = &store.ErrorSlice{Day: day}
+            byDay[day] = slice
+        }
+        if kind == errorFieldErrors {
+            slice.Errors += n
+        } else {
+            slice.Requests += n
+        }
+    }
+    out := make([]store.ErrorSlice, 0, len(byDay))
+    for _, slice := range byDay {
+        out = append(out, *slice)
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Day.After(out[j].Day) })
+    return out
+}
+
+// pruneErrorCounts drops the day fields of every error count that ended before
+// the given time.
+func (c *Client) pruneErrorCounts(ctx context.Context, before time.Time) error {
+    patterns := []string{domain.KeyPrefix(domain.KindErrors) + "*", domain.KeyPrefix(domain.KindSpanErrors) + "*"}
+    return c.pruneDayFields(ctx, patterns, before, func(field string) (time.Time, bool) {
+        day, _, ok := parseErrorField(field)
+        return day, ok
+    })
+}
@@ -0,0 +1,83 @@
+package redis
+
+import (
+    "context"
+    "encoding/json"
+    "sort"
+    "time"
+
+    goRedis "github.com/redis/go-redis/v9"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+// Baseline histories are hashes with one field per UTC day, "{yyyymmdd}" -> JSON
+// of the day's snapshot. Day fields sort like their dates, so the script below
+// drops expired days with a string comparison.
+const historyDayLayout = sketchDayLayout
+
+// recordHistoryScript sets field ARGV[1] of KEYS[1] to ARGV[2] and removes the
+// fields that sort before ARGV[3] (skipped when ARGV[3] is empty).
+var recordHistoryScript = goRedis.NewScript(`
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+if ARGV[3] ~= '' then
+    for _, f in ipairs(redis.call('HKEYS', KEYS[1])) do
+        if f < ARGV[3] then
+            redis.call('HDEL', KEYS[1], f)
+        end
+    end
+end
+return 1
+`)
+
+// RecordBaselineHistory stores snap under its day and drops days before the given time.
+func (c *Client) RecordBaselineHistory(ctx context.Context, key string, snap store.BaselineSnapshot, before time.Time) error {
+    day := store.SketchDay(snap.Day)
+    snap.Day = day
+    data, err := json.Marshal(snap)
+    if err != nil {
+        return err
+    }
+    cutoff := ""
+    if !before.IsZero() {
+        cutoff = store.SketchDay(before).Format(historyDayLayout)
+    }
+    return recordHistoryScript.Run(ctx, c.rdb, []string{c.key(ctx, key)}, day.Format(historyDayLayout), string(data), cutoff).Err()
+}
+
+// GetBaselineHistories fetches several histories in one pipeline, oldest day first.
+func (c *Client) GetBaselineHistories(ctx context.Context, keys []string) (map[string][]store.BaselineSnapshot, error) {
+    out := make(map[string][]store.BaselineSnapshot, len(keys))
+    if len(keys) == 0 {
+        return out, nil
+    }
+    pipe := c.rdb.Pipeline()
+    cmds := make([]*goRedis.MapStringStringCmd, len(keys))
+    for i, key := range keys {
+        cmds[i] = pipe.HGetAll(ctx, c.key(ctx, key))
+    }
+    if _, err := pipe.Exec(ctx); err != nil {
+        return nil, err
+    }
+    for i, key := range keys {
+        var history []store.BaselineSnapshot
+        for field, v := range cmds[i].Val() {
+            day, err := time.Parse(historyDayLayout, field)
+            if err != nil {
+                // skip malformed fields rather than failing entire read
+                continue
+            }
+            var snap store.BaselineSnapshot
+            if err := json.Unmarshal([]byte(v), &snap); err != nil {
+                continue
+            }
+            snap.Day = day
+            history = append(history, snap)
+        }
+        if len(history) > 0 {
+            sort.Slice(history, func(a, b int) bool { return history[a].Day.Before(history[b].Day) })
+            out[key] = history
+        }
+    }
+    return out, nil
+}
@@ -0,0 +1,186 @@
+package redis
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "sort"
+    "strconv"
+    "sync"
+
+    goRedis "github.com/redis/go-redis/v9"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+// The series index lets listing avoid SCAN. Per baseline kind (base, spanbase):
+//
+//	v2:idx:{kind}        SET   of series ids "{service}|{name}" (see domain.SeriesKey.SeriesID)
+//	v2:idx:{kind}:{id}   HASH  baseline key -> sampleCount
+//
+// SetBaseline updates both. In Cluster mode the per-series hash carries the same
+// hash tag as the series' baseline keys, so it is written in the same slot.
+const indexPrefix = "v2:idx:"
+
+// seriesIndexVersionKey marks that the index has been built from existing baselines.
+const seriesIndexVersionKey = "meta:seriesIndex"
+
+func indexSetKey(kind domain.KeyKind) string {
+    return indexPrefix + string(kind)
+}
+
+func (c *Client) indexBucketsKey(ctx context.Context, kind domain.KeyKind, id string) string {
+    if c.cluster {
+        id = "{" + id + "}"
+    }
+    return store.TenantKeyPrefix(store.TenantFromContext(ctx)) + indexPrefix + string(kind) + ":" + id
+}
+
+// indexBaseline queues the index writes for a baseline stored at key.
+// Keys that are not baseline series keys are not indexed.
+func (c *Client) indexBaseline(ctx context.Context, pipe goRedis.Pipeliner, key string, sampleCount int) {
+    sk, err := domain.ParseSeriesKey(key)
+    if err != nil || (sk.Kind != domain.KindBaseline && sk.Kind != domain.KindSpanBaseline) {
+        return
+    }
+    id := sk.SeriesID()
+    pipe.SAdd(ctx, c.key(ctx, indexSetKey(sk.Kind)), id)
+    pipe.HSet(ctx, c.indexBucketsKey(ctx, sk.Kind, id), key, sampleCount)
+}
+
+// ListBaselineKeys returns all baseline keys with at least minSamples samples,
+// read from the series index.
+func (c *Client) ListBaselineKeys(ctx context.Context, minSamples int) ([]string, error) {
+    var result []string
+    err := c.readIndex(ctx, domain.KindBaseline, func(_ string, buckets map[string]string) {
+        for key, v := range buckets {
+            n, err := strconv.Atoi(v)
+            if err != nil {
+                continue
+            }
+            // Only include keys with sufficient samples
+            if n >= minSamples {
+                result = append(result, key)
+            }
+        }
+    })
+    if err != nil {
+        return nil, err
+    }
+    sort.Strings(result)
+    return result, nil
+}
+
+// ListSeries returns every indexed series of kind with the sample count per bucket.
+func (c *Client) ListSeries(ctx context.Context, kind domain.KeyKind) ([]store.SeriesIndexEntry, error) {
+    var out []store.SeriesIndexEntry
+    err := c.readIndex(ctx, kind, func(id string, buckets map[string]string) {
+        service, name, err := domain.ParseSeriesID(id)
+        if err != nil {
+            return
+        }
+        e := store.SeriesIndexEntry{Service: service, Name: name, Buckets: make(map[domain.TimeBucket]int, len(buckets))}
+        for key, v := range buckets {
+            sk, err := domain.ParseSeriesKey(key)
+            if err != nil {
+                continue
+            }
+            n, err := strconv.Atoi(v)
+            if err != nil {
+                continue
+            }
+            e.Buckets[sk.Bucket] = n
+        }
+        if len(e.Buckets) > 0 {
+            out = append(out, e)
+        }
+    })
+    if err != nil {
+        return nil, err
+    }
+    return out, nil
+}
+
+// readIndex calls fn with the bucket hash of every series of kind, in id order.
+func (c *Client) readIndex(ctx context.Context, kind domain.KeyKind, fn func(id string, buckets map[string]string)) error {
+    ids, err := c.rdb.SMembers(ctx, c.key(ctx, indexSetKey(kind))).Result()
+    if err != nil {
+        return err
+    }
+    sort.Strings(ids)
+
+    const page = 100
+    for start := 0; start < len(ids); start += page {
+        chunk := ids[start:min(start+page, len(ids))]
+        pipe := c.rdb.Pipeline()
+        cmds := make([]*goRedis.MapStringStringCmd, len(chunk))
+        for i, id := range chunk {
+            cmds[i] = pipe.HGetAll(ctx, c.indexBucketsKey(ctx, kind, id))
+        }
+        if _, err := pipe.Exec(ctx); err != nil {
+            return err
+        }
+        for i, id := range chunk {
+            fn(id, cmds[i].Val())
+        }
+    }
+    return nil
+}
+
+// buildSeriesIndex indexes baselines written before the index existed, once.
+// It scans every baseline hash; an interrupted run is repeated on the next start.
+func (c *Client) buildSeriesIndex(ctx context.Context) error {
+    n, err := c.rdb.Get(ctx, c.key(ctx, seriesIndexVersionKey)).Int()
+    if err == nil && n >= 1 {
+        return nil
+    }
+    if err != nil && err != goRedis.Nil {
+        return err
+    }
+
+    var (
+        mu      sync.Mutex
+        indexed int
+    )
+    for _, kind := range []domain.KeyKind{domain.KindBaseline, domain.KindSpanBaseline} {
+        err := c.scan(ctx, domain.KeyPrefix(kind)+"*", func(rdb goRedis.Cmdable, keys []string) error {
+            pipe := rdb.Pipeline()
+            cmds := make([]*goRedis.StringCmd, len(keys))
+            for i, key := range keys {
+                cmds[i] = pipe.HGet(ctx, key, fieldSampleCount)
+            }
+            // Missing fields surface as redis.Nil on individual commands; handled below
+            _, _ = pipe.Exec(ctx)
+
+            // Index keys hash to other slots than the scanned node's keys, so write
+            // through the top-level client.
+            wpipe := c.rdb.Pipeline()
+            for i, key := range keys {
+                sampleCount, err := cmds[i].Int()
+                if err != nil {
+                    continue
+                }
+                c.indexBaseline(ctx, wpipe, c.logicalKey(ctx, key), sampleCount)
+            }
+            if _, err := wpipe.Exec(ctx); err != nil {
+                return err
+            }
+            mu.Lock()
+            indexed += len(keys)
+            mu.Unlock()
+            return nil
+        })
+        if err != nil {
+            return fmt.Errorf("index %s: %w", kind, err)
+        }
+    }
+
+    if err := c.rdb.Set(ctx, c.key(ctx, seriesIndexVersionKey), 1, 0).Err(); err != nil {
+        return err
+    }
+    if indexed > 0 {
+        log.Printf("redis: indexed %d existing baselines", indexed)
+    }
+    return nil
+}
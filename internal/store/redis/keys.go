@@ -0,0 +1,90 @@
+package redis
+
+import (
+    "context"
+    "strings"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+// seriesPrefixes are key prefixes of per-series data (one key per service/endpoint/bucket):
+// the current (v2) layout first, then the legacy v1 layout still seen during migration.
+var seriesPrefixes = []string{
+    domain.KeyPrefix(domain.KindBaseline),
+    domain.KeyPrefix(domain.KindDuration),
+    domain.KeyPrefix(domain.KindSpanBaseline),
+    domain.KeyPrefix(domain.KindSpanDuration),
+    domain.KeyPrefix(domain.KindSketch),
+    domain.KeyPrefix(domain.KindSpanSketch),
+    domain.KeyPrefix(domain.KindHistory),
+    domain.KeyPrefix(domain.KindErrors),
+    domain.KeyPrefix(domain.KindSpanErrors),
+    "base:", "dur:", "spanbase:", "spandur:",
+}
+
+// key maps a logical store key to the physical Redis key: the tenant prefix of
+// ctx (see store.TenantKeyPrefix) followed by the key.
+//
+// In Cluster mode series keys carry a hash tag over their service|endpoint part,
+// so every bucket of one series hashes to the same slot and multi-key pipelines
+// (e.g. the 48 keys fetched by the global fallback) hit a single node:
+//
+//	v2:base:svc|GET /a|9|weekday -> v2:base:{svc|GET /a}|9|weekday
+//
+// Outside Cluster mode series keys are used unchanged.
+func (c *Client) key(ctx context.Context, k string) string {
+    return store.TenantKeyPrefix(store.TenantFromContext(ctx)) + c.tagKey(k)
+}
+
+func (c *Client) tagKey(k string) string {
+    if !c.cluster {
+        return k
+    }
+    prefix, rest, ok := splitSeriesKey(k)
+    if !ok {
+        return k
+    }
+    i := bucketSuffixIndex(rest)
+    if i <= 0 {
+        return k
+    }
+    return prefix + "{" + rest[:i] + "}" + rest[i:]
+}
+
+// logicalKey reverses key for physical keys returned by SCAN (which is always
+// limited to the tenant's own keys).
+func (c *Client) logicalKey(ctx context.Context, k string) string {
+    k, _ = store.TrimTenantKey(store.TenantFromContext(ctx), k)
+    if !c.cluster {
+        return k
+    }
+    prefix, rest, ok := splitSeriesKey(k)
+    if !ok || !strings.HasPrefix(rest, "{") {
+        return k
+    }
+    i := bucketSuffixIndex(rest)
+    if i < 2 || rest[i-1] != '}' {
+        return k
+    }
+    return prefix + rest[1:i-1] + rest[i:]
+}
+
+func splitSeriesKey(k string) (prefix, rest string, ok bool) {
+    for _, p := range seriesPrefixes {
+        if strings.HasPrefix(k, p) {
+            return p, k[len(p):], true
+        }
+    }
+    return "", "", false
+}
+
+// bucketSuffixIndex returns the index of the "|{hour}|{dayType}" suffix in rest,
+// or -1 when rest does not end in two bucket components.
+func bucketSuffixIndex(rest string) int {
+    last := strings.LastIndex(rest, "|")
+    if last < 0 {
+        return -1
+    }
+    return strings.LastIndex(rest[:last], "|")
+}
@@ -0,0 +1,63 @@
+package redis
+
+import (
+    "context"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+func TestClusterHashTags(t *testing.T) {
+    ctx := context.Background()
+    c := &Client{cluster: true}
+
+    cases := map[string]string{
+        "v2:base:svc|GET /a|9|weekday":    "v2:base:{svc|GET /a}|9|weekday",
+        "v2:dur:svc|a%7Cb|9|weekday":      "v2:dur:{svc|a%7Cb}|9|weekday",
+        "base:svc|GET /a|9|weekday":       "base:{svc|GET /a}|9|weekday",
+        "dur:svc|GET /a|9|weekday":        "dur:{svc|GET /a}|9|weekday",
+        "spanbase:svc|db|query|0|weekend": "spanbase:{svc|db|query}|0|weekend",
+        "spandur:svc|x|23|weekend":        "spandur:{svc|x}|23|weekend",
+        "v2:sketch:svc|GET /a|9|weekday":  "v2:sketch:{svc|GET /a}|9|weekday",
+        "seen:trace-1":                    "seen:trace-1",
+        "dirtyKeys":                       "dirtyKeys",
+    }
+    for logical, physical := range cases {
+        assert.Equal(t, physical, c.key(ctx, logical), logical)
+        assert.Equal(t, logical, c.logicalKey(ctx, physical), physical)
+    }
+
+    // Single-node and Sentinel deployments keep the original key layout.
+    plain := &Client{}
+    assert.Equal(t, "v2:base:svc|GET /a|9|weekday", plain.key(ctx, "v2:base:svc|GET /a|9|weekday"))
+}
+
+func TestIndexKeysShareSeriesHashTag(t *testing.T) {
+    ctx := context.Background()
+    c := &Client{cluster: true}
+    sk, _ := domain.ParseSeriesKey("v2:base:svc|GET /a%7Cb|9|weekday")
+
+    // The per-series index hash must live in the same slot as the series' baselines.
+    assert.Equal(t, "v2:idx:base:{svc|GET /a%7Cb}", c.indexBucketsKey(ctx, sk.Kind, sk.SeriesID()))
+    assert.Equal(t, "v2:base:{svc|GET /a%7Cb}|9|weekday", c.key(ctx, sk.String()))
+
+    plain := &Client{}
+    assert.Equal(t, "v2:idx:base:svc|GET /a%7Cb", plain.indexBucketsKey(ctx, sk.Kind, sk.SeriesID()))
+}
+
+func TestTenantKeyPrefix(t *testing.T) {
+    c := &Client{cluster: true}
+    ctx := store.WithTenant(context.Background(), "staging")
+
+    assert.Equal(t, "tenant:staging:v2:base:{svc|GET /a}|9|weekday", c.key(ctx, "v2:base:svc|GET /a|9|weekday"))
+    assert.Equal(t, "v2:base:svc|GET /a|9|weekday", c.logicalKey(ctx, "tenant:staging:v2:base:{svc|GET /a}|9|weekday"))
+    assert.Equal(t, "tenant:staging:{dirty}:queue", c.key(ctx, dirtyQueueKey))
+    assert.Equal(t, "tenant:staging:seen:abc", c.key(ctx, "seen:abc"))
+    assert.Equal(t, "tenant:staging:v2:idx:base:{svc|GET /a}", c.indexBucketsKey(ctx, domain.KindBaseline, "svc|GET /a"))
+
+    // The default tenant keeps the unprefixed layout.
+    assert.Equal(t, "{dirty}:queue", c.key(context.Background(), dirtyQueueKey))
+}
@@ -2,46 +2,67 @@ package redis
 
 import (
     "context"
-    "strconv"
+    "slices"
+    "sort"
+    "sync"
+
+    goRedis "github.com/redis/go-redis/v9"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/store"
 )
 
-// ListBaselineKeys returns all baseline keys that have sufficient samples.
-// It scans for keys matching "base:*" pattern and filters by minSamples.
-func (c *Client) ListBaselineKeys(ctx context.Context, minSamples int) ([]string, error) {
-    var result []string
-    var cursor uint64
-    
-    // Use SCAN to iterate through all keys matching "base:*"
-    for {
-        keys, nextCursor, err := c.rdb.Scan(ctx, cursor, "base:*", 100).Result()
-        if err != nil {
-            return nil, err
-        }
-        
-        // Check each key's sample count
-        for _, key := range keys {
-            sampleCountStr, err := c.rdb.HGet(ctx, key, fieldSampleCount).Result()
+// scan iterates all keys of the context's tenant matching pattern page by page and
+// calls fn with the node-local client that returned them. fn receives physical keys.
+// In Cluster mode each master is scanned (concurrently); otherwise the single node is scanned.
+func (c *Client) scan(ctx context.Context, pattern string, fn func(rdb goRedis.Cmdable, keys []string) error) error {
+    pattern = store.TenantKeyPrefix(store.TenantFromContext(ctx)) + pattern
+    scanNode := func(ctx context.Context, rdb goRedis.Cmdable) error {
+        var cursor uint64
+        for {
+            keys, nextCursor, err := rdb.Scan(ctx, cursor, pattern, 100).Result()
             if err != nil {
-                // Skip keys that don't have sampleCount field or have errors
-                continue
+                return err
             }
-            
-            sampleCount, err := strconv.Atoi(sampleCountStr)
-            if err != nil {
-                continue
+            if len(keys) > 0 {
+                if err := fn(rdb, keys); err != nil {
+                    return err
+                }
             }
-            
-            // Only include keys with sufficient samples
-            if sampleCount >= minSamples {
-                result = append(result, key)
+            cursor = nextCursor
+            if cursor == 0 {
+                return nil
             }
         }
-        
-        cursor = nextCursor
-        if cursor == 0 {
-            break
+    }
+
+    if cc, ok := c.rdb.(*goRedis.ClusterClient); ok {
+        return cc.ForEachMaster(ctx, func(ctx context.Context, node *goRedis.Client) error {
+            return scanNode(ctx, node)
+        })
+    }
+    return scanNode(ctx, c.rdb)
+}
+
+// ListKeys returns every key starting with prefix, sorted. prefix is matched against
+// physical keys, so in Cluster mode it must not reach into the hash-tagged part
+// (kind prefixes such as domain.KeyPrefix(domain.KindBaseline) are fine).
+func (c *Client) ListKeys(ctx context.Context, prefix string) ([]string, error) {
+    var (
+        mu     sync.Mutex
+        result []string
+    )
+    err := c.scan(ctx, prefix+"*", func(rdb goRedis.Cmdable, keys []string) error {
+        mu.Lock()
+        defer mu.Unlock()
+        for _, key := range keys {
+            result = append(result, c.logicalKey(ctx, key))
         }
+        return nil
+    })
+    if err != nil {
+        return nil, err
     }
-    
-    return result, nil
+    // SCAN may return a key more than once
+    sort.Strings(result)
+    return slices.Compact(result), nil
 }
@@ -0,0 +1,161 @@
+package redis
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "sync/atomic"
+
+    goRedis "github.com/redis/go-redis/v9"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
+)
+
+// keyVersionKey records the series key layout the data has been migrated to.
+const keyVersionKey = "meta:keyVersion"
+
+// migrateKeysToV2 rewrites every v1 series key (and every v1 key queued in the
+// dirty queue) to the current layout, once. Each key is copied before the old one
+// is deleted, so an interrupted run is simply resumed on the next start.
+func (c *Client) migrateKeysToV2(ctx context.Context) error {
+    v, err := c.rdb.Get(ctx, c.key(ctx, keyVersionKey)).Int()
+    if err == nil && v >= domain.KeyVersion {
+        return nil
+    }
+    if err != nil && err != goRedis.Nil {
+        return err
+    }
+
+    var migrated int64
+    for _, pattern := range []string{"base:*", "dur:*", "spanbase:*", "spandur:*"} {
+        err := c.scan(ctx, pattern, func(_ goRedis.Cmdable, keys []string) error {
+            for _, physical := range keys {
+                newKey, ok := domain.MigrateV1Key(c.logicalKey(ctx, physical))
+                if !ok {
+                    continue
+                }
+                if err := c.moveKey(ctx, physical, c.key(ctx, newKey)); err != nil {
+                    return fmt.Errorf("migrate %s: %w", physical, err)
+                }
+                atomic.AddInt64(&migrated, 1)
+            }
+            return nil
+        })
+        if err != nil {
+            return err
+        }
+    }
+
+    if err := c.migrateDirtyQueueMembers(ctx); err != nil {
+        return fmt.Errorf("migrate dirty queue: %w", err)
+    }
+    if err := c.rdb.Set(ctx, c.key(ctx, keyVersionKey), domain.KeyVersion, 0).Err(); err != nil {
+        return err
+    }
+    if migrated > 0 {
+        log.Printf("redis: migrated %d keys to key layout v%d", migrated, domain.KeyVersion)
+    }
+    return nil
+}
+
+// moveKey copies a duration window (sorted set) or baseline (hash) from one key to
+// another and deletes the source. Windows are merged into an existing target;
+// an existing baseline target is kept as it is newer.
+func (c *Client) moveKey(ctx context.Context, from, to string) error {
+    typ, err := c.rdb.Type(ctx, from).Result()
+    if err != nil {
+        return err
+    }
+    switch typ {
+    case "zset":
+        zs, err := c.rdb.ZRangeWithScores(ctx, from, 0, -1).Result()
+        if err != nil {
+            return err
+        }
+        if len(zs) > 0 {
+            if err := c.rdb.ZAdd(ctx, to, zs...).Err(); err != nil {
+                return err
+            }
+        }
+    case "hash":
+        n, err := c.rdb.Exists(ctx, to).Result()
+        if err != nil {
+            return err
+        }
+        if n == 0 {
+            fields, err := c.rdb.HGetAll(ctx, from).Result()
+            if err != nil {
+                return err
+            }
+            if len(fields) > 0 {
+                if err := c.rdb.HSet(ctx, to, fields).Err(); err != nil {
+                    return err
+                }
+            }
+        }
+    case "none":
+        return nil
+    default:
+        return fmt.Errorf("unexpected type %s", typ)
+    }
+    return c.rdb.Del(ctx, from).Err()
+}
+
+// migrateDirtyQueueMembers rewrites v1 keys held as members of the dirty queue,
+// lease, retry and dead-letter structures.
+func (c *Client) migrateDirtyQueueMembers(ctx context.Context) error {
+    for _, zkey := range []string{c.key(ctx, dirtyQueueKey), c.key(ctx, dirtyLeaseKey)} {
+        zs, err := c.rdb.ZRangeWithScores(ctx, zkey, 0, -1).Result()
+        if err != nil {
+            return err
+        }
+        for _, z := range zs {
+            old, _ := z.Member.(string)
+            newKey, ok := domain.MigrateV1Key(old)
+            if !ok {
+                continue
+            }
+            if err := c.rdb.ZAddNX(ctx, zkey, goRedis.Z{Score: z.Score, Member: newKey}).Err(); err != nil {
+                return err
+            }
+            if err := c.rdb.ZRem(ctx, zkey, old).Err(); err != nil {
+                return err
+            }
+        }
+    }
+
+    retries, err := c.rdb.HGetAll(ctx, c.key(ctx, dirtyRetryKey)).Result()
+    if err != nil {
+        return err
+    }
+    for old, n := range retries {
+        newKey, ok := domain.MigrateV1Key(old)
+        if !ok {
+            continue
+        }
+        if err := c.rdb.HSet(ctx, c.key(ctx, dirtyRetryKey), newKey, n).Err(); err != nil {
+            return err
+        }
+        if err := c.rdb.HDel(ctx, c.key(ctx, dirtyRetryKey), old).Err(); err != nil {
+            return err
+        }
+    }
+
+    dead, err := c.rdb.SMembers(ctx, c.key(ctx, dirtyDeadKey)).Result()
+    if err != nil {
+        return err
+    }
+    for _, old := range dead {
+        newKey, ok := domain.MigrateV1Key(old)
+        if !ok {
+            continue
+        }
+        if err := c.rdb.SAdd(ctx, c.key(ctx, dirtyDeadKey), newKey).Err(); err != nil {
+            return err
+        }
+        if err := c.rdb.SRem(ctx, c.key(ctx, dirtyDeadKey), old).Err(); err != nil {
+            return err
+        }
+    }
+    return nil
+}
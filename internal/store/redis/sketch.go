@@ -0,0 +1,183 @@
+package redis
+
+import (
+    "context"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    goRedis "github.com/redis/go-redis/v9"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/store"
+)
+
+// Sketches are hashes with one field per day and bin, "{yyyymmdd}:{bin}" -> count,
+// so ingest updates a sketch with a single HINCRBY and expired days are dropped
+// with HDEL. A sketch holds at most a few hundred bins per retained day.
+const sketchDayLayout = "20060102"
+
+func sketchField(day time.Time, bin int32) string {
+    return day.Format(sketchDayLayout) + ":" + strconv.FormatInt(int64(bin), 10)
+}
+
+func parseSketchField(field string) (time.Time, int32, bool) {
+    dayStr, binStr, ok := strings.Cut(field, ":")
+    if !ok {
+        return time.Time{}, 0, false
+    }
+    day, err := time.Parse(sketchDayLayout, dayStr)
+    if err != nil {
+        return time.Time{}, 0, false
+    }
+    bin, err := strconv.ParseInt(binStr, 10, 32)
+    if err != nil {
+        return time.Time{}, 0, false
+    }
+    return day, int32(bin), true
+}
+
+// sketchExpired reports whether the slice of day ended before the given time.
+func sketchExpired(day, before time.Time) bool {
+    return !day.Add(24 * time.Hour).After(before)
+}
+
+func (c *Client) addSketch(ctx context.Context, pipe goRedis.Pipeliner, s store.SketchSample) {
+    at := s.At
+    if at.IsZero() {
+        at = time.Now()
+    }
+    pipe.HIncrBy(ctx, c.key(ctx, s.Key), sketchField(store.SketchDay(at), s.Bin), 1)
+}
+
+// GetSketch returns the day slices of the sketch at key ending after since, newest first.
+func (c *Client) GetSketch(ctx context.Context, key string, since time.Time) ([]store.SketchSlice, error) {
+    vals, err := c.rdb.HGetAll(ctx, c.key(ctx, key)).Result()
+    if err != nil {
+        return nil, err
+    }
+    return decodeSketch(vals, since), nil
+}
+
+// GetSketches fetches several sketches in one pipeline. In Cluster mode the
+// buckets of one series share a slot, so a fallback read hits a single node.
+func (c *Client) GetSketches(ctx context.Context, keys []string, since time.Time) (map[string][]store.SketchSlice, error) {
+    out := make(map[string][]store.SketchSlice, len(keys))
+    if len(keys) == 0 {
+        return out, nil
+    }
+    pipe := c.rdb.Pipeline()
+    cmds := make([]*goRedis.MapStringStringCmd, len(keys))
+    for i, key := range keys {
+        cmds[i] = pipe.HGetAll(ctx, c.key(ctx, key))
+    }
+    if _, err := pipe.Exec(ctx); err != nil {
+        return nil, err
+    }
+    for i, key := range keys {
+        if slices := decodeSketch(cmds[i].Val(), since); len(slices) > 0 {
+            out[key] = slices
+        }
+    }
+    return out, nil
+}
+
+// decodeSketch groups the fields of a sketch hash into day slices ending after
+// since, newest first.
+func decodeSketch(vals map[string]string, since time.Time) []store.SketchSlice {
+    byDay := make(map[time.Time]map[int32]int64)
+    for field, v := range vals {
+        day, bin, ok := parseSketchField(field)
+        if !ok {
+            // skip malformed fields rather than failing entire read
+            continue
+        }
+        if !since.IsZero() && sketchExpired(day, since) {
+            continue
+        }
+        n, err := strconv.ParseInt(v, 10, 64)
+        if err != nil {
+            continue
+        }
+        bins := byDay[day]
+        if bins == nil {
+            bins = make(map[int32]int64)
+            byDay[day] = bins
+        }
+        bins[bin] += n
+    }
+    out := make([]store.SketchSlice, 0, len(byDay))
+    for day, bins := range byDay {
+        out = append(out, store.SketchSlice{Day: day, Bins: bins})
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Day.After(out[j].Day) })
+    return out
+}
+
+// ReplaceSketch replaces the sketch at key with slices in one transaction.
+func (c *Client) ReplaceSketch(ctx context.Context, key string, slices []store.SketchSlice) error {
+    fields := make(map[string]interface{})
+    for _, slice := range slices {
+        day := store.SketchDay(slice.Day)
+        for bin, n := range slice.Bins {
+            if n > 0 {
+                fields[sketchField(day, bin)] = n
+            }
+        }
+    }
+    _, err := c.rdb.TxPipelined(ctx, func(pipe goRedis.Pipeliner) error {
+        pipe.Del(ctx, c.key(ctx, key))
+        if len(fields) > 0 {
+            pipe.HSet(ctx, c.key(ctx, key), fields)
+        }
+        return nil
+    })
+    return err
+}
+
+// pruneSketches drops the day fields of every sketch that ended before the given
+// time. Redis deletes hashes that become empty.
+func (c *Client) pruneSketches(ctx context.Context, before time.Time) error {
+    patterns := []string{domain.KeyPrefix(domain.KindSketch) + "*", domain.KeyPrefix(domain.KindSpanSketch) + "*"}
+    return c.pruneDayFields(ctx, patterns, before, func(field string) (time.Time, bool) {
+        day, _, ok := parseSketchField(field)
+        return day, ok
+    })
+}
+
+// pruneDayFields drops the fields of the hashes matching patterns whose day
+// (as read by dayOf) ended before the given time.
+func (c *Client) pruneDayFields(ctx context.Context, patterns []string, before time.Time, dayOf func(field string) (time.Time, bool)) error {
+    for _, pattern := range patterns {
+        err := c.scan(ctx, pattern, func(rdb goRedis.Cmdable, keys []string) error {
+            pipe := rdb.Pipeline()
+            cmds := make([]*goRedis.StringSliceCmd, len(keys))
+            for i, key := range keys {
+                cmds[i] = pipe.HKeys(ctx, key)
+            }
+            if _, err := pipe.Exec(ctx); err != nil {
+                return err
+            }
+
+            del := rdb.Pipeline()
+            for i, key := range keys {
+                var expired []string
+                for _, field := range cmds[i].Val() {
+                    if day, ok := dayOf(field); ok && sketchExpired(day, before) {
+                        expired = append(expired, field)
+                    }
+                }
+                if len(expired) > 0 {
+                    del.HDel(ctx, key, expired...)
+                }
+            }
+            _, err := del.Exec(ctx)
+            return err
+        })
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
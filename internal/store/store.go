@@ -3,24 +3,160 @@ package store
 import (
     "context"
     "time"
+
+    "github.com/alexchang/tempo-latency-anomaly-service/internal/domain"
 )
 
 // Baseline represents cached baseline statistics for a key.
-// Keys stored in Redis hash: p50, p95, mad, sampleCount, updatedAt
+// Keys stored in Redis hash: p50, p95, mad, sampleCount, updatedAt, rejected and
+// one pct:{name} field per extra percentile.
 type Baseline struct {
     P50         float64   `json:"p50" example:"233.5"`
     P95         float64   `json:"p95" example:"562.0"`
     MAD         float64   `json:"mad" example:"43.0"`
     SampleCount int       `json:"sampleCount" example:"50"`
     UpdatedAt   time.Time `json:"updatedAt" example:"2026-01-15T08:00:00Z"`
+    // Percentiles holds the configured extra quantiles by name, e.g. "p99".
+    Percentiles map[string]float64 `json:"percentiles,omitempty"`
+    // Rejected counts the samples of the bucket the ingest guard excluded,
+    // dropped or clamped (see config.GuardConfig).
+    Rejected int `json:"rejected,omitempty" example:"0"`
+}
+
+// Percentile returns the quantile stored under name ("p50", "p95" or one of
+// Percentiles) and whether the baseline has it.
+func (b *Baseline) Percentile(name string) (float64, bool) {
+    switch name {
+    case "p50":
+        return b.P50, true
+    case "p95":
+        return b.P95, true
+    }
+    v, ok := b.Percentiles[name]
+    return v, ok
+}
+
+// Sample is a single duration (ms) observed at At (the trace or span start time).
+type Sample struct {
+    At         time.Time `json:"t"`
+    DurationMs int64     `json:"v"`
 }
 
-// DurationOps defines operations for rolling window samples (dur:* lists).
+// DurationOps defines operations for rolling window samples (dur:* sorted sets).
+// Samples are ordered by timestamp; a window holds at most windowSize samples and
+// samples older than the retention period are dropped by PruneDurations.
 type DurationOps interface {
-    // AppendDuration pushes a duration (ms) into the rolling list and trims to windowSize.
-    AppendDuration(ctx context.Context, key string, durationMs int64, windowSize int) error
-    // GetDurations returns all durations (ms) currently in the rolling list.
+    // AppendDuration adds a duration (ms) observed at at and trims the window to the
+    // windowSize most recent samples. A zero at is stamped with the current time.
+    AppendDuration(ctx context.Context, key string, durationMs int64, at time.Time, windowSize int) error
+    // GetDurations returns all durations (ms) currently in the window, newest first.
     GetDurations(ctx context.Context, key string) ([]int64, error)
+    // GetSamples returns the samples observed at or after since, newest first.
+    // A zero since returns the whole window.
+    GetSamples(ctx context.Context, key string, since time.Time) ([]Sample, error)
+    // PruneDurations drops samples observed before the given time from every window
+    // and removes windows left empty. It returns the keys that lost samples.
+    // Sketch and error count slices of days that ended before the given time are
+    // dropped as well.
+    PruneDurations(ctx context.Context, before time.Time) ([]string, error)
+    // ReplaceSamples atomically replaces the window at key with samples, trimmed to the
+    // windowSize most recent. An empty samples slice deletes the window.
+    ReplaceSamples(ctx context.Context, key string, samples []Sample, windowSize int) error
+}
+
+// DurationSample is a single duration (ms) to append to the window at Key.
+// A zero At is stamped with the current time.
+type DurationSample struct {
+    Key        string
+    DurationMs int64
+    At         time.Time
+}
+
+// SketchSample counts one sample in bin Bin of the sketch at Key, in the day
+// slice covering At. A zero At is stamped with the current time.
+type SketchSample struct {
+    Key string
+    Bin int32
+    At  time.Time
+}
+
+// OutcomeSample counts one request, and an error if Error is set, in the error
+// counts at Key, in the day slice covering At. A zero At is stamped with the
+// current time.
+type OutcomeSample struct {
+    Key   string
+    Error bool
+    At    time.Time
+}
+
+// IngestBatch groups every write produced by ingesting one trace:
+// the root duration sample, all span samples, their sketch updates, the
+// baseline keys to mark dirty, one entry in Rejected per sample the ingest
+// guard acted on, naming the baseline whose rejected count to increment, and
+// the outcome (success or error) of the trace and its spans.
+type IngestBatch struct {
+    Samples    []DurationSample
+    Sketches   []SketchSample
+    DirtyKeys  []string
+    Rejected   []string
+    Outcomes   []OutcomeSample
+    WindowSize int
+}
+
+// BatchOps defines batched ingestion writes.
+type BatchOps interface {
+    // IngestBatch appends all samples (trimming each window to WindowSize), counts all
+    // sketch samples and outcomes, marks all dirty keys and increments the rejected
+    // counts of existing baselines in a single round trip. The writes are applied
+    // atomically: either all of them become visible or none do. The Redis Cluster
+    // backend is atomic per service/endpoint only; a batch interrupted between
+    // slots still gets its dirty keys enqueued, with a delay.
+    IngestBatch(ctx context.Context, b IngestBatch) error
+}
+
+// SketchSlice holds the bin counts (see stats.Sketch) of the samples of one
+// bucket observed on one UTC day. Day is midnight UTC.
+type SketchSlice struct {
+    Day  time.Time       `json:"day"`
+    Bins map[int32]int64 `json:"bins"`
+}
+
+// SketchDay returns the day of the slice a sample observed at at is counted in.
+func SketchDay(at time.Time) time.Time {
+    return at.UTC().Truncate(24 * time.Hour)
+}
+
+// SketchOps defines operations for per-bucket quantile sketches (sketch:* keys).
+// Sketches are updated through IngestBatch and kept as one slice per day, so
+// PruneDurations can expire them alongside the samples.
+type SketchOps interface {
+    // GetSketch returns the slices of the sketch at key for days ending after
+    // since, newest first. A zero since returns every slice.
+    GetSketch(ctx context.Context, key string, since time.Time) ([]SketchSlice, error)
+    // GetSketches returns the slices of several sketches in one call, filtered like
+    // GetSketch. Keys without slices are omitted.
+    GetSketches(ctx context.Context, keys []string, since time.Time) (map[string][]SketchSlice, error)
+    // ReplaceSketch atomically replaces the sketch at key with slices.
+    // An empty slices deletes the sketch.
+    ReplaceSketch(ctx context.Context, key string, slices []SketchSlice) error
+}
+
+// ErrorSlice holds the number of requests and errors of one bucket observed on
+// one UTC day. Day is midnight UTC.
+type ErrorSlice struct {
+    Day      time.Time `json:"day"`
+    Requests int64     `json:"requests"`
+    Errors   int64     `json:"errors"`
+}
+
+// ErrorOps defines the per-bucket request and error counts (err:* and spanerr:*
+// keys). Counts are updated through IngestBatch and kept as one slice per day
+// (see SketchDay), so PruneDurations expires them alongside the samples.
+type ErrorOps interface {
+    // GetErrorCounts returns the day slices of several error counts in one call for
+    // days ending after since, newest first. A zero since returns every slice.
+    // Keys without slices are omitted.
+    GetErrorCounts(ctx context.Context, keys []string, since time.Time) (map[string][]ErrorSlice, error)
 }
 
 // BaselineOps defines cached baseline read/write operations (base:* hashes).
@@ -28,12 +164,36 @@ type BaselineOps interface {
     // GetBaseline fetches baseline stats for key. Returns (nil, nil) if not found.
     GetBaseline(ctx context.Context, key string) (*Baseline, error)
     // SetBaseline stores baseline stats (including SampleCount and UpdatedAt).
+    // A zero Rejected keeps the stored rejected count, so recomputing does not
+    // reset it.
     SetBaseline(ctx context.Context, key string, b Baseline) error
     // GetBaselines fetches baseline stats for multiple keys in one call.
     // Returns a map of key -> Baseline for keys that exist. Missing keys are omitted.
     GetBaselines(ctx context.Context, keys []string) (map[string]*Baseline, error)
 }
 
+// BaselineSnapshot is the baseline of one bucket on Day (midnight UTC), as
+// written by the last recompute of that day.
+type BaselineSnapshot struct {
+    Day         time.Time `json:"day" example:"2026-01-15T00:00:00Z"`
+    P50         float64   `json:"p50" example:"233.5"`
+    P95         float64   `json:"p95" example:"562.0"`
+    MAD         float64   `json:"mad" example:"43.0"`
+    SampleCount int       `json:"sampleCount" example:"50"`
+}
+
+// HistoryOps defines the daily baseline history (hist:* keys) that drift
+// detection reads.
+type HistoryOps interface {
+    // RecordBaselineHistory stores snap as the entry of its day in the history at key,
+    // replacing an earlier entry of the same day, and drops the entries of days before
+    // the given time. A zero before keeps every entry.
+    RecordBaselineHistory(ctx context.Context, key string, snap BaselineSnapshot, before time.Time) error
+    // GetBaselineHistories returns the histories of several keys in one call, oldest
+    // day first. Keys without entries are omitted.
+    GetBaselineHistories(ctx context.Context, keys []string) (map[string][]BaselineSnapshot, error)
+}
+
 // DedupOps defines trace ID deduplication (seen:* keys).
 type DedupOps interface {
     // IsDuplicateOrMark performs atomic check-and-set with TTL.
@@ -41,25 +201,68 @@ type DedupOps interface {
     IsDuplicateOrMark(ctx context.Context, traceID string, ttl time.Duration) (bool, error)
 }
 
-// DirtyOps defines tracking of keys that need recomputation (dirtyKeys set).
+// DirtyQueueStats describes the state of the dirty-key work queue.
+type DirtyQueueStats struct {
+    // Pending is the number of keys waiting to be claimed.
+    Pending int64 `json:"pending"`
+    // Leased is the number of keys currently claimed by a worker.
+    Leased int64 `json:"leased"`
+    // DeadLetter is the number of keys that exhausted their retries.
+    DeadLetter int64 `json:"deadLetter"`
+    // OldestPendingAge is how long the oldest pending key has been waiting (0 if none).
+    OldestPendingAge time.Duration `json:"oldestPendingAge"`
+}
+
+// DirtyOps defines a lease-based work queue of keys that need recomputation.
+//
+// A claimed key stays leased until it is acknowledged (done) or negatively
+// acknowledged (failed). If its lease expires first, e.g. because the process
+// died mid-batch, the key is returned to the queue by the next claim.
 type DirtyOps interface {
-    // MarkDirty adds the key to the dirty set.
+    // MarkDirty enqueues the key. Marking a key that is already pending is a no-op.
     MarkDirty(ctx context.Context, key string) error
-    // PopDirtyBatch pops up to count keys from the dirty set for processing.
-    PopDirtyBatch(ctx context.Context, count int64) ([]string, error)
+    // ClaimDirtyBatch leases up to count pending keys (oldest first) for the lease duration.
+    ClaimDirtyBatch(ctx context.Context, count int64, lease time.Duration) ([]string, error)
+    // AckDirty releases the leases of successfully processed keys and clears their retry
+    // counters. Acknowledged keys are also removed from the dead-letter set.
+    AckDirty(ctx context.Context, keys ...string) error
+    // NackDirty releases the lease of a failed key and re-queues it. After more than
+    // maxRetries consecutive failures the key is moved to the dead-letter set instead;
+    // the returned bool reports whether that happened.
+    NackDirty(ctx context.Context, key string, maxRetries int) (bool, error)
+    // DirtyQueueStats reports queue depth, leases, dead letters and the oldest pending age.
+    DirtyQueueStats(ctx context.Context) (DirtyQueueStats, error)
+}
+
+// SeriesIndexEntry is one service/name pair from the series index together with
+// the sample count of each bucket that has a baseline.
+type SeriesIndexEntry struct {
+    Service string
+    Name    string
+    Buckets map[domain.TimeBucket]int
 }
 
 // ListOps defines operations for listing available baselines.
+// Listing is served from a series index that SetBaseline keeps up to date,
+// so it does not need to scan the keyspace.
 type ListOps interface {
-    // ListBaselineKeys returns all baseline keys matching the pattern (base:*).
-    // Returns keys that have sufficient samples for anomaly detection.
+    // ListBaselineKeys returns all baseline keys (base:*) with at least minSamples samples.
     ListBaselineKeys(ctx context.Context, minSamples int) ([]string, error)
+    // ListSeries returns every indexed series with baselines of kind
+    // (domain.KindBaseline or domain.KindSpanBaseline).
+    ListSeries(ctx context.Context, kind domain.KeyKind) ([]SeriesIndexEntry, error)
+    // ListKeys returns every key starting with prefix (see domain.KeyPrefix), sorted.
+    ListKeys(ctx context.Context, prefix string) ([]string, error)
 }
 
 // Store aggregates all storage operations and allows closing resources.
 type Store interface {
     DurationOps
+    SketchOps
+    ErrorOps
+    BatchOps
     BaselineOps
+    HistoryOps
     DedupOps
     DirtyOps
     ListOps
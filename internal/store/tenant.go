@@ -0,0 +1,50 @@
+package store
+
+import (
+    "context"
+    "strings"
+)
+
+// Keys of a tenant are stored under TenantKeyPrefix(tenant). The default tenant ""
+// uses no prefix, which keeps data written before tenants existed addressable.
+//
+// Every Store implementation scopes its operations to the tenant carried by the
+// context: keys passed in and returned are logical (unprefixed) keys, and the dirty
+// queue, dedup marks, listings and pruning only ever see the tenant's own keys.
+
+const tenantKeyPrefix = "tenant:"
+
+type tenantCtxKey struct{}
+
+// WithTenant returns a context whose store operations are scoped to tenant.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+    return context.WithValue(ctx, tenantCtxKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant set by WithTenant ("" if none).
+func TenantFromContext(ctx context.Context) string {
+    t, _ := ctx.Value(tenantCtxKey{}).(string)
+    return t
+}
+
+// TenantKeyPrefix returns the prefix carried by every key of tenant:
+// "" for the default tenant, otherwise "tenant:{tenant}:".
+// Tenant names must not contain ":" (see config validation).
+func TenantKeyPrefix(tenant string) string {
+    if tenant == "" {
+        return ""
+    }
+    return tenantKeyPrefix + tenant + ":"
+}
+
+// TrimTenantKey strips the prefix of tenant from a physical key. It reports false
+// when the key belongs to another tenant.
+func TrimTenantKey(tenant, key string) (string, bool) {
+    if tenant == "" {
+        if strings.HasPrefix(key, tenantKeyPrefix) {
+            return "", false
+        }
+        return key, true
+    }
+    return strings.CutPrefix(key, TenantKeyPrefix(tenant))
+}
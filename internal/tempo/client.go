@@ -146,6 +146,8 @@ func extractSpanData(traceResp TraceByIDResponse) []SpanData {
 					ServiceName:       service,
 					StartTimeUnixNano: span.StartTimeUnixNano,
 					EndTimeUnixNano:   span.EndTimeUnixNano,
+					StatusCode:        span.Status.Code,
+					HTTPStatusCode:    findHTTPStatusCode(span.Attributes),
 				})
 			}
 		}
@@ -163,6 +165,23 @@ func findServiceName(attrs []KeyValue) string {
 	return ""
 }
 
+// findHTTPStatusCode returns the HTTP status code attribute of a span.
+func findHTTPStatusCode(attrs []KeyValue) int {
+	for _, attr := range attrs {
+		if attr.Key != "http.response.status_code" && attr.Key != "http.status_code" {
+			continue
+		}
+		v := attr.Value.IntValue.String()
+		if v == "" {
+			v = attr.Value.StringValue
+		}
+		if code, err := strconv.Atoi(v); err == nil {
+			return code
+		}
+	}
+	return 0
+}
+
 func (c *Client) searchTraces(ctx context.Context, params url.Values) ([]domain.TraceEvent, error) {
 	endpoint := c.baseURL + "/api/search"
 	u, err := url.Parse(endpoint)
@@ -55,3 +55,37 @@ func TestClient_SearchTraces(t *testing.T) {
 		}
 	}
 }
+
+func TestClient_GetTraceSpans_Status(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/traces/trace-1", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"batches":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"orders"}}]},
+			"scopeSpans":[{"spans":[
+				{"spanId":"a","name":"GET /orders","status":{"code":"STATUS_CODE_ERROR","message":"boom"}},
+				{"spanId":"b","name":"GET /items","status":{"code":2}},
+				{"spanId":"c","name":"GET /users","attributes":[{"key":"http.response.status_code","value":{"intValue":"503"}}]},
+				{"spanId":"d","name":"GET /users","status":{"code":"STATUS_CODE_OK"},"attributes":[{"key":"http.status_code","value":{"intValue":502}}]},
+				{"spanId":"e","name":"GET /users","status":{},"attributes":[{"key":"http.status_code","value":{"intValue":"404"}}]}
+			]}]}]}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	client := NewClient(config.TempoConfig{URL: srv.URL})
+	spans, err := client.GetTraceSpans(context.Background(), "trace-1")
+	if !assert.NoError(t, err) || !assert.Len(t, spans, 5) {
+		return
+	}
+	assert.Equal(t, StatusCodeError, spans[0].StatusCode)
+	assert.Equal(t, 503, spans[2].HTTPStatusCode)
+	assert.Equal(t, 502, spans[3].HTTPStatusCode)
+
+	var isError []bool
+	for _, s := range spans {
+		isError = append(isError, s.IsError())
+	}
+	// An explicit OK status wins over a 5xx HTTP status, 4xx is not an error
+	assert.Equal(t, []bool{true, true, true, false, false}, isError)
+}
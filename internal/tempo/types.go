@@ -1,5 +1,10 @@
 package tempo
 
+import (
+	"encoding/json"
+	"strings"
+)
+
 // TempoResponse represents the JSON payload returned by Tempo search APIs.
 // It contains a list of trace summaries.
 type TempoResponse struct {
@@ -45,6 +50,8 @@ type KeyValue struct {
 // AttributeValue contains the typed value of an attribute.
 type AttributeValue struct {
 	StringValue string `json:"stringValue"`
+	// IntValue holds an int64, which OTLP JSON encodes as a string.
+	IntValue json.Number `json:"intValue"`
 }
 
 // ScopeSpan represents a collection of spans.
@@ -54,12 +61,52 @@ type ScopeSpan struct {
 
 // Span represents a single OTLP span.
 type Span struct {
-	TraceID           string `json:"traceId"`
-	SpanID            string `json:"spanId"`
-	ParentSpanID      string `json:"parentSpanId"`
-	Name              string `json:"name"`
-	StartTimeUnixNano string `json:"startTimeUnixNano"`
-	EndTimeUnixNano   string `json:"endTimeUnixNano"`
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId"`
+	Name              string     `json:"name"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []KeyValue `json:"attributes"`
+	Status            SpanStatus `json:"status"`
+}
+
+// SpanStatus is the OTLP status of a span.
+type SpanStatus struct {
+	Code    StatusCode `json:"code"`
+	Message string     `json:"message"`
+}
+
+// StatusCode is the OTLP span status code.
+type StatusCode int
+
+const (
+	StatusCodeUnset StatusCode = 0
+	StatusCodeOK    StatusCode = 1
+	StatusCodeError StatusCode = 2
+)
+
+// UnmarshalJSON accepts both the numeric code and the enum name
+// ("STATUS_CODE_ERROR") since OTLP JSON producers emit either.
+func (c *StatusCode) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err != nil {
+		var n int
+		if err := json.Unmarshal(b, &n); err != nil {
+			return err
+		}
+		*c = StatusCode(n)
+		return nil
+	}
+	switch strings.TrimPrefix(strings.ToUpper(name), "STATUS_CODE_") {
+	case "ERROR", "2":
+		*c = StatusCodeError
+	case "OK", "1":
+		*c = StatusCodeOK
+	default:
+		*c = StatusCodeUnset
+	}
+	return nil
 }
 
 // SpanData represents a normalized span with service context.
@@ -71,4 +118,22 @@ type SpanData struct {
 	ServiceName       string
 	StartTimeUnixNano string
 	EndTimeUnixNano   string
+	StatusCode        StatusCode
+	// HTTPStatusCode is the span's http.response.status_code (or the older
+	// http.status_code) attribute, 0 when absent.
+	HTTPStatusCode int
+}
+
+// IsError reports whether the span failed: its status is ERROR, or it is unset
+// and the span carries a 5xx HTTP status code. An explicit OK status wins over
+// the HTTP status code, as in the OpenTelemetry semantic conventions.
+func (s SpanData) IsError() bool {
+	switch s.StatusCode {
+	case StatusCodeError:
+		return true
+	case StatusCodeOK:
+		return false
+	default:
+		return s.HTTPStatusCode >= 500
+	}
 }
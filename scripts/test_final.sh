@@ -25,16 +25,16 @@ echo "   這段時間 Tempo poller 會拉取 traces 並計算 baselines"
 
 for i in {1..12}; do
     sleep 5
-    dur_count=$(docker exec tempo-anomaly-redis redis-cli KEYS "dur:*" | wc -l | tr -d ' ')
-    base_count=$(docker exec tempo-anomaly-redis redis-cli KEYS "base:*" | wc -l | tr -d ' ')
+    dur_count=$(docker exec tempo-anomaly-redis redis-cli KEYS "v2:dur:*" | wc -l | tr -d ' ')
+    base_count=$(docker exec tempo-anomaly-redis redis-cli KEYS "v2:base:*" | wc -l | tr -d ' ')
     echo "   ${i}. Duration keys: $dur_count, Baseline keys: $base_count"
 done
 echo ""
 
 # 測試 3: 驗證資料已收集
 echo "✅ Test 3: 驗證 Redis 資料"
-dur_count=$(docker exec tempo-anomaly-redis redis-cli KEYS "dur:*" | wc -l | tr -d ' ')
-base_count=$(docker exec tempo-anomaly-redis redis-cli KEYS "base:*" | wc -l | tr -d ' ')
+dur_count=$(docker exec tempo-anomaly-redis redis-cli KEYS "v2:dur:*" | wc -l | tr -d ' ')
+base_count=$(docker exec tempo-anomaly-redis redis-cli KEYS "v2:base:*" | wc -l | tr -d ' ')
 
 echo "   Duration keys: $dur_count"
 echo "   Baseline keys: $base_count"
@@ -47,11 +47,11 @@ echo ""
 # 測試 4: 查詢 Baseline API
 echo "✅ Test 4: 查詢 Baseline API"
 if [ "$base_count" -gt 0 ]; then
-    sample_key=$(docker exec tempo-anomaly-redis redis-cli KEYS "base:*" | head -1)
-    service=$(echo "$sample_key" | cut -d: -f2 | cut -d'|' -f1)
-    endpoint=$(echo "$sample_key" | cut -d: -f2 | cut -d'|' -f2)
-    hour=$(echo "$sample_key" | cut -d: -f2 | cut -d'|' -f3)
-    dayType=$(echo "$sample_key" | cut -d: -f2 | cut -d'|' -f4)
+    sample_key=$(docker exec tempo-anomaly-redis redis-cli KEYS "v2:base:*" | head -1)
+    service=$(echo "$sample_key" | cut -d: -f3- | cut -d'|' -f1)
+    endpoint=$(echo "$sample_key" | cut -d: -f3- | cut -d'|' -f2)
+    hour=$(echo "$sample_key" | cut -d: -f3- | cut -d'|' -f3)
+    dayType=$(echo "$sample_key" | cut -d: -f3- | cut -d'|' -f4)
     
     echo "   測試 key: $sample_key"
     
@@ -145,7 +145,7 @@ echo ""
 
 # 測試 8: 時間分桶驗證
 echo "✅ Test 8: 時間分桶驗證"
-unique_hours=$(docker exec tempo-anomaly-redis redis-cli KEYS "base:*" | cut -d'|' -f3 | sort -u | wc -l | tr -d ' ')
+unique_hours=$(docker exec tempo-anomaly-redis redis-cli KEYS "v2:base:*" | cut -d'|' -f3 | sort -u | wc -l | tr -d ' ')
 echo "   不同小時的分桶數: $unique_hours"
 
 if [ "$unique_hours" -gt 1 ]; then
@@ -157,8 +157,8 @@ echo ""
 
 # 測試 9: 工作日/週末分類
 echo "✅ Test 9: 工作日/週末分類"
-weekday_count=$(docker exec tempo-anomaly-redis redis-cli KEYS "base:*weekday" | wc -l | tr -d ' ')
-weekend_count=$(docker exec tempo-anomaly-redis redis-cli KEYS "base:*weekend" | wc -l | tr -d ' ')
+weekday_count=$(docker exec tempo-anomaly-redis redis-cli KEYS "v2:base:*weekday" | wc -l | tr -d ' ')
+weekend_count=$(docker exec tempo-anomaly-redis redis-cli KEYS "v2:base:*weekend" | wc -l | tr -d ' ')
 
 echo "   Weekday baselines: $weekday_count"
 echo "   Weekend baselines: $weekend_count"
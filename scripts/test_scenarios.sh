@@ -60,8 +60,8 @@ fi
 # ========================================
 test_case "Test 2" "驗證 Redis 中有 trace 資料"
 
-dur_count=$($REDIS_CLI KEYS "dur:*" | wc -l | tr -d ' ')
-base_count=$($REDIS_CLI KEYS "base:*" | wc -l | tr -d ' ')
+dur_count=$($REDIS_CLI KEYS "v2:dur:*" | wc -l | tr -d ' ')
+base_count=$($REDIS_CLI KEYS "v2:base:*" | wc -l | tr -d ' ')
 
 info "Duration keys: $dur_count"
 info "Baseline keys: $base_count"
@@ -84,13 +84,13 @@ fi
 test_case "Test 3" "查詢特定服務的 baseline 統計"
 
 # 從 Redis 中獲取一個實際存在的 baseline key
-sample_key=$($REDIS_CLI KEYS "base:*" | head -1)
+sample_key=$($REDIS_CLI KEYS "v2:base:*" | head -1)
 if [ -n "$sample_key" ]; then
-    # 解析 key 格式: base:service|endpoint|hour|dayType
-    service=$(echo "$sample_key" | cut -d: -f2 | cut -d'|' -f1)
-    endpoint=$(echo "$sample_key" | cut -d: -f2 | cut -d'|' -f2)
-    hour=$(echo "$sample_key" | cut -d: -f2 | cut -d'|' -f3)
-    dayType=$(echo "$sample_key" | cut -d: -f2 | cut -d'|' -f4)
+    # 解析 key 格式: v2:base:service|endpoint|hour|dayType
+    service=$(echo "$sample_key" | cut -d: -f3- | cut -d'|' -f1)
+    endpoint=$(echo "$sample_key" | cut -d: -f3- | cut -d'|' -f2)
+    hour=$(echo "$sample_key" | cut -d: -f3- | cut -d'|' -f3)
+    dayType=$(echo "$sample_key" | cut -d: -f3- | cut -d'|' -f4)
     
     info "測試 key: $sample_key"
     info "Service: $service"
@@ -243,7 +243,7 @@ fi
 test_case "Test 7" "驗證時間分桶邏輯 (不同小時應該有不同的 baseline)"
 
 # 檢查是否有不同小時的 baseline
-hour_keys=$($REDIS_CLI KEYS "base:*" | head -20)
+hour_keys=$($REDIS_CLI KEYS "v2:base:*" | head -20)
 unique_hours=$(echo "$hour_keys" | cut -d'|' -f3 | sort -u | wc -l | tr -d ' ')
 
 info "發現 $unique_hours 個不同的小時分桶"
@@ -259,8 +259,8 @@ fi
 # ========================================
 test_case "Test 8" "驗證工作日/週末分類"
 
-weekday_count=$($REDIS_CLI KEYS "base:*weekday" | wc -l | tr -d ' ')
-weekend_count=$($REDIS_CLI KEYS "base:*weekend" | wc -l | tr -d ' ')
+weekday_count=$($REDIS_CLI KEYS "v2:base:*weekday" | wc -l | tr -d ' ')
+weekend_count=$($REDIS_CLI KEYS "v2:base:*weekend" | wc -l | tr -d ' ')
 
 info "Weekday baselines: $weekday_count"
 info "Weekend baselines: $weekend_count"
@@ -299,13 +299,13 @@ fi
 # ========================================
 test_case "Test 10" "驗證 Tempo 持續拉取"
 
-initial_count=$($REDIS_CLI KEYS "dur:*" | wc -l | tr -d ' ')
+initial_count=$($REDIS_CLI KEYS "v2:dur:*" | wc -l | tr -d ' ')
 info "初始 duration keys: $initial_count"
 info "等待 20 秒讓 poller 再次執行..."
 
 sleep 20
 
-final_count=$($REDIS_CLI KEYS "dur:*" | wc -l | tr -d ' ')
+final_count=$($REDIS_CLI KEYS "v2:dur:*" | wc -l | tr -d ' ')
 info "最終 duration keys: $final_count"
 
 if [ "$final_count" -ge "$initial_count" ]; then
@@ -16,23 +16,23 @@ echo ""
 # 測試 2: 檢查 Redis 資料
 echo "Test 2: Redis 資料統計"
 echo "Duration keys:"
-docker exec tempo-anomaly-redis redis-cli KEYS "dur:*" | wc -l
+docker exec tempo-anomaly-redis redis-cli KEYS "v2:dur:*" | wc -l
 echo "Baseline keys:"
-docker exec tempo-anomaly-redis redis-cli KEYS "base:*" | wc -l
+docker exec tempo-anomaly-redis redis-cli KEYS "v2:base:*" | wc -l
 echo "Dirty keys:"
 docker exec tempo-anomaly-redis redis-cli SCARD dirtyKeys
 echo ""
 
 # 測試 3: 查詢一個 baseline
 echo "Test 3: 查詢 Baseline"
-sample_key=$(docker exec tempo-anomaly-redis redis-cli KEYS "base:*" | head -1)
+sample_key=$(docker exec tempo-anomaly-redis redis-cli KEYS "v2:base:*" | head -1)
 echo "Sample key: $sample_key"
 
 if [ -n "$sample_key" ]; then
-    service=$(echo "$sample_key" | cut -d: -f2 | cut -d'|' -f1)
-    endpoint=$(echo "$sample_key" | cut -d: -f2 | cut -d'|' -f2)
-    hour=$(echo "$sample_key" | cut -d: -f2 | cut -d'|' -f3)
-    dayType=$(echo "$sample_key" | cut -d: -f2 | cut -d'|' -f4)
+    service=$(echo "$sample_key" | cut -d: -f3- | cut -d'|' -f1)
+    endpoint=$(echo "$sample_key" | cut -d: -f3- | cut -d'|' -f2)
+    hour=$(echo "$sample_key" | cut -d: -f3- | cut -d'|' -f3)
+    dayType=$(echo "$sample_key" | cut -d: -f3- | cut -d'|' -f4)
     
     echo "Service: $service"
     echo "Endpoint: $endpoint"